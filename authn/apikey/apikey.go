@@ -0,0 +1,191 @@
+// Package apikey implements the scoped and revocable API keys, aka personal access tokens,
+// for the machine clients.
+//
+// The key looks like "gst_<id>_<secret>", the id locates the model.APIKey and only the
+// sha256 hash of the secret is stored. The secret is random with 130 bits entropy and
+// can't be guessed from the hash, so the key is verified by the single sha256 on every
+// request rather than the slow password hash.
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+	"github.com/forbearing/gst/util"
+)
+
+// Prefix is the prefix of the API keys, it tells the API keys from the jwt tokens.
+const Prefix = consts.FrameworkName + "_"
+
+// lastUsedInterval throttles the updates of the last used time.
+const lastUsedInterval = time.Minute
+
+var (
+	ErrInvalidKey   = errors.New("invalid api key")
+	ErrKeyNotFound  = errors.New("api key not found")
+	ErrInvalidScope = errors.New("invalid api key scope")
+)
+
+// IsKey reports whether the token looks like an API key.
+func IsKey(token string) bool {
+	return strings.HasPrefix(token, Prefix)
+}
+
+// Create mints a new API key of the user, the key expires after ttl, zero ttl never expires.
+// The plaintext key is returned in model.APIKey.Key and is never stored.
+func Create(ctx *types.DatabaseContext, userID, username, name string, scopes []string, ttl time.Duration) (*model.APIKey, error) {
+	if len(userID) == 0 {
+		return nil, errors.New("user id is required")
+	}
+	for _, scope := range scopes {
+		if _, _, err := parseScope(scope); err != nil {
+			return nil, err
+		}
+	}
+	secret := rand.Text()
+	k := &model.APIKey{
+		UserID:   userID,
+		Username: username,
+		Name:     name,
+		Hash:     hash(secret),
+		Scopes:   scopes,
+	}
+	k.ID = util.IndexedUUID()
+	if ttl > 0 {
+		k.ExpiresAt = util.ValueOf(model.GormTime(time.Now().Add(ttl)))
+	}
+	if err := database.Database[*model.APIKey](ctx).Create(k); err != nil {
+		return nil, errors.Wrap(err, "failed to create api key")
+	}
+	k.Key = Prefix + k.ID + "_" + secret
+	return k, nil
+}
+
+// List returns the API keys of the user.
+func List(ctx *types.DatabaseContext, userID string) ([]*model.APIKey, error) {
+	keys := make([]*model.APIKey, 0)
+	if err := database.Database[*model.APIKey](ctx).WithLimit(-1).WithQuery(&model.APIKey{UserID: userID}).List(&keys); err != nil {
+		return nil, errors.Wrap(err, "failed to list api keys")
+	}
+	return keys, nil
+}
+
+// Revoke revokes the API key of the user, the revoked key is kept for audit.
+func Revoke(ctx *types.DatabaseContext, userID, id string) error {
+	k := new(model.APIKey)
+	if err := database.Database[*model.APIKey](ctx).Get(k, id); err != nil {
+		return errors.Wrap(err, "failed to get api key")
+	}
+	if len(k.ID) == 0 || k.UserID != userID {
+		return ErrKeyNotFound
+	}
+	if k.RevokedAt != nil {
+		return nil
+	}
+	k.RevokedAt = util.ValueOf(model.GormTime(time.Now()))
+	if err := database.Database[*model.APIKey](ctx).Update(k); err != nil {
+		return errors.Wrap(err, "failed to revoke api key")
+	}
+	return nil
+}
+
+// Authenticate returns the API key and its owner by the plaintext key.
+// The revoked and expired keys and the keys of the disabled users are rejected.
+func Authenticate(ctx *types.DatabaseContext, key, clientIP string) (*model.APIKey, *model.User, error) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(key, Prefix), "_")
+	if !IsKey(key) || !ok || len(id) == 0 || len(secret) == 0 {
+		return nil, nil, ErrInvalidKey
+	}
+	k := new(model.APIKey)
+	if err := database.Database[*model.APIKey](ctx).Get(k, id); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get api key")
+	}
+	if len(k.ID) == 0 || subtle.ConstantTimeCompare([]byte(k.Hash), []byte(hash(secret))) != 1 {
+		return nil, nil, ErrInvalidKey
+	}
+	if k.RevokedAt != nil {
+		return nil, nil, errors.Wrap(ErrInvalidKey, "api key revoked")
+	}
+	if k.ExpiresAt != nil && time.Now().After(time.Time(*k.ExpiresAt)) {
+		return nil, nil, errors.Wrap(ErrInvalidKey, "api key expired")
+	}
+	user := new(model.User)
+	if err := database.Database[*model.User](ctx).Get(user, k.UserID); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get user")
+	}
+	if len(user.ID) == 0 || user.Status == 0 {
+		return nil, nil, errors.Wrap(ErrInvalidKey, "api key owner not found or disabled")
+	}
+
+	if k.LastUsedAt == nil || time.Since(time.Time(*k.LastUsedAt)) > lastUsedInterval || k.LastUsedIP != clientIP {
+		k.LastUsedAt = util.ValueOf(model.GormTime(time.Now()))
+		k.LastUsedIP = clientIP
+		if err := database.Database[*model.APIKey](ctx).UpdateByID(k.ID, "last_used_at", k.LastUsedAt); err != nil {
+			return nil, nil, errors.Wrap(err, "failed to update api key")
+		}
+		if err := database.Database[*model.APIKey](ctx).UpdateByID(k.ID, "last_used_ip", k.LastUsedIP); err != nil {
+			return nil, nil, errors.Wrap(err, "failed to update api key")
+		}
+	}
+	return k, user, nil
+}
+
+// Allow reports whether the scopes of the API key allow the request.
+func Allow(k *model.APIKey, method, urlPath string) bool {
+	// The empty scopes are stored as the empty string.
+	scopes := slices.DeleteFunc(slices.Clone(k.Scopes), func(s string) bool { return len(strings.TrimSpace(s)) == 0 })
+	if len(scopes) == 0 {
+		return true
+	}
+	for _, scope := range scopes {
+		methods, pattern, err := parseScope(scope)
+		if err != nil {
+			continue
+		}
+		if !slices.Contains(methods, "*") && !slices.Contains(methods, strings.ToUpper(method)) {
+			continue
+		}
+		if pattern == "*" || pattern == urlPath {
+			return true
+		}
+		if matched, _ := path.Match(pattern, urlPath); matched {
+			return true
+		}
+		// "/api/user/*" also matches the nested paths.
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok && strings.HasPrefix(urlPath, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseScope parses the scope "<methods>:<path>".
+func parseScope(scope string) (methods []string, pattern string, err error) {
+	m, pattern, ok := strings.Cut(strings.TrimSpace(scope), ":")
+	if !ok || len(m) == 0 || len(pattern) == 0 || (pattern != "*" && !strings.HasPrefix(pattern, "/")) {
+		return nil, "", errors.Wrapf(ErrInvalidScope, "%q, expected \"<methods>:<path>\"", scope)
+	}
+	if _, err = path.Match(pattern, ""); err != nil {
+		return nil, "", errors.Wrapf(ErrInvalidScope, "%q: %v", scope, err)
+	}
+	for method := range strings.SplitSeq(m, "|") {
+		methods = append(methods, strings.ToUpper(strings.TrimSpace(method)))
+	}
+	return methods, pattern, nil
+}
+
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,97 @@
+package apikey_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/forbearing/gst/authn/apikey"
+	"github.com/forbearing/gst/bootstrap"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	os.Setenv(config.LOGGER_DIR, "/tmp/test_apikey")
+	os.Setenv(config.DATABASE_TYPE, string(config.DBSqlite))
+	os.Setenv(config.SQLITE_IS_MEMORY, "false")
+	os.Setenv(config.SQLITE_PATH, "/tmp/test_apikey.db")
+	_ = os.Remove("/tmp/test_apikey.db")
+
+	model.Register[*model.User]()
+
+	if err := bootstrap.Bootstrap(); err != nil {
+		panic(err)
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	ctx := &types.DatabaseContext{}
+	u := &model.User{Name: "robot"}
+	require.NoError(t, database.Database[*model.User](nil).Create(u))
+
+	_, err := apikey.Create(ctx, u.ID, u.Name, "bad", []string{"/api/user"}, 0)
+	require.ErrorIs(t, err, apikey.ErrInvalidScope)
+
+	k, err := apikey.Create(ctx, u.ID, u.Name, "ci", []string{"GET|HEAD:/api/user/*"}, 0)
+	require.NoError(t, err)
+	require.True(t, apikey.IsKey(k.Key))
+
+	// Only the hash is stored.
+	stored := new(model.APIKey)
+	require.NoError(t, database.Database[*model.APIKey](nil).Get(stored, k.ID))
+	assert.NotContains(t, stored.Hash, k.Key)
+	assert.Empty(t, stored.Key)
+
+	authed, owner, err := apikey.Authenticate(ctx, k.Key, "10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, k.ID, authed.ID)
+	assert.Equal(t, u.ID, owner.ID)
+	assert.Equal(t, "10.0.0.1", authed.LastUsedIP)
+	assert.NotNil(t, authed.LastUsedAt)
+
+	_, _, err = apikey.Authenticate(ctx, k.Key+"x", "10.0.0.1")
+	require.ErrorIs(t, err, apikey.ErrInvalidKey)
+
+	// The key of other user can't be revoked.
+	require.ErrorIs(t, apikey.Revoke(ctx, "other", k.ID), apikey.ErrKeyNotFound)
+	require.NoError(t, apikey.Revoke(ctx, u.ID, k.ID))
+	_, _, err = apikey.Authenticate(ctx, k.Key, "10.0.0.1")
+	require.ErrorIs(t, err, apikey.ErrInvalidKey)
+
+	expired, err := apikey.Create(ctx, u.ID, u.Name, "expired", nil, time.Millisecond)
+	require.NoError(t, err)
+	time.Sleep(time.Second)
+	_, _, err = apikey.Authenticate(ctx, expired.Key, "10.0.0.1")
+	require.ErrorIs(t, err, apikey.ErrInvalidKey)
+
+	keys, err := apikey.List(ctx, u.ID)
+	require.NoError(t, err)
+	assert.Len(t, keys, 2)
+}
+
+func TestAllow(t *testing.T) {
+	tests := []struct {
+		scopes []string
+		method string
+		path   string
+		allow  bool
+	}{
+		{nil, "DELETE", "/api/user/1", true},
+		{[]string{""}, "DELETE", "/api/user/1", true},
+		{[]string{"GET:/api/user/*"}, "GET", "/api/user/1", true},
+		{[]string{"GET:/api/user/*"}, "GET", "/api/user/1/avatar", true},
+		{[]string{"GET:/api/user/*"}, "POST", "/api/user/1", false},
+		{[]string{"GET:/api/user/*"}, "GET", "/api/order/1", false},
+		{[]string{"get|post:/api/order"}, "POST", "/api/order", true},
+		{[]string{"*:*"}, "PATCH", "/api/anything", true},
+		{[]string{"GET:/api/user/*", "*:/api/order/*"}, "DELETE", "/api/order/1", true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.allow, apikey.Allow(&model.APIKey{Scopes: tt.scopes}, tt.method, tt.path), "%v %s %s", tt.scopes, tt.method, tt.path)
+	}
+}
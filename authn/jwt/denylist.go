@@ -0,0 +1,119 @@
+package jwt
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/provider/redis"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+	"go.uber.org/zap"
+)
+
+// denylist is the "jti" of the revoked tokens, the entries expire with the tokens.
+// It's shared by all the nodes through redis if redis enabled, otherwise it's in-process.
+var denylist denylister = newLocalDenylist()
+
+const denylistPrefix = consts.FrameworkName + ":jwt:deny:"
+
+type denylister interface {
+	add(key string, ttl time.Duration) error
+	contains(key string) (bool, error)
+}
+
+func initDenylist() {
+	if config.App.Redis.Enable {
+		denylist = &redisDenylist{cache: redis.Cache[int64]()}
+	} else {
+		denylist = newLocalDenylist()
+	}
+}
+
+// IsRevoked reports whether the token of the claims is revoked by its "jti" claim.
+// It fails closed, the token is regarded as revoked if the denylist is unavailable.
+func IsRevoked(claims *Claims) bool {
+	if claims == nil {
+		return true
+	}
+	if len(claims.ID) == 0 {
+		return false
+	}
+	denied, err := denylist.contains(claims.ID)
+	if err != nil {
+		zap.S().Errorw("failed to check token denylist", "jti", claims.ID, "error", err)
+		return true
+	}
+	return denied
+}
+
+// RevokeToken denies the single token by its "jti" claim until the token expires.
+func RevokeToken(claims *Claims) error {
+	if claims == nil || len(claims.ID) == 0 {
+		return errors.New("token has no jti")
+	}
+	ttl := config.App.Auth.RefreshTokenExpireDuration
+	if claims.ExpiresAt != nil {
+		ttl = time.Until(claims.ExpiresAt.Time)
+	}
+	if ttl <= 0 {
+		return nil
+	}
+	if err := denylist.add(claims.ID, ttl); err != nil {
+		return errors.Wrap(err, "failed to add token denylist")
+	}
+	return nil
+}
+
+type localDenylist struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+	pruned  time.Time
+}
+
+func newLocalDenylist() *localDenylist {
+	return &localDenylist{entries: make(map[string]time.Time)}
+}
+
+func (d *localDenylist) add(key string, ttl time.Duration) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	d.entries[key] = now.Add(ttl)
+	// Prune the expired entries at most once a minute.
+	if now.Sub(d.pruned) > time.Minute {
+		for k, expire := range d.entries {
+			if now.After(expire) {
+				delete(d.entries, k)
+			}
+		}
+		d.pruned = now
+	}
+	return nil
+}
+
+func (d *localDenylist) contains(key string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	expire, found := d.entries[key]
+	return found && time.Now().Before(expire), nil
+}
+
+type redisDenylist struct {
+	cache types.Cache[int64]
+}
+
+func (d *redisDenylist) add(key string, ttl time.Duration) error {
+	return d.cache.Set(denylistPrefix+key, time.Now().Unix(), ttl)
+}
+
+func (d *redisDenylist) contains(key string) (bool, error) {
+	if _, err := d.cache.Get(denylistPrefix + key); err != nil {
+		if errors.Is(err, types.ErrEntryNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/forbearing/gst/database"
 	"github.com/forbearing/gst/model"
 	"github.com/forbearing/gst/types/consts"
+	"github.com/forbearing/gst/util"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/hashicorp/golang-lru/v2/expirable"
 	"github.com/mssola/useragent"
@@ -18,6 +19,9 @@ import (
 const (
 	MinUserIDLength   = 1
 	MinUsernameLength = 3
+
+	// TokenTypeMFA is the "typ" claim of the "MFA pending" token, see GenMFAToken.
+	TokenTypeMFA = "mfa"
 )
 
 var (
@@ -27,24 +31,24 @@ var (
 	ErrTokenExpired        = errors.New("token expired")
 	ErrTokenMalformed      = errors.New("token malformed")
 	ErrTokenNotValidYet    = errors.New("token not valid yet")
+	ErrTokenRevoked        = errors.New("token revoked")
 )
 
-var (
-	secret = []byte("defaultSecret")
-	issuer = consts.FrameworkName
-)
+var issuer = consts.FrameworkName
 
 var sessionCache *expirable.LRU[string, *model.Session]
 
 type Claims struct {
 	UserID            string `json:"user_id,omitempty"`
 	Username          string `json:"username,omitempty"`
+	TenantID          string `json:"tenant_id,omitempty"`
 	PreferredUsername string `json:"preferred_username,omitempty"`
 	GivenName         string `json:"given_name,omitempty"`
 	FamilyName        string `json:"family_name,omitempty"`
 	Email             string `json:"email,omitempty"`
 	EmailVerified     bool   `json:"email_verified,omitempty"`
 	Scope             string `json:"scope,omitempty"`
+	Nonce             string `json:"nonce,omitempty"`
 
 	// Standard Claims
 	AuthTime *jwt.NumericDate `json:"auth_time"` // The time at which the JWT was issued.
@@ -58,6 +62,10 @@ type Claims struct {
 }
 
 func Init() error {
+	if err := initKeys(); err != nil {
+		return err
+	}
+	initDenylist()
 	sessionCache = expirable.NewLRU(0, func(_ string, s *model.Session) {
 		_ = database.Database[*model.Session](nil).WithPurge().Delete(s)
 	}, config.App.Auth.RefreshTokenExpireDuration)
@@ -66,13 +74,15 @@ func Init() error {
 		return errors.Wrap(err, "failed to list sessions")
 	}
 	for _, session := range sessions {
-		setSession(session.UserID, session)
+		sessionCache.Add(session.ID, session)
 	}
 
 	return nil
 }
 
 // GenTokens 生成 access token 和 refresh token
+// The tenant id of session is carried by the access token, see types.TenantScoped.
+// The tokens carry the session id as "sid" claim, which is revoked by RevokeSession.
 func GenTokens(userID string, username string, session *model.Session) (aToken, rToken string, err error) {
 	if len(userID) < MinUserIDLength || len(username) < MinUsernameLength {
 		return "", "", errors.New("invalid user id or username")
@@ -81,36 +91,41 @@ func GenTokens(userID string, username string, session *model.Session) (aToken,
 	if username == config.App.Auth.NoneExpireUsername {
 		return config.App.Auth.NoneExpireToken, "", nil
 	}
-	if aToken, err = genAccessToken(userID, username); err != nil {
+	if session == nil {
+		session = new(model.Session)
+	}
+	session.UserID = userID
+	session.Username = username
+	session.ID = session.DeviceID()
+	if aToken, err = genAccessToken(userID, username, session.TenantID, session.ID); err != nil {
 		return "", "", err
 	}
-	if rToken, err = genRefreshToken(userID); err != nil {
+	if rToken, err = genRefreshToken(userID, session.ID); err != nil {
 		return "", "", err
 	}
 
-	if session == nil {
-		session = new(model.Session)
-	}
 	session.AccessToken = aToken
 	session.RefreshToken = rToken
-	session.UserID = userID
-	session.Username = username
 	// setToken(aToken, rToken, session)
-	setSession(userID, session)
+	setSession(session)
 
 	return aToken, rToken, nil
 }
 
-func RevokeTokens(userID string) {
-	removeSession(userID)
+// RevokeTokens logs out all the sessions of the user on all the nodes.
+func RevokeTokens(userID string) error {
+	return removeSession(userID)
 }
 
-func genAccessToken(userID string, username string) (token string, err error) {
+func genAccessToken(userID string, username string, tenantID string, sessionID string) (token string, err error) {
 	now := time.Now()
 	claims := Claims{
 		UserID:   userID,
 		Username: username,
+		TenantID: tenantID,
+		Sid:      sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        util.UUID(),
 			ExpiresAt: jwt.NewNumericDate(now.Add(config.App.Auth.AccessTokenExpireDuration)), // 过期时间
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
@@ -118,25 +133,28 @@ func genAccessToken(userID string, username string) (token string, err error) {
 			Subject:   userID,
 		},
 	}
-	// NewWithClaims 使用指定的签名方法创建签名对象
-	// SignedString 使用指定的 secret 签名并获得完整的编码后的字符串 token
-	if token, err = jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret); err != nil {
+	// 使用当前的签名密钥签名并获得完整的编码后的字符串 token, header 中的 kid 标识签名密钥
+	if token, err = keys.sign(claims); err != nil {
 		return "", errors.Wrap(err, "failed to generate access token")
 	}
 	return token, nil
 }
 
-func genRefreshToken(userID string) (rToken string, err error) {
+func genRefreshToken(userID string, sessionID string) (rToken string, err error) {
 	now := time.Now()
-	// refresh token 不需要任何自定义数据
-	// 使用指定的 secret 签名并获得完整的编码后的字符串 token
-	if rToken, err = jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		ExpiresAt: jwt.NewNumericDate(now.Add(config.App.Auth.RefreshTokenExpireDuration)), // 过期时间
-		IssuedAt:  jwt.NewNumericDate(now),                                                 // 签发时间
-		NotBefore: jwt.NewNumericDate(now),                                                 // 生效时间
-		Issuer:    issuer,                                                                  // 签发人
-		Subject:   userID,
-	}).SignedString(secret); err != nil {
+	// refresh token 只需要 session id, 用于吊销 session
+	// 使用当前的签名密钥签名并获得完整的编码后的字符串 token
+	if rToken, err = keys.sign(Claims{
+		Sid: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        util.UUID(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(config.App.Auth.RefreshTokenExpireDuration)), // 过期时间
+			IssuedAt:  jwt.NewNumericDate(now),                                                 // 签发时间
+			NotBefore: jwt.NewNumericDate(now),                                                 // 生效时间
+			Issuer:    issuer,                                                                  // 签发人
+			Subject:   userID,
+		},
+	}); err != nil {
 		return "", errors.Wrap(err, "failed to generate refresh token")
 	}
 	return rToken, nil
@@ -150,12 +168,15 @@ func RefreshTokens(accessToken, refreshToken string, session *model.Session) (ne
 	if token, err = jwt.ParseWithClaims(refreshToken, refreshClaims, keyFunc); err != nil {
 		return "", "", errors.Wrap(err, ErrInvalidRefreshToken.Error())
 	}
-	if !token.Valid {
+	if !token.Valid || refreshClaims.Typ == TokenTypeMFA {
 		return "", "", ErrInvalidRefreshToken
 	}
 	if time.Now().After(refreshClaims.ExpiresAt.Time) {
 		return "", "", ErrTokenExpired
 	}
+	if IsRevoked(refreshClaims) {
+		return "", "", ErrTokenRevoked
+	}
 
 	// verify access token
 	accessClaims := new(Claims)
@@ -166,11 +187,28 @@ func RefreshTokens(accessToken, refreshToken string, session *model.Session) (ne
 	} else if !token.Valid {
 		return "", "", ErrInvalidAccessToken
 	}
+	if accessClaims.Typ == TokenTypeMFA {
+		return "", "", ErrInvalidAccessToken
+	}
 	// verify whether subject is the same
 	if refreshClaims.Subject != accessClaims.Subject {
 		return "", "", ErrTokenMalformed
 	}
 
+	// The refresh token is used only once, the replaced access token is revoked too.
+	if err = RevokeToken(refreshClaims); err != nil {
+		return "", "", err
+	}
+	if len(accessClaims.ID) > 0 {
+		if err = RevokeToken(accessClaims); err != nil {
+			return "", "", err
+		}
+	}
+
+	if session == nil {
+		session = new(model.Session)
+	}
+	session.TenantID = accessClaims.TenantID
 	return GenTokens(accessClaims.UserID, accessClaims.Username, session)
 }
 
@@ -209,6 +247,49 @@ func ParseToken(tokenStr string) (*Claims, error) {
 	if claims.Issuer != issuer {
 		return nil, errors.New("invalid token issuer")
 	}
+	// The "MFA pending" token never authenticates the requests.
+	if claims.Typ == TokenTypeMFA {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// GenMFAToken generates the short-lived "MFA pending" token, which is issued by the
+// first login step and exchanged for the real tokens once the MFA is verified.
+func GenMFAToken(userID, username, tenantID string) (string, error) {
+	now := time.Now()
+	token, err := keys.sign(Claims{
+		UserID:   userID,
+		Username: username,
+		TenantID: tenantID,
+		Typ:      TokenTypeMFA,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(config.App.Auth.MFATokenExpireDuration)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    issuer,
+			Subject:   userID,
+		},
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to generate mfa token")
+	}
+	return token, nil
+}
+
+// ParseMFAToken parses the "MFA pending" token generated by GenMFAToken.
+func ParseMFAToken(tokenStr string) (*Claims, error) {
+	claims := new(Claims)
+	token, err := jwt.ParseWithClaims(tokenStr, claims, keyFunc)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrTokenExpired
+		}
+		return nil, errors.Wrap(err, "failed to parse mfa token")
+	}
+	if !token.Valid || claims.Issuer != issuer || claims.Typ != TokenTypeMFA {
+		return nil, ErrInvalidToken
+	}
 	return claims, nil
 }
 
@@ -220,12 +301,18 @@ func Verify(claims *Claims, accessToken, userAgent string) error {
 		return nil
 	}
 
-	session, found := GetSession(claims.UserID)
-	if !found {
+	if IsRevoked(claims) {
+		return ErrTokenRevoked
+	}
+	session, found := GetSession(claims.Sid)
+	if !found || session.UserID != claims.UserID {
 		return errors.New("session not found")
 	}
 	if session.AccessToken != accessToken {
-		return errors.New("access token not match")
+		// The tokens may be refreshed by other nodes.
+		if session, found = loadSession(claims.Sid); !found || session.AccessToken != accessToken {
+			return errors.New("access token not match")
+		}
 	}
 
 	ua := useragent.New(userAgent)
@@ -262,4 +349,3 @@ func ParseTokenFromHeader(header http.Header) (token string, claims *Claims, err
 	claims, err = ParseToken(items[1])
 	return token, claims, err
 }
-func keyFunc(token *jwt.Token) (any, error) { return secret, nil }
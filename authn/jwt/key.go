@@ -0,0 +1,388 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/util"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	keyFileExt  = ".pem"
+	rsaKeyBits  = 2048
+	reloadDelay = 10 * time.Second
+)
+
+// keyMaintainPeriod is the period to reload the key directory and rotate the signing key.
+var keyMaintainPeriod = 1 * time.Minute
+
+// keys is the signing keys, it defaults to HS256 with the default secret until Init.
+var keys = newHMACKeyring([]byte("defaultSecret"))
+
+// signingKey is a jwt signing key identified by kid.
+type signingKey struct {
+	kid       string
+	secret    []byte        // HMAC secret
+	private   crypto.Signer // RSA, ECDSA or Ed25519 private key
+	createdAt time.Time
+	retiredAt time.Time // zero if the key is the active signing key
+}
+
+func (k *signingKey) signKey() any {
+	if k.secret != nil {
+		return k.secret
+	}
+	return k.private
+}
+
+func (k *signingKey) verifyKey() any {
+	if k.secret != nil {
+		return k.secret
+	}
+	return k.private.Public()
+}
+
+// expired reports whether the retired key is out of the grace period.
+func (k *signingKey) expired(now time.Time, grace time.Duration) bool {
+	return !k.retiredAt.IsZero() && now.After(k.retiredAt.Add(grace))
+}
+
+// keyring holds the active signing key and the retired keys still verifying tokens.
+type keyring struct {
+	mu       sync.RWMutex
+	method   jwt.SigningMethod
+	dir      string
+	interval time.Duration
+	grace    time.Duration
+	active   *signingKey
+	keys     map[string]*signingKey
+	reloaded time.Time
+	stop     chan struct{}
+}
+
+func newHMACKeyring(secret []byte) *keyring {
+	key := &signingKey{secret: secret, createdAt: time.Now()}
+	return &keyring{
+		method: jwt.SigningMethodHS256,
+		active: key,
+		keys:   map[string]*signingKey{"": key},
+	}
+}
+
+// initKeys creates the keyring by config and starts the key rotation.
+func initKeys() error {
+	cfg := config.App.Auth
+	method := jwt.GetSigningMethod(cfg.SigningMethod)
+	if method == nil {
+		return errors.Newf("unsupported jwt signing method %q", cfg.SigningMethod)
+	}
+
+	var kr *keyring
+	switch method.(type) {
+	case *jwt.SigningMethodHMAC:
+		kr = newHMACKeyring([]byte(cfg.SigningSecret))
+		kr.method = method
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA, *jwt.SigningMethodEd25519:
+		// The key generated by each replica is unknown to the others, they reject its tokens.
+		if len(cfg.SigningKeyDir) == 0 {
+			return errors.Newf("jwt signing method %q requires config \"auth.signing_key_dir\"", cfg.SigningMethod)
+		}
+		kr = &keyring{
+			method:   method,
+			dir:      cfg.SigningKeyDir,
+			interval: cfg.KeyRotationInterval,
+			grace:    cfg.KeyGracePeriod,
+			keys:     make(map[string]*signingKey),
+		}
+		if err := kr.reload(); err != nil {
+			return err
+		}
+		if kr.active == nil {
+			if err := kr.rotate(); err != nil {
+				return err
+			}
+		}
+		kr.stop = make(chan struct{})
+		go kr.maintain()
+	default:
+		return errors.Newf("unsupported jwt signing method %q", cfg.SigningMethod)
+	}
+
+	if old := keys; old.stop != nil {
+		close(old.stop)
+	}
+	keys = kr
+	return nil
+}
+
+// maintain reloads the key directory, rotates the signing key and purges the expired keys periodically.
+func (kr *keyring) maintain() {
+	ticker := time.NewTicker(keyMaintainPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-kr.stop:
+			return
+		case <-ticker.C:
+			if err := kr.reload(); err != nil {
+				zap.S().Errorw("failed to reload jwt signing keys", "error", err)
+			}
+			kr.mu.RLock()
+			due := kr.interval > 0 && kr.active != nil && time.Since(kr.active.createdAt) >= kr.interval
+			kr.mu.RUnlock()
+			if due {
+				if err := kr.rotate(); err != nil {
+					zap.S().Errorw("failed to rotate jwt signing key", "error", err)
+				}
+			}
+			kr.purge()
+		}
+	}
+}
+
+// reload loads the keys from the key directory, the newest key is the active signing key,
+// the older keys are retired when their successors are created.
+// The key files out of the grace period are removed if the rotation is enabled.
+func (kr *keyring) reload() error {
+	if len(kr.dir) == 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(kr.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "failed to read jwt signing key directory %s", kr.dir)
+	}
+
+	loaded := make([]*signingKey, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != keyFileExt {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return errors.Wrapf(err, "failed to stat jwt signing key %s", entry.Name())
+		}
+		filename := filepath.Join(kr.dir, entry.Name())
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read jwt signing key %s", filename)
+		}
+		private, err := parsePrivateKey(data)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse jwt signing key %s", filename)
+		}
+		if !matchMethod(kr.method, private) {
+			return errors.Newf("jwt signing key %s doesn't match the signing method %s", filename, kr.method.Alg())
+		}
+		loaded = append(loaded, &signingKey{
+			kid:       strings.TrimSuffix(entry.Name(), keyFileExt),
+			private:   private,
+			createdAt: info.ModTime(),
+		})
+	}
+	slices.SortFunc(loaded, func(a, b *signingKey) int {
+		if c := a.createdAt.Compare(b.createdAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.kid, b.kid)
+	})
+	for i := range len(loaded) - 1 {
+		loaded[i].retiredAt = loaded[i+1].createdAt
+	}
+
+	now := time.Now()
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	kr.reloaded = now
+	if len(loaded) == 0 {
+		return nil
+	}
+	kr.keys = make(map[string]*signingKey, len(loaded))
+	for _, key := range loaded {
+		if key.expired(now, kr.grace) {
+			if kr.interval > 0 {
+				if err := os.Remove(filepath.Join(kr.dir, key.kid+keyFileExt)); err != nil && !os.IsNotExist(err) {
+					zap.S().Warnw("failed to remove expired jwt signing key", "kid", key.kid, "error", err)
+				}
+			}
+			continue
+		}
+		kr.keys[key.kid] = key
+	}
+	kr.active = loaded[len(loaded)-1]
+	return nil
+}
+
+// rotate generates a new signing key, the previous one is retired and keeps
+// verifying the tokens within the grace period.
+func (kr *keyring) rotate() error {
+	private, err := generateKey(kr.method)
+	if err != nil {
+		return errors.Wrap(err, "failed to generate jwt signing key")
+	}
+	key := &signingKey{kid: util.IndexedUUID(), private: private, createdAt: time.Now()}
+
+	if len(kr.dir) > 0 {
+		der, err := x509.MarshalPKCS8PrivateKey(private)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal jwt signing key")
+		}
+		if err = os.MkdirAll(kr.dir, 0o700); err != nil {
+			return errors.Wrapf(err, "failed to create jwt signing key directory %s", kr.dir)
+		}
+		filename := filepath.Join(kr.dir, key.kid+keyFileExt)
+		if err = os.WriteFile(filename, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+			return errors.Wrapf(err, "failed to write jwt signing key %s", filename)
+		}
+	}
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	if kr.active != nil {
+		kr.active.retiredAt = key.createdAt
+	}
+	kr.active = key
+	kr.keys[key.kid] = key
+	zap.S().Infow("jwt signing key rotated", "kid", key.kid, "method", kr.method.Alg())
+	return nil
+}
+
+// purge removes the retired keys out of the grace period.
+func (kr *keyring) purge() {
+	now := time.Now()
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	for kid, key := range kr.keys {
+		if key.expired(now, kr.grace) {
+			delete(kr.keys, kid)
+		}
+	}
+}
+
+// sign signs the claims with the active key, the key id is set to the "kid" header.
+func (kr *keyring) sign(claims jwt.Claims) (string, error) {
+	kr.mu.RLock()
+	key := kr.active
+	kr.mu.RUnlock()
+
+	token := jwt.NewWithClaims(kr.method, claims)
+	if len(key.kid) > 0 {
+		token.Header["kid"] = key.kid
+	}
+	return token.SignedString(key.signKey())
+}
+
+// lookup returns the key by kid, the key directory is reloaded for the unknown kid,
+// the key may be just rotated by other replicas sharing the directory.
+func (kr *keyring) lookup(kid string) (*signingKey, bool) {
+	kr.mu.RLock()
+	key, ok := kr.keys[kid]
+	reloaded := kr.reloaded
+	kr.mu.RUnlock()
+	if ok || len(kr.dir) == 0 || time.Since(reloaded) < reloadDelay {
+		return key, ok
+	}
+	if err := kr.reload(); err != nil {
+		zap.S().Warnw("failed to reload jwt signing keys", "error", err)
+	}
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	key, ok = kr.keys[kid]
+	return key, ok
+}
+
+// keyFunc returns the verification key of the token by its "kid" header.
+func keyFunc(token *jwt.Token) (any, error) {
+	kr := keys
+	if token.Method.Alg() != kr.method.Alg() {
+		return nil, errors.Newf("unexpected signing method %s", token.Method.Alg())
+	}
+	kid, _ := token.Header["kid"].(string)
+	key, ok := kr.lookup(kid)
+	if !ok || key.expired(time.Now(), kr.grace) {
+		return nil, errors.Newf("unknown signing key %q", kid)
+	}
+	return key.verifyKey(), nil
+}
+
+// parsePrivateKey parses the PEM encoded PKCS#8, PKCS#1 or SEC 1 private key.
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	var key any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.Newf("unsupported private key type %T", key)
+	}
+	return signer, nil
+}
+
+func generateKey(method jwt.SigningMethod) (crypto.Signer, error) {
+	switch method.(type) {
+	case *jwt.SigningMethodRSA:
+		return rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case *jwt.SigningMethodECDSA:
+		return ecdsa.GenerateKey(curveOf(method), rand.Reader)
+	case *jwt.SigningMethodEd25519:
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		return private, err
+	}
+	return nil, errors.Newf("unsupported signing method %s", method.Alg())
+}
+
+func matchMethod(method jwt.SigningMethod, key crypto.Signer) bool {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		_, ok := method.(*jwt.SigningMethodRSA)
+		return ok
+	case *ecdsa.PrivateKey:
+		_, ok := method.(*jwt.SigningMethodECDSA)
+		return ok && k.Curve == curveOf(method)
+	case ed25519.PrivateKey:
+		_, ok := method.(*jwt.SigningMethodEd25519)
+		return ok
+	}
+	return false
+}
+
+func curveOf(method jwt.SigningMethod) elliptic.Curve {
+	switch method.Alg() {
+	case jwt.SigningMethodES384.Alg():
+		return elliptic.P384()
+	case jwt.SigningMethodES512.Alg():
+		return elliptic.P521()
+	}
+	return elliptic.P256()
+}
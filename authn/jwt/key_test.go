@@ -0,0 +1,108 @@
+package jwt
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/forbearing/gst/config"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyRotation(t *testing.T) {
+	// The asymmetric keys must be shared by the replicas.
+	config.App.Auth.SigningMethod = "RS256"
+	config.App.Auth.SigningKeyDir = ""
+	require.Error(t, initKeys())
+
+	for _, method := range []string{"RS256", "ES256", "EdDSA"} {
+		t.Run(method, func(t *testing.T) {
+			dir := t.TempDir()
+			config.App.Auth.SigningMethod = method
+			config.App.Auth.SigningKeyDir = dir
+			config.App.Auth.KeyRotationInterval = time.Hour
+			config.App.Auth.KeyGracePeriod = time.Hour
+			config.App.Auth.AccessTokenExpireDuration = time.Hour
+			require.NoError(t, initKeys())
+			defer func() { keys = newHMACKeyring([]byte("defaultSecret")) }()
+
+			oldToken, err := genAccessToken("user1", "user1", "", "")
+			require.NoError(t, err)
+			claims, err := ParseToken(oldToken)
+			require.NoError(t, err)
+			assert.Equal(t, "user1", claims.UserID)
+
+			// The rotated key keeps verifying the tokens within the grace period.
+			oldKid := keys.active.kid
+			require.NoError(t, keys.rotate())
+			newToken, err := genAccessToken("user2", "user2", "", "")
+			require.NoError(t, err)
+			token, err := jwt.ParseWithClaims(newToken, new(Claims), keyFunc)
+			require.NoError(t, err)
+			assert.NotEqual(t, oldKid, token.Header["kid"])
+			_, err = ParseToken(oldToken)
+			require.NoError(t, err)
+
+			jwks := JWKS()
+			require.Len(t, jwks.Keys, 2)
+			for _, k := range jwks.Keys {
+				assert.Equal(t, method, k.Alg)
+				assert.Equal(t, "sig", k.Use)
+				// Other services verify the tokens offline by the published keys.
+				if k.Kid == token.Header["kid"] {
+					pub, err := k.PublicKey()
+					require.NoError(t, err)
+					_, err = jwt.ParseWithClaims(newToken, new(Claims), func(*jwt.Token) (any, error) { return pub, nil })
+					require.NoError(t, err)
+				}
+			}
+			files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+			require.NoError(t, err)
+			assert.Len(t, files, 2)
+
+			// The keys are loaded from the directory after restart.
+			require.NoError(t, initKeys())
+			_, err = ParseToken(oldToken)
+			require.NoError(t, err)
+			_, err = ParseToken(newToken)
+			require.NoError(t, err)
+
+			// The retired key out of the grace period is purged.
+			keys.grace = 0
+			keys.purge()
+			_, err = ParseToken(oldToken)
+			require.Error(t, err)
+			_, err = ParseToken(newToken)
+			require.NoError(t, err)
+			assert.Len(t, JWKS().Keys, 1)
+
+			// The HS256 token is rejected by the asymmetric keys.
+			hs, err := newHMACKeyring([]byte("defaultSecret")).sign(jwt.RegisteredClaims{Issuer: issuer})
+			require.NoError(t, err)
+			_, err = ParseToken(hs)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestLoadKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	kr := &keyring{method: jwt.SigningMethodRS256, dir: dir, keys: make(map[string]*signingKey)}
+	require.NoError(t, kr.rotate())
+	kid := kr.active.kid
+
+	loaded := &keyring{method: jwt.SigningMethodRS256, dir: dir, keys: make(map[string]*signingKey)}
+	require.NoError(t, loaded.reload())
+	require.NotNil(t, loaded.active)
+	assert.Equal(t, kid, loaded.active.kid)
+
+	// The key mismatching the signing method is rejected.
+	mismatch := &keyring{method: jwt.SigningMethodES256, dir: dir, keys: make(map[string]*signingKey)}
+	require.Error(t, mismatch.reload())
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "invalid.pem"), []byte("invalid"), 0o600))
+	require.Error(t, loaded.reload())
+}
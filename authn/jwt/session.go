@@ -1,8 +1,12 @@
 package jwt
 
 import (
+	"slices"
+
+	"github.com/cockroachdb/errors"
 	"github.com/forbearing/gst/database"
 	"github.com/forbearing/gst/model"
+	"github.com/golang-jwt/jwt/v5"
 )
 
 // func setToken(accessToken, refreshToken string, s *model.Session) {
@@ -39,24 +43,90 @@ import (
 // 	return refreshTokenCache.Get(refreshToken)
 // }
 
-func setSession(userID string, s *model.Session) {
-	if len(userID) == 0 || s == nil {
+func setSession(s *model.Session) {
+	if s == nil || len(s.UserID) == 0 {
 		return
 	}
 	_ = database.Database[*model.Session](nil).Update(s)
 	// sessionCache.Add 必须在 database.Update 之后, 因为它的ID会在 database.Database 之后生成
-	sessionCache.Add(userID, s)
+	sessionCache.Add(s.ID, s)
 }
 
-func GetSession(userID string) (*model.Session, bool) {
-	// TODO: database
-	return sessionCache.Get(userID)
+// GetSession returns the session by id, the session created by other nodes is loaded from database.
+func GetSession(sessionID string) (*model.Session, bool) {
+	if s, found := sessionCache.Get(sessionID); found {
+		return s, true
+	}
+	return loadSession(sessionID)
 }
 
-func removeSession(userID string) {
-	sessionCache.Remove(userID)
+// ListSessions returns the sessions of the user.
+func ListSessions(userID string) ([]*model.Session, error) {
 	sessions := make([]*model.Session, 0)
-	if err := database.Database[*model.Session](nil).WithLimit(-1).WithSelect("id").WithQuery(&model.Session{UserID: userID}).List(&sessions); err == nil {
-		_ = database.Database[*model.Session](nil).WithPurge().Delete(sessions...)
+	if err := database.Database[*model.Session](nil).WithLimit(-1).WithQuery(&model.Session{UserID: userID}).List(&sessions); err != nil {
+		return nil, errors.Wrap(err, "failed to list sessions")
+	}
+	return sessions, nil
+}
+
+// RevokeSession logs out the session on all the nodes, the current tokens of the session
+// are denied until they expire. The next login of the device creates the session again.
+func RevokeSession(sessionID string) error {
+	if len(sessionID) == 0 {
+		return errors.New("session id is required")
+	}
+	s, found := GetSession(sessionID)
+	if !found {
+		return nil
+	}
+	for _, token := range []string{s.AccessToken, s.RefreshToken} {
+		if len(token) == 0 {
+			continue
+		}
+		// The token is issued by us and stored in database, no need to verify it again.
+		claims := new(Claims)
+		if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+			return errors.Wrap(err, "failed to parse session token")
+		}
+		if err := RevokeToken(claims); err != nil {
+			return err
+		}
+	}
+	sessionCache.Remove(sessionID)
+	if err := database.Database[*model.Session](nil).WithPurge().Delete(s); err != nil {
+		return errors.Wrap(err, "failed to delete session")
+	}
+	return nil
+}
+
+func removeSession(userID string) error {
+	sessions, err := ListSessions(userID)
+	if err != nil {
+		return err
+	}
+	// The sessions only in cache, eg: failed to save to database.
+	for _, s := range sessionCache.Values() {
+		if s.UserID == userID && !slices.ContainsFunc(sessions, func(e *model.Session) bool { return e.ID == s.ID }) {
+			sessions = append(sessions, s)
+		}
+	}
+	var errs []error
+	for _, s := range sessions {
+		if err = RevokeSession(s.ID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func loadSession(sessionID string) (*model.Session, bool) {
+	if len(sessionID) == 0 {
+		return nil, false
+	}
+	s := new(model.Session)
+	if err := database.Database[*model.Session](nil).Get(s, sessionID); err != nil || len(s.ID) == 0 {
+		return nil, false
 	}
+	sessionCache.Add(s.ID, s)
+	return s, true
 }
@@ -0,0 +1,86 @@
+package jwt_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/forbearing/gst/authn/jwt"
+	"github.com/forbearing/gst/bootstrap"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/model"
+	"github.com/mssola/useragent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	os.Setenv(config.LOGGER_DIR, "/tmp/test_jwt")
+	os.Setenv(config.DATABASE_TYPE, string(config.DBSqlite))
+	os.Setenv(config.SQLITE_IS_MEMORY, "false")
+	os.Setenv(config.SQLITE_PATH, "/tmp/test_jwt.db")
+	_ = os.Remove("/tmp/test_jwt.db")
+
+	if err := bootstrap.Bootstrap(); err != nil {
+		panic(err)
+	}
+}
+
+const (
+	linuxUA = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	macUA   = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/605.1.15"
+)
+
+func newSession(userAgent string) *model.Session {
+	ua := useragent.New(userAgent)
+	engineName, _ := ua.Engine()
+	browserName, _ := ua.Browser()
+	return &model.Session{Platform: ua.Platform(), OS: ua.OS(), EngineName: engineName, BrowserName: browserName}
+}
+
+func TestSessions(t *testing.T) {
+	const userID = "session-user"
+	login := func(userAgent string) (string, string, *jwt.Claims) {
+		aToken, rToken, err := jwt.GenTokens(userID, "alice", newSession(userAgent))
+		require.NoError(t, err)
+		claims, err := jwt.ParseToken(aToken)
+		require.NoError(t, err)
+		require.NotEmpty(t, claims.Sid)
+		require.NotEmpty(t, claims.ID)
+		require.NoError(t, jwt.Verify(claims, aToken, userAgent))
+		return aToken, rToken, claims
+	}
+
+	// Every device has its own session.
+	linuxToken, linuxRefresh, linux := login(linuxUA)
+	macToken, _, mac := login(macUA)
+	assert.NotEqual(t, linux.Sid, mac.Sid)
+	sessions, err := jwt.ListSessions(userID)
+	require.NoError(t, err)
+	assert.Len(t, sessions, 2)
+
+	// The refresh token is used only once.
+	newToken, _, err := jwt.RefreshTokens(linuxToken, linuxRefresh, newSession(linuxUA))
+	require.NoError(t, err)
+	_, _, err = jwt.RefreshTokens(linuxToken, linuxRefresh, newSession(linuxUA))
+	require.ErrorIs(t, err, jwt.ErrTokenRevoked)
+	require.Error(t, jwt.Verify(linux, linuxToken, linuxUA))
+	linux, err = jwt.ParseToken(newToken)
+	require.NoError(t, err)
+	require.NoError(t, jwt.Verify(linux, newToken, linuxUA))
+
+	// Log out one device.
+	require.NoError(t, jwt.RevokeSession(linux.Sid))
+	require.ErrorIs(t, jwt.Verify(linux, newToken, linuxUA), jwt.ErrTokenRevoked)
+	require.NoError(t, jwt.Verify(mac, macToken, macUA))
+	sessions, err = jwt.ListSessions(userID)
+	require.NoError(t, err)
+	assert.Len(t, sessions, 1)
+
+	// Log out all devices.
+	require.NoError(t, jwt.RevokeTokens(userID))
+	require.ErrorIs(t, jwt.Verify(mac, macToken, macUA), jwt.ErrTokenRevoked)
+	assert.True(t, jwt.IsRevoked(mac))
+
+	// The revoked device logs in again.
+	login(macUA)
+}
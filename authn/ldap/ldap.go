@@ -0,0 +1,278 @@
+// Package ldap authenticates the users by LDAP and synchronizes the LDAP group
+// memberships to the RBAC roles.
+//
+// The LDAP users are linked to model.User by model.UserIdentity with provider "ldap",
+// the subject is the LDAP username.
+//
+// The roles mapped from the LDAP groups by config "ldap.group_roles" are owned by LDAP:
+// they're assigned and unassigned by the group memberships at the login and by the
+// periodic reconciliation, the other roles of the users are never touched.
+package ldap
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/authz/rbac"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/cronjob"
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/model"
+	providerldap "github.com/forbearing/gst/provider/ldap"
+	"github.com/forbearing/gst/types"
+	"github.com/go-ldap/ldap/v3"
+	"go.uber.org/zap"
+)
+
+// Provider is the provider name of the LDAP users in model.UserIdentity.
+const Provider = "ldap"
+
+var (
+	ErrInvalidCredentials = errors.New("invalid ldap username or password")
+	ErrUserNotLinked      = errors.New("no user linked to the ldap user")
+)
+
+// Directory looks up the LDAP users and groups, it's the connection of package provider/ldap
+// by default. The missing user is reported by providerldap.ErrUserNotFound.
+type Directory interface {
+	// Connected reports whether the directory is reachable.
+	Connected() bool
+	Authenticate(username, password string) (bool, error)
+	GetUser(username string, attributes []string) (*ldap.Entry, error)
+	GetUserGroups(username string) ([]string, error)
+}
+
+var directory Directory = providerDirectory{}
+
+// RegisterDirectory registers the custom directory, it overrides the connection of package provider/ldap.
+func RegisterDirectory(d Directory) { directory = d }
+
+type providerDirectory struct{}
+
+func (providerDirectory) Connected() bool { return providerldap.Conn() != nil }
+
+func (providerDirectory) Authenticate(username, password string) (bool, error) {
+	return providerldap.Authenticate(username, password)
+}
+
+func (providerDirectory) GetUser(username string, attributes []string) (*ldap.Entry, error) {
+	return providerldap.GetUser(username, attributes)
+}
+
+func (providerDirectory) GetUserGroups(username string) ([]string, error) {
+	return providerldap.GetUserGroups(username)
+}
+
+// Init registers the cronjob reconciling the LDAP group memberships to the RBAC roles.
+func Init() error {
+	cfg := config.App.Ldap
+	if !cfg.Enable || !cfg.Login {
+		return nil
+	}
+	if _, err := groupRoles(cfg.GroupRoles); err != nil {
+		return err
+	}
+	if len(cfg.ReconcileSpec) > 0 && len(cfg.GroupRoles) > 0 {
+		cronjob.Register(Reconcile, cfg.ReconcileSpec, "ldap role reconcile")
+	}
+	return nil
+}
+
+// Enabled reports whether the LDAP login is enabled.
+func Enabled() bool {
+	return config.App.Ldap.Enable && config.App.Ldap.Login && directory.Connected()
+}
+
+// Login authenticates the user by LDAP and returns the linked user, the user is
+// provisioned at the first login if config "ldap.auto_provision" enabled.
+// The roles mapped from the LDAP groups of the user are synchronized.
+func Login(ctx *types.DatabaseContext, username, password string) (*model.User, error) {
+	// The empty password is the unauthenticated bind in LDAP, which always succeeds.
+	if len(username) == 0 || len(password) == 0 {
+		return nil, ErrInvalidCredentials
+	}
+	ok, err := directory.Authenticate(username, password)
+	if err != nil {
+		if errors.Is(err, providerldap.ErrUserNotFound) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	user, err := link(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := directory.GetUserGroups(username)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get ldap user groups")
+	}
+	if err = SyncRoles(user.ID, groups); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// link returns the user linked to the LDAP user, the user is provisioned if not linked.
+func link(ctx *types.DatabaseContext, username string) (*model.User, error) {
+	identity := new(model.UserIdentity)
+	if err := database.Database[*model.UserIdentity](ctx).Get(identity, model.UserIdentityID(Provider, username)); err != nil {
+		return nil, errors.Wrap(err, "failed to get user identity")
+	}
+	if len(identity.ID) > 0 {
+		user := new(model.User)
+		if err := database.Database[*model.User](ctx).Get(user, identity.UserID); err != nil {
+			return nil, errors.Wrap(err, "failed to get user")
+		}
+		if len(user.ID) > 0 {
+			return user, nil
+		}
+		// The linked user was deleted, link again.
+		if err := database.Database[*model.UserIdentity](ctx).WithPurge().Delete(identity); err != nil {
+			return nil, errors.Wrap(err, "failed to delete user identity")
+		}
+	}
+	if !config.App.Ldap.AutoProvision {
+		return nil, ErrUserNotLinked
+	}
+
+	entry, err := directory.GetUser(username, []string{"mail", "cn", "displayName"})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get ldap user")
+	}
+	users := make([]*model.User, 0)
+	if err = database.Database[*model.User](ctx).WithLimit(1).WithQuery(&model.User{Name: username}).List(&users); err != nil {
+		return nil, errors.Wrap(err, "failed to list users")
+	}
+	// The local user with the same name is never taken over, it may have different owner.
+	if len(users) > 0 {
+		return nil, errors.Wrapf(ErrUserNotLinked, "username %q is taken by local user", username)
+	}
+	user := &model.User{
+		Name:     username,
+		Nickname: entry.GetAttributeValue("displayName"),
+		Email:    entry.GetAttributeValue("mail"),
+	}
+	if len(user.Nickname) == 0 {
+		user.Nickname = entry.GetAttributeValue("cn")
+	}
+	if err = database.Database[*model.User](ctx).Create(user); err != nil {
+		return nil, errors.Wrap(err, "failed to create user")
+	}
+	if err = database.Database[*model.UserIdentity](ctx).Create(&model.UserIdentity{
+		UserID:   user.ID,
+		Provider: Provider,
+		Subject:  username,
+		Email:    user.Email,
+	}); err != nil {
+		return nil, errors.Wrap(err, "failed to create user identity")
+	}
+	zap.S().Infow("ldap user provisioned", "username", username, "user_id", user.ID)
+	return user, nil
+}
+
+// SyncRoles assigns the roles mapped from the groups to the user and unassigns the other
+// mapped roles, the roles not mapped from any LDAP group are never touched.
+// It's a no-op if the RBAC is not enabled.
+func SyncRoles(userID string, groups []string) error {
+	if rbac.Enforcer == nil {
+		return nil
+	}
+	mapping, err := groupRoles(config.App.Ldap.GroupRoles)
+	if err != nil {
+		return err
+	}
+	current, err := rbac.Enforcer.GetRolesForUser(userID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get user roles")
+	}
+	assign, unassign := diffRoles(mapping, groups, current)
+	for _, role := range assign {
+		if err = rbac.RBAC().AssignRole(userID, role); err != nil {
+			return errors.Wrapf(err, "failed to assign role %s", role)
+		}
+	}
+	for _, role := range unassign {
+		if err = rbac.RBAC().UnassignRole(userID, role); err != nil {
+			return errors.Wrapf(err, "failed to unassign role %s", role)
+		}
+	}
+	if len(assign) > 0 || len(unassign) > 0 {
+		zap.S().Infow("ldap roles synchronized", "user_id", userID, "assigned", assign, "unassigned", unassign)
+	}
+	return nil
+}
+
+// Reconcile synchronizes the roles of all the LDAP users by their current group memberships,
+// the users removed from LDAP lose all the mapped roles.
+func Reconcile() error {
+	if !directory.Connected() {
+		return errors.New("LDAP connection not initialized")
+	}
+	identities := make([]*model.UserIdentity, 0)
+	if err := database.Database[*model.UserIdentity](nil).WithLimit(-1).WithQuery(&model.UserIdentity{Provider: Provider}).List(&identities); err != nil {
+		return errors.Wrap(err, "failed to list ldap user identities")
+	}
+	var errs []error
+	for _, identity := range identities {
+		groups, err := directory.GetUserGroups(identity.Subject)
+		if err != nil {
+			if !errors.Is(err, providerldap.ErrUserNotFound) {
+				errs = append(errs, errors.Wrapf(err, "failed to get groups of ldap user %s", identity.Subject))
+				continue
+			}
+			groups = nil
+		}
+		if err = SyncRoles(identity.UserID, groups); err != nil {
+			errs = append(errs, errors.Wrapf(err, "failed to sync roles of ldap user %s", identity.Subject))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// groupRoles parses the "group=role" items to the roles by group.
+func groupRoles(items []string) (map[string][]string, error) {
+	mapping := make(map[string][]string, len(items))
+	for _, item := range items {
+		group, role, ok := strings.Cut(item, "=")
+		group, role = strings.TrimSpace(group), strings.TrimSpace(role)
+		if !ok || len(group) == 0 || len(role) == 0 {
+			return nil, errors.Newf("invalid ldap group role mapping %q, expected \"group=role\"", item)
+		}
+		mapping[group] = append(mapping[group], role)
+	}
+	return mapping, nil
+}
+
+// diffRoles returns the mapped roles to assign and to unassign by the groups of the user.
+// The LDAP group names are case-insensitive.
+func diffRoles(mapping map[string][]string, groups []string, current []string) (assign, unassign []string) {
+	desired := make(map[string]bool)
+	managed := make(map[string]bool)
+	for group, roles := range mapping {
+		member := slices.ContainsFunc(groups, func(g string) bool { return strings.EqualFold(g, group) })
+		for _, role := range roles {
+			managed[role] = true
+			if member {
+				desired[role] = true
+			}
+		}
+	}
+	for role := range desired {
+		if !slices.Contains(current, role) {
+			assign = append(assign, role)
+		}
+	}
+	for _, role := range current {
+		if managed[role] && !desired[role] {
+			unassign = append(unassign, role)
+		}
+	}
+	slices.Sort(assign)
+	slices.Sort(unassign)
+	return assign, unassign
+}
@@ -0,0 +1,38 @@
+package ldap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupRoles(t *testing.T) {
+	mapping, err := groupRoles([]string{"admins=admin", " devs = developer ", "devs=viewer"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"admins": {"admin"}, "devs": {"developer", "viewer"}}, mapping)
+
+	for _, item := range []string{"admins", "=admin", "admins="} {
+		_, err = groupRoles([]string{item})
+		assert.Error(t, err, item)
+	}
+}
+
+func TestDiffRoles(t *testing.T) {
+	mapping := map[string][]string{"admins": {"admin"}, "devs": {"developer", "viewer"}, "ops": {"viewer"}}
+
+	// The mapped roles are assigned, the manual roles are kept.
+	assign, unassign := diffRoles(mapping, []string{"Devs"}, []string{"auditor"})
+	assert.Equal(t, []string{"developer", "viewer"}, assign)
+	assert.Empty(t, unassign)
+
+	// The role still mapped from other group is kept.
+	assign, unassign = diffRoles(mapping, []string{"ops"}, []string{"developer", "viewer", "auditor"})
+	assert.Empty(t, assign)
+	assert.Equal(t, []string{"developer"}, unassign)
+
+	// The user removed from LDAP loses all the mapped roles.
+	assign, unassign = diffRoles(mapping, nil, []string{"admin", "viewer", "auditor"})
+	assert.Empty(t, assign)
+	assert.Equal(t, []string{"admin", "viewer"}, unassign)
+}
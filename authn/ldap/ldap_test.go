@@ -0,0 +1,185 @@
+package ldap_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/authn/ldap"
+	"github.com/forbearing/gst/authz/rbac"
+	"github.com/forbearing/gst/bootstrap"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/model"
+	providerldap "github.com/forbearing/gst/provider/ldap"
+	goldap "github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type entry struct {
+	password string
+	groups   []string
+	attrs    map[string][]string
+}
+
+// directory is the stub LDAP directory, the key is the username.
+type directory struct {
+	connected bool
+	users     map[string]*entry
+}
+
+func (d *directory) Connected() bool { return d.connected }
+
+func (d *directory) Authenticate(username, password string) (bool, error) {
+	e, ok := d.users[username]
+	if !ok {
+		return false, errors.Wrapf(providerldap.ErrUserNotFound, "user %s", username)
+	}
+	return e.password == password, nil
+}
+
+func (d *directory) GetUser(username string, _ []string) (*goldap.Entry, error) {
+	e, ok := d.users[username]
+	if !ok {
+		return nil, errors.Wrapf(providerldap.ErrUserNotFound, "user %s", username)
+	}
+	return goldap.NewEntry("uid="+username+",ou=users,dc=example,dc=com", e.attrs), nil
+}
+
+func (d *directory) GetUserGroups(username string) ([]string, error) {
+	e, ok := d.users[username]
+	if !ok {
+		return nil, errors.Wrapf(providerldap.ErrUserNotFound, "user %s", username)
+	}
+	return e.groups, nil
+}
+
+var dir = &directory{connected: true, users: make(map[string]*entry)}
+
+func init() {
+	os.Setenv(config.LOGGER_DIR, "/tmp/test_ldap")
+	os.Setenv(config.DATABASE_TYPE, string(config.DBSqlite))
+	os.Setenv(config.SQLITE_IS_MEMORY, "false")
+	os.Setenv(config.SQLITE_PATH, "/tmp/test_ldap.db")
+	os.Setenv(config.AUTH_RBAC_ENABLE, "true")
+	_ = os.Remove("/tmp/test_ldap.db")
+
+	model.Register[*model.User]()
+	if err := bootstrap.Bootstrap(); err != nil {
+		panic(err)
+	}
+	config.App.Ldap.GroupRoles = []string{"admins=admin", "devs=developer"}
+	ldap.RegisterDirectory(dir)
+}
+
+func roles(t *testing.T, userID string) []string {
+	t.Helper()
+	roles, err := rbac.Enforcer.GetRolesForUser(userID)
+	require.NoError(t, err)
+	return roles
+}
+
+func TestLogin(t *testing.T) {
+	dir.users["alice"] = &entry{
+		password: "secret",
+		groups:   []string{"Devs"},
+		attrs:    map[string][]string{"cn": {"alice"}, "displayName": {"Alice"}, "mail": {"alice@example.com"}},
+	}
+	dir.users["bob"] = &entry{password: "secret", attrs: map[string][]string{"cn": {"Bob"}}}
+
+	t.Run("invalid credentials", func(t *testing.T) {
+		for _, c := range [][2]string{{"alice", "wrong"}, {"alice", ""}, {"", "secret"}, {"nobody", "secret"}} {
+			_, err := ldap.Login(nil, c[0], c[1])
+			assert.ErrorIs(t, err, ldap.ErrInvalidCredentials, c[0])
+		}
+	})
+
+	t.Run("not linked", func(t *testing.T) {
+		config.App.Ldap.AutoProvision = false
+		_, err := ldap.Login(nil, "alice", "secret")
+		assert.ErrorIs(t, err, ldap.ErrUserNotLinked)
+	})
+
+	config.App.Ldap.AutoProvision = true
+	var userID string
+	t.Run("provision", func(t *testing.T) {
+		user, err := ldap.Login(nil, "alice", "secret")
+		require.NoError(t, err)
+		userID = user.ID
+		assert.Equal(t, "alice", user.Name)
+		assert.Equal(t, "Alice", user.Nickname)
+		assert.Equal(t, "alice@example.com", user.Email)
+		assert.Equal(t, []string{"developer"}, roles(t, user.ID))
+
+		identity := new(model.UserIdentity)
+		require.NoError(t, database.Database[*model.UserIdentity](nil).Get(identity, model.UserIdentityID(ldap.Provider, "alice")))
+		assert.Equal(t, user.ID, identity.UserID)
+
+		// The nickname falls back to the cn.
+		user, err = ldap.Login(nil, "bob", "secret")
+		require.NoError(t, err)
+		assert.Equal(t, "Bob", user.Nickname)
+		assert.Empty(t, roles(t, user.ID))
+	})
+
+	t.Run("linked", func(t *testing.T) {
+		// The groups changed, the manual role is kept.
+		require.NoError(t, rbac.RBAC().AssignRole(userID, "auditor"))
+		dir.users["alice"].groups = []string{"admins"}
+		user, err := ldap.Login(nil, "alice", "secret")
+		require.NoError(t, err)
+		assert.Equal(t, userID, user.ID)
+		assert.ElementsMatch(t, []string{"admin", "auditor"}, roles(t, user.ID))
+
+		// The linked user was deleted, it's provisioned again.
+		user, err = ldap.Login(nil, "bob", "secret")
+		require.NoError(t, err)
+		require.NoError(t, database.Database[*model.User](nil).WithPurge().Delete(user))
+		relinked, err := ldap.Login(nil, "bob", "secret")
+		require.NoError(t, err)
+		assert.NotEqual(t, user.ID, relinked.ID)
+	})
+
+	t.Run("local user", func(t *testing.T) {
+		dir.users["carol"] = &entry{password: "secret"}
+		require.NoError(t, database.Database[*model.User](nil).Create(&model.User{Name: "carol"}))
+		_, err := ldap.Login(nil, "carol", "secret")
+		assert.ErrorIs(t, err, ldap.ErrUserNotLinked)
+	})
+}
+
+func TestSyncRoles(t *testing.T) {
+	const userID = "sync-user"
+	require.NoError(t, rbac.RBAC().AssignRole(userID, "auditor"))
+
+	require.NoError(t, ldap.SyncRoles(userID, []string{"admins", "devs", "others"}))
+	assert.ElementsMatch(t, []string{"admin", "developer", "auditor"}, roles(t, userID))
+
+	require.NoError(t, ldap.SyncRoles(userID, []string{"ADMINS"}))
+	assert.ElementsMatch(t, []string{"admin", "auditor"}, roles(t, userID))
+
+	require.NoError(t, ldap.SyncRoles(userID, nil))
+	assert.Equal(t, []string{"auditor"}, roles(t, userID))
+}
+
+func TestReconcile(t *testing.T) {
+	dir.users["dave"] = &entry{password: "secret", groups: []string{"devs"}}
+	dir.users["erin"] = &entry{password: "secret", groups: []string{"admins"}}
+	dave, err := ldap.Login(nil, "dave", "secret")
+	require.NoError(t, err)
+	erin, err := ldap.Login(nil, "erin", "secret")
+	require.NoError(t, err)
+	require.NoError(t, rbac.RBAC().AssignRole(erin.ID, "auditor"))
+
+	// dave moved to the other group, erin removed from LDAP.
+	dir.users["dave"].groups = []string{"admins"}
+	delete(dir.users, "erin")
+	require.NoError(t, ldap.Reconcile())
+	assert.Equal(t, []string{"admin"}, roles(t, dave.ID))
+	assert.Equal(t, []string{"auditor"}, roles(t, erin.ID))
+
+	dir.connected = false
+	defer func() { dir.connected = true }()
+	assert.Error(t, ldap.Reconcile())
+}
@@ -0,0 +1,249 @@
+// Package mfa implements the multi-factor authentication of the local users by TOTP
+// (RFC 6238) and one-time recovery codes.
+//
+// The login with MFA has two steps:
+//  1. The password is verified, the "MFA pending" token is issued by jwt.GenMFAToken.
+//  2. The TOTP or recovery code is verified with the pending token, the real tokens are issued.
+//
+// The MFA is required for the users enrolled TOTP, the users marked by model.MFA.Required
+// and the users having any role of config "auth.mfa_required_roles".
+package mfa
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/authz/rbac"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+	"github.com/forbearing/gst/util"
+)
+
+var (
+	ErrNotEnrolled     = errors.New("mfa not enrolled")
+	ErrAlreadyEnrolled = errors.New("mfa already enrolled")
+	ErrInvalidCode     = errors.New("invalid mfa code")
+)
+
+const (
+	recoveryCodeCount = 10
+	recoveryCodeSize  = 10
+)
+
+// Status returns whether the user enrolled the MFA and whether the MFA is required.
+func Status(ctx *types.DatabaseContext, userID string) (enrolled, required bool, err error) {
+	m, err := get(ctx, userID)
+	if err != nil {
+		return false, false, err
+	}
+	if m.Enabled || m.Required {
+		return m.Enabled, true, nil
+	}
+	if roles := config.App.Auth.MFARequiredRoles; len(roles) > 0 && rbac.Enforcer != nil {
+		userRoles, err := rbac.Enforcer.GetImplicitRolesForUser(userID)
+		if err != nil {
+			return false, false, errors.Wrap(err, "failed to get user roles")
+		}
+		if slices.ContainsFunc(userRoles, func(role string) bool { return slices.Contains(roles, role) }) {
+			return false, true, nil
+		}
+	}
+	return false, false, nil
+}
+
+// Enroll generates a new TOTP secret for the user, the secret takes effect after Activate.
+// It returns the secret and the "otpauth://" key uri.
+func Enroll(ctx *types.DatabaseContext, userID, username string) (secret, uri string, err error) {
+	m, err := get(ctx, userID)
+	if err != nil {
+		return "", "", err
+	}
+	if m.Enabled {
+		return "", "", ErrAlreadyEnrolled
+	}
+	m.UserID = userID
+	m.Secret = GenerateSecret()
+	m.LastCounter = 0
+	if err = database.Database[*model.MFA](ctx).Create(m); err != nil {
+		return "", "", errors.Wrap(err, "failed to save mfa")
+	}
+	issuer := config.App.AppInfo.Name
+	if len(issuer) == 0 {
+		issuer = consts.FrameworkName
+	}
+	return m.Secret, KeyURI(issuer, username, m.Secret), nil
+}
+
+// Activate activates the enrolled TOTP by the first code generated by the authenticator app,
+// and returns the recovery codes, which are shown to the user only once.
+func Activate(ctx *types.DatabaseContext, userID, code string) ([]string, error) {
+	m, err := get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if m.Enabled {
+		return nil, ErrAlreadyEnrolled
+	}
+	if len(m.Secret) == 0 {
+		return nil, ErrNotEnrolled
+	}
+	counter, ok := validateCode(m.Secret, code, time.Now(), m.LastCounter)
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+	// The concurrent activations by the same code, maybe on the other replicas, only one succeeds.
+	m.Enabled, m.LastCounter = true, counter
+	if err = save(ctx, m); err != nil {
+		return nil, err
+	}
+	return resetRecoveryCodes(ctx, userID)
+}
+
+// Verify verifies the TOTP or recovery code of the user, the code is accepted only once
+// even if verified concurrently by the replicas.
+func Verify(ctx *types.DatabaseContext, userID, code string) error {
+	m, err := get(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !m.Enabled {
+		return ErrNotEnrolled
+	}
+	code = strings.TrimSpace(code)
+	if counter, ok := validateCode(m.Secret, code, time.Now(), m.LastCounter); ok {
+		// The counter only moves forward, the code accepted by the others is rejected.
+		m.LastCounter = counter
+		return save(ctx, m)
+	}
+	return useRecoveryCode(ctx, userID, code)
+}
+
+// Disable removes the TOTP and recovery codes of the user after verifying the code.
+// The MFA may still be required by model.MFA.Required or the roles.
+func Disable(ctx *types.DatabaseContext, userID, code string) error {
+	if err := Verify(ctx, userID, code); err != nil {
+		return err
+	}
+	m, err := get(ctx, userID)
+	if err != nil {
+		return err
+	}
+	m.Enabled, m.Secret, m.LastCounter = false, "", 0
+	if err = database.Database[*model.MFA](ctx).Update(m); err != nil {
+		return errors.Wrap(err, "failed to save mfa")
+	}
+	return deleteRecoveryCodes(ctx, userID)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user after verifying the code.
+func RegenerateRecoveryCodes(ctx *types.DatabaseContext, userID, code string) ([]string, error) {
+	if err := Verify(ctx, userID, code); err != nil {
+		return nil, err
+	}
+	return resetRecoveryCodes(ctx, userID)
+}
+
+// get returns the MFA settings of the user, the empty settings if not exists.
+func get(ctx *types.DatabaseContext, userID string) (*model.MFA, error) {
+	if len(userID) == 0 {
+		return nil, errors.New("user id is required")
+	}
+	m := new(model.MFA)
+	if err := database.Database[*model.MFA](ctx).Get(m, userID); err != nil {
+		return nil, errors.Wrap(err, "failed to get mfa")
+	}
+	m.UserID = userID
+	return m, nil
+}
+
+// save updates the MFA settings read by get, it fails with ErrInvalidCode if they're
+// changed by the others since, eg: the same code verified concurrently.
+func save(ctx *types.DatabaseContext, m *model.MFA) error {
+	if err := database.Database[*model.MFA](ctx).Update(m); err != nil {
+		if errors.Is(err, database.ErrVersionConflict) {
+			return ErrInvalidCode
+		}
+		return errors.Wrap(err, "failed to save mfa")
+	}
+	return nil
+}
+
+func resetRecoveryCodes(ctx *types.DatabaseContext, userID string) ([]string, error) {
+	if err := deleteRecoveryCodes(ctx, userID); err != nil {
+		return nil, err
+	}
+	codes := make([]string, recoveryCodeCount)
+	records := make([]*model.MFARecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		codes[i] = generateRecoveryCode()
+		records[i] = &model.MFARecoveryCode{UserID: userID, Hash: hashRecoveryCode(codes[i])}
+	}
+	if err := database.Database[*model.MFARecoveryCode](ctx).Create(records...); err != nil {
+		return nil, errors.Wrap(err, "failed to create recovery codes")
+	}
+	return codes, nil
+}
+
+func deleteRecoveryCodes(ctx *types.DatabaseContext, userID string) error {
+	records := make([]*model.MFARecoveryCode, 0)
+	if err := database.Database[*model.MFARecoveryCode](ctx).WithLimit(-1).WithQuery(&model.MFARecoveryCode{UserID: userID}).List(&records); err != nil {
+		return errors.Wrap(err, "failed to list recovery codes")
+	}
+	if err := database.Database[*model.MFARecoveryCode](ctx).WithPurge().Delete(records...); err != nil {
+		return errors.Wrap(err, "failed to delete recovery codes")
+	}
+	return nil
+}
+
+// useRecoveryCode marks the unused recovery code as used.
+func useRecoveryCode(ctx *types.DatabaseContext, userID, code string) error {
+	if len(code) == 0 {
+		return ErrInvalidCode
+	}
+	// The hash is not queryable, match the few recovery codes of the user one by one.
+	records := make([]*model.MFARecoveryCode, 0)
+	if err := database.Database[*model.MFARecoveryCode](ctx).WithLimit(-1).WithQuery(&model.MFARecoveryCode{UserID: userID}).List(&records); err != nil {
+		return errors.Wrap(err, "failed to list recovery codes")
+	}
+	hash := hashRecoveryCode(code)
+	for _, record := range records {
+		if record.UsedAt != nil || subtle.ConstantTimeCompare([]byte(record.Hash), []byte(hash)) != 1 {
+			continue
+		}
+		// The recovery code used by the others is rejected.
+		record.UsedAt = util.ValueOf(model.GormTime(time.Now()))
+		if err := database.Database[*model.MFARecoveryCode](ctx).Update(record); err != nil {
+			if errors.Is(err, database.ErrVersionConflict) {
+				return ErrInvalidCode
+			}
+			return errors.Wrap(err, "failed to update recovery code")
+		}
+		return nil
+	}
+	return ErrInvalidCode
+}
+
+// generateRecoveryCode returns the random recovery code like "abcde-fghij".
+func generateRecoveryCode() string {
+	b := make([]byte, recoveryCodeSize)
+	_, _ = rand.Read(b)
+	code := strings.ToLower(b32.EncodeToString(b))[:recoveryCodeSize]
+	return code[:recoveryCodeSize/2] + "-" + code[recoveryCodeSize/2:]
+}
+
+// hashRecoveryCode returns the sha256 hash of the recovery code, the case, the surrounding
+// spaces and the dashes typed by the user are ignored.
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,83 @@
+package mfa_test
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/forbearing/gst/authn/mfa"
+	"github.com/forbearing/gst/bootstrap"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	os.Setenv(config.LOGGER_DIR, "/tmp/test_mfa")
+	os.Setenv(config.DATABASE_TYPE, string(config.DBSqlite))
+	os.Setenv(config.SQLITE_IS_MEMORY, "false")
+	os.Setenv(config.SQLITE_PATH, "/tmp/test_mfa.db")
+	_ = os.Remove("/tmp/test_mfa.db")
+
+	if err := bootstrap.Bootstrap(); err != nil {
+		panic(err)
+	}
+}
+
+func TestMFA(t *testing.T) {
+	ctx := &types.DatabaseContext{}
+	userID := "mfa-user"
+
+	enrolled, required, err := mfa.Status(ctx, userID)
+	require.NoError(t, err)
+	assert.False(t, enrolled)
+	assert.False(t, required)
+	require.ErrorIs(t, mfa.Verify(ctx, userID, "000000"), mfa.ErrNotEnrolled)
+
+	secret, uri, err := mfa.Enroll(ctx, userID, "alice")
+	require.NoError(t, err)
+	assert.Contains(t, uri, secret)
+
+	_, err = mfa.Activate(ctx, userID, "000000")
+	require.ErrorIs(t, err, mfa.ErrInvalidCode)
+	code, err := mfa.GenerateCode(secret, time.Now())
+	require.NoError(t, err)
+	codes, err := mfa.Activate(ctx, userID, code)
+	require.NoError(t, err)
+	require.Len(t, codes, 10)
+
+	enrolled, required, err = mfa.Status(ctx, userID)
+	require.NoError(t, err)
+	assert.True(t, enrolled)
+	assert.True(t, required)
+
+	// The TOTP code used by the activation can't be replayed.
+	require.ErrorIs(t, mfa.Verify(ctx, userID, code), mfa.ErrInvalidCode)
+
+	// The recovery code is accepted only once.
+	require.NoError(t, mfa.Verify(ctx, userID, codes[3]))
+	require.ErrorIs(t, mfa.Verify(ctx, userID, codes[3]), mfa.ErrInvalidCode)
+
+	// The recovery code verified concurrently is accepted only once.
+	var wg sync.WaitGroup
+	var accepted atomic.Int32
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if mfa.Verify(ctx, userID, codes[5]) == nil {
+				accepted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), accepted.Load())
+
+	require.NoError(t, mfa.Disable(ctx, userID, codes[4]))
+	enrolled, _, err = mfa.Status(ctx, userID)
+	require.NoError(t, err)
+	assert.False(t, enrolled)
+}
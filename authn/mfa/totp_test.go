@@ -0,0 +1,61 @@
+package mfa
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The SHA1 test vectors of RFC 6238 Appendix B, truncated to 6 digits.
+func TestGenerateCode(t *testing.T) {
+	secret := b32.EncodeToString([]byte("12345678901234567890"))
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		code, err := GenerateCode(secret, time.Unix(tt.unix, 0))
+		require.NoError(t, err)
+		assert.Equal(t, tt.code, code, "time %d", tt.unix)
+	}
+}
+
+func TestValidateCode(t *testing.T) {
+	secret := GenerateSecret()
+	now := time.Now()
+	code, err := GenerateCode(secret, now)
+	require.NoError(t, err)
+
+	counter, ok := validateCode(secret, code, now, 0)
+	require.True(t, ok)
+	assert.Equal(t, now.Unix()/totpPeriod, counter)
+
+	// The accepted code can't be replayed.
+	_, ok = validateCode(secret, code, now, counter)
+	assert.False(t, ok)
+
+	// The code of the adjacent time step is accepted for the clock skew.
+	_, ok = validateCode(secret, code, now.Add(totpPeriod*time.Second), 0)
+	assert.True(t, ok)
+	_, ok = validateCode(secret, code, now.Add(3*totpPeriod*time.Second), 0)
+	assert.False(t, ok)
+
+	_, ok = validateCode(secret, "12345", now, 0)
+	assert.False(t, ok)
+}
+
+func TestRecoveryCode(t *testing.T) {
+	code := generateRecoveryCode()
+	assert.Len(t, code, recoveryCodeSize+1)
+	assert.Equal(t, hashRecoveryCode(code), hashRecoveryCode(" "+strings.ToUpper(strings.ReplaceAll(code, "-", ""))))
+	assert.NotEqual(t, hashRecoveryCode(code), hashRecoveryCode(generateRecoveryCode()))
+}
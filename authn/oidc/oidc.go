@@ -0,0 +1,369 @@
+// Package oidc implements the OpenID Connect relying party to log in users with
+// an external identity provider, eg: Keycloak, Dex, Okta, Google.
+//
+// The flow is the authorization code flow with PKCE:
+//  1. AuthCodeURL returns the authorization url the user agent is redirected to.
+//  2. The identity provider redirects back to the redirect url with code and state.
+//  3. Exchange exchanges the code for the tokens and verifies the ID token.
+//  4. User links the ID token to model.User, the user is provisioned if enabled.
+//
+// The handlers are controller.User.OIDCLogin and controller.User.OIDCCallback, which issue
+// the framework's own tokens by jwt.GenTokens.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/authn/jwt"
+	"github.com/forbearing/gst/config"
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrProviderNotFound = errors.New("oidc provider not found")
+	ErrInvalidState     = errors.New("invalid or expired oidc state")
+	ErrInvalidIDToken   = errors.New("invalid id token")
+	ErrUserNotLinked    = errors.New("no user linked to the oidc identity")
+)
+
+const (
+	// stateTTL is the max duration between AuthCodeURL and Exchange.
+	stateTTL       = 10 * time.Minute
+	maxStates      = 10000
+	refetchDelay   = 10 * time.Second
+	requestTimeout = 10 * time.Second
+	clockSkew      = 1 * time.Minute
+)
+
+var (
+	providers = make(map[string]*Provider)
+	mu        sync.RWMutex
+
+	// states holds the nonce and PKCE verifier of the pending logins by state.
+	// NOTE: the state is kept in memory, the callback must reach the replica starting the login,
+	// eg: by sticky sessions, if the application runs multiple replicas.
+	states = expirable.NewLRU[string, *authState](maxStates, nil, stateTTL)
+
+	httpClient = &http.Client{Timeout: requestTimeout}
+
+	// validMethods is the accepted ID token signing algorithms, "none" and HMAC are never accepted.
+	validMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+)
+
+type authState struct {
+	provider string
+	nonce    string
+	verifier string
+}
+
+// Provider is the OIDC identity provider, the provider metadata is discovered at the first use.
+type Provider struct {
+	cfg config.OIDC
+
+	mu        sync.Mutex
+	metadata  *metadata
+	keys      map[string]any
+	fetchedAt time.Time
+}
+
+// metadata is the OpenID provider metadata, see OpenID Connect Discovery 1.0.
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Init registers the OIDC provider configured by config "oidc".
+func Init() error {
+	if !config.App.OIDC.Enable {
+		return nil
+	}
+	return Register(config.App.OIDC)
+}
+
+// Register registers the identity provider by cfg.Name, the provider with the same name is replaced.
+// Call it to use multiple identity providers in addition to the one configured by config "oidc".
+func Register(cfg config.OIDC) error {
+	if len(cfg.Name) == 0 {
+		return errors.New("oidc provider name is required")
+	}
+	if len(cfg.Issuer) == 0 || len(cfg.ClientID) == 0 || len(cfg.RedirectURL) == 0 {
+		return errors.Newf("oidc provider %q requires issuer, client_id and redirect_url", cfg.Name)
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid"}
+	}
+	if !slices.Contains(cfg.Scopes, "openid") {
+		cfg.Scopes = append([]string{"openid"}, cfg.Scopes...)
+	}
+	if len(cfg.UsernameClaim) == 0 {
+		cfg.UsernameClaim = "preferred_username"
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	providers[cfg.Name] = &Provider{cfg: cfg}
+	zap.S().Infow("successfully register oidc provider", "name", cfg.Name, "issuer", cfg.Issuer)
+	return nil
+}
+
+// Get returns the registered identity provider by name.
+func Get(name string) (*Provider, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	p, ok := providers[name]
+	return p, ok
+}
+
+// Name returns the provider name.
+func (p *Provider) Name() string { return p.cfg.Name }
+
+// AuthCodeURL returns the authorization url to start the login, the state, nonce and
+// PKCE code verifier are generated and kept until Exchange.
+func (p *Provider) AuthCodeURL(ctx context.Context) (string, error) {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	state, nonce, verifier := randomString(), randomString(), randomString()
+	states.Add(state, &authState{provider: p.cfg.Name, nonce: nonce, verifier: verifier})
+
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(md.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return md.AuthorizationEndpoint + sep + query.Encode(), nil
+}
+
+// Exchange exchanges the authorization code for the tokens and returns the verified ID token claims.
+// The state is consumed, a state can be exchanged only once.
+func (p *Provider) Exchange(ctx context.Context, code, state string) (*jwt.Claims, error) {
+	st, ok := states.Get(state)
+	if !ok || st.provider != p.cfg.Name {
+		return nil, ErrInvalidState
+	}
+	states.Remove(state)
+	if len(code) == 0 {
+		return nil, errors.New("authorization code is required")
+	}
+	md, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"client_id":     {p.cfg.ClientID},
+		"code_verifier": {st.verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, md.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if len(p.cfg.ClientSecret) > 0 {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+	tokens := new(tokenResponse)
+	if err = doJSON(req, tokens); err != nil && len(tokens.Error) == 0 {
+		return nil, errors.Wrap(err, "failed to exchange authorization code")
+	}
+	if len(tokens.Error) > 0 {
+		return nil, errors.Newf("failed to exchange authorization code: %s: %s", tokens.Error, tokens.ErrorDescription)
+	}
+	if len(tokens.IDToken) == 0 {
+		return nil, errors.Wrap(ErrInvalidIDToken, "no id token in token response")
+	}
+	return p.verify(ctx, md, tokens.IDToken, st.nonce, tokens.AccessToken)
+}
+
+// verify verifies the ID token signature and claims, see OpenID Connect Core 1.0 section 3.1.3.7.
+func (p *Provider) verify(ctx context.Context, md *metadata, raw, nonce, accessToken string) (*jwt.Claims, error) {
+	claims := new(jwt.Claims)
+	token, err := gojwt.ParseWithClaims(raw, claims, func(token *gojwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, md, kid)
+	},
+		gojwt.WithValidMethods(validMethods),
+		gojwt.WithIssuer(md.Issuer),
+		gojwt.WithAudience(p.cfg.ClientID),
+		gojwt.WithExpirationRequired(),
+		gojwt.WithIssuedAt(),
+		gojwt.WithLeeway(clockSkew),
+	)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidIDToken, err.Error())
+	}
+	if len(claims.Subject) == 0 {
+		return nil, errors.Wrap(ErrInvalidIDToken, "subject is required")
+	}
+	if claims.Nonce != nonce {
+		return nil, errors.Wrap(ErrInvalidIDToken, "nonce not match")
+	}
+	if len(claims.Audience) > 1 && claims.Azp != p.cfg.ClientID {
+		return nil, errors.Wrap(ErrInvalidIDToken, "authorized party not match")
+	}
+	if len(claims.AtHash) > 0 && len(accessToken) > 0 {
+		if claims.AtHash != tokenHash(token.Method.Alg(), accessToken) {
+			return nil, errors.Wrap(ErrInvalidIDToken, "access token hash not match")
+		}
+	}
+	return claims, nil
+}
+
+// discover fetches the provider metadata from "<issuer>/.well-known/openid-configuration".
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(p.cfg.Issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	md := new(metadata)
+	if err = doJSON(req, md); err != nil {
+		return nil, errors.Wrapf(err, "failed to discover oidc provider %s", p.cfg.Name)
+	}
+	if md.Issuer != p.cfg.Issuer {
+		return nil, errors.Newf("oidc issuer not match, expected %q, got %q", p.cfg.Issuer, md.Issuer)
+	}
+	if len(md.AuthorizationEndpoint) == 0 || len(md.TokenEndpoint) == 0 || len(md.JWKSURI) == 0 {
+		return nil, errors.Newf("oidc provider %s metadata is incomplete", p.cfg.Name)
+	}
+	p.metadata = md
+	return md, nil
+}
+
+// key returns the ID token verification key by kid, the key set is fetched again
+// for the unknown kid, the provider may have rotated its keys.
+func (p *Provider) key(ctx context.Context, md *metadata, kid string) (any, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.lookup(kid); ok {
+		return key, nil
+	}
+	if time.Since(p.fetchedAt) < refetchDelay {
+		return nil, errors.Newf("unknown signing key %q", kid)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, md.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	set := new(jwt.JSONWebKeySet)
+	if err = doJSON(req, set); err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch oidc provider %s keys", p.cfg.Name)
+	}
+	p.fetchedAt = time.Now()
+	p.keys = make(map[string]any, len(set.Keys))
+	for _, k := range set.Keys {
+		if len(k.Use) > 0 && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.PublicKey()
+		if err != nil {
+			zap.S().Warnw("skip invalid oidc provider key", "provider", p.cfg.Name, "kid", k.Kid, "error", err)
+			continue
+		}
+		p.keys[k.Kid] = pub
+	}
+	if key, ok := p.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, errors.Newf("unknown signing key %q", kid)
+}
+
+// lookup returns the key by kid, the only key is used if the token has no kid.
+func (p *Provider) lookup(kid string) (any, bool) {
+	if len(kid) == 0 && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func doJSON(req *http.Request, dest any) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	// The error response body is decoded as well, eg: the token error response.
+	_ = json.Unmarshal(body, dest)
+	if resp.StatusCode != http.StatusOK {
+		return errors.Newf("unexpected status code %d", resp.StatusCode)
+	}
+	return json.Unmarshal(body, dest)
+}
+
+// tokenHash returns the left-most half of the hash of token, base64url encoded,
+// the hash algorithm is the one used by the ID token signing algorithm.
+func tokenHash(alg, token string) string {
+	var h hash.Hash
+	switch {
+	case strings.HasSuffix(alg, "384"):
+		h = sha512.New384()
+	case strings.HasSuffix(alg, "512"), alg == "EdDSA":
+		h = sha512.New()
+	default:
+		h = sha256.New()
+	}
+	h.Write([]byte(token))
+	sum := h.Sum(nil)
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+}
+
+func randomString() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
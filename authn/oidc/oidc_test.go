@@ -0,0 +1,251 @@
+package oidc_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/forbearing/gst/authn/jwt"
+	"github.com/forbearing/gst/authn/oidc"
+	"github.com/forbearing/gst/bootstrap"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/types"
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	os.Setenv(config.LOGGER_DIR, "/tmp/test_oidc")
+	os.Setenv(config.DATABASE_TYPE, string(config.DBSqlite))
+	os.Setenv(config.SQLITE_IS_MEMORY, "false")
+	os.Setenv(config.SQLITE_PATH, "/tmp/test_oidc.db")
+	_ = os.Remove("/tmp/test_oidc.db")
+
+	model.Register[*model.User]()
+
+	if err := bootstrap.Bootstrap(); err != nil {
+		panic(err)
+	}
+}
+
+// idp is a local stand-in OpenID provider issuing ID tokens for the fixed user.
+type idp struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu         sync.Mutex
+	codes      map[string]url.Values // code -> authorization request
+	badNonce   bool
+	email      string
+	emailValid bool
+}
+
+func newIdP(t *testing.T) *idp {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	p := &idp{key: key, codes: make(map[string]url.Values), email: "alice@example.com", emailValid: true}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(jwt.JSONWebKeySet{Keys: []jwt.JSONWebKey{{
+			Kty: "RSA",
+			Kid: "idp-key",
+			Use: "sig",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	// The user is authenticated and consents immediately.
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		code := rand.Text()
+		p.mu.Lock()
+		p.codes[code] = q
+		p.mu.Unlock()
+		http.Redirect(w, r, q.Get("redirect_uri")+"?"+url.Values{"code": {code}, "state": {q.Get("state")}}.Encode(), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		p.mu.Lock()
+		req, ok := p.codes[r.PostForm.Get("code")]
+		delete(p.codes, r.PostForm.Get("code"))
+		p.mu.Unlock()
+
+		id, secret, _ := r.BasicAuth()
+		challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		switch {
+		case !ok, id != "client", secret != "secret",
+			req.Get("code_challenge") != base64.RawURLEncoding.EncodeToString(challenge[:]),
+			req.Get("redirect_uri") != r.PostForm.Get("redirect_uri"):
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		nonce := req.Get("nonce")
+		if p.badNonce {
+			nonce = "replayed"
+		}
+		now := time.Now()
+		token := gojwt.NewWithClaims(gojwt.SigningMethodRS256, jwt.Claims{
+			PreferredUsername: "alice",
+			GivenName:         "Alice",
+			FamilyName:        "Liddell",
+			Email:             p.email,
+			EmailVerified:     p.emailValid,
+			Nonce:             nonce,
+			RegisteredClaims: gojwt.RegisteredClaims{
+				Issuer:    p.URL,
+				Subject:   "alice-subject",
+				Audience:  gojwt.ClaimStrings{"client"},
+				ExpiresAt: gojwt.NewNumericDate(now.Add(time.Minute)),
+				IssuedAt:  gojwt.NewNumericDate(now),
+			},
+		})
+		token.Header["kid"] = "idp-key"
+		idToken, err := token.SignedString(key)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "access", "token_type": "Bearer", "id_token": idToken})
+	})
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+// authorize starts the login and returns the code and state of the authorization response.
+func authorize(t *testing.T, p *oidc.Provider) (code, state string) {
+	authURL, err := p.AuthCodeURL(t.Context())
+	require.NoError(t, err)
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "app.example.com", location.Host)
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func TestOIDC(t *testing.T) {
+	server := newIdP(t)
+	require.NoError(t, oidc.Register(config.OIDC{
+		Name:          "local",
+		Issuer:        server.URL,
+		ClientID:      "client",
+		ClientSecret:  "secret",
+		RedirectURL:   "https://app.example.com/api/oidc/local/callback",
+		AutoProvision: true,
+	}))
+	provider, ok := oidc.Get("local")
+	require.True(t, ok)
+	dbctx := &types.DatabaseContext{}
+
+	code, state := authorize(t, provider)
+	claims, err := provider.Exchange(t.Context(), code, state)
+	require.NoError(t, err)
+	assert.Equal(t, "alice-subject", claims.Subject)
+	assert.Equal(t, "alice@example.com", claims.Email)
+
+	// The state is consumed.
+	_, err = provider.Exchange(t.Context(), code, state)
+	require.ErrorIs(t, err, oidc.ErrInvalidState)
+
+	// The user is provisioned at the first login and linked at the next logins.
+	user, err := provider.User(dbctx, claims)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user.Name)
+	assert.Equal(t, "Alice Liddell", user.Nickname)
+	assert.True(t, user.EmailVerified)
+
+	code, state = authorize(t, provider)
+	claims, err = provider.Exchange(t.Context(), code, state)
+	require.NoError(t, err)
+	linked, err := provider.User(dbctx, claims)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, linked.ID)
+
+	// The framework's own tokens are issued to the user.
+	aToken, _, err := jwt.GenTokens(linked.ID, linked.Name, nil)
+	require.NoError(t, err)
+	parsed, err := jwt.ParseToken(aToken)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, parsed.UserID)
+
+	// The ID token with the wrong nonce is rejected.
+	server.badNonce = true
+	code, state = authorize(t, provider)
+	_, err = provider.Exchange(t.Context(), code, state)
+	require.ErrorIs(t, err, oidc.ErrInvalidIDToken)
+	server.badNonce = false
+
+	// The code can't be exchanged without the PKCE verifier of its state.
+	code, _ = authorize(t, provider)
+	_, state = authorize(t, provider)
+	_, err = provider.Exchange(t.Context(), code, state)
+	require.Error(t, err)
+}
+
+func TestOIDCLinkByEmail(t *testing.T) {
+	server := newIdP(t)
+	server.email = "bob@example.com"
+	require.NoError(t, oidc.Register(config.OIDC{
+		Name:         "corp",
+		Issuer:       server.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  "https://app.example.com/api/oidc/corp/callback",
+		LinkByEmail:  true,
+	}))
+	provider, _ := oidc.Get("corp")
+	dbctx := &types.DatabaseContext{}
+
+	// No user linked and the provisioning is disabled.
+	code, state := authorize(t, provider)
+	claims, err := provider.Exchange(t.Context(), code, state)
+	require.NoError(t, err)
+	_, err = provider.User(dbctx, claims)
+	require.ErrorIs(t, err, oidc.ErrUserNotLinked)
+
+	// The existing user is linked by the verified email.
+	bob := &model.User{Name: "bob", Email: "bob@example.com"}
+	require.NoError(t, database.Database[*model.User](nil).Create(bob))
+	user, err := provider.User(dbctx, claims)
+	require.NoError(t, err)
+	assert.Equal(t, bob.ID, user.ID)
+
+	// The unverified email is never linked.
+	server.email, server.emailValid = "carol@example.com", false
+	require.NoError(t, database.Database[*model.User](nil).Create(&model.User{Name: "carol", Email: "carol@example.com"}))
+	code, state = authorize(t, provider)
+	claims, err = provider.Exchange(t.Context(), code, state)
+	require.NoError(t, err)
+	claims.Subject = "carol-subject"
+	_, err = provider.User(dbctx, claims)
+	require.ErrorIs(t, err, oidc.ErrUserNotLinked)
+}
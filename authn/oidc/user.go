@@ -0,0 +1,120 @@
+package oidc
+
+import (
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/authn/jwt"
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/types"
+)
+
+// User returns the user linked to the ID token identity, the identity is linked:
+//   - to the user linked at the previous login, by provider and subject.
+//   - to the user with the same verified email, if config "oidc.link_by_email" enabled.
+//   - to the new provisioned user, if config "oidc.auto_provision" enabled.
+//
+// It returns ErrUserNotLinked if no user linked.
+func (p *Provider) User(ctx *types.DatabaseContext, claims *jwt.Claims) (*model.User, error) {
+	if claims == nil || len(claims.Subject) == 0 {
+		return nil, ErrInvalidIDToken
+	}
+	identityID := model.UserIdentityID(p.cfg.Name, claims.Subject)
+
+	identity := new(model.UserIdentity)
+	if err := database.Database[*model.UserIdentity](ctx).Get(identity, identityID); err != nil {
+		return nil, errors.Wrap(err, "failed to get user identity")
+	}
+	if len(identity.ID) > 0 {
+		user := new(model.User)
+		if err := database.Database[*model.User](ctx).Get(user, identity.UserID); err != nil {
+			return nil, errors.Wrap(err, "failed to get user")
+		}
+		if len(user.ID) > 0 {
+			return user, nil
+		}
+		// The linked user was deleted, link again.
+		if err := database.Database[*model.UserIdentity](ctx).WithPurge().Delete(identity); err != nil {
+			return nil, errors.Wrap(err, "failed to delete user identity")
+		}
+	}
+
+	var user *model.User
+	if p.cfg.LinkByEmail && claims.EmailVerified && len(claims.Email) > 0 {
+		users := make([]*model.User, 0)
+		if err := database.Database[*model.User](ctx).WithLimit(2).WithQuery(&model.User{Email: claims.Email}).List(&users); err != nil {
+			return nil, errors.Wrap(err, "failed to list users")
+		}
+		// The email must identify exactly one user.
+		if len(users) == 1 {
+			user = users[0]
+		}
+	}
+	if user == nil && p.cfg.AutoProvision {
+		var err error
+		if user, err = p.provision(ctx, claims, identityID); err != nil {
+			return nil, err
+		}
+	}
+	if user == nil {
+		return nil, ErrUserNotLinked
+	}
+
+	if err := database.Database[*model.UserIdentity](ctx).Create(&model.UserIdentity{
+		UserID:   user.ID,
+		Provider: p.cfg.Name,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}); err != nil {
+		return nil, errors.Wrap(err, "failed to create user identity")
+	}
+	return user, nil
+}
+
+// provision creates the user by the ID token claims, the user has no password and can only
+// log in by the identity provider.
+func (p *Provider) provision(ctx *types.DatabaseContext, claims *jwt.Claims, identityID string) (*model.User, error) {
+	name := p.username(claims)
+	users := make([]*model.User, 0)
+	if err := database.Database[*model.User](ctx).WithLimit(1).WithQuery(&model.User{Name: name}).List(&users); err != nil {
+		return nil, errors.Wrap(err, "failed to list users")
+	}
+	// The username is taken by other user, make it unique by the identity.
+	if len(users) > 0 {
+		name = name + "_" + identityID[:8]
+	}
+
+	user := &model.User{
+		Name:          name,
+		Nickname:      strings.TrimSpace(claims.GivenName + " " + claims.FamilyName),
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+	}
+	if len(user.Nickname) == 0 {
+		user.Nickname = name
+	}
+	if err := database.Database[*model.User](ctx).Create(user); err != nil {
+		return nil, errors.Wrap(err, "failed to create user")
+	}
+	return user, nil
+}
+
+// username returns the username by config "oidc.username_claim", it falls back to
+// preferred_username, email and subject.
+func (p *Provider) username(claims *jwt.Claims) string {
+	candidates := map[string]string{
+		"preferred_username": claims.PreferredUsername,
+		"email":              claims.Email,
+		"sub":                claims.Subject,
+	}
+	if name := candidates[p.cfg.UsernameClaim]; len(name) > 0 {
+		return name
+	}
+	for _, name := range []string{claims.PreferredUsername, claims.Email, claims.Subject} {
+		if len(name) > 0 {
+			return name
+		}
+	}
+	return claims.Subject
+}
@@ -0,0 +1,67 @@
+package passwd
+
+import (
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/types"
+)
+
+// CheckHistory returns ErrPasswordReused if the password is the current password of the
+// user or any of the last "auth.password_history" passwords.
+func CheckHistory(ctx *types.DatabaseContext, u *model.User, password string) error {
+	depth := config.App.Auth.PasswordHistory
+	if depth <= 0 {
+		return nil
+	}
+	if len(u.Password) > 0 && Compare(password, u.Password) == nil {
+		return ErrPasswordReused
+	}
+	histories, err := listHistory(ctx, u.ID)
+	if err != nil {
+		return err
+	}
+	for _, h := range histories[:min(depth, len(histories))] {
+		if Compare(password, h.Hash) == nil {
+			return ErrPasswordReused
+		}
+	}
+	return nil
+}
+
+// Remember records the replaced password hash of the user, the histories beyond
+// "auth.password_history" are purged.
+func Remember(ctx *types.DatabaseContext, userID, hashed string) error {
+	depth := config.App.Auth.PasswordHistory
+	if depth <= 0 || len(hashed) == 0 {
+		return nil
+	}
+	if err := database.Database[*model.PasswordHistory](ctx).Create(&model.PasswordHistory{UserID: userID, Hash: hashed}); err != nil {
+		return errors.Wrap(err, "failed to create password history")
+	}
+	histories, err := listHistory(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if len(histories) <= depth {
+		return nil
+	}
+	if err = database.Database[*model.PasswordHistory](ctx).WithPurge().Delete(histories[depth:]...); err != nil {
+		return errors.Wrap(err, "failed to delete password histories")
+	}
+	return nil
+}
+
+// listHistory returns the password histories of the user, the newest first.
+func listHistory(ctx *types.DatabaseContext, userID string) ([]*model.PasswordHistory, error) {
+	histories := make([]*model.PasswordHistory, 0)
+	if err := database.Database[*model.PasswordHistory](ctx).
+		WithLimit(-1).
+		WithQuery(&model.PasswordHistory{UserID: userID}).
+		WithOrder("created_at desc").
+		List(&histories); err != nil {
+		return nil, errors.Wrap(err, "failed to list password histories")
+	}
+	return histories, nil
+}
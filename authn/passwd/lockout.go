@@ -0,0 +1,87 @@
+package passwd
+
+import (
+	"math"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/types"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var ErrAccountLocked = errors.New("account is locked")
+
+// Locked returns the time until the account is locked, the zero time if not locked.
+func Locked(u *model.User) (time.Time, bool) {
+	if u.LockExpire <= 0 {
+		return time.Time{}, false
+	}
+	until := time.Unix(u.LockExpire, 0)
+	if time.Now().After(until) {
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+// Fail records a login failure of the user. The account is locked at every
+// "auth.lockout_threshold" consecutive failures, the lockout duration starts at
+// "auth.lockout_duration" and doubles at every further lockout up to "auth.lockout_max_duration".
+// It returns the time until the account is locked, the zero time if not locked.
+func Fail(ctx *types.DatabaseContext, u *model.User) (time.Time, error) {
+	cfg := config.App.Auth
+	if err := database.Database[*model.User](ctx).UpdateByID(u.ID, "num_wrong", gorm.Expr("num_wrong + 1")); err != nil {
+		return time.Time{}, errors.Wrap(err, "failed to update login failures")
+	}
+	// Read back the counter increased by the concurrent failures too.
+	current := new(model.User)
+	if err := database.Database[*model.User](ctx).Get(current, u.ID); err != nil {
+		return time.Time{}, errors.Wrap(err, "failed to get user")
+	}
+	u.NumWrong = current.NumWrong
+	if cfg.LockoutThreshold <= 0 || u.NumWrong%cfg.LockoutThreshold != 0 {
+		return time.Time{}, nil
+	}
+	until := time.Now().Add(lockoutDuration(u.NumWrong / cfg.LockoutThreshold))
+	u.LockExpire = until.Unix()
+	if err := database.Database[*model.User](ctx).UpdateByID(u.ID, "lock_expire", u.LockExpire); err != nil {
+		return time.Time{}, errors.Wrap(err, "failed to lock account")
+	}
+	zap.S().Warnw("account locked", "user_id", u.ID, "username", u.Name, "failures", u.NumWrong, "until", until)
+	return until, nil
+}
+
+// Succeed resets the login failures of the user.
+func Succeed(ctx *types.DatabaseContext, u *model.User) error {
+	if u.NumWrong == 0 && u.LockExpire == 0 {
+		return nil
+	}
+	return Unlock(ctx, u.ID)
+}
+
+// Unlock unlocks the account and resets the login failures, it's used by the admin.
+func Unlock(ctx *types.DatabaseContext, userID string) error {
+	if err := database.Database[*model.User](ctx).UpdateByID(userID, "num_wrong", 0); err != nil {
+		return errors.Wrap(err, "failed to reset login failures")
+	}
+	if err := database.Database[*model.User](ctx).UpdateByID(userID, "lock_expire", 0); err != nil {
+		return errors.Wrap(err, "failed to unlock account")
+	}
+	return nil
+}
+
+// lockoutDuration returns the duration of the nth lockout.
+func lockoutDuration(n int) time.Duration {
+	cfg := config.App.Auth
+	d := cfg.LockoutDuration
+	for i := 1; i < n && d < math.MaxInt64/2 && (cfg.LockoutMaxDuration <= 0 || d < cfg.LockoutMaxDuration); i++ {
+		d *= 2
+	}
+	if cfg.LockoutMaxDuration > 0 && d > cfg.LockoutMaxDuration {
+		d = cfg.LockoutMaxDuration
+	}
+	return d
+}
@@ -0,0 +1,92 @@
+// Package passwd implements the password policy, the password history and the account
+// lockout of the local users.
+//
+// The policy is configured by the "auth.password_*" settings, the lockout is configured
+// by the "auth.lockout_*" settings.
+package passwd
+
+import (
+	"time"
+	"unicode"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/model"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrPasswordReused = errors.New("password was used recently")
+
+// bcryptCost is the cost of the password hash.
+const bcryptCost = 8
+
+// Hash returns the bcrypt hash of the password.
+func Hash(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+// Compare compares the password with the bcrypt hash, it returns nil on match.
+func Compare(password, hashed string) error {
+	return bcrypt.CompareHashAndPassword([]byte(hashed), []byte(password))
+}
+
+// Validate checks the password against the password policy.
+func Validate(password string) error {
+	cfg := config.App.Auth
+	if len(password) < cfg.PasswordMinLength {
+		return errors.Newf("password must be at least %d characters", cfg.PasswordMinLength)
+	}
+	var (
+		hasNumber      = false
+		hasLowerCase   = false
+		hasUpperCase   = false
+		hasSpecialChar = false
+	)
+	for _, c := range password {
+		switch {
+		case unicode.IsNumber(c):
+			hasNumber = true
+		case unicode.IsLower(c):
+			hasLowerCase = true
+		case unicode.IsUpper(c):
+			hasUpperCase = true
+		case unicode.IsPunct(c) || unicode.IsSymbol(c):
+			hasSpecialChar = true
+		}
+	}
+
+	if cfg.PasswordRequireNumber && !hasNumber {
+		return errors.New("password must contain at least one number")
+	}
+	if cfg.PasswordRequireLower && !hasLowerCase {
+		return errors.New("password must contain at least one lowercase letter")
+	}
+	if cfg.PasswordRequireUpper && !hasUpperCase {
+		return errors.New("password must contain at least one uppercase letter")
+	}
+	if cfg.PasswordRequireSpecial && !hasSpecialChar {
+		return errors.New("password must contain at least one special character")
+	}
+	return nil
+}
+
+// Expired reports whether the password of the user is expired.
+// The password never changed is aged from the user creation.
+func Expired(u *model.User) bool {
+	expire := config.App.Auth.PasswordExpireDuration
+	if expire <= 0 {
+		return false
+	}
+	changedAt := u.GetCreatedAt()
+	if u.LastPasswordChangeAt != nil {
+		changedAt = time.Time(*u.LastPasswordChangeAt)
+	}
+	if changedAt.IsZero() {
+		return false
+	}
+	return time.Since(changedAt) > expire
+}
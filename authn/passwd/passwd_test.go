@@ -0,0 +1,117 @@
+package passwd_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/forbearing/gst/authn/passwd"
+	"github.com/forbearing/gst/bootstrap"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	os.Setenv(config.LOGGER_DIR, "/tmp/test_passwd")
+	os.Setenv(config.DATABASE_TYPE, string(config.DBSqlite))
+	os.Setenv(config.SQLITE_IS_MEMORY, "false")
+	os.Setenv(config.SQLITE_PATH, "/tmp/test_passwd.db")
+	_ = os.Remove("/tmp/test_passwd.db")
+
+	model.Register[*model.User]()
+
+	if err := bootstrap.Bootstrap(); err != nil {
+		panic(err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	defer func(cfg config.Auth) { config.App.Auth = cfg }(config.App.Auth)
+	require.NoError(t, passwd.Validate("Passw0rd!"))
+	require.Error(t, passwd.Validate("Pa0!"))
+	require.Error(t, passwd.Validate("password0!"))
+	require.Error(t, passwd.Validate("Password!!"))
+
+	config.App.Auth.PasswordMinLength = 12
+	config.App.Auth.PasswordRequireSpecial = false
+	require.NoError(t, passwd.Validate("Password0000"))
+	require.Error(t, passwd.Validate("Password000"))
+}
+
+func TestExpired(t *testing.T) {
+	defer func(cfg config.Auth) { config.App.Auth = cfg }(config.App.Auth)
+	u := &model.User{LastPasswordChangeAt: util.ValueOf(model.GormTime(time.Now().Add(-48 * time.Hour)))}
+	assert.False(t, passwd.Expired(u))
+	config.App.Auth.PasswordExpireDuration = 24 * time.Hour
+	assert.True(t, passwd.Expired(u))
+	u.LastPasswordChangeAt = util.ValueOf(model.GormTime(time.Now()))
+	assert.False(t, passwd.Expired(u))
+}
+
+func TestHistory(t *testing.T) {
+	defer func(cfg config.Auth) { config.App.Auth = cfg }(config.App.Auth)
+	config.App.Auth.PasswordHistory = 2
+	ctx := &types.DatabaseContext{}
+
+	hash := func(p string) string {
+		h, err := passwd.Hash(p)
+		require.NoError(t, err)
+		return h
+	}
+	u := &model.User{Name: "history", Password: hash("Passw0rd!1")}
+	require.NoError(t, database.Database[*model.User](nil).Create(u))
+
+	// Change the password twice: 1 -> 2 -> 3.
+	for _, p := range []string{"Passw0rd!2", "Passw0rd!3"} {
+		require.NoError(t, passwd.CheckHistory(ctx, u, p))
+		require.NoError(t, passwd.Remember(ctx, u.ID, u.Password))
+		u.Password = hash(p)
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.ErrorIs(t, passwd.CheckHistory(ctx, u, "Passw0rd!3"), passwd.ErrPasswordReused)
+	require.ErrorIs(t, passwd.CheckHistory(ctx, u, "Passw0rd!2"), passwd.ErrPasswordReused)
+	require.ErrorIs(t, passwd.CheckHistory(ctx, u, "Passw0rd!1"), passwd.ErrPasswordReused)
+
+	// The history beyond the depth is purged.
+	require.NoError(t, passwd.Remember(ctx, u.ID, u.Password))
+	u.Password = hash("Passw0rd!4")
+	require.NoError(t, passwd.CheckHistory(ctx, u, "Passw0rd!1"))
+	require.ErrorIs(t, passwd.CheckHistory(ctx, u, "Passw0rd!2"), passwd.ErrPasswordReused)
+}
+
+func TestLockout(t *testing.T) {
+	defer func(cfg config.Auth) { config.App.Auth = cfg }(config.App.Auth)
+	config.App.Auth.LockoutThreshold = 3
+	config.App.Auth.LockoutDuration = time.Minute
+	config.App.Auth.LockoutMaxDuration = 3 * time.Minute
+	ctx := &types.DatabaseContext{}
+
+	u := &model.User{Name: "lockout"}
+	require.NoError(t, database.Database[*model.User](nil).Create(u))
+
+	// The lockout duration doubles at every lockout up to the max duration.
+	for _, expected := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute} {
+		for range 2 {
+			until, err := passwd.Fail(ctx, u)
+			require.NoError(t, err)
+			assert.True(t, until.IsZero())
+		}
+		until, err := passwd.Fail(ctx, u)
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(expected), until, 2*time.Second)
+		_, locked := passwd.Locked(u)
+		assert.True(t, locked)
+	}
+
+	require.NoError(t, passwd.Unlock(ctx, u.ID))
+	unlocked := new(model.User)
+	require.NoError(t, database.Database[*model.User](nil).Get(unlocked, u.ID))
+	_, locked := passwd.Locked(unlocked)
+	assert.False(t, locked)
+	assert.Zero(t, unlocked.NumWrong)
+}
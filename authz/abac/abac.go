@@ -0,0 +1,396 @@
+// Package abac implements the attribute-based authorization on top of casbin rbac.
+//
+// The casbin policies decide whether the subject can access the api (sub, path, method),
+// the abac policies decide which records of the model and which fields of the record the
+// subject can access:
+//   - The row conditions reference the record attributes, eg: owner == subject, department match.
+//     They're translated into types.Filter and pushed into database.WithQuery by the controllers,
+//     so the records not granted are never loaded.
+//   - The read masks hide the fields from the responses, and the fields can't be queried or sorted by.
+//   - The write masks keep the stored values of the fields in the updates.
+//
+// The policies are registered per model and granted to roles:
+//
+//	abac.Register[*model.Order](
+//		&abac.Policy{Roles: []string{"sales"}, Rows: abac.Owner("created_by"), ReadDeny: []string{"cost"}, WriteDeny: []string{"price"}},
+//		&abac.Policy{Roles: []string{"manager"}, Rows: abac.Match("department_id", "department_id")},
+//	)
+//	abac.RegisterAttributes(func(ctx context.Context, s *abac.Subject) (map[string]string, error) {
+//		return map[string]string{"department_id": lookupDepartment(s.UserID)}, nil
+//	})
+//
+// The models without policies are not restricted. The root and the users having the "admin"
+// role are never restricted.
+package abac
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/authz/rbac"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+	"github.com/stoewer/go-strcase"
+)
+
+// AnyRole grants the policy to all subjects.
+const AnyRole = "*"
+
+// ErrReadDenied is returned by Decision.CheckQuery if the query references the read denied fields.
+var ErrReadDenied = errors.New("read denied field can't be queried")
+
+var (
+	mu         sync.RWMutex
+	policies   = make(map[reflect.Type][]*Policy)
+	attributes AttributeFunc
+)
+
+// Subject is the user the policies evaluated for.
+type Subject struct {
+	UserID   string
+	Username string
+	TenantID string
+	Roles    []string
+	// Attrs are the subject attributes referenced by the row conditions, eg: "department_id".
+	// They're loaded by the function registered by RegisterAttributes.
+	Attrs map[string]string
+}
+
+// AttributeFunc loads the attributes of the subject.
+type AttributeFunc func(ctx context.Context, s *Subject) (map[string]string, error)
+
+// Condition builds the row filter of the subject, ok is false if no records granted,
+// the nil filter grants all records.
+type Condition func(s *Subject) (f *types.Filter, ok bool)
+
+// Policy grants the records and fields of the model to the subjects having any of the roles.
+type Policy struct {
+	// Roles the policy granted to, AnyRole for all subjects.
+	Roles []string
+	// Rows restricts the records granted, nil grants all records.
+	Rows Condition
+	// ReadDeny are the fields hidden from the responses.
+	ReadDeny []string
+	// WriteDeny are the fields can't be modified. The full update writes the fields hidden
+	// from responses as zero values, so the read denied fields are usually write denied too.
+	WriteDeny []string
+}
+
+// Register registers the policies of model M, the policies of the same model are appended.
+// The fields are the column names, json names or structure field names of the model.
+func Register[M types.Model](p ...*Policy) {
+	mu.Lock()
+	defer mu.Unlock()
+	typ := reflect.TypeFor[M]()
+	for _, policy := range p {
+		if policy != nil {
+			policies[typ] = append(policies[typ], policy)
+		}
+	}
+}
+
+// RegisterAttributes registers the function loading the subject attributes.
+func RegisterAttributes(fn AttributeFunc) {
+	mu.Lock()
+	defer mu.Unlock()
+	attributes = fn
+}
+
+// Registered reports whether model M has any policy.
+func Registered[M types.Model]() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return len(policies[reflect.TypeFor[M]()]) > 0
+}
+
+// NewSubject returns the subject with its roles, including the inherited ones, and attributes.
+// The casbin subject is the username for root and admin, otherwise the user id, see middleware.Authz.
+func NewSubject(ctx context.Context, userID, username, tenantID string) (*Subject, error) {
+	s := &Subject{UserID: userID, Username: username, TenantID: tenantID}
+	sub := userID
+	if username == consts.ROOT || username == consts.ADMIN {
+		sub = username
+	}
+	if rbac.Enforcer != nil && len(sub) > 0 {
+		roles, err := rbac.Enforcer.GetImplicitRolesForUser(sub)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get subject roles")
+		}
+		s.Roles = roles
+	}
+	mu.RLock()
+	fn := attributes
+	mu.RUnlock()
+	if fn != nil {
+		attrs, err := fn(ctx, s)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load subject attributes")
+		}
+		s.Attrs = attrs
+	}
+	return s, nil
+}
+
+// Admin reports whether the subject is never restricted.
+func (s *Subject) Admin() bool {
+	return s.Username == consts.ROOT || slices.Contains(s.Roles, consts.ADMIN)
+}
+
+// Owner grants the records whose field equals the subject user id.
+func Owner(field string) Condition {
+	return func(s *Subject) (*types.Filter, bool) {
+		if len(s.UserID) == 0 {
+			return nil, false
+		}
+		return &types.Filter{Field: field, Op: types.FilterEq, Values: []string{s.UserID}}, true
+	}
+}
+
+// Match grants the records whose field equals the subject attribute, eg: department match.
+// No records granted if the subject hasn't the attribute.
+func Match(field, attr string) Condition {
+	return func(s *Subject) (*types.Filter, bool) {
+		val := s.Attrs[attr]
+		if len(val) == 0 {
+			return nil, false
+		}
+		return &types.Filter{Field: field, Op: types.FilterEq, Values: []string{val}}, true
+	}
+}
+
+// Decision is the result of the policies of a model evaluated for a subject.
+// The nil Decision doesn't restrict anything.
+type Decision struct {
+	// Denied is true if no records granted.
+	Denied bool
+	// Rows is the filter of the records granted, nil for all records.
+	Rows *types.Filter
+	// ReadDeny and WriteDeny are the fields denied by all the policies applied.
+	ReadDeny  []string
+	WriteDeny []string
+}
+
+// Evaluate evaluates the policies of model M for the subject, it returns nil if model M has
+// no policies or the subject is admin. The policies applied are combined permissively:
+// the records granted by any policy are accessible, the fields are denied only if denied by all
+// the policies granting records. The subject is denied if no records granted.
+func Evaluate[M types.Model](s *Subject) *Decision {
+	mu.RLock()
+	ps := policies[reflect.TypeFor[M]()]
+	mu.RUnlock()
+	if len(ps) == 0 || s == nil || s.Admin() {
+		return nil
+	}
+
+	d := new(Decision)
+	rows := make([]*types.Filter, 0, len(ps))
+	granted, unrestricted := false, false
+	for _, p := range ps {
+		if !slices.Contains(p.Roles, AnyRole) && !slices.ContainsFunc(p.Roles, func(r string) bool { return slices.Contains(s.Roles, r) }) {
+			continue
+		}
+		var f *types.Filter
+		if p.Rows != nil {
+			var ok bool
+			if f, ok = p.Rows(s); !ok {
+				continue
+			}
+		}
+		if f.IsEmpty() {
+			unrestricted = true
+		} else {
+			rows = append(rows, f)
+		}
+		if !granted {
+			d.ReadDeny, d.WriteDeny = slices.Clone(p.ReadDeny), slices.Clone(p.WriteDeny)
+		} else {
+			d.ReadDeny = intersect(d.ReadDeny, p.ReadDeny)
+			d.WriteDeny = intersect(d.WriteDeny, p.WriteDeny)
+		}
+		granted = true
+	}
+	if !granted {
+		return &Decision{Denied: true}
+	}
+	if !unrestricted {
+		if len(rows) == 1 {
+			d.Rows = rows[0]
+		} else {
+			d.Rows = &types.Filter{Or: rows}
+		}
+	}
+	return d
+}
+
+// Scope combines the filter with the rows granted, the result is passed to
+// types.QueryConfig.Filter.
+func (d *Decision) Scope(f *types.Filter) *types.Filter {
+	if d == nil || d.Rows == nil {
+		return f
+	}
+	if f.IsEmpty() {
+		return d.Rows
+	}
+	return &types.Filter{And: []*types.Filter{f, d.Rows}}
+}
+
+// Restricted reports whether the subject is restricted by the decision.
+func (d *Decision) Restricted() bool {
+	return d != nil && (d.Denied || d.Rows != nil || len(d.ReadDeny) > 0 || len(d.WriteDeny) > 0)
+}
+
+// MaskRead sets the read denied fields of the record to zero value.
+func (d *Decision) MaskRead(m any) {
+	if d == nil || len(d.ReadDeny) == 0 {
+		return
+	}
+	val := reflect.Indirect(reflect.ValueOf(m))
+	if val.Kind() != reflect.Struct {
+		return
+	}
+	for _, path := range fieldPaths(val.Type(), d.ReadDeny) {
+		// The field of the nil embedded pointer is zero already.
+		if field, err := val.FieldByIndexErr(path); err == nil && field.CanSet() {
+			field.SetZero()
+		}
+	}
+}
+
+// CheckQuery returns ErrReadDenied if the query record q, the filter f or the sort order
+// references the read denied fields, otherwise the masked values could be inferred from
+// the records matched or their order.
+func (d *Decision) CheckQuery(q any, f *types.Filter, order string) error {
+	if d == nil || len(d.ReadDeny) == 0 {
+		return nil
+	}
+	val := reflect.Indirect(reflect.ValueOf(q))
+	if val.Kind() != reflect.Struct {
+		return nil
+	}
+	typ := val.Type()
+	for _, path := range fieldPaths(typ, d.ReadDeny) {
+		if field, err := val.FieldByIndexErr(path); err == nil && !field.IsZero() {
+			return errors.Wrap(ErrReadDenied, typ.FieldByIndex(path).Name)
+		}
+	}
+	if name, ok := d.filterDenied(typ, f); ok {
+		return errors.Wrap(ErrReadDenied, name)
+	}
+	for item := range strings.SplitSeq(order, ",") {
+		if fields := strings.Fields(item); len(fields) > 0 && d.readDenied(typ, strings.Trim(fields[0], "`")) {
+			return errors.Wrap(ErrReadDenied, fields[0])
+		}
+	}
+	return nil
+}
+
+// filterDenied returns the first read denied field referenced by the filter.
+// The fields of the associations, eg: "Profile.city", are not restricted by the decision.
+func (d *Decision) filterDenied(typ reflect.Type, f *types.Filter) (string, bool) {
+	if f == nil {
+		return "", false
+	}
+	if name, _, nested := strings.Cut(f.Field, "."); len(name) > 0 && !nested && d.readDenied(typ, name) {
+		return name, true
+	}
+	for _, sub := range slices.Concat(f.And, f.Or) {
+		if name, ok := d.filterDenied(typ, sub); ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// readDenied reports whether the field named by the client, either the column name, json name
+// or structure field name, is read denied.
+func (d *Decision) readDenied(typ reflect.Type, name string) bool {
+	for _, path := range fieldPaths(typ, []string{name, strcase.SnakeCase(name)}) {
+		sf := typ.FieldByIndex(path)
+		if slices.ContainsFunc(d.ReadDeny, func(deny string) bool { return fieldMatch(sf, deny) }) {
+			return true
+		}
+	}
+	return false
+}
+
+// KeepWrite copies the write denied fields from the stored record src to the updated record dst,
+// so the fields are never modified whatever the request provides.
+func (d *Decision) KeepWrite(dst, src any) {
+	if d == nil || len(d.WriteDeny) == 0 {
+		return
+	}
+	dstVal, srcVal := reflect.Indirect(reflect.ValueOf(dst)), reflect.Indirect(reflect.ValueOf(src))
+	if dstVal.Kind() != reflect.Struct || dstVal.Type() != srcVal.Type() {
+		return
+	}
+	for _, path := range fieldPaths(dstVal.Type(), d.WriteDeny) {
+		// The fields are paired by the index path, the path through the nil embedded
+		// pointer of either record is skipped.
+		dstField, err := dstVal.FieldByIndexErr(path)
+		if err != nil || !dstField.CanSet() {
+			continue
+		}
+		srcField, err := srcVal.FieldByIndexErr(path)
+		if err != nil {
+			continue
+		}
+		dstField.Set(srcField)
+	}
+}
+
+// fieldPaths returns the index paths of the exported fields of the structure by column name,
+// json name or structure field name, including the fields of the embedded structures.
+func fieldPaths(typ reflect.Type, names []string) [][]int {
+	paths := make([][]int, 0, len(names))
+	for i := range typ.NumField() {
+		sf := typ.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		if sf.Anonymous {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for _, path := range fieldPaths(ft, names) {
+					paths = append(paths, append([]int{i}, path...))
+				}
+			}
+			continue
+		}
+		if slices.ContainsFunc(names, func(name string) bool { return fieldMatch(sf, name) }) {
+			paths = append(paths, []int{i})
+		}
+	}
+	return paths
+}
+
+func fieldMatch(sf reflect.StructField, name string) bool {
+	if sf.Name == name || strcase.SnakeCase(sf.Name) == name {
+		return true
+	}
+	if jsonName, _, _ := strings.Cut(sf.Tag.Get(consts.TAG_JSON), ","); len(jsonName) > 0 && jsonName != "-" && jsonName == name {
+		return true
+	}
+	for item := range strings.SplitSeq(sf.Tag.Get("gorm"), ";") {
+		if k, v, _ := strings.Cut(item, ":"); strings.EqualFold(strings.TrimSpace(k), "column") && strings.TrimSpace(v) == name {
+			return true
+		}
+	}
+	return false
+}
+
+func intersect(a, b []string) []string {
+	res := make([]string, 0, len(a))
+	for _, s := range a {
+		if slices.Contains(b, s) {
+			res = append(res, s)
+		}
+	}
+	return res
+}
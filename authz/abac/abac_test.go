@@ -0,0 +1,133 @@
+package abac_test
+
+import (
+	"testing"
+
+	"github.com/forbearing/gst/authz/abac"
+	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Order struct {
+	OwnerID      string  `json:"owner_id"`
+	DepartmentID string  `json:"department_id"`
+	Cost         float64 `json:"cost"`
+	Price        float64 `json:"price"`
+
+	model.Base
+}
+
+type Extra struct {
+	Secret string `json:"secret"`
+}
+
+type Invoice struct {
+	*Extra
+	Amount float64 `json:"amount"`
+
+	model.Base
+}
+
+func init() {
+	abac.Register[*Order](
+		&abac.Policy{Roles: []string{"sales"}, Rows: abac.Owner("owner_id"), ReadDeny: []string{"cost"}, WriteDeny: []string{"cost", "Price"}},
+		&abac.Policy{Roles: []string{"manager"}, Rows: abac.Match("department_id", "department_id"), WriteDeny: []string{"cost"}},
+	)
+	abac.Register[*Invoice](&abac.Policy{Roles: []string{"sales"}, ReadDeny: []string{"secret"}, WriteDeny: []string{"secret", "amount"}})
+}
+
+func TestEvaluate(t *testing.T) {
+	assert.Nil(t, abac.Evaluate[*model.User](&abac.Subject{UserID: "u1"}), "model without policies")
+	assert.Nil(t, abac.Evaluate[*Order](&abac.Subject{UserID: "u1", Roles: []string{"admin"}}), "admin")
+
+	d := abac.Evaluate[*Order](&abac.Subject{UserID: "u1"})
+	require.NotNil(t, d)
+	assert.True(t, d.Denied, "no policy applied")
+
+	d = abac.Evaluate[*Order](&abac.Subject{UserID: "u1", Roles: []string{"sales"}})
+	require.NotNil(t, d)
+	assert.False(t, d.Denied)
+	assert.Equal(t, &types.Filter{Field: "owner_id", Op: types.FilterEq, Values: []string{"u1"}}, d.Rows)
+	assert.Equal(t, []string{"cost"}, d.ReadDeny)
+
+	// The manager without department is granted nothing by the department policy.
+	d = abac.Evaluate[*Order](&abac.Subject{UserID: "u1", Roles: []string{"manager"}})
+	assert.True(t, d.Denied)
+
+	// The policies are combined permissively.
+	d = abac.Evaluate[*Order](&abac.Subject{UserID: "u1", Roles: []string{"sales", "manager"}, Attrs: map[string]string{"department_id": "d1"}})
+	require.NotNil(t, d)
+	require.NotNil(t, d.Rows)
+	assert.Len(t, d.Rows.Or, 2)
+	assert.Empty(t, d.ReadDeny)
+	assert.Equal(t, []string{"cost"}, d.WriteDeny)
+
+	// The masks of the policy granting nothing are ignored.
+	d = abac.Evaluate[*Order](&abac.Subject{UserID: "u1", Roles: []string{"sales", "manager"}})
+	require.NotNil(t, d)
+	assert.Equal(t, []string{"cost"}, d.ReadDeny)
+
+	d = abac.Evaluate[*Order](&abac.Subject{UserID: "u1", Roles: []string{"sales", "manager"}, Attrs: map[string]string{"department_id": "d1"}})
+	query := &types.Filter{Field: "price", Op: types.FilterGt, Values: []string{"10"}}
+	assert.Equal(t, &types.Filter{And: []*types.Filter{query, d.Rows}}, d.Scope(query))
+	assert.Equal(t, d.Rows, d.Scope(nil))
+	var nop *abac.Decision
+	assert.Equal(t, query, nop.Scope(query))
+}
+
+func TestMask(t *testing.T) {
+	d := abac.Evaluate[*Order](&abac.Subject{UserID: "u1", Roles: []string{"sales"}})
+	require.NotNil(t, d)
+
+	o := &Order{OwnerID: "u1", Cost: 1, Price: 2}
+	d.MaskRead(o)
+	assert.Equal(t, &Order{OwnerID: "u1", Price: 2}, o)
+
+	stored := &Order{OwnerID: "u1", Cost: 1, Price: 2}
+	stored.Remark = new(string)
+	req := &Order{OwnerID: "u1", Price: 3}
+	d.KeepWrite(req, stored)
+	assert.Equal(t, 1.0, req.Cost)
+	assert.Equal(t, 2.0, req.Price)
+	assert.Nil(t, req.Remark)
+}
+
+func TestMaskEmbeddedPointer(t *testing.T) {
+	d := abac.Evaluate[*Invoice](&abac.Subject{UserID: "u1", Roles: []string{"sales"}})
+	require.NotNil(t, d)
+
+	// The fields are paired by the index path even if the embedded pointer is nil on one side.
+	stored := &Invoice{Extra: &Extra{Secret: "s"}, Amount: 1}
+	req := &Invoice{Amount: 2}
+	d.KeepWrite(req, stored)
+	assert.Nil(t, req.Extra)
+	assert.Equal(t, 1.0, req.Amount)
+
+	req = &Invoice{Extra: &Extra{Secret: "changed"}, Amount: 2}
+	d.KeepWrite(req, &Invoice{Amount: 1})
+	assert.Equal(t, "changed", req.Secret)
+	assert.Equal(t, 1.0, req.Amount)
+	d.KeepWrite(req, stored)
+	assert.Equal(t, "s", req.Secret)
+
+	d.MaskRead(req)
+	assert.Empty(t, req.Secret)
+	d.MaskRead(&Invoice{})
+}
+
+func TestCheckQuery(t *testing.T) {
+	d := abac.Evaluate[*Order](&abac.Subject{UserID: "u1", Roles: []string{"sales"}})
+	require.NotNil(t, d)
+
+	require.NoError(t, d.CheckQuery(&Order{OwnerID: "u1"}, &types.Filter{Field: "price", Op: types.FilterGt, Values: []string{"1"}}, "price desc"))
+	require.ErrorIs(t, d.CheckQuery(&Order{Cost: 1}, nil, ""), abac.ErrReadDenied)
+	require.ErrorIs(t, d.CheckQuery(&Order{}, &types.Filter{Or: []*types.Filter{{Field: "Cost", Op: types.FilterGt, Values: []string{"1"}}}}, ""), abac.ErrReadDenied)
+	require.ErrorIs(t, d.CheckQuery(&Order{}, nil, "price, `cost` DESC"), abac.ErrReadDenied)
+
+	// The embedded pointer is nil.
+	d = abac.Evaluate[*Invoice](&abac.Subject{UserID: "u1", Roles: []string{"sales"}})
+	require.NoError(t, d.CheckQuery(&Invoice{}, nil, "amount"))
+	require.ErrorIs(t, d.CheckQuery(&Invoice{}, nil, "secret"), abac.ErrReadDenied)
+}
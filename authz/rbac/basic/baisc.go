@@ -37,10 +37,10 @@ g = _, _
 
 [policy_effect]
 #e = priority(p.eft) || some(where (p.eft == allow))
-e = some(where (p.eft == allow))
+e = some(where (p.eft == allow)) && !some(where (p.eft == deny))
 
 [matchers]
-m = g(r.sub, "admin") || (g(r.sub, p.sub) && keyMatch3(r.obj, p.obj) && r.act == p.act)
+m = (g(r.sub, "admin") && p.eft != "deny") || (g(r.sub, p.sub) && keyMatch3(r.obj, p.obj) && r.act == p.act)
 `)
 
 func Init() (err error) {
@@ -0,0 +1,156 @@
+package basic_test
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/forbearing/gst/authz/rbac"
+	"github.com/forbearing/gst/bootstrap"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/model"
+	modelauthz "github.com/forbearing/gst/model/authz"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	os.Setenv(config.LOGGER_DIR, "/tmp/test_basic_rbac")
+	os.Setenv(config.DATABASE_TYPE, string(config.DBSqlite))
+	os.Setenv(config.SQLITE_IS_MEMORY, "false")
+	os.Setenv(config.SQLITE_PATH, "/tmp/test_basic_rbac.db")
+	os.Setenv(config.AUTH_RBAC_ENABLE, "true")
+	_ = os.Remove("/tmp/test_basic_rbac.db")
+
+	if err := bootstrap.Bootstrap(); err != nil {
+		panic(err)
+	}
+}
+
+func TestIntrospector(t *testing.T) {
+	_, err := rbac.Enforcer.AddPolicy("editor", "/api/docs/{id}", http.MethodGet, "allow")
+	require.NoError(t, err)
+	_, err = rbac.Enforcer.AddGroupingPolicy("u1", "editor")
+	require.NoError(t, err)
+
+	i := rbac.Introspector()
+	roles, err := i.Roles("u1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"editor"}, roles)
+
+	perms, err := i.Permissions("u1")
+	require.NoError(t, err)
+	assert.Equal(t, []types.PermissionCheck{{Resource: "/api/docs/{id}", Action: http.MethodGet, Allowed: true}}, perms)
+
+	checks, err := i.Can("u1",
+		types.PermissionCheck{Resource: "/api/docs/:id", Action: http.MethodGet},
+		types.PermissionCheck{Resource: "/api/docs/:id", Action: http.MethodDelete},
+	)
+	require.NoError(t, err)
+	assert.True(t, checks[0].Allowed)
+	assert.Equal(t, "/api/docs/{id}", checks[0].Resource)
+	assert.False(t, checks[1].Allowed)
+
+	e, err := i.Explain("u1", "/api/docs/:id", http.MethodGet)
+	require.NoError(t, err)
+	assert.True(t, e.Allowed)
+	assert.Equal(t, []string{"p", "editor", "/api/docs/{id}", http.MethodGet, "allow"}, e.Policy)
+
+	e, err = i.Explain("u1", "/api/users", http.MethodGet)
+	require.NoError(t, err)
+	assert.False(t, e.Allowed)
+	assert.Empty(t, e.Policy)
+
+	e, err = i.Explain(consts.ROOT, "/api/users", http.MethodDelete)
+	require.NoError(t, err)
+	assert.True(t, e.Allowed)
+	assert.Equal(t, "allowed by the admin role", e.Reason)
+}
+
+func TestRoleHierarchy(t *testing.T) {
+	r := rbac.RBAC()
+	require.NoError(t, r.AddRole("viewer"))
+	require.NoError(t, r.AddRole("author", "viewer"))
+	require.Error(t, r.AddRole("viewer", "author")) // cycle
+	require.NoError(t, r.GrantPermission("viewer", "/api/posts/{id}", http.MethodGet))
+	require.NoError(t, r.GrantPermission("viewer", "/api/posts/{id}/comments", http.MethodGet))
+	require.NoError(t, r.GrantPermission("author", "/api/posts/{id}", http.MethodPut))
+	require.NoError(t, r.DenyPermission("author", "/api/posts/{id}/comments", http.MethodGet))
+	require.NoError(t, r.AssignRole("u2", "author"))
+
+	tests := []struct {
+		sub, obj, act string
+		allow         bool
+	}{
+		{"u2", "/api/posts/1", http.MethodGet, true}, // inherited from viewer
+		{"u2", "/api/posts/1", http.MethodPut, true},
+		{"u2", "/api/posts/1/comments", http.MethodGet, false}, // the deny overrides the inherited allow
+		{"author", "/api/posts/1", http.MethodDelete, false},
+		{"viewer", "/api/posts/1/comments", http.MethodGet, true},
+		{consts.ADMIN, "/api/posts/1/comments", http.MethodGet, true}, // the deny of other roles doesn't apply to admin
+	}
+	for _, tt := range tests {
+		allow, err := rbac.Enforcer.Enforce(tt.sub, tt.obj, tt.act)
+		require.NoError(t, err)
+		assert.Equal(t, tt.allow, allow, "%s %s %s", tt.sub, tt.act, tt.obj)
+	}
+
+	e, err := rbac.Introspector().Explain("u2", "/api/posts/:id/comments", http.MethodGet)
+	require.NoError(t, err)
+	assert.Equal(t, "denied by policy", e.Reason)
+	assert.Equal(t, []string{"p", "author", "/api/posts/{id}/comments", http.MethodGet, "deny"}, e.Policy)
+
+	roles, err := r.ListRoles()
+	require.NoError(t, err)
+	assert.Subset(t, roles, []string{consts.ADMIN, "author", "viewer"})
+	members, err := r.ListMembers("viewer")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"author"}, members)
+	grants, err := r.ListGrants("author")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []types.RoleGrant{
+		{Role: "author", Resource: "/api/posts/{id}", Action: http.MethodPut, Effect: "allow"},
+		{Role: "author", Resource: "/api/posts/{id}/comments", Action: http.MethodGet, Effect: "deny"},
+	}, grants)
+
+	// The grants of the parent are not inherited after the parents replaced.
+	require.NoError(t, r.AddRole("author"))
+	allow, err := rbac.Enforcer.Enforce("u2", "/api/posts/1", http.MethodGet)
+	require.NoError(t, err)
+	assert.False(t, allow)
+
+	require.NoError(t, r.RemoveRole("author"))
+	roles, err = r.ListRoles()
+	require.NoError(t, err)
+	assert.NotContains(t, roles, "author")
+	allow, err = rbac.Enforcer.Enforce("u2", "/api/posts/1", http.MethodPut)
+	require.NoError(t, err)
+	assert.False(t, allow)
+}
+
+func TestRoleUpdate(t *testing.T) {
+	role := &modelauthz.Role{Name: "reviewer", Parents: model.GormStrings{"guest"}}
+	require.NoError(t, database.Database[*modelauthz.Role](nil).Create(role))
+	members, err := rbac.RBAC().ListMembers("guest")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"reviewer"}, members)
+
+	// The update without parents keeps the inheritance.
+	remark := "updated"
+	require.NoError(t, database.Database[*modelauthz.Role](nil).Update(&modelauthz.Role{Name: "reviewer", Base: model.Base{ID: role.ID, Remark: &remark}}))
+	members, err = rbac.RBAC().ListMembers("guest")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"reviewer"}, members)
+	stored := new(modelauthz.Role)
+	require.NoError(t, database.Database[*modelauthz.Role](nil).Get(stored, role.ID))
+	assert.Equal(t, model.GormStrings{"guest"}, stored.Parents)
+
+	// The empty parents remove it.
+	require.NoError(t, database.Database[*modelauthz.Role](nil).Update(&modelauthz.Role{Name: "reviewer", Parents: model.GormStrings{}, Base: model.Base{ID: role.ID}}))
+	members, err = rbac.RBAC().ListMembers("guest")
+	require.NoError(t, err)
+	assert.Empty(t, members)
+}
@@ -0,0 +1,231 @@
+package rbac
+
+import (
+	"regexp"
+	"slices"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+)
+
+var ErrRBACDisabled = errors.New("rbac is not enabled")
+
+var ginParamRegexp = regexp.MustCompile(`:([a-zA-Z0-9_]+)`)
+
+// Resource converts the gin route path to the casbin keyMatch3 resource,
+// eg: "/api/user/:id" -> "/api/user/{id}".
+func Resource(ginPath string) string {
+	return ginParamRegexp.ReplaceAllString(ginPath, `{$1}`)
+}
+
+// Subject returns the casbin subject of the user, the username for root and admin,
+// otherwise the user id, see middleware.Authz.
+func Subject(userID, username string) string {
+	if username == consts.ROOT || username == consts.ADMIN {
+		return username
+	}
+	return userID
+}
+
+// Introspector returns the types.RBACIntrospector of the RBAC.
+func Introspector() types.RBACIntrospector {
+	return &rbac{
+		enforcer: Enforcer,
+		addapter: Adapter,
+	}
+}
+
+func (r *rbac) Roles(subject string) ([]string, error) {
+	if r.enforcer == nil {
+		return nil, ErrRBACDisabled
+	}
+	roles, err := r.enforcer.GetImplicitRolesForUser(subject)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get roles of %q", subject)
+	}
+	return roles, nil
+}
+
+func (r *rbac) Permissions(subject string) ([]types.PermissionCheck, error) {
+	if r.enforcer == nil {
+		return nil, ErrRBACDisabled
+	}
+	policies, err := r.enforcer.GetImplicitPermissionsForUser(subject)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get permissions of %q", subject)
+	}
+	perms := make([]types.PermissionCheck, 0, len(policies))
+	for _, p := range policies {
+		// p is (sub, obj, act, eft)
+		if len(p) < 3 || (len(p) > 3 && p[3] != "allow") {
+			continue
+		}
+		perm := types.PermissionCheck{Resource: p[1], Action: p[2], Allowed: true}
+		if !slices.Contains(perms, perm) {
+			perms = append(perms, perm)
+		}
+	}
+	return perms, nil
+}
+
+func (r *rbac) Can(subject string, checks ...types.PermissionCheck) ([]types.PermissionCheck, error) {
+	if r.enforcer == nil {
+		return nil, ErrRBACDisabled
+	}
+	res := make([]types.PermissionCheck, len(checks))
+	for i, check := range checks {
+		check.Resource = Resource(check.Resource)
+		allowed, err := r.enforcer.Enforce(subject, check.Resource, check.Action)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to enforce %s %s", check.Action, check.Resource)
+		}
+		check.Allowed = allowed
+		res[i] = check
+	}
+	return res, nil
+}
+
+func (r *rbac) Explain(subject, resource, action string) (*types.PermissionExplanation, error) {
+	roles, err := r.Roles(subject)
+	if err != nil {
+		return nil, err
+	}
+	e := &types.PermissionExplanation{Subject: subject, Resource: Resource(resource), Action: action, Roles: roles}
+	allowed, explain, err := r.enforcer.EnforceEx(subject, e.Resource, action)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to enforce %s %s", action, e.Resource)
+	}
+	explainReason(e, allowed, subject == consts.ADMIN || slices.Contains(roles, consts.ADMIN), explain)
+	return e, nil
+}
+
+// explainReason sets the decision and the reason of the explanation, explain is the policy
+// matched by casbin EnforceEx.
+func explainReason(e *types.PermissionExplanation, allowed, admin bool, explain []string) {
+	e.Allowed = allowed
+	switch {
+	case allowed && admin:
+		e.Reason = "allowed by the admin role"
+	case allowed && len(explain) > 0:
+		e.Reason = "allowed by policy"
+		e.Policy = append([]string{"p"}, explain...)
+	case allowed:
+		e.Reason = "allowed"
+	case len(explain) > 0:
+		e.Reason = "denied by policy"
+		e.Policy = append([]string{"p"}, explain...)
+	default:
+		e.Reason = "denied by default, no policy matched"
+	}
+}
+
+type tenantIntrospector struct {
+	enforcer *casbin.Enforcer
+	tenant   string
+}
+
+// TenantIntrospector returns the types.RBACIntrospector of the tenant RBAC in the tenant,
+// the decisions are the same as EnforceTenant.
+func TenantIntrospector(tenant string) types.RBACIntrospector {
+	return &tenantIntrospector{enforcer: TenantEnforcer, tenant: tenant}
+}
+
+// Roles returns the roles of the subject in the tenant, and the super admin role if the
+// subject is the super admin.
+func (r *tenantIntrospector) Roles(subject string) ([]string, error) {
+	if r.enforcer == nil {
+		return nil, ErrTenantRBACDisabled
+	}
+	roles := make([]string, 0)
+	if slices.Contains(r.enforcer.GetRolesForUserInDomain(subject, AllTenants), SuperAdmin) {
+		roles = append(roles, SuperAdmin)
+	}
+	if len(r.tenant) == 0 {
+		return roles, nil
+	}
+	implicit, err := r.enforcer.GetImplicitRolesForUser(subject, r.tenant)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get roles of %q in tenant %q", subject, r.tenant)
+	}
+	for _, role := range implicit {
+		if !slices.Contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+	return roles, nil
+}
+
+func (r *tenantIntrospector) Permissions(subject string) ([]types.PermissionCheck, error) {
+	if r.enforcer == nil {
+		return nil, ErrTenantRBACDisabled
+	}
+	perms := make([]types.PermissionCheck, 0)
+	if len(r.tenant) == 0 {
+		return perms, nil
+	}
+	roles, err := r.enforcer.GetImplicitRolesForUser(subject, r.tenant)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get roles of %q in tenant %q", subject, r.tenant)
+	}
+	// The policies are filtered by the tenant field, it's not the casbin domain field "dom".
+	policies := make([][]string, 0)
+	for _, sub := range append([]string{subject}, roles...) {
+		filtered, err := r.enforcer.GetFilteredPolicy(0, r.tenant, sub)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get permissions of %q in tenant %q", sub, r.tenant)
+		}
+		policies = append(policies, filtered...)
+	}
+	for _, p := range policies {
+		// p is (tenant, sub, obj, act, eft)
+		if len(p) < 4 || p[0] != r.tenant || (len(p) > 4 && p[4] != "allow") {
+			continue
+		}
+		perm := types.PermissionCheck{Resource: p[2], Action: p[3], Allowed: true}
+		if !slices.Contains(perms, perm) {
+			perms = append(perms, perm)
+		}
+	}
+	return perms, nil
+}
+
+func (r *tenantIntrospector) Can(subject string, checks ...types.PermissionCheck) ([]types.PermissionCheck, error) {
+	if r.enforcer == nil {
+		return nil, ErrTenantRBACDisabled
+	}
+	res := make([]types.PermissionCheck, len(checks))
+	for i, check := range checks {
+		check.Resource = Resource(check.Resource)
+		allowed, _, err := r.enforce(subject, check.Resource, check.Action)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to enforce %s %s", check.Action, check.Resource)
+		}
+		check.Allowed = allowed
+		res[i] = check
+	}
+	return res, nil
+}
+
+func (r *tenantIntrospector) Explain(subject, resource, action string) (*types.PermissionExplanation, error) {
+	roles, err := r.Roles(subject)
+	if err != nil {
+		return nil, err
+	}
+	e := &types.PermissionExplanation{Subject: subject, Resource: Resource(resource), Action: action, Roles: roles}
+	allowed, explain, err := r.enforce(subject, e.Resource, action)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to enforce %s %s", action, e.Resource)
+	}
+	explainReason(e, allowed, slices.Contains(roles, SuperAdmin) || slices.Contains(roles, TenantAdmin), explain)
+	return e, nil
+}
+
+// enforce enforces the request in the tenant, only the super admin is allowed without tenant.
+func (r *tenantIntrospector) enforce(subject, resource, action string) (bool, []string, error) {
+	if len(r.tenant) == 0 {
+		return slices.Contains(r.enforcer.GetRolesForUserInDomain(subject, AllTenants), SuperAdmin), nil, nil
+	}
+	return r.enforcer.EnforceEx(r.tenant, subject, resource, action)
+}
@@ -1,8 +1,11 @@
 package rbac
 
 import (
+	"slices"
+
 	"github.com/casbin/casbin/v2"
 	gormadapter "github.com/casbin/gorm-adapter/v3"
+	"github.com/cockroachdb/errors"
 	"github.com/forbearing/gst/types"
 )
 
@@ -16,6 +19,24 @@ type rbac struct {
 	addapter *gormadapter.Adapter
 }
 
+const (
+	effectAllow = "allow"
+	effectDeny  = "deny"
+)
+
+// RoleStore persists the role objects, it's registered by package model/authz
+// so the roles exist even if they have no grants or members.
+type RoleStore interface {
+	Save(name string, parents []string) error
+	Delete(name string) error
+	List() ([]string, error)
+}
+
+var roleStore RoleStore
+
+// RegisterRoleStore registers the store persisting the role objects.
+func RegisterRoleStore(s RoleStore) { roleStore = s }
+
 func RBAC() types.RBAC {
 	return &rbac{
 		enforcer: Enforcer,
@@ -23,27 +44,86 @@ func RBAC() types.RBAC {
 	}
 }
 
-// AddRole is a no-op in Casbin, roles are created implicitly when used.
-func (r *rbac) AddRole(name string) error {
+// AddRole persists the role and replaces its parents, the role inherits the permissions
+// of the parents by casbin grouping policy (role, parent).
+func (r *rbac) AddRole(name string, parents ...string) error {
+	parents = slices.Compact(slices.Sorted(slices.Values(parents)))
+	if r.enforcer != nil {
+		for _, parent := range parents {
+			if parent == name {
+				return errors.Newf("role %q can't inherit itself", name)
+			}
+			ancestors, err := r.enforcer.GetImplicitRolesForUser(parent)
+			if err != nil {
+				return err
+			}
+			if slices.Contains(ancestors, name) {
+				return errors.Newf("role %q inheriting %q makes a cycle", name, parent)
+			}
+		}
+		current, err := r.enforcer.GetRolesForUser(name)
+		if err != nil {
+			return err
+		}
+		for _, parent := range current {
+			if !slices.Contains(parents, parent) {
+				if _, err = r.enforcer.DeleteRoleForUser(name, parent); err != nil {
+					return err
+				}
+			}
+		}
+		for _, parent := range parents {
+			if _, err = r.enforcer.AddRoleForUser(name, parent); err != nil {
+				return err
+			}
+		}
+		if err = r.enforcer.SavePolicy(); err != nil {
+			return err
+		}
+	}
+	if roleStore != nil {
+		return roleStore.Save(name, parents)
+	}
 	return nil
 }
 
+// RemoveRole removes the role with its grants, members and parents.
 func (r *rbac) RemoveRole(name string) error {
-	if _, err := r.enforcer.DeleteRole(name); err != nil {
-		return err
+	if r.enforcer != nil {
+		if _, err := r.enforcer.DeleteRole(name); err != nil {
+			return err
+		}
+		if err := r.enforcer.SavePolicy(); err != nil {
+			return err
+		}
 	}
-	return r.enforcer.SavePolicy()
+	if roleStore != nil {
+		return roleStore.Delete(name)
+	}
+	return nil
 }
 
 func (r *rbac) GrantPermission(role string, resource string, action string) error {
-	if _, err := r.enforcer.AddPermissionForUser(role, resource, action, "allow"); err != nil {
+	return r.setPermission(role, resource, action, effectAllow)
+}
+
+func (r *rbac) DenyPermission(role string, resource string, action string) error {
+	return r.setPermission(role, resource, action, effectDeny)
+}
+
+// setPermission replaces the effect of the permission (role, resource, action).
+func (r *rbac) setPermission(role, resource, action, effect string) error {
+	if _, err := r.enforcer.RemoveFilteredPolicy(0, role, resource, action); err != nil {
+		return err
+	}
+	if _, err := r.enforcer.AddPermissionForUser(role, resource, action, effect); err != nil {
 		return err
 	}
 	return r.enforcer.SavePolicy()
 }
 
 func (r *rbac) RevokePermission(role string, resource string, action string) error {
-	if _, err := r.enforcer.DeletePermissionForUser(role, resource, action, "allow"); err != nil {
+	if _, err := r.enforcer.RemoveFilteredPolicy(0, role, resource, action); err != nil {
 		return err
 	}
 	return r.enforcer.SavePolicy()
@@ -63,6 +143,56 @@ func (r *rbac) UnassignRole(subject string, role string) error {
 	return r.enforcer.SavePolicy()
 }
 
+// ListRoles returns the roles persisted, inherited or granted permissions.
+func (r *rbac) ListRoles() ([]string, error) {
+	roles := make([]string, 0)
+	if roleStore != nil {
+		names, err := roleStore.List()
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, names...)
+	}
+	if r.enforcer != nil {
+		names, err := r.enforcer.GetAllRoles()
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, names...)
+		if names, err = r.enforcer.GetAllSubjects(); err != nil {
+			return nil, err
+		}
+		roles = append(roles, names...)
+	}
+	return slices.Compact(slices.Sorted(slices.Values(roles))), nil
+}
+
+func (r *rbac) ListMembers(role string) ([]string, error) {
+	if r.enforcer == nil {
+		return nil, ErrRBACDisabled
+	}
+	return r.enforcer.GetUsersForRole(role)
+}
+
+func (r *rbac) ListGrants(role string) ([]types.RoleGrant, error) {
+	if r.enforcer == nil {
+		return nil, ErrRBACDisabled
+	}
+	policies, err := r.enforcer.GetFilteredPolicy(0, role)
+	if err != nil {
+		return nil, err
+	}
+	grants := make([]types.RoleGrant, 0, len(policies))
+	for _, p := range policies {
+		// p is (sub, obj, act, eft)
+		if len(p) < 4 {
+			continue
+		}
+		grants = append(grants, types.RoleGrant{Role: p[0], Resource: p[1], Action: p[2], Effect: p[3]})
+	}
+	return grants, nil
+}
+
 // | 操作             | 函数                                  |
 // | ---------------- | ------------------------------------- |
 // | 添加角色权限     | `AddPolicy(role, obj, act)`           |
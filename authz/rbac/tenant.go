@@ -0,0 +1,150 @@
+package rbac
+
+import (
+	"slices"
+
+	"github.com/casbin/casbin/v2"
+	gormadapter "github.com/casbin/gorm-adapter/v3"
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+)
+
+const (
+	// SuperAdmin is the role granted all the permissions in every tenant.
+	SuperAdmin = "super_admin"
+	// TenantAdmin is the role granted all the permissions in its tenant.
+	TenantAdmin = consts.ADMIN
+	// AllTenants is the tenant of the super admin assignments.
+	AllTenants = "*"
+)
+
+// TenantEnforcer and TenantAdapter are initialized by package authz/rbac/tenant.
+var (
+	TenantEnforcer *casbin.Enforcer
+	TenantAdapter  *gormadapter.Adapter
+)
+
+var ErrTenantRBACDisabled = errors.New("tenant rbac is not enabled")
+
+// TenantRoleStore persists the tenant role objects, it's registered by package model/authz
+// so the roles exist even if they have no grants or members.
+type TenantRoleStore interface {
+	Save(tenant, name string) error
+	Delete(tenant, name string) error
+}
+
+var tenantRoleStore TenantRoleStore
+
+// RegisterTenantRoleStore registers the store persisting the tenant role objects.
+func RegisterTenantRoleStore(s TenantRoleStore) { tenantRoleStore = s }
+
+type tenantRBAC struct {
+	enforcer *casbin.Enforcer
+}
+
+// TenantRBAC returns the types.TenantRBAC whose roles, grants and assignments are scoped by tenant.
+func TenantRBAC() types.TenantRBAC {
+	return &tenantRBAC{enforcer: TenantEnforcer}
+}
+
+// AddRole persists the role in the tenant, casbin has no role object and
+// creates the roles implicitly by the grants and assignments.
+func (r *tenantRBAC) AddRole(tenant, name string) error {
+	if err := r.check(tenant); err != nil {
+		return err
+	}
+	if tenantRoleStore != nil {
+		return tenantRoleStore.Save(tenant, name)
+	}
+	return nil
+}
+
+// RemoveRole removes the role with its grants and assignments in the tenant.
+func (r *tenantRBAC) RemoveRole(tenant, name string) error {
+	if err := r.check(tenant); err != nil {
+		return err
+	}
+	if _, err := r.enforcer.RemoveFilteredGroupingPolicy(1, name, tenant); err != nil {
+		return err
+	}
+	if _, err := r.enforcer.RemoveFilteredPolicy(0, tenant, name); err != nil {
+		return err
+	}
+	if tenantRoleStore != nil {
+		return tenantRoleStore.Delete(tenant, name)
+	}
+	return nil
+}
+
+func (r *tenantRBAC) GrantPermission(tenant, role, resource, action string) error {
+	if err := r.check(tenant); err != nil {
+		return err
+	}
+	_, err := r.enforcer.AddPolicy(tenant, role, resource, action, "allow")
+	return err
+}
+
+func (r *tenantRBAC) RevokePermission(tenant, role, resource, action string) error {
+	if err := r.check(tenant); err != nil {
+		return err
+	}
+	_, err := r.enforcer.RemovePolicy(tenant, role, resource, action, "allow")
+	return err
+}
+
+func (r *tenantRBAC) AssignRole(tenant, subject, role string) error {
+	if err := r.check(tenant); err != nil {
+		return err
+	}
+	_, err := r.enforcer.AddGroupingPolicy(subject, role, tenant)
+	return err
+}
+
+func (r *tenantRBAC) UnassignRole(tenant, subject, role string) error {
+	if err := r.check(tenant); err != nil {
+		return err
+	}
+	_, err := r.enforcer.RemoveGroupingPolicy(subject, role, tenant)
+	return err
+}
+
+// check makes sure the grants and assignments are never written to all tenants,
+// only the super admin assignments are.
+func (r *tenantRBAC) check(tenant string) error {
+	if r.enforcer == nil {
+		return ErrTenantRBACDisabled
+	}
+	if len(tenant) == 0 || tenant == AllTenants {
+		return errors.Newf("invalid tenant %q", tenant)
+	}
+	return nil
+}
+
+// EnforceTenant reports whether the subject can access the resource by the action in the tenant.
+// Only the super admin can access without tenant.
+func EnforceTenant(tenant, subject, resource, action string) (bool, error) {
+	if TenantEnforcer == nil {
+		return false, ErrTenantRBACDisabled
+	}
+	if len(tenant) == 0 {
+		return IsSuperAdmin(subject), nil
+	}
+	return TenantEnforcer.Enforce(tenant, subject, resource, action)
+}
+
+// IsSuperAdmin reports whether the subject is the super admin of all tenants.
+func IsSuperAdmin(subject string) bool {
+	if TenantEnforcer == nil {
+		return false
+	}
+	return slices.Contains(TenantEnforcer.GetRolesForUserInDomain(subject, AllTenants), SuperAdmin)
+}
+
+// TenantRoles returns the roles of the subject in the tenant.
+func TenantRoles(tenant, subject string) []string {
+	if TenantEnforcer == nil {
+		return nil
+	}
+	return TenantEnforcer.GetRolesForUserInDomain(subject, tenant)
+}
@@ -1,5 +1,35 @@
+// Package tenant initializes the multi-tenant RBAC, see rbac.TenantRBAC.
+// The roles, grants and assignments are scoped by tenant:
+//   - The subject is granted the permissions of its roles in the tenant of the request only.
+//   - The subject having role "admin" in a tenant is granted all the permissions in the tenant.
+//   - The subject having role "super_admin" in all tenants "*" is granted all the permissions
+//     in every tenant, the root is the super admin by default.
+//
+// The policies are stored in table "casbin_tenant_rule", separated from the policies of package basic.
+// It's enabled by config "auth.tenant_rbac_enable", see middleware.TenantAuthz.
 package tenant
 
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/casbin/casbin/v2"
+	gormadapter "github.com/casbin/gorm-adapter/v3"
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/authz/rbac"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/logger"
+	modelauthz "github.com/forbearing/gst/model/authz"
+	"github.com/forbearing/gst/types/consts"
+)
+
+const tableName = "casbin_tenant_rule"
+
+var defaultSuperAdmins = []string{
+	consts.ROOT,
+}
+
 var modelData = []byte(`
 [request_definition]
 r = tenant, sub, obj, act
@@ -14,12 +44,40 @@ g = _, _, _
 e = some(where (p.eft == allow))
 
 [matchers]
-m = (g(r.sub, "super_admin", "*") || r.tenant == p.tenant) &&
-    g(r.sub, p.sub, p.tenant) &&
-    keyMatch3(r.obj, p.obj) &&
-    r.act == p.act
+m = g(r.sub, "super_admin", "*") || g(r.sub, "admin", r.tenant) || \
+    (r.tenant == p.tenant && g(r.sub, p.sub, r.tenant) && keyMatch3(r.obj, p.obj) && r.act == p.act)
 `)
 
-func Init() error {
+func Init() (err error) {
+	if !config.App.Auth.TenantRBACEnable {
+		return nil
+	}
+
+	filename := filepath.Join(config.Tempdir(), "casbin_tenant_model.conf")
+	if err = os.WriteFile(filename, modelData, 0o600); err != nil {
+		return errors.Wrapf(err, "failed to write model file %s", filename)
+	}
+	if rbac.TenantAdapter, err = gormadapter.NewAdapterByDBWithCustomTable(database.DB, new(modelauthz.CasbinRule), tableName); err != nil {
+		return errors.Wrap(err, "failed to create casbin adapter")
+	}
+	if rbac.TenantEnforcer, err = casbin.NewEnforcer(filename, rbac.TenantAdapter); err != nil {
+		return errors.Wrap(err, "failed to create casbin enforcer")
+	}
+
+	rbac.TenantEnforcer.SetLogger(logger.Casbin)
+	rbac.TenantEnforcer.EnableLog(true)
+	rbac.TenantEnforcer.EnableAutoSave(true)
+	rbac.TenantEnforcer.EnableAutoNotifyDispatcher(true)
+	rbac.TenantEnforcer.EnableAutoNotifyWatcher(true)
+	rbac.TenantEnforcer.EnableEnforce(true)
+
+	if err = rbac.TenantEnforcer.LoadPolicy(); err != nil {
+		return errors.Wrap(err, "failed to load casbin policy")
+	}
+	for _, user := range defaultSuperAdmins {
+		if _, err = rbac.TenantEnforcer.AddGroupingPolicy(user, rbac.SuperAdmin, rbac.AllTenants); err != nil {
+			return errors.Wrap(err, "failed to add super admin")
+		}
+	}
 	return nil
 }
@@ -0,0 +1,207 @@
+package tenant_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/forbearing/gst/authz/rbac"
+	"github.com/forbearing/gst/bootstrap"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/middleware"
+	"github.com/forbearing/gst/model"
+	modelauthz "github.com/forbearing/gst/model/authz"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	os.Setenv(config.LOGGER_DIR, "/tmp/test_tenant_rbac")
+	os.Setenv(config.DATABASE_TYPE, string(config.DBSqlite))
+	os.Setenv(config.SQLITE_IS_MEMORY, "false")
+	os.Setenv(config.SQLITE_PATH, "/tmp/test_tenant_rbac.db")
+	os.Setenv(config.AUTH_TENANT_RBAC_ENABLE, "true")
+	model.Register[*model.User]()
+	_ = os.Remove("/tmp/test_tenant_rbac.db")
+
+	if err := bootstrap.Bootstrap(); err != nil {
+		panic(err)
+	}
+}
+
+func TestEnforceTenant(t *testing.T) {
+	r := rbac.TenantRBAC()
+	require.NoError(t, r.GrantPermission("t1", "editor", "/api/docs/{id}", http.MethodGet))
+	require.NoError(t, r.AssignRole("t1", "u1", "editor"))
+	require.NoError(t, r.AssignRole("t2", "u2", rbac.TenantAdmin))
+	require.Error(t, r.AssignRole(rbac.AllTenants, "u1", rbac.SuperAdmin))
+	require.Error(t, r.GrantPermission("", "editor", "/api/docs", http.MethodGet))
+
+	tests := []struct {
+		tenant, sub, obj, act string
+		allow                 bool
+	}{
+		{"t1", "u1", "/api/docs/1", http.MethodGet, true},
+		{"t1", "u1", "/api/docs/1", http.MethodDelete, false},
+		{"t1", "u1", "/api/users", http.MethodGet, false},
+		{"t2", "u1", "/api/docs/1", http.MethodGet, false},  // the roles of t1 are not granted in t2
+		{"t2", "u2", "/api/users", http.MethodDelete, true}, // the tenant admin
+		{"t1", "u2", "/api/docs/1", http.MethodGet, false},
+		{"t1", consts.ROOT, "/api/users", http.MethodDelete, true}, // the super admin
+		{"", consts.ROOT, "/api/users", http.MethodGet, true},
+		{"", "u1", "/api/docs/1", http.MethodGet, false},
+	}
+	for _, tt := range tests {
+		allow, err := rbac.EnforceTenant(tt.tenant, tt.sub, tt.obj, tt.act)
+		require.NoError(t, err)
+		assert.Equal(t, tt.allow, allow, "%s %s %s %s", tt.tenant, tt.sub, tt.act, tt.obj)
+	}
+	assert.Equal(t, []string{"editor"}, rbac.TenantRoles("t1", "u1"))
+	assert.Empty(t, rbac.TenantRoles("t2", "u1"))
+
+	// Remove the role with its grants and assignments.
+	require.NoError(t, r.RemoveRole("t1", "editor"))
+	allow, err := rbac.EnforceTenant("t1", "u1", "/api/docs/1", http.MethodGet)
+	require.NoError(t, err)
+	assert.False(t, allow)
+}
+
+func TestTenantModels(t *testing.T) {
+	ctx1 := &types.DatabaseContext{TenantID: "m1"}
+	ctx2 := &types.DatabaseContext{TenantID: "m2"}
+	user := &model.User{Name: "bob"}
+	require.NoError(t, database.Database[*model.User](nil).Create(user))
+
+	require.NoError(t, database.Database[*modelauthz.TenantRole](ctx1).Create(&modelauthz.TenantRole{Name: "viewer"}))
+	require.NoError(t, database.Database[*modelauthz.TenantRolePermission](ctx1).Create(&modelauthz.TenantRolePermission{Role: "viewer", Resource: "/api/docs", Action: http.MethodGet}))
+	require.NoError(t, database.Database[*modelauthz.TenantUserRole](ctx1).Create(&modelauthz.TenantUserRole{UserID: user.ID, Role: "viewer"}))
+	allow, err := rbac.EnforceTenant("m1", user.ID, "/api/docs", http.MethodGet)
+	require.NoError(t, err)
+	assert.True(t, allow)
+
+	// The roles of other tenants are invisible and can't be assigned.
+	roles := make([]*modelauthz.TenantRole, 0)
+	require.NoError(t, database.Database[*modelauthz.TenantRole](ctx2).WithLimit(-1).WithQuery(&modelauthz.TenantRole{}, types.QueryConfig{AllowEmpty: true}).List(&roles))
+	assert.Empty(t, roles)
+	require.Error(t, database.Database[*modelauthz.TenantUserRole](ctx2).Create(&modelauthz.TenantUserRole{UserID: user.ID, Role: "viewer"}))
+	require.Error(t, database.Database[*modelauthz.TenantRole](ctx2).Create(&modelauthz.TenantRole{Name: "viewer", TenantScoped: model.TenantScoped{TenantID: "m1"}}))
+	allow, err = rbac.EnforceTenant("m2", user.ID, "/api/docs", http.MethodGet)
+	require.NoError(t, err)
+	assert.False(t, allow)
+
+	// Deleting the assignment of other tenant is no-op.
+	userRoles := make([]*modelauthz.TenantUserRole, 0)
+	require.NoError(t, database.Database[*modelauthz.TenantUserRole](ctx1).WithQuery(&modelauthz.TenantUserRole{UserID: user.ID}).List(&userRoles))
+	require.Len(t, userRoles, 1)
+	_ = database.Database[*modelauthz.TenantUserRole](ctx2).Delete(&modelauthz.TenantUserRole{Base: model.Base{ID: userRoles[0].ID}})
+	allow, err = rbac.EnforceTenant("m1", user.ID, "/api/docs", http.MethodGet)
+	require.NoError(t, err)
+	assert.True(t, allow)
+
+	require.NoError(t, database.Database[*modelauthz.TenantUserRole](ctx1).Delete(&modelauthz.TenantUserRole{Base: model.Base{ID: userRoles[0].ID}}))
+	allow, err = rbac.EnforceTenant("m1", user.ID, "/api/docs", http.MethodGet)
+	require.NoError(t, err)
+	assert.False(t, allow)
+}
+
+func TestAddRole(t *testing.T) {
+	r := rbac.TenantRBAC()
+	ctx := &types.DatabaseContext{TenantID: "r1"}
+	list := func() []string {
+		roles := make([]*modelauthz.TenantRole, 0)
+		require.NoError(t, database.Database[*modelauthz.TenantRole](ctx).WithLimit(-1).WithQuery(&modelauthz.TenantRole{}, types.QueryConfig{AllowEmpty: true}).List(&roles))
+		names := make([]string, 0, len(roles))
+		for _, role := range roles {
+			names = append(names, role.Name)
+		}
+		return names
+	}
+
+	// The role exists without grants or members.
+	require.NoError(t, r.AddRole("r1", "auditor"))
+	require.NoError(t, r.AddRole("r1", "auditor"))
+	assert.Equal(t, []string{"auditor"}, list())
+	require.Error(t, r.AddRole(rbac.AllTenants, "auditor"))
+
+	require.NoError(t, r.RemoveRole("r1", "auditor"))
+	assert.Empty(t, list())
+}
+
+func TestTenantMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	serve := func(userID, username, bound, header string) (int, string) {
+		var tenant string
+		r := gin.New()
+		r.GET("/", func(c *gin.Context) {
+			c.Set(consts.CTX_USER_ID, userID)
+			c.Set(consts.CTX_USERNAME, username)
+			c.Set(consts.CTX_TENANT_ID, bound)
+		}, middleware.Tenant(), func(c *gin.Context) {
+			tenant = c.GetString(consts.CTX_TENANT_ID)
+			c.Status(http.StatusOK)
+		})
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if len(header) > 0 {
+			req.Header.Set("X-Tenant-ID", header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code, tenant
+	}
+
+	code, tenant := serve("u1", "alice", "t1", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "t1", tenant)
+	code, _ = serve("u1", "alice", "t1", "t2")
+	assert.Equal(t, http.StatusForbidden, code, "the user bound to t1 can't access t2")
+	code, _ = serve("u3", "carol", "", "t3")
+	assert.Equal(t, http.StatusForbidden, code, "the unbound user without roles in t3 can't access t3")
+	require.NoError(t, rbac.TenantRBAC().AssignRole("t3", "u3", "viewer"))
+	code, tenant = serve("u3", "carol", "", "t3")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "t3", tenant, "the unbound user chooses the tenant having roles in")
+	code, tenant = serve("root-id", consts.ROOT, "t1", "t2")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "t2", tenant, "the super admin switches tenant")
+}
+
+func TestTenantIntrospector(t *testing.T) {
+	r := rbac.TenantRBAC()
+	require.NoError(t, r.GrantPermission("t3", "viewer", "/api/notes/{id}", http.MethodGet))
+	require.NoError(t, r.AssignRole("t3", "u3", "viewer"))
+
+	i := rbac.TenantIntrospector("t3")
+	roles, err := i.Roles("u3")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"viewer"}, roles)
+	perms, err := i.Permissions("u3")
+	require.NoError(t, err)
+	assert.Equal(t, []types.PermissionCheck{{Resource: "/api/notes/{id}", Action: http.MethodGet, Allowed: true}}, perms)
+	checks, err := i.Can("u3",
+		types.PermissionCheck{Resource: "/api/notes/:id", Action: http.MethodGet},
+		types.PermissionCheck{Resource: "/api/notes/:id", Action: http.MethodDelete},
+	)
+	require.NoError(t, err)
+	assert.True(t, checks[0].Allowed)
+	assert.False(t, checks[1].Allowed)
+	e, err := i.Explain("u3", "/api/notes/:id", http.MethodGet)
+	require.NoError(t, err)
+	assert.True(t, e.Allowed)
+	assert.Equal(t, []string{"p", "t3", "viewer", "/api/notes/{id}", http.MethodGet, "allow"}, e.Policy)
+
+	// The roles of t3 are not granted in the other tenants.
+	checks, err = rbac.TenantIntrospector("t4").Can("u3", types.PermissionCheck{Resource: "/api/notes/:id", Action: http.MethodGet})
+	require.NoError(t, err)
+	assert.False(t, checks[0].Allowed)
+
+	e, err = rbac.TenantIntrospector("t4").Explain(consts.ROOT, "/api/users", http.MethodDelete)
+	require.NoError(t, err)
+	assert.True(t, e.Allowed)
+	assert.Equal(t, "allowed by the admin role", e.Reason)
+	assert.Contains(t, e.Roles, rbac.SuperAdmin)
+}
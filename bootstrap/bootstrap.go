@@ -7,6 +7,8 @@ import (
 	"syscall"
 
 	"github.com/forbearing/gst/authn/jwt"
+	authnldap "github.com/forbearing/gst/authn/ldap"
+	"github.com/forbearing/gst/authn/oidc"
 	"github.com/forbearing/gst/authz/rbac/basic"
 	"github.com/forbearing/gst/authz/rbac/tenant"
 	"github.com/forbearing/gst/cache"
@@ -22,10 +24,12 @@ import (
 	"github.com/forbearing/gst/debug/pprof"
 	"github.com/forbearing/gst/debug/statsviz"
 	"github.com/forbearing/gst/grpc"
+	"github.com/forbearing/gst/jobqueue"
 	"github.com/forbearing/gst/logger/logrus"
 	pkgzap "github.com/forbearing/gst/logger/zap"
 	"github.com/forbearing/gst/metrics"
 	"github.com/forbearing/gst/middleware"
+	"github.com/forbearing/gst/outbox"
 	"github.com/forbearing/gst/provider/cassandra"
 	"github.com/forbearing/gst/provider/elastic"
 	"github.com/forbearing/gst/provider/etcd"
@@ -104,6 +108,8 @@ func Bootstrap() error {
 		basic.Init,
 		tenant.Init,
 		jwt.Init,
+		oidc.Init,
+		authnldap.Init,
 
 		// service
 		service.Init,
@@ -118,8 +124,14 @@ func Bootstrap() error {
 		// job
 		task.Init, // nolint:staticcheck
 		cronjob.Init,
+		jobqueue.Init,
+		outbox.Init,
 	)
 
+	RegisterCleanup(cronjob.Stop)
+	RegisterCleanup(task.Stop) // nolint:staticcheck
+	RegisterCleanup(jobqueue.Stop)
+	RegisterCleanup(outbox.Stop)
 	RegisterCleanup(redis.Close)
 	RegisterCleanup(kafka.Close)
 	RegisterCleanup(etcd.Close)
@@ -18,6 +18,7 @@ import (
 	"github.com/forbearing/gst/model"
 	"github.com/forbearing/gst/types"
 	"github.com/forbearing/gst/types/consts"
+	"github.com/forbearing/gst/util"
 	"github.com/google/go-querystring/query"
 	"golang.org/x/time/rate"
 )
@@ -347,42 +348,44 @@ func (c *Client) request(action action, payload any) (*Resp, error) {
 		return nil, errors.Wrap(err, "invalid request url")
 	}
 
-	var reader io.Reader
+	var data []byte
 	if payload != nil {
 		switch v := payload.(type) {
 		case []byte:
-			reader = bytes.NewReader(v)
+			data = v
 		default:
-			var data []byte
 			if data, err = json.Marshal(v); err != nil {
 				return nil, errors.Wrap(err, "failed to marshal payload")
 			}
-			reader = bytes.NewReader(data)
 		}
 	}
-	req, err := http.NewRequest(method, url, reader)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to create request")
-	}
-	if c.ctx != nil {
-		req = req.WithContext(c.ctx)
-	}
-	if len(c.username) > 0 {
-		req.SetBasicAuth(c.username, c.password)
-	}
-	if len(c.token) > 0 {
-		req.Header.Set("Authorization", "Bearer "+c.token)
-	}
-	maps.Copy(req.Header, c.header)
-
-	if c.debug {
-		dump, _ := httputil.DumpRequest(req, true)
-		fmt.Println(string(dump))
+	header := c.header.Clone()
+	// The retried POST/PATCH requests carry the same idempotency key,
+	// so the server processes it only once.
+	if c.maxRetries > 0 && (method == http.MethodPost || method == http.MethodPatch) && len(header.Get(consts.HEADER_IDEMPOTENCY_KEY)) == 0 {
+		header.Set(consts.HEADER_IDEMPOTENCY_KEY, util.UUID())
 	}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to request")
+	var resp *http.Response
+	for attempt := 0; ; attempt++ {
+		if resp, err = c.do(method, url, data, payload != nil, header); err == nil && !shouldRetry(resp.StatusCode) {
+			break
+		}
+		if attempt >= c.maxRetries {
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+		if err == nil {
+			resp.Body.Close()
+		}
+		c.Warnw("retry request", "method", method, "url", url, "attempt", attempt+1, "error", err)
+		select {
+		case <-c.ctx.Done():
+			return nil, errors.Wrap(c.ctx.Err(), "request canceled while waiting for retry")
+		case <-time.After(c.retryWait):
+		}
 	}
 	defer resp.Body.Close()
 
@@ -412,3 +415,41 @@ func (c *Client) request(action action, payload any) (*Resp, error) {
 	// Delete or BatchDelete response is empty with http status 204.
 	return &Resp{}, nil
 }
+
+// do sends one http request, the body is recreated from data for each attempt.
+func (c *Client) do(method, url string, data []byte, hasBody bool, header http.Header) (*http.Response, error) {
+	var reader io.Reader
+	if hasBody {
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request")
+	}
+	if c.ctx != nil {
+		req = req.WithContext(c.ctx)
+	}
+	if len(c.username) > 0 {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	if len(c.token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	maps.Copy(req.Header, header)
+
+	if c.debug {
+		dump, _ := httputil.DumpRequest(req, true)
+		fmt.Println(string(dump))
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to request")
+	}
+	return resp, nil
+}
+
+// shouldRetry reports whether the request should be retried by the response status code.
+func shouldRetry(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
@@ -42,6 +42,9 @@ func WithDebug() Option {
 	}
 }
 
+// WithRetry retries the request up to maxRetries times on network error, 429 and 5xx responses,
+// waiting the given duration between attempts. The POST and PATCH requests carry an auto-generated
+// "Idempotency-Key" header unless already set by WithHeader, so the retries are processed only once by server.
 func WithRetry(maxRetries int, wait time.Duration) Option {
 	return func(c *Client) {
 		if maxRetries < 0 {
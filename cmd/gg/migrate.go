@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/forbearing/gst/internal/codegen/gen"
+	"github.com/spf13/cobra"
+)
+
+// migrateCmd represents the migrate command
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Versioned schema migrations management",
+	Long: `Versioned schema migrations management.
+
+The migrations live in the migrations directory (default: "migrations") as a Go package,
+import it in main.go so that the application applies the pending migrations at startup:
+
+  import _ "<module>/migrations"
+
+This command provides subcommands to:
+- Create a new Go or SQL migration
+- Apply the pending migrations
+- Roll back the applied migrations
+- Show the migrations status
+
+Examples:
+  gg migrate new add_user_email        # Create a Go migration
+  gg migrate new add_user_email --sql  # Create a SQL migration
+  gg migrate up                        # Apply all pending migrations
+  gg migrate down                      # Roll back the latest migration
+  gg migrate down 3                    # Roll back the latest 3 migrations
+  gg migrate status                    # Show the migrations status`,
+}
+
+var migrateNewCmd = &cobra.Command{
+	Use:   "new <name>",
+	Short: "Create a new migration",
+	Args:  cobra.ExactArgs(1),
+	RunE:  migrateNewRun,
+}
+
+var migrateUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Apply all pending migrations",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return migrateExec("up")
+	},
+}
+
+var migrateDownCmd = &cobra.Command{
+	Use:   "down [steps]",
+	Short: "Roll back the latest applied migrations, default to 1",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		steps := 1
+		if len(args) > 0 {
+			var err error
+			if steps, err = strconv.Atoi(args[0]); err != nil || steps <= 0 {
+				return fmt.Errorf("invalid steps %q", args[0])
+			}
+		}
+		return migrateExec("down", strconv.Itoa(steps))
+	},
+}
+
+var migrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the migrations status",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return migrateExec("status")
+	},
+}
+
+var (
+	migrateDir    string
+	migrateSQL    bool
+	migrateConfig string
+
+	migrateNameRegexp = regexp.MustCompile(`^\w+$`)
+)
+
+func init() {
+	migrateCmd.AddCommand(migrateNewCmd, migrateUpCmd, migrateDownCmd, migrateStatusCmd)
+
+	migrateCmd.PersistentFlags().StringVar(&migrateDir, "dir", "migrations", "Migrations directory")
+	migrateCmd.PersistentFlags().StringVarP(&migrateConfig, "config", "c", "", "Application config file used to connect the database")
+	migrateNewCmd.Flags().BoolVar(&migrateSQL, "sql", false, "Create a SQL migration instead of Go migration")
+}
+
+func migrateNewRun(cmd *cobra.Command, args []string) error {
+	name := strings.ToLower(args[0])
+	if !migrateNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid migration name %q, only letters, digits and underscores are allowed", args[0])
+	}
+	now := time.Now().UTC()
+	version := now.Format("20060102150405")
+	// The version must be unique, the migrations created within the same second are delayed.
+	for matches, _ := filepath.Glob(filepath.Join(migrateDir, version+"_*")); len(matches) > 0; matches, _ = filepath.Glob(filepath.Join(migrateDir, version+"_*")) {
+		now = now.Add(time.Second)
+		version = now.Format("20060102150405")
+	}
+	pkg := migratePackageName()
+
+	logSection("Create Migration")
+	if migrateSQL {
+		writeFileWithLog(filepath.Join(migrateDir, version+"_"+name+".up.sql"), "-- "+name+"\n")
+		writeFileWithLog(filepath.Join(migrateDir, version+"_"+name+".down.sql"), "-- "+name+"\n")
+		if filename := filepath.Join(migrateDir, "migrations.go"); !fileExists(filename) {
+			writeFileWithLog(filename, fmt.Sprintf(migrateEmbedTemplate, pkg))
+		}
+	} else {
+		writeFileWithLog(filepath.Join(migrateDir, version+"_"+name+".go"), fmt.Sprintf(migrateGoTemplate, pkg, version, name))
+	}
+
+	fmt.Printf("\n%s Import the migrations in main.go: %s\n", cyan("→"), bold(fmt.Sprintf("import _ \"<module>/%s\"", filepath.ToSlash(migrateDir))))
+	return nil
+}
+
+// migrateExec runs the migration command within a temporary program, which imports
+// the migrations package of the project, so the Go migrations are executed as well.
+func migrateExec(args ...string) error {
+	mod, err := gen.GetModulePath()
+	if err != nil {
+		return err
+	}
+	pkg := ""
+	if fileExists(migrateDir) {
+		pkg = mod + "/" + filepath.ToSlash(filepath.Clean(migrateDir))
+	}
+
+	// The directory prefixed with "_" is ignored by "go build ./...".
+	dir, err := os.MkdirTemp(".", "_gg_migrate_")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	buf := new(bytes.Buffer)
+	if err = migrateRunnerTemplate.Execute(buf, map[string]string{"Package": pkg}); err != nil {
+		return err
+	}
+	if err = os.WriteFile(filepath.Join(dir, "main.go"), buf.Bytes(), 0o600); err != nil {
+		return err
+	}
+
+	logSection("Migrate " + strings.Join(args, " "))
+	runArgs := append([]string{"run", "./" + filepath.ToSlash(dir)}, args...)
+	if len(migrateConfig) > 0 {
+		runArgs = append(runArgs, migrateConfig)
+	}
+	runCmd := exec.Command("go", runArgs...)
+	runCmd.Stdout = os.Stdout
+	runCmd.Stderr = os.Stderr
+	if err = runCmd.Run(); err != nil {
+		fmt.Printf("%s Migrate failed: %v\n", red("✘"), err)
+		return err
+	}
+	return nil
+}
+
+// migratePackageName returns the package name of the migrations directory.
+func migratePackageName() string {
+	name := strings.ToLower(filepath.Base(filepath.Clean(migrateDir)))
+	name = regexp.MustCompile(`\W`).ReplaceAllString(name, "")
+	if len(name) == 0 || name == "." {
+		return "migrations"
+	}
+	return name
+}
+
+const migrateGoTemplate = `package %s
+
+import (
+	"github.com/forbearing/gst/database/migrate"
+	"gorm.io/gorm"
+)
+
+func init() {
+	migrate.Register(%s, %q,
+		// up
+		func(tx *gorm.DB) error {
+			return nil
+		},
+		// down
+		func(tx *gorm.DB) error {
+			return nil
+		},
+	)
+}
+`
+
+const migrateEmbedTemplate = `package %s
+
+import (
+	"embed"
+
+	"github.com/forbearing/gst/database/migrate"
+)
+
+//go:embed *.sql
+var sqlFiles embed.FS
+
+func init() {
+	migrate.RegisterFS(sqlFiles, ".")
+}
+`
+
+var migrateRunnerTemplate = template.Must(template.New("runner").Parse(`package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/database/migrate"
+	"github.com/forbearing/gst/database/mysql"
+	"github.com/forbearing/gst/database/postgres"
+	"github.com/forbearing/gst/database/sqlite"
+	"github.com/forbearing/gst/database/sqlserver"
+	pkgzap "github.com/forbearing/gst/logger/zap"
+{{- if .Package}}
+
+	_ "{{.Package}}"
+{{- end}}
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	command, args := args[0], args[1:]
+	steps := 0
+	if command == "down" {
+		steps, _ = strconv.Atoi(args[0])
+		args = args[1:]
+	}
+	if len(args) > 0 {
+		config.SetConfigFile(args[0])
+	}
+	if err := config.Init(); err != nil {
+		return err
+	}
+	if err := pkgzap.Init(); err != nil {
+		return err
+	}
+	// The migrations are applied by the command, not by the database initialization.
+	config.App.Database.Migrate = false
+	for _, fn := range []func() error{sqlite.Init, postgres.Init, mysql.Init, sqlserver.Init} {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	if database.DB == nil {
+		return fmt.Errorf("no database enabled")
+	}
+
+	switch command {
+	case "up":
+		return migrate.Up(database.DB, nil)
+	case "down":
+		return migrate.Down(database.DB, nil, steps)
+	case "status":
+		list, err := migrate.Status(database.DB, nil)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tDATABASE\tSTATUS\tAPPLIED AT")
+		for _, s := range list {
+			status, appliedAt := "pending", ""
+			if s.Applied {
+				status, appliedAt = "applied", s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if s.Missing {
+				status = "missing"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", s.Version, s.Name, s.DBName, status, appliedAt)
+		}
+		return w.Flush()
+	}
+	return fmt.Errorf("unknown command %q", command)
+}
+`))
@@ -11,6 +11,26 @@ const (
 	AUTH_ACCESS_TOKEN_EXPIRE_DURATION  = "AUTH_ACCESS_TOKEN_EXPIRE_DURATION"  //nolint:staticcheck,gosec
 	AUTH_REFRESH_TOKEN_EXPIRE_DURATION = "AUTH_REFRESH_TOKEN_EXPIRE_DURATION" //nolint:staticcheck,gosec
 	AUTH_RBAC_ENABLE                   = "AUTH_RBAC_ENABLE"                   //nolint:staticcheck
+	AUTH_TENANT_RBAC_ENABLE            = "AUTH_TENANT_RBAC_ENABLE"            //nolint:staticcheck
+	AUTH_TENANT_HEADER                 = "AUTH_TENANT_HEADER"                 //nolint:staticcheck
+	AUTH_TENANT_DOMAIN                 = "AUTH_TENANT_DOMAIN"                 //nolint:staticcheck
+	AUTH_SIGNING_METHOD                = "AUTH_SIGNING_METHOD"                //nolint:staticcheck
+	AUTH_SIGNING_SECRET                = "AUTH_SIGNING_SECRET"                //nolint:staticcheck,gosec
+	AUTH_SIGNING_KEY_DIR               = "AUTH_SIGNING_KEY_DIR"               //nolint:staticcheck
+	AUTH_KEY_ROTATION_INTERVAL         = "AUTH_KEY_ROTATION_INTERVAL"         //nolint:staticcheck
+	AUTH_KEY_GRACE_PERIOD              = "AUTH_KEY_GRACE_PERIOD"              //nolint:staticcheck
+	AUTH_MFA_TOKEN_EXPIRE_DURATION     = "AUTH_MFA_TOKEN_EXPIRE_DURATION"     //nolint:staticcheck,gosec
+	AUTH_MFA_REQUIRED_ROLES            = "AUTH_MFA_REQUIRED_ROLES"            //nolint:staticcheck
+	AUTH_PASSWORD_MIN_LENGTH           = "AUTH_PASSWORD_MIN_LENGTH"           //nolint:staticcheck,gosec
+	AUTH_PASSWORD_REQUIRE_UPPER        = "AUTH_PASSWORD_REQUIRE_UPPER"        //nolint:staticcheck,gosec
+	AUTH_PASSWORD_REQUIRE_LOWER        = "AUTH_PASSWORD_REQUIRE_LOWER"        //nolint:staticcheck,gosec
+	AUTH_PASSWORD_REQUIRE_NUMBER       = "AUTH_PASSWORD_REQUIRE_NUMBER"       //nolint:staticcheck,gosec
+	AUTH_PASSWORD_REQUIRE_SPECIAL      = "AUTH_PASSWORD_REQUIRE_SPECIAL"      //nolint:staticcheck,gosec
+	AUTH_PASSWORD_EXPIRE_DURATION      = "AUTH_PASSWORD_EXPIRE_DURATION"      //nolint:staticcheck,gosec
+	AUTH_PASSWORD_HISTORY              = "AUTH_PASSWORD_HISTORY"              //nolint:staticcheck,gosec
+	AUTH_LOCKOUT_THRESHOLD             = "AUTH_LOCKOUT_THRESHOLD"             //nolint:staticcheck
+	AUTH_LOCKOUT_DURATION              = "AUTH_LOCKOUT_DURATION"              //nolint:staticcheck
+	AUTH_LOCKOUT_MAX_DURATION          = "AUTH_LOCKOUT_MAX_DURATION"          //nolint:staticcheck
 )
 
 type Auth struct {
@@ -23,6 +43,49 @@ type Auth struct {
 	RefreshTokenExpireDuration time.Duration `json:"refresh_token_expire_duration" mapstructure:"refresh_token_expire_duration" ini:"refresh_token_expire_duration" yaml:"refresh_token_expire_duration"`
 
 	RBACEnable bool `json:"rbac_enable" mapstructure:"rbac_enable" ini:"rbac_enable" yaml:"rbac_enable"`
+	// TenantRBACEnable enables the RBAC scoped by tenant, see package authz/rbac/tenant.
+	TenantRBACEnable bool `json:"tenant_rbac_enable" mapstructure:"tenant_rbac_enable" ini:"tenant_rbac_enable" yaml:"tenant_rbac_enable"`
+	// TenantHeader is the request header of the tenant id, see middleware.Tenant.
+	TenantHeader string `json:"tenant_header" mapstructure:"tenant_header" ini:"tenant_header" yaml:"tenant_header"`
+	// TenantDomain is the parent domain of the tenant subdomains, eg: the tenant of "acme.example.com"
+	// is "acme" if it's "example.com". Empty disables the tenant resolution from subdomain.
+	TenantDomain string `json:"tenant_domain" mapstructure:"tenant_domain" ini:"tenant_domain" yaml:"tenant_domain"`
+
+	// SigningMethod is the jwt signing algorithm, one of HS256, RS256, ES256 and EdDSA.
+	SigningMethod string `json:"signing_method" mapstructure:"signing_method" ini:"signing_method" yaml:"signing_method"`
+	// SigningSecret is the HMAC secret used by HS256.
+	SigningSecret string `json:"signing_secret" mapstructure:"signing_secret" ini:"signing_secret" yaml:"signing_secret"`
+	// SigningKeyDir is the directory of PEM encoded private keys "<kid>.pem" used by RS256, ES256 and EdDSA,
+	// it's required by them and shared by all the replicas. The newest key signs the tokens, all the keys verify the tokens.
+	SigningKeyDir string `json:"signing_key_dir" mapstructure:"signing_key_dir" ini:"signing_key_dir" yaml:"signing_key_dir"`
+	// KeyRotationInterval is the interval to generate a new signing key, zero disables the rotation.
+	KeyRotationInterval time.Duration `json:"key_rotation_interval" mapstructure:"key_rotation_interval" ini:"key_rotation_interval" yaml:"key_rotation_interval"`
+	// KeyGracePeriod is the duration the rotated keys still verify the tokens.
+	KeyGracePeriod time.Duration `json:"key_grace_period" mapstructure:"key_grace_period" ini:"key_grace_period" yaml:"key_grace_period"`
+
+	// MFATokenExpireDuration is the lifetime of the "MFA pending" token issued by the first login step.
+	MFATokenExpireDuration time.Duration `json:"mfa_token_expire_duration" mapstructure:"mfa_token_expire_duration" ini:"mfa_token_expire_duration" yaml:"mfa_token_expire_duration"`
+	// MFARequiredRoles is the RBAC roles whose users must log in with MFA.
+	MFARequiredRoles []string `json:"mfa_required_roles" mapstructure:"mfa_required_roles" ini:"mfa_required_roles" yaml:"mfa_required_roles"`
+
+	// PasswordMinLength is the minimum length of the local user passwords.
+	PasswordMinLength     int  `json:"password_min_length" mapstructure:"password_min_length" ini:"password_min_length" yaml:"password_min_length"`
+	PasswordRequireUpper  bool `json:"password_require_upper" mapstructure:"password_require_upper" ini:"password_require_upper" yaml:"password_require_upper"`
+	PasswordRequireLower  bool `json:"password_require_lower" mapstructure:"password_require_lower" ini:"password_require_lower" yaml:"password_require_lower"`
+	PasswordRequireNumber bool `json:"password_require_number" mapstructure:"password_require_number" ini:"password_require_number" yaml:"password_require_number"`
+	// PasswordRequireSpecial requires at least one punctuation or symbol character.
+	PasswordRequireSpecial bool `json:"password_require_special" mapstructure:"password_require_special" ini:"password_require_special" yaml:"password_require_special"`
+	// PasswordExpireDuration is the lifetime of the passwords, zero means never expire.
+	PasswordExpireDuration time.Duration `json:"password_expire_duration" mapstructure:"password_expire_duration" ini:"password_expire_duration" yaml:"password_expire_duration"`
+	// PasswordHistory is the number of the previous passwords can't be reused, zero disables the check.
+	PasswordHistory int `json:"password_history" mapstructure:"password_history" ini:"password_history" yaml:"password_history"`
+
+	// LockoutThreshold is the number of consecutive login failures to lock the account, zero disables the lockout.
+	LockoutThreshold int `json:"lockout_threshold" mapstructure:"lockout_threshold" ini:"lockout_threshold" yaml:"lockout_threshold"`
+	// LockoutDuration is the duration of the first lockout, it doubles at every further lockout.
+	LockoutDuration time.Duration `json:"lockout_duration" mapstructure:"lockout_duration" ini:"lockout_duration" yaml:"lockout_duration"`
+	// LockoutMaxDuration is the upper limit of the lockout duration.
+	LockoutMaxDuration time.Duration `json:"lockout_max_duration" mapstructure:"lockout_max_duration" ini:"lockout_max_duration" yaml:"lockout_max_duration"`
 }
 
 func (*Auth) setDefault() {
@@ -35,4 +98,28 @@ func (*Auth) setDefault() {
 	cv.SetDefault("auth.refresh_token_expire_duration", "168h")
 
 	cv.SetDefault("auth.rbac_enable", false)
+	cv.SetDefault("auth.tenant_rbac_enable", false)
+	cv.SetDefault("auth.tenant_header", "X-Tenant-ID")
+	cv.SetDefault("auth.tenant_domain", "")
+
+	cv.SetDefault("auth.signing_method", "HS256")
+	cv.SetDefault("auth.signing_secret", "defaultSecret")
+	cv.SetDefault("auth.signing_key_dir", "")
+	cv.SetDefault("auth.key_rotation_interval", 0)
+	cv.SetDefault("auth.key_grace_period", "168h")
+
+	cv.SetDefault("auth.mfa_token_expire_duration", "5m")
+	cv.SetDefault("auth.mfa_required_roles", []string{})
+
+	cv.SetDefault("auth.password_min_length", 8)
+	cv.SetDefault("auth.password_require_upper", true)
+	cv.SetDefault("auth.password_require_lower", true)
+	cv.SetDefault("auth.password_require_number", true)
+	cv.SetDefault("auth.password_require_special", true)
+	cv.SetDefault("auth.password_expire_duration", 0)
+	cv.SetDefault("auth.password_history", 0)
+
+	cv.SetDefault("auth.lockout_threshold", 5)
+	cv.SetDefault("auth.lockout_duration", "5m")
+	cv.SetDefault("auth.lockout_max_duration", "24h")
 }
@@ -65,6 +65,7 @@ type Config struct {
 	S3            `json:"s3" mapstructure:"s3" ini:"s3" yaml:"s3"`
 	Logger        `json:"logger" mapstructure:"logger" ini:"logger" yaml:"logger"`
 	Ldap          `json:"ldap" mapstructure:"ldap" ini:"ldap" yaml:"ldap"`
+	OIDC          `json:"oidc" mapstructure:"oidc" ini:"oidc" yaml:"oidc"`
 	Influxdb      `json:"influxdb" mapstructure:"influxdb" ini:"influxdb" yaml:"influxdb"`
 	Mqtt          `json:"mqtt" mapstructure:"mqtt" ini:"mqtt" yaml:"mqtt"`
 	Nats          `json:"nats" mapstructure:"nats" ini:"nats" yaml:"nats"`
@@ -77,6 +78,9 @@ type Config struct {
 	Feishu        `json:"feishu" mapstructure:"feishu" ini:"feishu" yaml:"feishu"`
 	Debug         `json:"debug" mapstructure:"debug" ini:"debug" yaml:"debug"`
 	Audit         `json:"audit" mapstructure:"audit" ini:"audit" yaml:"audit"`
+	Cronjob       `json:"cronjob" mapstructure:"cronjob" ini:"cronjob" yaml:"cronjob"`
+	JobQueue      `json:"jobqueue" mapstructure:"jobqueue" ini:"jobqueue" yaml:"jobqueue"`
+	Outbox        `json:"outbox" mapstructure:"outbox" ini:"outbox" yaml:"outbox"`
 }
 
 // setDefault will set config default value
@@ -99,6 +103,7 @@ func (c *Config) setDefault() {
 	c.Mongo.setDefault()
 	c.Kafka.setDefault()
 	c.Ldap.setDefault()
+	c.OIDC.setDefault()
 	c.Influxdb.setDefault()
 	c.Minio.setDefault()
 	c.S3.setDefault()
@@ -113,6 +118,9 @@ func (c *Config) setDefault() {
 	c.Feishu.setDefault()
 	c.Debug.setDefault()
 	c.Audit.setDefault()
+	c.Cronjob.setDefault()
+	c.JobQueue.setDefault()
+	c.Outbox.setDefault()
 }
 
 // Init initializes the application configuration
@@ -0,0 +1,49 @@
+package config
+
+import "time"
+
+const (
+	CRONJOB_LOCKER            = "CRONJOB_LOCKER"            //nolint:staticcheck
+	CRONJOB_LOCK_TTL          = "CRONJOB_LOCK_TTL"          //nolint:staticcheck
+	CRONJOB_HISTORY           = "CRONJOB_HISTORY"           //nolint:staticcheck
+	CRONJOB_HISTORY_RETENTION = "CRONJOB_HISTORY_RETENTION" //nolint:staticcheck
+	CRONJOB_DRAIN_TIMEOUT     = "CRONJOB_DRAIN_TIMEOUT"     //nolint:staticcheck
+)
+
+// CronjobLocker is the backend of the locks claiming the cronjob runs across replicas.
+type CronjobLocker string
+
+const (
+	// CronjobLockerNone runs every cronjob on every replica.
+	CronjobLockerNone CronjobLocker = ""
+	// CronjobLockerMemory claims the runs in process, it's only useful for tests.
+	CronjobLockerMemory   CronjobLocker = "memory"
+	CronjobLockerDatabase CronjobLocker = "database"
+	CronjobLockerRedis    CronjobLocker = "redis"
+	CronjobLockerEtcd     CronjobLocker = "etcd"
+)
+
+type Cronjob struct {
+	// Locker enables the distributed mode, each scheduled tick runs on only one replica.
+	Locker CronjobLocker `json:"locker" mapstructure:"locker" ini:"locker" yaml:"locker"`
+	// LockTTL is how long the claim of a tick and the lock of a running cronjob last, they're
+	// renewed every third of it until the run finishes, and the run is canceled once they are lost.
+	// It must be positive and should be longer than the replicas' clock skew.
+	LockTTL time.Duration `json:"lock_ttl" mapstructure:"lock_ttl" ini:"lock_ttl" yaml:"lock_ttl"`
+	// History persists the runs of the cronjobs and tasks, see model.JobRun. It's disabled by
+	// default, every run of every replica is a record, including the built-in tasks.
+	History bool `json:"history" mapstructure:"history" ini:"history" yaml:"history"`
+	// HistoryRetention is how long the runs are kept, zero keeps forever.
+	HistoryRetention time.Duration `json:"history_retention" mapstructure:"history_retention" ini:"history_retention" yaml:"history_retention"`
+	// DrainTimeout is how long the shutdown waits for the running cronjobs and tasks,
+	// their contexts are canceled at the beginning of the shutdown.
+	DrainTimeout time.Duration `json:"drain_timeout" mapstructure:"drain_timeout" ini:"drain_timeout" yaml:"drain_timeout"`
+}
+
+func (*Cronjob) setDefault() {
+	cv.SetDefault("cronjob.locker", CronjobLockerNone)
+	cv.SetDefault("cronjob.lock_ttl", 10*time.Minute)
+	cv.SetDefault("cronjob.history", false)
+	cv.SetDefault("cronjob.history_retention", 7*24*time.Hour)
+	cv.SetDefault("cronjob.drain_timeout", 30*time.Second)
+}
@@ -19,6 +19,8 @@ const (
 	DATABASE_MAX_OPEN_CONNS       = "DATABASE_MAX_OPEN_CONNS"       //nolint:staticcheck
 	DATABASE_CONN_MAX_LIFETIME    = "DATABASE_CONN_MAX_LIFETIME"    //nolint:staticcheck
 	DATABASE_CONN_MAX_IDLE_TIME   = "DATABASE_CONN_MAX_IDLE_TIME"   //nolint:staticcheck
+	DATABASE_MIGRATE              = "DATABASE_MIGRATE"              //nolint:staticcheck
+	DATABASE_REPLICA_CHECK_PERIOD = "DATABASE_REPLICA_CHECK_PERIOD" //nolint:staticcheck
 )
 
 type Database struct {
@@ -28,6 +30,12 @@ type Database struct {
 	MaxOpenConns       int           `json:"max_open_conns" mapstructure:"max_open_conns" ini:"max_open_conns" yaml:"max_open_conns"`
 	ConnMaxLifetime    time.Duration `json:"conn_max_lifetime" mapstructure:"conn_max_lifetime" ini:"conn_max_lifetime" yaml:"conn_max_lifetime"`
 	ConnMaxIdleTime    time.Duration `json:"conn_max_idle_time" mapstructure:"conn_max_idle_time" ini:"conn_max_idle_time" yaml:"conn_max_idle_time"`
+
+	// Migrate applies the pending versioned migrations registered in package database/migrate at startup.
+	Migrate bool `json:"migrate" mapstructure:"migrate" ini:"migrate" yaml:"migrate"`
+	// ReplicaCheckPeriod is the interval to check the health of read replicas,
+	// the unhealthy replicas are skipped until they recover.
+	ReplicaCheckPeriod time.Duration `json:"replica_check_period" mapstructure:"replica_check_period" ini:"replica_check_period" yaml:"replica_check_period"`
 }
 
 func (*Database) setDefault() {
@@ -37,4 +45,6 @@ func (*Database) setDefault() {
 	cv.SetDefault("database.max_open_conns", 100)
 	cv.SetDefault("database.conn_max_lifetime", 1*time.Hour)
 	cv.SetDefault("database.conn_max_idle_time", 10*time.Minute)
+	cv.SetDefault("database.migrate", true)
+	cv.SetDefault("database.replica_check_period", 10*time.Second)
 }
@@ -0,0 +1,79 @@
+package config
+
+import "time"
+
+const (
+	JOBQUEUE_BACKEND       = "JOBQUEUE_BACKEND"       //nolint:staticcheck
+	JOBQUEUE_CHANNEL       = "JOBQUEUE_CHANNEL"       //nolint:staticcheck
+	JOBQUEUE_WORKER        = "JOBQUEUE_WORKER"        //nolint:staticcheck
+	JOBQUEUE_CONCURRENCY   = "JOBQUEUE_CONCURRENCY"   //nolint:staticcheck
+	JOBQUEUE_POLL_INTERVAL = "JOBQUEUE_POLL_INTERVAL" //nolint:staticcheck
+	JOBQUEUE_LEASE         = "JOBQUEUE_LEASE"         //nolint:staticcheck
+	JOBQUEUE_MAX_RETRIES   = "JOBQUEUE_MAX_RETRIES"   //nolint:staticcheck
+	JOBQUEUE_BACKOFF       = "JOBQUEUE_BACKOFF"       //nolint:staticcheck
+	JOBQUEUE_MAX_BACKOFF   = "JOBQUEUE_MAX_BACKOFF"   //nolint:staticcheck
+	JOBQUEUE_RETENTION     = "JOBQUEUE_RETENTION"     //nolint:staticcheck
+	JOBQUEUE_DRAIN_TIMEOUT = "JOBQUEUE_DRAIN_TIMEOUT" //nolint:staticcheck
+)
+
+// JobQueueBackend is the backend delivering the enqueued jobs to the workers.
+type JobQueueBackend string
+
+const (
+	// JobQueueBackendDatabase only polls the database.
+	JobQueueBackendDatabase JobQueueBackend = "database"
+	// JobQueueBackendRedis, JobQueueBackendKafka and JobQueueBackendNats only notify the workers
+	// of all replicas once a job is enqueued, the jobs are still stored in and claimed from the
+	// database, which is polled for the delayed and retried jobs too.
+	JobQueueBackendRedis JobQueueBackend = "redis"
+	JobQueueBackendKafka JobQueueBackend = "kafka"
+	JobQueueBackendNats  JobQueueBackend = "nats"
+)
+
+// JobQueue is the configuration of the background job queue, the jobs are always
+// persisted in database, see model.Job.
+type JobQueue struct {
+	Backend JobQueueBackend `json:"backend" mapstructure:"backend" ini:"backend" yaml:"backend"`
+	// Channel is the redis channel, kafka topic or nats subject of the notifications.
+	Channel string `json:"channel" mapstructure:"channel" ini:"channel" yaml:"channel"`
+
+	// Worker runs the workers on this replica, disable it for the replicas only enqueuing jobs.
+	Worker bool `json:"worker" mapstructure:"worker" ini:"worker" yaml:"worker"`
+	// Concurrency is the max running jobs of each queue on each replica.
+	Concurrency int `json:"concurrency" mapstructure:"concurrency" ini:"concurrency" yaml:"concurrency"`
+	// Queues overrides the concurrency of the queues, the key is the queue name.
+	Queues map[string]int `json:"queues" mapstructure:"queues" ini:"queues" yaml:"queues"`
+	// PollInterval is how often the idle workers poll the database.
+	PollInterval time.Duration `json:"poll_interval" mapstructure:"poll_interval" ini:"poll_interval" yaml:"poll_interval"`
+	// Lease is how long a running job is locked by the worker, it's renewed while running.
+	// The job locked by a crashed replica is run again once the lease expires.
+	Lease time.Duration `json:"lease" mapstructure:"lease" ini:"lease" yaml:"lease"`
+
+	// MaxRetries is the default max retries of the failed jobs, the jobs failed
+	// after all retries are dead-lettered.
+	MaxRetries int `json:"max_retries" mapstructure:"max_retries" ini:"max_retries" yaml:"max_retries"`
+	// Backoff is the default delay before the first retry, it doubles every retry up to MaxBackoff.
+	Backoff    time.Duration `json:"backoff" mapstructure:"backoff" ini:"backoff" yaml:"backoff"`
+	MaxBackoff time.Duration `json:"max_backoff" mapstructure:"max_backoff" ini:"max_backoff" yaml:"max_backoff"`
+
+	// Retention is how long the finished jobs are kept, zero keeps forever.
+	// The dead jobs are kept until retried or deleted.
+	Retention time.Duration `json:"retention" mapstructure:"retention" ini:"retention" yaml:"retention"`
+	// DrainTimeout is how long the shutdown waits for the running jobs, their contexts are
+	// canceled at the beginning of the shutdown and the interrupted jobs are run again later.
+	DrainTimeout time.Duration `json:"drain_timeout" mapstructure:"drain_timeout" ini:"drain_timeout" yaml:"drain_timeout"`
+}
+
+func (*JobQueue) setDefault() {
+	cv.SetDefault("jobqueue.backend", JobQueueBackendDatabase)
+	cv.SetDefault("jobqueue.channel", "gst-jobqueue")
+	cv.SetDefault("jobqueue.worker", true)
+	cv.SetDefault("jobqueue.concurrency", 10)
+	cv.SetDefault("jobqueue.poll_interval", time.Second)
+	cv.SetDefault("jobqueue.lease", time.Minute)
+	cv.SetDefault("jobqueue.max_retries", 3)
+	cv.SetDefault("jobqueue.backoff", 10*time.Second)
+	cv.SetDefault("jobqueue.max_backoff", time.Hour)
+	cv.SetDefault("jobqueue.retention", 7*24*time.Hour)
+	cv.SetDefault("jobqueue.drain_timeout", 30*time.Second)
+}
@@ -32,6 +32,11 @@ const (
 	LDAP_CA_FILE              = "LDAP_CA_FILE"              //nolint:staticcheck
 	LDAP_INSECURE_SKIP_VERIFY = "LDAP_INSECURE_SKIP_VERIFY" //nolint:staticcheck
 
+	LDAP_LOGIN          = "LDAP_LOGIN"          //nolint:staticcheck
+	LDAP_AUTO_PROVISION = "LDAP_AUTO_PROVISION" //nolint:staticcheck
+	LDAP_GROUP_ROLES    = "LDAP_GROUP_ROLES"    //nolint:staticcheck
+	LDAP_RECONCILE_SPEC = "LDAP_RECONCILE_SPEC" //nolint:staticcheck
+
 	LDAP_ENABLE = "LDAP_ENABLE" //nolint:staticcheck
 )
 
@@ -89,6 +94,15 @@ type Ldap struct {
 	CAFile             string `json:"ca_file" mapstructure:"ca_file" ini:"ca_file" yaml:"ca_file"`
 	InsecureSkipVerify bool   `json:"insecure_skip_verify" mapstructure:"insecure_skip_verify" ini:"insecure_skip_verify" yaml:"insecure_skip_verify"`
 
+	// Login authenticates the users of controller.User.Login by LDAP before the local users.
+	Login bool `json:"login" mapstructure:"login" ini:"login" yaml:"login"`
+	// AutoProvision creates the user at the first LDAP login.
+	AutoProvision bool `json:"auto_provision" mapstructure:"auto_provision" ini:"auto_provision" yaml:"auto_provision"`
+	// GroupRoles maps the LDAP groups to the RBAC roles, the item format is "group=role", eg: "admins=admin".
+	GroupRoles []string `json:"group_roles" mapstructure:"group_roles" ini:"group_roles" yaml:"group_roles"`
+	// ReconcileSpec is the cron spec to reconcile the LDAP group memberships to RBAC roles, empty disables it.
+	ReconcileSpec string `json:"reconcile_spec" mapstructure:"reconcile_spec" ini:"reconcile_spec" yaml:"reconcile_spec"`
+
 	Enable bool `json:"enable" mapstructure:"enable" ini:"enable" yaml:"enable"`
 }
 
@@ -121,5 +135,10 @@ func (*Ldap) setDefault() {
 	cv.SetDefault("ldap.ca_file", "")
 	cv.SetDefault("ldap.insecure_skip_verify", false)
 
+	cv.SetDefault("ldap.login", false)
+	cv.SetDefault("ldap.auto_provision", true)
+	cv.SetDefault("ldap.group_roles", []string{})
+	cv.SetDefault("ldap.reconcile_spec", "0 */10 * * * *")
+
 	cv.SetDefault("ldap.enable", false)
 }
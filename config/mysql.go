@@ -8,6 +8,7 @@ const (
 	MYSQL_PASSWORD = "MYSQL_PASSWORD" //nolint:staticcheck
 	MYSQL_CHARSET  = "MYSQL_CHARSET"  //nolint:staticcheck
 	MYSQL_ENABLE   = "MYSQL_ENABLE"   //nolint:staticcheck
+	MYSQL_REPLICAS = "MYSQL_REPLICAS" //nolint:staticcheck
 )
 
 type MySQL struct {
@@ -18,6 +19,9 @@ type MySQL struct {
 	Password string `json:"password" mapstructure:"password" ini:"password" yaml:"password"`
 	Charset  string `json:"charset" mapstructure:"charset" ini:"charset" yaml:"charset"`
 	Enable   bool   `json:"enable" mapstructure:"enable" ini:"enable" yaml:"enable"`
+
+	// Replicas is the "host:port" addresses of the read replicas, they share the database and credentials with primary.
+	Replicas []string `json:"replicas" mapstructure:"replicas" ini:"replicas" yaml:"replicas"`
 }
 
 func (*MySQL) setDefault() {
@@ -28,4 +32,5 @@ func (*MySQL) setDefault() {
 	cv.SetDefault("mysql.password", "")
 	cv.SetDefault("mysql.charset", "utf8mb4")
 	cv.SetDefault("mysql.enable", true)
+	cv.SetDefault("mysql.replicas", []string{})
 }
@@ -0,0 +1,48 @@
+package config
+
+const (
+	OIDC_NAME           = "OIDC_NAME"           //nolint:staticcheck
+	OIDC_ISSUER         = "OIDC_ISSUER"         //nolint:staticcheck
+	OIDC_CLIENT_ID      = "OIDC_CLIENT_ID"      //nolint:staticcheck
+	OIDC_CLIENT_SECRET  = "OIDC_CLIENT_SECRET"  //nolint:staticcheck,gosec
+	OIDC_REDIRECT_URL   = "OIDC_REDIRECT_URL"   //nolint:staticcheck
+	OIDC_SCOPES         = "OIDC_SCOPES"         //nolint:staticcheck
+	OIDC_USERNAME_CLAIM = "OIDC_USERNAME_CLAIM" //nolint:staticcheck
+	OIDC_AUTO_PROVISION = "OIDC_AUTO_PROVISION" //nolint:staticcheck
+	OIDC_LINK_BY_EMAIL  = "OIDC_LINK_BY_EMAIL"  //nolint:staticcheck
+
+	OIDC_ENABLE = "OIDC_ENABLE" //nolint:staticcheck
+)
+
+// OIDC is the OpenID Connect identity provider used to log in users.
+type OIDC struct {
+	// Name is the provider name in the login url, eg: "/oidc/:provider/login".
+	Name         string   `json:"name" mapstructure:"name" ini:"name" yaml:"name"`
+	Issuer       string   `json:"issuer" mapstructure:"issuer" ini:"issuer" yaml:"issuer"`
+	ClientID     string   `json:"client_id" mapstructure:"client_id" ini:"client_id" yaml:"client_id"`
+	ClientSecret string   `json:"client_secret" mapstructure:"client_secret" ini:"client_secret" yaml:"client_secret"`
+	RedirectURL  string   `json:"redirect_url" mapstructure:"redirect_url" ini:"redirect_url" yaml:"redirect_url"`
+	Scopes       []string `json:"scopes" mapstructure:"scopes" ini:"scopes" yaml:"scopes"`
+	// UsernameClaim is the ID token claim used as the username of provisioned user.
+	UsernameClaim string `json:"username_claim" mapstructure:"username_claim" ini:"username_claim" yaml:"username_claim"`
+	// AutoProvision creates the user at the first login if no user is linked.
+	AutoProvision bool `json:"auto_provision" mapstructure:"auto_provision" ini:"auto_provision" yaml:"auto_provision"`
+	// LinkByEmail links the existing user with the same verified email at the first login.
+	LinkByEmail bool `json:"link_by_email" mapstructure:"link_by_email" ini:"link_by_email" yaml:"link_by_email"`
+
+	Enable bool `json:"enable" mapstructure:"enable" ini:"enable" yaml:"enable"`
+}
+
+func (*OIDC) setDefault() {
+	cv.SetDefault("oidc.name", "oidc")
+	cv.SetDefault("oidc.issuer", "")
+	cv.SetDefault("oidc.client_id", "")
+	cv.SetDefault("oidc.client_secret", "")
+	cv.SetDefault("oidc.redirect_url", "")
+	cv.SetDefault("oidc.scopes", []string{"openid", "profile", "email"})
+	cv.SetDefault("oidc.username_claim", "preferred_username")
+	cv.SetDefault("oidc.auto_provision", true)
+	cv.SetDefault("oidc.link_by_email", false)
+
+	cv.SetDefault("oidc.enable", false)
+}
@@ -0,0 +1,65 @@
+package config
+
+import "time"
+
+const (
+	OUTBOX_ENABLE         = "OUTBOX_ENABLE"         //nolint:staticcheck
+	OUTBOX_BROKER         = "OUTBOX_BROKER"         //nolint:staticcheck
+	OUTBOX_TOPIC          = "OUTBOX_TOPIC"          //nolint:staticcheck
+	OUTBOX_RELAY_INTERVAL = "OUTBOX_RELAY_INTERVAL" //nolint:staticcheck
+	OUTBOX_BATCH_SIZE     = "OUTBOX_BATCH_SIZE"     //nolint:staticcheck
+	OUTBOX_LEASE          = "OUTBOX_LEASE"          //nolint:staticcheck
+	OUTBOX_BACKOFF        = "OUTBOX_BACKOFF"        //nolint:staticcheck
+	OUTBOX_MAX_BACKOFF    = "OUTBOX_MAX_BACKOFF"    //nolint:staticcheck
+	OUTBOX_MAX_ATTEMPTS   = "OUTBOX_MAX_ATTEMPTS"   //nolint:staticcheck
+	OUTBOX_RETENTION      = "OUTBOX_RETENTION"      //nolint:staticcheck
+)
+
+// OutboxBroker is the broker the outbox events delivered to.
+type OutboxBroker string
+
+const (
+	OutboxBrokerKafka    OutboxBroker = "kafka"
+	OutboxBrokerNats     OutboxBroker = "nats"
+	OutboxBrokerMqtt     OutboxBroker = "mqtt"
+	OutboxBrokerRocketMQ OutboxBroker = "rocketmq"
+)
+
+// Outbox is the configuration of the relay delivering the outbox events, see model.OutboxEvent.
+// The events are always written, they're kept pending until the relay enabled.
+type Outbox struct {
+	// Enable runs the relay on this replica.
+	Enable bool         `json:"enable" mapstructure:"enable" ini:"enable" yaml:"enable"`
+	Broker OutboxBroker `json:"broker" mapstructure:"broker" ini:"broker" yaml:"broker"`
+	// Topic is the default topic of the events without topic.
+	Topic string `json:"topic" mapstructure:"topic" ini:"topic" yaml:"topic"`
+	// RelayInterval is how often the relay polls the pending events, at least 1 second.
+	RelayInterval time.Duration `json:"relay_interval" mapstructure:"relay_interval" ini:"relay_interval" yaml:"relay_interval"`
+	// BatchSize is the max events claimed by each poll.
+	BatchSize int `json:"batch_size" mapstructure:"batch_size" ini:"batch_size" yaml:"batch_size"`
+	// Lease is how long the claimed events are locked by the relay, the events locked by
+	// a crashed replica are delivered again once the lease expires.
+	Lease time.Duration `json:"lease" mapstructure:"lease" ini:"lease" yaml:"lease"`
+	// Backoff is the delay of the redelivery after the first failure, it doubles every
+	// failure up to MaxBackoff.
+	Backoff    time.Duration `json:"backoff" mapstructure:"backoff" ini:"backoff" yaml:"backoff"`
+	MaxBackoff time.Duration `json:"max_backoff" mapstructure:"max_backoff" ini:"max_backoff" yaml:"max_backoff"`
+	// MaxAttempts is the max delivery attempts of the event, the event still failed is given up
+	// as dead so the later events of its aggregate are not held back. Zero retries forever.
+	MaxAttempts int `json:"max_attempts" mapstructure:"max_attempts" ini:"max_attempts" yaml:"max_attempts"`
+	// Retention is how long the delivered events are kept, zero keeps forever.
+	Retention time.Duration `json:"retention" mapstructure:"retention" ini:"retention" yaml:"retention"`
+}
+
+func (*Outbox) setDefault() {
+	cv.SetDefault("outbox.enable", false)
+	cv.SetDefault("outbox.broker", "")
+	cv.SetDefault("outbox.topic", "gst-outbox")
+	cv.SetDefault("outbox.relay_interval", time.Second)
+	cv.SetDefault("outbox.batch_size", 100)
+	cv.SetDefault("outbox.lease", time.Minute)
+	cv.SetDefault("outbox.backoff", time.Second)
+	cv.SetDefault("outbox.max_backoff", 5*time.Minute)
+	cv.SetDefault("outbox.max_attempts", 20)
+	cv.SetDefault("outbox.retention", 7*24*time.Hour)
+}
@@ -9,6 +9,7 @@ const (
 	POSTGRES_SSLMODE  = "POSTGRES_SSLMODE"  //nolint:staticcheck
 	POSTGRES_TIMEZONE = "POSTGRES_TIMEZONE" //nolint:staticcheck
 	POSTGRES_ENABLE   = "POSTGRES_ENABLE"   //nolint:staticcheck
+	POSTGRES_REPLICAS = "POSTGRES_REPLICAS" //nolint:staticcheck
 )
 
 type Postgres struct {
@@ -20,6 +21,9 @@ type Postgres struct {
 	SSLMode  string `json:"sslmode" mapstructure:"sslmode" ini:"sslmode" yaml:"sslmode"`
 	TimeZone string `json:"timezone" mapstructure:"timezone" ini:"timezone" yaml:"timezone"`
 	Enable   bool   `json:"enable" mapstructure:"enable" ini:"enable" yaml:"enable"`
+
+	// Replicas is the "host:port" addresses of the read replicas, they share the database and credentials with primary.
+	Replicas []string `json:"replicas" mapstructure:"replicas" ini:"replicas" yaml:"replicas"`
 }
 
 func (*Postgres) setDefault() {
@@ -31,4 +35,5 @@ func (*Postgres) setDefault() {
 	cv.SetDefault("postgres.sslmode", "disable")
 	cv.SetDefault("postgres.timezone", "UTC")
 	cv.SetDefault("postgres.enable", true)
+	cv.SetDefault("postgres.replicas", []string{})
 }
@@ -34,6 +34,18 @@ const (
 	SERVER_CIRCUIT_BREAKER_ENABLE       = "SERVER_CIRCUIT_BREAKER_ENABLE"       //nolint:staticcheck
 
 	SERVER_CIRCULAR_BUFFER_SIZE_OPERATION_LOG = "SERVER_CIRCULAR_BUFFER_SIZE_OPERATION_LOG" //nolint:staticcheck
+
+	SERVER_IDEMPOTENCY_ENABLE   = "SERVER_IDEMPOTENCY_ENABLE"   //nolint:staticcheck
+	SERVER_IDEMPOTENCY_BACKEND  = "SERVER_IDEMPOTENCY_BACKEND"  //nolint:staticcheck
+	SERVER_IDEMPOTENCY_TTL      = "SERVER_IDEMPOTENCY_TTL"      //nolint:staticcheck
+	SERVER_IDEMPOTENCY_LOCK_TTL = "SERVER_IDEMPOTENCY_LOCK_TTL" //nolint:staticcheck
+)
+
+type IdempotencyBackend string
+
+const (
+	IdempotencyMemory IdempotencyBackend = "memory"
+	IdempotencyRedis  IdempotencyBackend = "redis"
 )
 
 type Server struct {
@@ -51,6 +63,9 @@ type Server struct {
 
 	// Circular buffer
 	CircularBuffer CircularBuffer `json:"circular_buffer" mapstructure:"circular_buffer" ini:"circular_buffer" yaml:"circular_buffer"`
+
+	// Idempotency
+	Idempotency Idempotency `json:"idempotency" mapstructure:"idempotency" ini:"idempotency" yaml:"idempotency"`
 }
 
 type CircuitBreaker struct {
@@ -66,6 +81,17 @@ type CircularBuffer struct {
 	SizeOperationLog int64 `json:"size_operation_log" mapstructure:"size_operation_log" ini:"size" yaml:"size_operation_log"`
 }
 
+// Idempotency configures the deduplication of requests carrying the "Idempotency-Key" header.
+// Backend is where the responses stored, "redis" requires redis enabled, otherwise fallback to "memory".
+// TTL is the window the response replayed for the same key, LockTTL is the max duration
+// a request holds the key, the concurrent requests with the same key are rejected in this duration.
+type Idempotency struct {
+	Enable  bool               `json:"enable" mapstructure:"enable" ini:"enable" yaml:"enable"`
+	Backend IdempotencyBackend `json:"backend" mapstructure:"backend" ini:"backend" yaml:"backend"`
+	TTL     time.Duration      `json:"ttl" mapstructure:"ttl" ini:"ttl" yaml:"ttl"`
+	LockTTL time.Duration      `json:"lock_ttl" mapstructure:"lock_ttl" ini:"lock_ttl" yaml:"lock_ttl"`
+}
+
 func (*Server) setDefault() {
 	cv.SetDefault("server.mode", Dev)
 	cv.SetDefault("server.listen", "")
@@ -86,4 +112,10 @@ func (*Server) setDefault() {
 
 	// Circular buffer defaults
 	cv.SetDefault("server.circular_buffer.size_operation_log", int64(10000))
+
+	// Idempotency defaults
+	cv.SetDefault("server.idempotency.enable", true)
+	cv.SetDefault("server.idempotency.backend", IdempotencyMemory)
+	cv.SetDefault("server.idempotency.ttl", 24*time.Hour)
+	cv.SetDefault("server.idempotency.lock_ttl", time.Minute)
 }
@@ -12,6 +12,7 @@ const (
 	SQLSERVER_TRUST_SERVER = "SQLSERVER_TRUST_SERVER" //nolint:staticcheck
 	SQLSERVER_APP_NAME     = "SQLSERVER_APP_NAME"     //nolint:staticcheck
 	SQLSERVER_ENABLE       = "SQLSERVER_ENABLE"       //nolint:staticcheck
+	SQLSERVER_REPLICAS     = "SQLSERVER_REPLICAS"     //nolint:staticcheck
 )
 
 type SQLServer struct {
@@ -24,6 +25,9 @@ type SQLServer struct {
 	TrustServer bool   `json:"trust_server" mapstructure:"trust_server" ini:"trust_server" yaml:"trust_server"`
 	AppName     string `json:"app_name" mapstructure:"app_name" ini:"app_name" yaml:"app_name"`
 	Enable      bool   `json:"enable" mapstructure:"enable" ini:"enable" yaml:"enable"`
+
+	// Replicas is the "host:port" addresses of the read replicas, they share the database and credentials with primary.
+	Replicas []string `json:"replicas" mapstructure:"replicas" ini:"replicas" yaml:"replicas"`
 }
 
 func (*SQLServer) setDefault() {
@@ -36,4 +40,5 @@ func (*SQLServer) setDefault() {
 	cv.SetDefault("sqlserver.trust_server", true)
 	cv.SetDefault("sqlserver.app_name", consts.FrameworkName)
 	cv.SetDefault("sqlserver.enable", false)
+	cv.SetDefault("sqlserver.replicas", []string{})
 }
@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/authn/apikey"
+	"github.com/forbearing/gst/logger"
+	. "github.com/forbearing/gst/response"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+	"github.com/gin-gonic/gin"
+)
+
+type apiKey struct{}
+
+// APIKey mints, lists and revokes the API keys of the current user.
+//
+// Example:
+//
+//	router.Auth().POST("/apikey", controller.APIKey.Create)
+//	router.Auth().GET("/apikey", controller.APIKey.List)
+//	router.Auth().DELETE("/apikey/:id", controller.APIKey.Delete)
+var APIKey = new(apiKey)
+
+type apiKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes,omitempty"`
+	// TTL is the lifetime of the key, eg: "720h", empty means never expire.
+	TTL string `json:"ttl,omitempty"`
+}
+
+// Create mints an API key of the current user, the plaintext key is only returned here.
+func (*apiKey) Create(c *gin.Context) {
+	log := logger.Controller.WithControllerContext(types.NewControllerContext(c), consts.Phase("CreateAPIKey"))
+	req := new(apiKeyRequest)
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Error(err)
+		ResponseJSON(c, CodeInvalidParam.WithErr(err))
+		return
+	}
+	var ttl time.Duration
+	if len(req.TTL) > 0 {
+		var err error
+		if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl < 0 {
+			log.Errorf("invalid ttl %q", req.TTL)
+			ResponseJSON(c, CodeInvalidParam.WithErr(errors.Newf("invalid ttl %q", req.TTL)))
+			return
+		}
+	}
+	k, err := apikey.Create(types.NewDatabaseContext(c), c.GetString(consts.CTX_USER_ID), c.GetString(consts.CTX_USERNAME), req.Name, req.Scopes, ttl)
+	if err != nil {
+		log.Error(err)
+		if errors.Is(err, apikey.ErrInvalidScope) {
+			ResponseJSON(c, CodeInvalidParam.WithErr(err))
+		} else {
+			ResponseJSON(c, CodeFailure)
+		}
+		return
+	}
+	ResponseJSON(c, CodeSuccess, k)
+}
+
+// List lists the API keys of the current user, the keys are identified by the id.
+func (*apiKey) List(c *gin.Context) {
+	log := logger.Controller.WithControllerContext(types.NewControllerContext(c), consts.Phase("ListAPIKey"))
+	keys, err := apikey.List(types.NewDatabaseContext(c), c.GetString(consts.CTX_USER_ID))
+	if err != nil {
+		log.Error(err)
+		ResponseJSON(c, CodeFailure)
+		return
+	}
+	ResponseJSON(c, CodeSuccess, gin.H{"items": keys, "total": len(keys)})
+}
+
+// Delete revokes the API key of the current user by path parameter "id".
+func (*apiKey) Delete(c *gin.Context) {
+	log := logger.Controller.WithControllerContext(types.NewControllerContext(c), consts.Phase("DeleteAPIKey"))
+	if err := apikey.Revoke(types.NewDatabaseContext(c), c.GetString(consts.CTX_USER_ID), c.Param(consts.PARAM_ID)); err != nil {
+		log.Error(err)
+		if errors.Is(err, apikey.ErrKeyNotFound) {
+			ResponseJSON(c, CodeNotFound.WithErr(err))
+		} else {
+			ResponseJSON(c, CodeFailure)
+		}
+		return
+	}
+	ResponseJSON(c, CodeSuccess)
+}
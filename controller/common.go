@@ -163,28 +163,32 @@ func writeFeishuSessionAndCookie(c *gin.Context, aToken, rToken string, userInfo
 		Value:   base64.StdEncoding.EncodeToString([]byte(name)), // 中文名,需要转码
 		Expires: time.Now().Add(config.App.AccessTokenExpireDuration),
 	})
-	ua := useragent.New(c.Request.UserAgent())
-	engineName, engineVersion := ua.Engine()
-	browserName, browserVersion := ua.Browser()
-	err = database.Database[*modellog.LoginLog](types.NewDatabaseContext(c)).Create(&modellog.LoginLog{
+	writeLoginLog(c, &modellog.LoginLog{
 		UserID:   userInfo.UserID,
 		Username: userInfo.Name,
 		Token:    aToken,
 		Status:   modellog.LoginStatusSuccess,
-		ClientIP: c.ClientIP(),
-		UserAgent: model.UserAgent{
-			Source:   c.Request.UserAgent(),
-			Platform: fmt.Sprintf("%s %s", ua.Platform(), ua.OS()),
-			Engine:   fmt.Sprintf("%s %s", engineName, engineVersion),
-			Browser:  fmt.Sprintf("%s %s", browserName, browserVersion),
-		},
 	})
-	if err != nil {
-		zap.S().Error(err)
-	}
 	domain := config.App.Domain
 	if len(util.ParseScheme(c.Request)) > 0 && len(c.Request.Host) > 0 {
 		domain = fmt.Sprintf("%s://%s", util.ParseScheme(c.Request), c.Request.Host)
 	}
 	c.Redirect(http.StatusTemporaryRedirect, domain)
 }
+
+// writeLoginLog records the login attempt with the client ip and user agent of the request.
+func writeLoginLog(c *gin.Context, loginLog *modellog.LoginLog) {
+	ua := useragent.New(c.Request.UserAgent())
+	engineName, engineVersion := ua.Engine()
+	browserName, browserVersion := ua.Browser()
+	loginLog.ClientIP = c.ClientIP()
+	loginLog.UserAgent = model.UserAgent{
+		Source:   c.Request.UserAgent(),
+		Platform: fmt.Sprintf("%s %s", ua.Platform(), ua.OS()),
+		Engine:   fmt.Sprintf("%s %s", engineName, engineVersion),
+		Browser:  fmt.Sprintf("%s %s", browserName, browserVersion),
+	}
+	if err := database.Database[*modellog.LoginLog](types.NewDatabaseContext(c)).Create(loginLog); err != nil {
+		zap.S().Error(err)
+	}
+}
@@ -8,19 +8,24 @@ import (
 	"io"
 	"net/http"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/authz/abac"
 	"github.com/forbearing/gst/config"
 	"github.com/forbearing/gst/database"
 	"github.com/forbearing/gst/ds/queue/circularbuffer"
 	"github.com/forbearing/gst/logger"
+	"github.com/forbearing/gst/middleware"
 	"github.com/forbearing/gst/model"
 	modellog "github.com/forbearing/gst/model/log"
 	"github.com/forbearing/gst/pkg/auditmanager"
+	"github.com/forbearing/gst/pkg/codec"
 	"github.com/forbearing/gst/pkg/filetype"
+	"github.com/forbearing/gst/pkg/filter"
 	"github.com/forbearing/gst/provider/otel"
 	. "github.com/forbearing/gst/response"
 	"github.com/forbearing/gst/service"
@@ -30,6 +35,7 @@ import (
 	pluralize "github.com/gertd/go-pluralize"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/schema"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -58,11 +64,11 @@ import (
 
 处理资源顺序:
     通用流程: Request -> ServiceBefore -> ModelBefore -> Database -> ModelAfter -> ServiceAfter -> Response.
-	导入数据: Request -> ServiceBefore -> Import -> ModelBefore ->  Database -> ModelAfter -> ServiceAfter -> Response.
+	导入数据: Request -> Import -> ServiceBefore -> ModelBefore ->  Database -> ModelAfter -> ServiceAfter -> Response.
 	导出数据: Request -> ServiceBefore -> ModelBefore -> Database -> ModelAfter -> ServiceAfter -> Export -> Response.
 
-    Import 逻辑类似于 Update 逻辑
-	Import 的 Model 的 UpdateBefore() 在 service 层里面处理, ServiceBefore 是可选的
+    Import 逻辑类似于 CreateMany 逻辑, 只是比 CreateMany 逻辑多了 Import 步骤
+	Import 之后会依次调用 Service 的 CreateManyBefore/CreateManyAfter 和 Model 的 CreateBefore/CreateAfter
 	Export 逻辑类似于 List 逻辑, 只是比 Update 逻辑多了 Export 步骤
 
 其他:
@@ -124,7 +130,7 @@ func Create[M types.Model, REQ types.Request, RSP types.Response](c *gin.Context
 // When all three generic types are identical, the factory enables automatic resource management:
 //   - Controller layer automatically handles resource creation in database
 //   - Service hooks (CreateBefore/CreateAfter) are executed for business logic
-//   - Processing flow: Request -> ServiceBefore -> ModelBefore -> Database -> ModelAfter -> ServiceAfter -> Response
+//   - Processing flow: Request -> Validate -> ServiceBefore -> ModelBefore -> Database -> ModelAfter -> ServiceAfter -> Response
 //   - The request body is directly bound to the model type M
 //   - Automatic setting of CreatedBy/UpdatedBy fields from context
 //
@@ -132,7 +138,7 @@ func Create[M types.Model, REQ types.Request, RSP types.Response](c *gin.Context
 // When types differ, the factory delegates full control to the service layer:
 //   - Service layer has complete control over resource creation
 //   - No automatic database operations or service hooks
-//   - Processing flow: Request -> Service.Create -> Response
+//   - Processing flow: Request -> Validate -> Service.Create -> Response
 //   - The request body is bound to the REQ type
 //   - Service must handle all business logic and database operations
 //
@@ -147,6 +153,15 @@ func Create[M types.Model, REQ types.Request, RSP types.Response](c *gin.Context
 // Returns:
 //   - gin.HandlerFunc: A gin handler function for HTTP POST requests
 //
+// Validation:
+// The request payload is validated by the `validate` struct tags and the optional
+// types.Validator before any service hook, see package pkg/validation.
+// The field errors are responded with CodeValidationFailed.
+//
+// Idempotency:
+// The request carrying the "Idempotency-Key" header is processed only once within the
+// configured window, the retries replay the stored response, see middleware.Idempotency.
+//
 // HTTP Response:
 //   - Success: 201 Created with the created resource data
 //   - Error: 400 Bad Request for invalid parameters, 500 Internal Server Error for other failures
@@ -162,7 +177,7 @@ func Create[M types.Model, REQ types.Request, RSP types.Response](c *gin.Context
 //	CreateFactory[*model.User, *CreateUserRequest, *CreateUserResponse]()
 func CreateFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...*types.ControllerConfig[M]) gin.HandlerFunc {
 	handler, _ := extractConfig(cfg...)
-	return func(c *gin.Context) {
+	return middleware.IdempotentHandler(func(c *gin.Context) {
 		var err error
 		var reqErr error
 
@@ -197,6 +212,9 @@ func CreateFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...
 				log.Warn(ErrRequestBodyEmpty)
 			}
 			logRequest(log, consts.PHASE_CREATE, req)
+			if !errors.Is(reqErr, io.EOF) && !validateRequest(c, ctrlSpanCtx, consts.PHASE_CREATE, log, span, req) {
+				return
+			}
 			var serviceCtx *types.ServiceContext
 			if rsp, err = traceServiceOperation[M, RSP](ctrlSpanCtx, consts.PHASE_CREATE, func(spanCtx context.Context) (RSP, error) {
 				serviceCtx = types.NewServiceContext(c, spanCtx).WithPhase(consts.PHASE_CREATE)
@@ -228,6 +246,19 @@ func CreateFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...
 			log.Infoz("create", zap.Object(reflect.TypeOf(*new(M)).Elem().String(), req))
 		}
 		logRequest(log, consts.PHASE_CREATE, req)
+		decision, ok := evaluatePolicies[M](c, log, span)
+		if !ok {
+			return
+		}
+		// Database.Create overwrites the existing record, it must be granted.
+		stored, ok := grantedRecords(c, log, span, handler, decision, itemIDs([]M{req}))
+		if !ok {
+			return
+		}
+		keepWriteDenied(decision, []M{req}, stored) // keep write denied fields
+		if !errors.Is(reqErr, io.EOF) && !validateRequest(c, ctrlSpanCtx, consts.PHASE_CREATE, log, span, req) {
+			return
+		}
 
 		// 1.Perform business logic processing before create resource.
 		var serviceCtxBefore *types.ServiceContext
@@ -301,9 +332,10 @@ func CreateFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...
 			log.Warn(err)
 		}
 
+		decision.MaskRead(req)
 		logResponse(log, consts.PHASE_CREATE, req)
 		ResponseJSON(c, CodeSuccess.WithStatus(http.StatusCreated), req)
-	}
+	})
 }
 
 // Delete is a generic function to product gin handler to delete one or multiple resources.
@@ -458,6 +490,13 @@ func DeleteFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...
 			ids = append(ids, id)
 		}
 		log.Info(fmt.Sprintf("%s delete %v", typ.Name(), ids))
+		decision, ok := evaluatePolicies[M](c, log, span)
+		if !ok {
+			return
+		}
+		if _, ok = grantedRecords(c, log, span, handler, decision, ids); !ok {
+			return
+		}
 
 		// 1.Perform business logic processing before delete resources.
 		// TODO: Should there be one service hook(DeleteBefore), or multiple?
@@ -564,13 +603,15 @@ func Update[M types.Model, REQ types.Request, RSP types.Response](c *gin.Context
 // When all three generic types are identical, the factory enables automatic resource management:
 //   - Controller layer automatically handles resource update in database
 //   - Service hooks (UpdateBefore/UpdateAfter) are executed for business logic
-//   - Processing flow: Request -> ServiceBefore -> ModelBefore -> Database -> ModelAfter -> ServiceAfter -> Response
+//   - Processing flow: Request -> Validate -> ServiceBefore -> ModelBefore -> Database -> ModelAfter -> ServiceAfter -> Response
 //   - Resource ID can be specified in two ways (route parameter has higher priority):
 //   - Route parameter: PUT /api/users/123
 //   - Request body: PUT /api/users with JSON {"id": "123", "name": "new name"}
 //   - Automatic preservation of original created_at and created_by fields
 //   - Automatic setting of updated_by field to current user
 //   - Validates resource existence before update
+//   - Optimistic concurrency for types.Versioned model: the If-Match header is checked against the
+//     current ETag, the update with stale version fails, the response contains the ETag of new version
 //
 // Mode 2: Custom Types (M != REQ or REQ != RSP)
 // When types differ, the factory delegates full control to the service layer:
@@ -594,6 +635,8 @@ func Update[M types.Model, REQ types.Request, RSP types.Response](c *gin.Context
 // HTTP Response:
 //   - Success: 200 OK with the updated resource data
 //   - Error: 400 Bad Request for invalid parameters, 404 Not Found if resource doesn't exist, 500 Internal Server Error for other failures
+//   - Conflict: 409 Conflict if the types.Versioned record has been modified by others,
+//     412 Precondition Failed if the If-Match header doesn't match the current ETag
 //
 // Resource ID Priority:
 //  1. Route parameter (/api/users/123) - highest priority
@@ -649,6 +692,9 @@ func UpdateFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...
 				log.Warn(ErrRequestBodyEmpty)
 			}
 			logRequest(log, consts.PHASE_UPDATE, req)
+			if !errors.Is(reqErr, io.EOF) && !validateRequest(c, ctrlSpanCtx, consts.PHASE_UPDATE, log, span, req) {
+				return
+			}
 			var serviceCtx *types.ServiceContext
 			if rsp, err = traceServiceOperation[M, RSP](ctrlSpanCtx, consts.PHASE_UPDATE, func(spanCtx context.Context) (RSP, error) {
 				serviceCtx = types.NewServiceContext(c, spanCtx).WithPhase(consts.PHASE_UPDATE)
@@ -705,8 +751,12 @@ func UpdateFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...
 		// 'm' is the structure value such as: &model.User{ID: myid, Name: myname}.
 		m := reflect.New(typ).Interface().(M) //nolint:errcheck
 		m.SetID(id)
-		// Make sure the record must be already exists.
-		if err = handler(types.NewDatabaseContext(c)).WithLimit(1).WithQuery(m).List(&data); err != nil {
+		decision, ok := evaluatePolicies[M](c, log, span)
+		if !ok {
+			return
+		}
+		// Make sure the record must be already exists and granted.
+		if err = handler(types.NewDatabaseContext(c)).WithLimit(1).WithQuery(m, types.QueryConfig{Filter: decision.Scope(nil)}).List(&data); err != nil {
 			log.Error(err)
 			ResponseJSON(c, CodeFailure.WithErr(err))
 			otel.RecordError(span, err)
@@ -718,9 +768,21 @@ func UpdateFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...
 			return
 		}
 
+		if !checkIfMatch(c, log, span, data[0]) {
+			return
+		}
+		// Use the version matched by If-Match if the request body doesn't contain the version.
+		if v, ok := any(req).(types.Versioned); ok && v.GetVersion() == 0 && len(c.GetHeader(consts.HEADER_IF_MATCH)) > 0 {
+			v.SetVersion(any(data[0]).(types.Versioned).GetVersion()) //nolint:errcheck
+		}
+
+		decision.KeepWrite(req, data[0])                   // keep original write denied fields
 		req.SetCreatedAt(data[0].GetCreatedAt())           // keep original "created_at"
 		req.SetCreatedBy(data[0].GetCreatedBy())           // keep original "created_by"
 		req.SetUpdatedBy(c.GetString(consts.CTX_USERNAME)) // set updated_by to current user”
+		if !validateRequest(c, ctrlSpanCtx, consts.PHASE_UPDATE, log, span, req) {
+			return
+		}
 
 		// 1.Perform business logic processing before update resource.
 		var serviceCtxBefore *types.ServiceContext
@@ -737,7 +799,7 @@ func UpdateFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...
 		log.Infoz("update in database", zap.Object(typ.Name(), req))
 		if err = handler(types.NewDatabaseContext(c)).Update(req); err != nil {
 			log.Error(err)
-			ResponseJSON(c, CodeFailure.WithErr(err))
+			ResponseJSON(c, updateErrorCode(err).WithErr(err))
 			otel.RecordError(span, err)
 			return
 		}
@@ -789,7 +851,9 @@ func UpdateFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...
 			log.Warn(err)
 		}
 
+		decision.MaskRead(req)
 		logResponse(log, consts.PHASE_UPDATE, req)
+		setETag(c, req)
 		ResponseJSON(c, CodeSuccess, req)
 	}
 }
@@ -815,13 +879,15 @@ func Patch[M types.Model, REQ types.Request, RSP types.Response](c *gin.Context)
 // When all three generic types are identical, the factory enables automatic resource management:
 //   - Controller layer automatically handles partial resource update in database
 //   - Service hooks (PatchBefore/PatchAfter) are executed for business logic
-//   - Processing flow: Request -> ServiceBefore -> ModelBefore -> Database -> ModelAfter -> ServiceAfter -> Response
+//   - Processing flow: Request -> Validate -> ServiceBefore -> ModelBefore -> Database -> ModelAfter -> ServiceAfter -> Response
 //   - Resource ID can be specified in two ways (route parameter has higher priority):
 //   - Route parameter: PATCH /api/users/123
 //   - Request body: PATCH /api/users with JSON {"id": "123", "name": "new name"}
 //   - Only non-zero fields from request are applied to existing resource (partial update)
 //   - Automatic setting of updated_by field to current user
 //   - Validates resource existence before update
+//   - Optimistic concurrency for types.Versioned model: the If-Match header is checked against the
+//     current ETag, the update with stale version fails, the response contains the ETag of new version
 //   - Preserves original created_at and created_by fields automatically
 //
 // Mode 2: Custom Types (M != REQ or REQ != RSP)
@@ -852,6 +918,8 @@ func Patch[M types.Model, REQ types.Request, RSP types.Response](c *gin.Context)
 // HTTP Response:
 //   - Success: 200 OK with the updated resource data
 //   - Error: 400 Bad Request for invalid parameters, 404 Not Found if resource doesn't exist, 500 Internal Server Error for other failures
+//   - Conflict: 409 Conflict if the types.Versioned record has been modified by others,
+//     412 Precondition Failed if the If-Match header doesn't match the current ETag
 //
 // Resource ID Priority:
 //  1. Route parameter (/api/users/123) - highest priority
@@ -908,6 +976,9 @@ func PatchFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...*
 				log.Warn(ErrRequestBodyEmpty)
 			}
 			logRequest(log, consts.PHASE_PATCH, req)
+			if !errors.Is(reqErr, io.EOF) && !validateRequest(c, ctrlSpanCtx, consts.PHASE_PATCH, log, span, req) {
+				return
+			}
 			var serviceCtx *types.ServiceContext
 			if rsp, err = traceServiceOperation[M, RSP](ctrlSpanCtx, consts.PHASE_PATCH, func(spanCtx context.Context) (RSP, error) {
 				serviceCtx = types.NewServiceContext(c, spanCtx).WithPhase(consts.PHASE_PATCH)
@@ -950,9 +1021,13 @@ func PatchFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...*
 		// 'm' is the structure value such as: &model.User{ID: myid, Name: myname}.
 		m := reflect.New(typ).Interface().(M) //nolint:errcheck
 		m.SetID(id)
+		decision, ok := evaluatePolicies[M](c, log, span)
+		if !ok {
+			return
+		}
 
-		// Make sure the record must be already exists.
-		if err := handler(types.NewDatabaseContext(c)).WithLimit(1).WithQuery(m).List(&data); err != nil {
+		// Make sure the record must be already exists and granted.
+		if err := handler(types.NewDatabaseContext(c)).WithLimit(1).WithQuery(m, types.QueryConfig{Filter: decision.Scope(nil)}).List(&data); err != nil {
 			log.Error(err)
 			ResponseJSON(c, CodeFailure.WithErr(err))
 			otel.RecordError(span, err)
@@ -963,15 +1038,23 @@ func PatchFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...*
 			ResponseJSON(c, CodeNotFound)
 			return
 		}
+		if !checkIfMatch(c, log, span, data[0]) {
+			return
+		}
 		// req.SetCreatedAt(data[0].GetCreatedAt())
 		// req.SetCreatedBy(data[0].GetCreatedBy())
 		// req.SetUpdatedBy(c.GetString(CTX_USERNAME))
 		data[0].SetUpdatedBy(c.GetString(consts.CTX_USERNAME))
 
+		decision.KeepWrite(req, data[0]) // keep original write denied fields
 		newVal := reflect.ValueOf(req).Elem()
 		oldVal := reflect.ValueOf(data[0]).Elem()
 		patchValue(log, typ, oldVal, newVal)
 		cur := oldVal.Addr().Interface().(M) //nolint:errcheck
+		// Validate the merged record, the fields not provided in request keep their stored values.
+		if !validateRequest(c, ctrlSpanCtx, consts.PHASE_PATCH, log, span, cur) {
+			return
+		}
 
 		// 1.Perform business logic processing before partial update resource.
 		var serviceCtxBefore *types.ServiceContext
@@ -987,7 +1070,7 @@ func PatchFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...*
 		// 2.Partial update resource in database.
 		if err := handler(types.NewDatabaseContext(c)).Update(cur); err != nil {
 			log.Error(err)
-			ResponseJSON(c, CodeFailure.WithErr(err))
+			ResponseJSON(c, updateErrorCode(err).WithErr(err))
 			otel.RecordError(span, err)
 			return
 		}
@@ -1042,7 +1125,9 @@ func PatchFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...*
 
 		// NOTE: You should response `oldVal` instead of `req`.
 		// The req is `newVal`.
+		decision.MaskRead(cur)
 		logResponse(log, consts.PHASE_PATCH, cur)
+		setETag(c, cur)
 		ResponseJSON(c, CodeSuccess, cur)
 	}
 }
@@ -1108,6 +1193,9 @@ func List[M types.Model, REQ types.Request, RSP types.Response](c *gin.Context)
 //   - _expand: Comma-separated list of fields to expand (foreign key relationships)
 //   - _depth: Expansion depth for recursive relationships (1-99, default: 1)
 //   - _fuzzy: Enable fuzzy matching for string fields (true/false)
+//   - field[op]: Operator-aware filter on model fields, eg: age[gte]=18, status[in]=a,b, deleted_at[null]=true
+//     (op: eq, ne, gt, gte, lt, lte, in, nin, like, null, between)
+//   - _filter: Json encoded filter with nested AND/OR groups, see types.Filter
 //   - _or: Use OR logic instead of AND for multiple conditions (true/false)
 //   - _sortby: Field name for sorting (append " desc" for descending order)
 //   - _select: Comma-separated list of fields to select
@@ -1244,7 +1332,25 @@ func ListFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...*t
 		}
 		log.Infoz(fmt.Sprintf("%s: list query parameter", typ.Name()), zap.Object(typ.String(), m))
 
-		var err error
+		queryFilter, err := filter.Parse(c.Request.URL.Query())
+		if err != nil {
+			log.Error(err)
+			ResponseJSON(c, CodeInvalidParam.WithErr(err))
+			otel.RecordError(span, err)
+			return
+		}
+		decision, ok := evaluatePolicies[M](c, log, span)
+		if !ok {
+			return
+		}
+		if err = decision.CheckQuery(m, queryFilter, c.Query(consts.QUERY_SORTBY)); err != nil {
+			log.Error(err)
+			ResponseJSON(c, CodeForbidden.WithErr(err))
+			otel.RecordError(span, err)
+			return
+		}
+		queryFilter = decision.Scope(queryFilter)
+
 		var or bool
 		var fuzzy bool
 		var expands []string
@@ -1261,6 +1367,10 @@ func ListFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...*t
 				nocache = _nocache
 			}
 		}
+		// The cache is shared by the subjects, never serve the restricted subject from cache.
+		if decision.Restricted() {
+			nocache = true
+		}
 		if orStr, ok := c.GetQuery(consts.QUERY_OR); ok {
 			or, _ = strconv.ParseBool(orStr)
 		}
@@ -1346,6 +1456,7 @@ func ListFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...*t
 				FuzzyMatch: fuzzy,
 				AllowEmpty: true,
 				RawQuery:   svc.FilterRaw(ctx),
+				Filter:     queryFilter,
 			}).
 			WithCursor(cursorValue, cursorNext, cursorFields).
 			WithExclude(m.Excludes()).
@@ -1373,6 +1484,9 @@ func ListFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...*t
 			otel.RecordError(span, err)
 			return
 		}
+		for i := range data {
+			decision.MaskRead(data[i])
+		}
 		total := new(int64)
 		nototalStr, _ := c.GetQuery(consts.QUERY_NOTOTAL)
 		nototal, _ = strconv.ParseBool(nototalStr)
@@ -1387,6 +1501,7 @@ func ListFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...*t
 					FuzzyMatch: fuzzy,
 					AllowEmpty: true,
 					RawQuery:   svc.FilterRaw(ctx),
+					Filter:     queryFilter,
 				}).
 				WithExclude(m.Excludes()).
 				WithTimeRange(columnName, startTime, endTime).
@@ -1474,6 +1589,7 @@ func Get[M types.Model, REQ types.Request, RSP types.Response](c *gin.Context) {
 //   - Supports comprehensive query parameters for field expansion, caching, and selection
 //   - Automatic caching support with configurable cache control
 //   - Advanced features: deep recursive expansion, field selection, cache bypass
+//   - Responds the ETag header for types.Versioned model and honours the If-None-Match header
 //   - Resource ID must be provided via route parameter (e.g., /api/users/123)
 //
 // Mode 2: Custom Types (M != REQ or REQ != RSP)
@@ -1506,6 +1622,7 @@ func Get[M types.Model, REQ types.Request, RSP types.Response](c *gin.Context) {
 //
 // HTTP Response (Mode 1):
 //   - Success: 200 OK with resource data or cached byte response
+//   - Not Modified: 304 Not Modified when the If-None-Match header matches the ETag of types.Versioned model
 //   - Not Found: 404 Not Found when resource doesn't exist or has empty ID/CreatedAt
 //   - Error: 400 Bad Request for missing route parameter, 500 Internal Server Error for other failures
 //
@@ -1606,6 +1723,11 @@ func GetFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...*ty
 		m := reflect.New(typ).Interface().(M) //nolint:errcheck
 		m.SetID(param)                        // `GetBefore` hook need id.
 
+		decision, ok := evaluatePolicies[M](c, log, span)
+		if !ok {
+			return
+		}
+
 		var err error
 		var expands []string
 		nocache := true // default disable cache.
@@ -1616,6 +1738,10 @@ func GetFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...*ty
 				nocache = _nocache
 			}
 		}
+		// The cache is shared by the subjects, never serve the restricted subject from cache.
+		if decision.Restricted() {
+			nocache = true
+		}
 		if depthStr, ok := c.GetQuery(consts.QUERY_DEPTH); ok {
 			depth, _ = strconv.Atoi(depthStr)
 			if depth < 1 || depth > 99 {
@@ -1678,9 +1804,14 @@ func GetFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...*ty
 			return
 		}
 		// 2.Get resource from database.
+		// The record not granted is not found.
+		db := handler(types.NewDatabaseContext(c))
+		if rows := decision.Scope(nil); rows != nil {
+			db = db.WithQuery(reflect.New(typ).Interface().(M), types.QueryConfig{AllowEmpty: true, Filter: rows}) //nolint:errcheck
+		}
 		cache := make([]byte, 0)
 		cached := false
-		if err = handler(types.NewDatabaseContext(c)).
+		if err = db.
 			WithIndex(index).
 			WithSelect(strings.Split(selects, ",")...).
 			WithExpand(expands).
@@ -1705,6 +1836,7 @@ func GetFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...*ty
 			otel.RecordError(span, err)
 			return
 		}
+		decision.MaskRead(m)
 		// It will returns a empty types.Model if found nothing from database,
 		// we should response status code "CodeNotFound".
 		if !cached {
@@ -1741,6 +1873,13 @@ func GetFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...*ty
 			log.Warn(err)
 		}
 
+		// Respond 304 if the client already has the current version,
+		// the record is decoded from the cache too.
+		setETag(c, m)
+		if tag := etag(m); len(tag) > 0 && matchETag(c.GetHeader(consts.HEADER_IF_NONE_MATCH), tag, true) {
+			c.Status(http.StatusNotModified)
+			return
+		}
 		if cached {
 			ResponseBytes(c, CodeSuccess, cache)
 		} else {
@@ -1863,7 +2002,7 @@ func CreateMany[M types.Model, REQ types.Request, RSP types.Response](c *gin.Con
 // When all three generic types are identical, the factory enables automatic resource management:
 //   - Controller layer automatically handles batch resource creation in database
 //   - Service hooks (CreateManyBefore/CreateManyAfter) are executed for business logic
-//   - Processing flow: Request -> ServiceBefore -> Database -> ServiceAfter -> Response
+//   - Processing flow: Request -> Validate -> ServiceBefore -> Database -> ServiceAfter -> Response
 //   - The request body is bound to requestData[M] structure containing Items slice
 //   - Automatic setting of CreatedBy/UpdatedBy fields from context for each item
 //   - Supports atomic operations through options configuration
@@ -1891,6 +2030,7 @@ func CreateMany[M types.Model, REQ types.Request, RSP types.Response](c *gin.Con
 // HTTP Response:
 //   - Success: 201 Created with batch operation summary and created resources
 //   - Error: 400 Bad Request for invalid parameters, 500 Internal Server Error for other failures
+//   - Replay: the stored response if the "Idempotency-Key" header was used, see middleware.Idempotency
 //
 // Request Body Format (Unified Types):
 //
@@ -1924,7 +2064,7 @@ func CreateMany[M types.Model, REQ types.Request, RSP types.Response](c *gin.Con
 //	// Service layer controls all batch creation logic
 func CreateManyFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...*types.ControllerConfig[M]) gin.HandlerFunc {
 	handler, _ := extractConfig(cfg...)
-	return func(c *gin.Context) {
+	return middleware.IdempotentHandler(func(c *gin.Context) {
 		var err error
 		var reqErr error
 
@@ -1959,6 +2099,9 @@ func CreateManyFactory[M types.Model, REQ types.Request, RSP types.Response](cfg
 				log.Warn(ErrRequestBodyEmpty)
 			}
 			logRequest(log, consts.PHASE_CREATE_MANY, req)
+			if !errors.Is(reqErr, io.EOF) && !validateRequest(c, ctrlSpanCtx, consts.PHASE_CREATE_MANY, log, span, req) {
+				return
+			}
 			var serviceCtx *types.ServiceContext
 			if rsp, err = traceServiceOperation[M, RSP](ctrlSpanCtx, consts.PHASE_CREATE_MANY, func(spanCtx context.Context) (RSP, error) {
 				serviceCtx = types.NewServiceContext(c, spanCtx).WithPhase(consts.PHASE_CREATE_MANY)
@@ -1996,6 +2139,19 @@ func CreateManyFactory[M types.Model, REQ types.Request, RSP types.Response](cfg
 			m.SetUpdatedBy(c.GetString(consts.CTX_USERNAME))
 			log.Infoz("create_many", zap.Bool("atomic", req.Options.Atomic), zap.Object(typ.Name(), m))
 		}
+		decision, ok := evaluatePolicies[M](c, log, span)
+		if !ok {
+			return
+		}
+		// Database.Create overwrites the existing records, they must be granted.
+		stored, ok := grantedRecords(c, log, span, handler, decision, itemIDs(req.Items))
+		if !ok {
+			return
+		}
+		keepWriteDenied(decision, req.Items, stored) // keep write denied fields
+		if !validateItems(c, ctrlSpanCtx, consts.PHASE_CREATE_MANY, log, span, req.Items) {
+			return
+		}
 
 		// 1.Perform business logic processing before batch create resource.
 		var serviceCtxBefore *types.ServiceContext
@@ -2072,9 +2228,12 @@ func CreateManyFactory[M types.Model, REQ types.Request, RSP types.Response](cfg
 				Failed:    0,
 			}
 		}
+		for i := range req.Items {
+			decision.MaskRead(req.Items[i])
+		}
 		logResponse(log, consts.PHASE_CREATE_MANY, req)
 		ResponseJSON(c, CodeSuccess.WithStatus(http.StatusCreated), req)
-	}
+	})
 }
 
 // DeleteMany
@@ -2213,6 +2372,13 @@ func DeleteManyFactory[M types.Model, REQ types.Request, RSP types.Response](cfg
 			m.SetID(id)
 			req.Items = append(req.Items, m)
 		}
+		decision, ok := evaluatePolicies[M](c, log, span)
+		if !ok {
+			return
+		}
+		if _, ok = grantedRecords(c, log, span, handler, decision, itemIDs(req.Items)); !ok {
+			return
+		}
 		var serviceCtxBefore *types.ServiceContext
 		if err = traceServiceHook[M](ctrlSpanCtx, consts.PHASE_DELETE_MANY_BEFORE, func(spanCtx context.Context) error {
 			serviceCtxBefore = types.NewServiceContext(c, spanCtx).WithPhase(consts.PHASE_DELETE_MANY_BEFORE)
@@ -2373,6 +2539,9 @@ func UpdateManyFactory[M types.Model, REQ types.Request, RSP types.Response](cfg
 				log.Warn(ErrRequestBodyEmpty)
 			}
 			logRequest(log, consts.PHASE_UPDATE_MANY, req)
+			if !errors.Is(reqErr, io.EOF) && !validateRequest(c, ctrlSpanCtx, consts.PHASE_UPDATE_MANY, log, span, req) {
+				return
+			}
 			var serviceCtx *types.ServiceContext
 			if rsp, err = traceServiceOperation[M, RSP](ctrlSpanCtx, consts.PHASE_UPDATE_MANY, func(spanCtx context.Context) (RSP, error) {
 				serviceCtx = types.NewServiceContext(c, spanCtx).WithPhase(consts.PHASE_UPDATE_MANY)
@@ -2399,6 +2568,19 @@ func UpdateManyFactory[M types.Model, REQ types.Request, RSP types.Response](cfg
 			log.Warn(ErrRequestBodyEmpty)
 		}
 		logRequest(log, consts.PHASE_UPDATE_MANY, req)
+		decision, ok := evaluatePolicies[M](c, log, span)
+		if !ok {
+			return
+		}
+		// Database.Update creates the records not stored, the records stored must be granted.
+		stored, ok := grantedRecords(c, log, span, handler, decision, itemIDs(req.Items))
+		if !ok {
+			return
+		}
+		keepWriteDenied(decision, req.Items, stored) // keep original write denied fields
+		if !validateItems(c, ctrlSpanCtx, consts.PHASE_UPDATE_MANY, log, span, req.Items) {
+			return
+		}
 
 		// 1.Perform business logic processing before batch update resource.
 		var serviceCtxBefore *types.ServiceContext
@@ -2415,7 +2597,7 @@ func UpdateManyFactory[M types.Model, REQ types.Request, RSP types.Response](cfg
 		if !errors.Is(reqErr, io.EOF) {
 			if err = handler(types.NewDatabaseContext(c)).Update(req.Items...); err != nil {
 				log.Error(err)
-				ResponseJSON(c, CodeFailure.WithErr(err))
+				ResponseJSON(c, updateErrorCode(err).WithErr(err))
 				otel.RecordError(span, err)
 				return
 			}
@@ -2475,6 +2657,9 @@ func UpdateManyFactory[M types.Model, REQ types.Request, RSP types.Response](cfg
 				Failed:    0,
 			}
 		}
+		for i := range req.Items {
+			decision.MaskRead(req.Items[i])
+		}
 		logResponse(log, consts.PHASE_UPDATE_MANY, req)
 		ResponseJSON(c, CodeSuccess, req)
 	}
@@ -2565,6 +2750,9 @@ func PatchManyFactory[M types.Model, REQ types.Request, RSP types.Response](cfg
 				log.Warn(ErrRequestBodyEmpty)
 			}
 			logRequest(log, consts.PHASE_PATCH_MANY, req)
+			if !errors.Is(reqErr, io.EOF) && !validateRequest(c, ctrlSpanCtx, consts.PHASE_PATCH_MANY, log, span, req) {
+				return
+			}
 			var serviceCtx *types.ServiceContext
 			if rsp, err = traceServiceOperation[M, RSP](ctrlSpanCtx, consts.PHASE_PATCH_MANY, func(spanCtx context.Context) (RSP, error) {
 				serviceCtx = types.NewServiceContext(c, spanCtx).WithPhase(consts.PHASE_PATCH_MANY)
@@ -2593,11 +2781,18 @@ func PatchManyFactory[M types.Model, REQ types.Request, RSP types.Response](cfg
 			log.Warn(ErrRequestBodyEmpty)
 		}
 		logRequest(log, consts.PHASE_PATCH_MANY, req)
+		decision, ok := evaluatePolicies[M](c, log, span)
+		if !ok {
+			return
+		}
+		if _, ok = grantedRecords(c, log, span, handler, decision, itemIDs(req.Items)); !ok {
+			return
+		}
 		for _, m := range req.Items {
 			var results []M
 			v := reflect.New(typ).Interface().(M) //nolint:errcheck
 			v.SetID(m.GetID())
-			if err = handler(types.NewDatabaseContext(c)).WithLimit(1).WithQuery(v).List(&results); err != nil {
+			if err = handler(types.NewDatabaseContext(c)).WithLimit(1).WithQuery(v, types.QueryConfig{Filter: decision.Scope(nil)}).List(&results); err != nil {
 				log.Error(err)
 				otel.RecordError(span, err)
 				continue
@@ -2610,6 +2805,7 @@ func PatchManyFactory[M types.Model, REQ types.Request, RSP types.Response](cfg
 				log.Warnf("partial update resource not found, id is empty")
 				continue
 			}
+			decision.KeepWrite(m, results[0]) // keep original write denied fields
 			oldVal, newVal := reflect.ValueOf(results[0]).Elem(), reflect.ValueOf(m).Elem()
 			patchValue(log, typ, oldVal, newVal)
 			shouldUpdates = append(shouldUpdates, oldVal.Addr().Interface().(M)) //nolint:errcheck
@@ -2630,7 +2826,7 @@ func PatchManyFactory[M types.Model, REQ types.Request, RSP types.Response](cfg
 		if !errors.Is(reqErr, io.EOF) {
 			if err = handler(types.NewDatabaseContext(c)).Update(shouldUpdates...); err != nil {
 				log.Error(err)
-				ResponseJSON(c, CodeFailure.WithErr(err))
+				ResponseJSON(c, updateErrorCode(err).WithErr(err))
 				otel.RecordError(span, err)
 				return
 			}
@@ -2690,6 +2886,9 @@ func PatchManyFactory[M types.Model, REQ types.Request, RSP types.Response](cfg
 				Failed:    0,
 			}
 		}
+		for i := range req.Items {
+			decision.MaskRead(req.Items[i])
+		}
 		logResponse(log, consts.PHASE_PATCH_MANY, req)
 		ResponseJSON(c, CodeSuccess, req)
 	}
@@ -2729,6 +2928,31 @@ func Export[M types.Model, REQ types.Request, RSP types.Response](c *gin.Context
 }
 
 // ExportFactory is a factory function to export resources to frontend.
+//
+// The resources are encoded by the service Export method, the default implementation
+// service.Base.Export supports csv, xlsx and ndjson, the columns are the model fields
+// with json tag and can be chosen by query parameter `_select`.
+// The format is chosen by query parameter `_format`(csv, xlsx, ndjson) or "Accept" header,
+// default to xlsx. The "Content-Type" and "Content-Disposition" are set according to the format.
+//
+// For examples:
+//
+//	/user/export?_format=csv&_select=id,name,email
+//	/user/export?_format=ndjson
+//
+// Set query parameter `_stream=true` to export large tables in bounded memory, the records
+// are read from database batch by batch by keyset pagination and written to the response
+// incrementally with chunked transfer encoding. In streaming mode:
+//   - `size` is the batch size, default to 1000.
+//   - `limit` is the total number of records to export, default no limit.
+//   - `_cursor_fields`, `_cursor_value` and `_cursor_next` specify the keyset field(default "id")
+//     and where to start, records are ordered by the keyset field and `_sortby` is ignored.
+//   - Service.ListAfter is called for each batch and Service.Export is not used.
+//
+// For examples:
+//
+//	/user/export?_stream=true&_format=csv
+//	/user/export?_stream=true&_format=ndjson&size=5000&_cursor_fields=created_at
 func ExportFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...*types.ControllerConfig[M]) gin.HandlerFunc {
 	handler, _ := extractConfig(cfg...)
 	return func(c *gin.Context) {
@@ -2768,15 +2992,45 @@ func ExportFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...
 		}
 		log.Info("query parameter: ", m)
 
-		var err error
+		queryFilter, err := filter.Parse(c.Request.URL.Query())
+		if err != nil {
+			log.Error(err)
+			ResponseJSON(c, CodeInvalidParam.WithErr(err))
+			otel.RecordError(span, err)
+			return
+		}
+		decision, ok := evaluatePolicies[M](c, log, span)
+		if !ok {
+			return
+		}
+		if err = decision.CheckQuery(m, queryFilter, c.Query(consts.QUERY_SORTBY)); err != nil {
+			log.Error(err)
+			ResponseJSON(c, CodeForbidden.WithErr(err))
+			otel.RecordError(span, err)
+			return
+		}
+		queryFilter = decision.Scope(queryFilter)
+
 		var or bool
 		var fuzzy bool
+		var stream bool
+		var cursorNext bool
+		cursorValue := c.Query(consts.QUERY_CURSOR_VALUE)
+		cursorFields := c.Query(consts.QUERY_CURSOR_FIELDS)
 		depth := 1
 		var expands []string
 		data := make([]M, 0)
 		if orStr, ok := c.GetQuery(consts.QUERY_OR); ok {
 			or, _ = strconv.ParseBool(orStr)
 		}
+		if streamStr, ok := c.GetQuery(consts.QUERY_STREAM); ok {
+			stream, _ = strconv.ParseBool(streamStr)
+		}
+		if cursorNextStr, ok := c.GetQuery(consts.QUERY_CURSOR_NEXT); ok {
+			cursorNext, _ = strconv.ParseBool(cursorNextStr)
+		} else {
+			cursorNext = true
+		}
 		if fuzzyStr, ok := c.GetQuery(consts.QUERY_FUZZY); ok {
 			fuzzy, _ = strconv.ParseBool(fuzzyStr)
 		}
@@ -2841,7 +3095,32 @@ func ExportFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...
 			return
 		}
 		sortBy, _ := c.GetQuery(consts.QUERY_SORTBY)
-		_, _ = page, size
+		_ = page
+		if stream {
+			dbSelects := strings.Split(selects, ",")
+			if len(selects) > 0 && len(cursorFields) > 0 {
+				dbSelects = append(dbSelects, cursorFields) // cursor field is required by keyset pagination.
+			}
+			exportStream(c, ctrlSpanCtx, span, svc, log, decision, handler(types.NewDatabaseContext(c)).
+				WithLimit(limit).
+				WithBatchSize(size).
+				WithOr(or).
+				WithIndex(index).
+				WithSelect(dbSelects...).
+				WithQuery(svc.Filter(svcCtx, m), types.QueryConfig{
+					FuzzyMatch: fuzzy,
+					AllowEmpty: true,
+					RawQuery:   svc.FilterRaw(svcCtx),
+					Filter:     queryFilter,
+				}).
+				WithExclude(m.Excludes()).
+				WithExpand(expands, sortBy).
+				WithTimeRange(columnName, startTime, endTime).
+				WithCursor(cursorValue, cursorNext, cursorFields),
+				codec.Columns(typ, strings.Split(selects, ",")...),
+			)
+			return
+		}
 		// 2.List resources from database.
 		if err = handler(types.NewDatabaseContext(c)).
 			// WithPagination(page, size). // 不要使用 WithPagination, 否则 WithLimit 不生效
@@ -2853,6 +3132,7 @@ func ExportFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...
 				FuzzyMatch: fuzzy,
 				AllowEmpty: true,
 				RawQuery:   svc.FilterRaw(svcCtx),
+				Filter:     queryFilter,
 			}).
 			WithExclude(m.Excludes()).
 			WithExpand(expands, sortBy).
@@ -2873,7 +3153,11 @@ func ExportFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...
 			otel.RecordError(span, err)
 			return
 		}
+		for i := range data {
+			decision.MaskRead(data[i])
+		}
 		log.Info("export data length: ", len(data))
+		format := codec.Negotiate(c.Request.URL.Query(), c.Request.Header)
 		// 4.Export
 		exported, err := traceServiceExport[M](ctrlSpanCtx, consts.PHASE_EXPORT, func(spanCtx context.Context) ([]byte, error) {
 			return svc.Export(types.NewServiceContext(c, spanCtx).WithPhase(consts.PHASE_EXPORT), data...)
@@ -2906,19 +3190,115 @@ func ExportFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...
 		// 	log.Error("failed to write operation log to database: ", err.Error())
 		// }
 		ResponseDATA(c, exported, map[string]string{
-			"Content-Disposition": "attachment; filename=exported.xlsx",
+			"Content-Type":        format.ContentType(),
+			"Content-Disposition": "attachment; filename=exported." + format.Ext(),
 		})
 	}
 }
 
-// Import
+// exportStream writes the resources to the response batch by batch with chunked transfer encoding,
+// the database is iterated by keyset pagination so only one batch is held in memory.
+// The Service.ListAfter hook is invoked for each batch, and the Service.Export is not used,
+// the resources are always encoded by the built-in codec.
+//
+// The response status and headers are sent before the first batch, so an error occurred
+// in the middle of the stream cannot be reported to the client by response code, the
+// response is truncated and the error is logged.
+func exportStream[M types.Model, REQ types.Request, RSP types.Response](
+	c *gin.Context,
+	ctrlSpanCtx context.Context,
+	span trace.Span,
+	svc types.Service[M, REQ, RSP],
+	log types.Logger,
+	decision *abac.Decision,
+	db types.Database[M],
+	columns []codec.Column,
+) {
+	format := codec.Negotiate(c.Request.URL.Query(), c.Request.Header)
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", "attachment; filename=exported."+format.Ext())
+	c.Status(http.StatusOK)
+
+	var total int
+	enc, err := codec.NewEncoder(c.Writer, format, columns)
+	if err != nil {
+		log.Error(err)
+		c.Writer.Header().Del("Content-Type")
+		c.Writer.Header().Del("Content-Disposition")
+		ResponseJSON(c, CodeFailure.WithErr(err))
+		otel.RecordError(span, err)
+		return
+	}
+	err = db.Iterate(func(batch []M) error {
+		if err := traceServiceHook[M](ctrlSpanCtx, consts.PHASE_EXPORT, func(spanCtx context.Context) error {
+			return svc.ListAfter(types.NewServiceContext(c, spanCtx).WithPhase(consts.PHASE_EXPORT), &batch)
+		}); err != nil {
+			return err
+		}
+		for _, m := range batch {
+			decision.MaskRead(m)
+			if err := enc.Encode(m); err != nil {
+				return err
+			}
+		}
+		c.Writer.Flush()
+		total += len(batch)
+		return nil
+	})
+	if err == nil {
+		err = enc.Close()
+	}
+	if err != nil {
+		log.Error(err)
+		otel.RecordError(span, err)
+		if !c.Writer.Written() {
+			c.Writer.Header().Del("Content-Type")
+			c.Writer.Header().Del("Content-Disposition")
+			ResponseJSON(c, CodeFailure.WithErr(err))
+			return
+		}
+		c.Abort()
+		return
+	}
+	c.Writer.Flush()
+	log.Info("export data length: ", total)
+}
+
+// importReport is the response data of ImportFactory.
+type importReport struct {
+	Total     int             `json:"total"`
+	Succeeded int             `json:"succeeded"`
+	Failed    int             `json:"failed"`
+	Errors    codec.RowErrors `json:"errors,omitempty"`
+}
+
+// Import is a gin handler to import resources from uploaded file.
 func Import[M types.Model, REQ types.Request, RSP types.Response](c *gin.Context) {
 	ImportFactory[M, REQ, RSP]()(c)
 }
 
-// ImportFactory
+// ImportFactory is a factory function that produces a gin handler for importing resources
+// from the uploaded file, the form field name of the file must be "file".
+//
+// The file is decoded by the service Import method, the default implementation
+// service.Base.Import supports csv, xlsx and ndjson, header row of csv/xlsx is mapped
+// back to the model fields by json tag.
+// The format is specified by query parameter `_format`, or detected from file content.
+//
+// The decoded resources are created in the same way as CreateManyFactory:
+// Service.CreateManyBefore -> Model.CreateBefore -> Database -> Model.CreateAfter -> Service.CreateManyAfter.
+// The service instance is the one registered for consts.PHASE_IMPORT.
+//
+// Rows failed to decode are skipped and reported in the response:
+//
+//	{
+//	  "total": 3,
+//	  "succeeded": 2,
+//	  "failed": 1,
+//	  "errors": [{"row": 3, "column": "age", "error": "strconv.ParseUint: parsing \"abc\": invalid syntax"}]
+//	}
 func ImportFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...*types.ControllerConfig[M]) gin.HandlerFunc {
-	handler, _ := extractConfig(cfg...)
+	handler, db := extractConfig(cfg...)
 	return func(c *gin.Context) {
 		ctrlSpanCtx, span := startControllerSpan[M](c, consts.PHASE_IMPORT)
 		defer span.End()
@@ -2961,28 +3341,71 @@ func ImportFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...
 
 		// check filetype
 
+		svc := service.Factory[M, REQ, RSP]().Service(consts.PHASE_IMPORT)
+		// Import drains buf, keep the content to locate the rows.
+		data := buf.Bytes()
+		var rowErrs codec.RowErrors
 		ml, err := traceServiceImport(ctrlSpanCtx, consts.PHASE_IMPORT, func(spanCtx context.Context) ([]M, error) {
-			return service.Factory[M, REQ, RSP]().Service(consts.PHASE_IMPORT).
-				Import(types.NewServiceContext(c, spanCtx).WithPhase(consts.PHASE_IMPORT), buf)
+			return svc.Import(types.NewServiceContext(c, spanCtx).WithPhase(consts.PHASE_IMPORT), buf)
 		})
+		if err != nil && !errors.As(err, &rowErrs) {
+			log.Error(err)
+			ResponseJSON(c, CodeFailure.WithErr(err))
+			otel.RecordError(span, err)
+			return
+		}
+		failed := make(map[int]struct{}, len(rowErrs))
+		for _, e := range rowErrs {
+			failed[e.Row] = struct{}{}
+		}
+		total := len(ml) + len(failed)
+		// Validate the records and reject the records already exist.
+		ml, checkErrs, err := checkImport(c, ctrlSpanCtx, handler, db, ml, importRows(c, data, rowErrs, len(ml)))
 		if err != nil {
 			log.Error(err)
 			ResponseJSON(c, CodeFailure.WithErr(err))
 			otel.RecordError(span, err)
 			return
 		}
+		rowErrs = append(rowErrs, checkErrs...)
+		sort.SliceStable(rowErrs, func(i, j int) bool { return rowErrs[i].Row < rowErrs[j].Row })
+		if len(rowErrs) > 0 {
+			log.Warnz("some rows failed to import", zap.Int("failed", total-len(ml)), zap.Error(rowErrs))
+		}
 
-		// service layer already create/update the records in database, just update fields "created_by", "updated_by".
 		for i := range ml {
 			ml[i].SetCreatedBy(c.GetString(consts.CTX_USERNAME))
 			ml[i].SetUpdatedBy(c.GetString(consts.CTX_USERNAME))
 		}
-		if err := handler(types.NewDatabaseContext(c)).Update(ml...); err != nil {
+		// 1.Perform business logic processing before batch create the imported resources.
+		var serviceCtxBefore *types.ServiceContext
+		if err = traceServiceHook[M](ctrlSpanCtx, consts.PHASE_CREATE_MANY_BEFORE, func(spanCtx context.Context) error {
+			serviceCtxBefore = types.NewServiceContext(c, spanCtx).WithPhase(consts.PHASE_IMPORT)
+			return svc.CreateManyBefore(serviceCtxBefore, ml...)
+		}); err != nil {
+			log.Error(err)
+			handleServiceError(c, serviceCtxBefore, err)
+			otel.RecordError(span, err)
+			return
+		}
+		// 2.Batch create the imported resources in database.
+		if err = handler(types.NewDatabaseContext(c)).Create(ml...); err != nil {
 			log.Error(err)
 			ResponseJSON(c, CodeFailure.WithErr(err))
 			otel.RecordError(span, err)
 			return
 		}
+		// 3.Perform business logic processing after batch create the imported resources.
+		var serviceCtxAfter *types.ServiceContext
+		if err = traceServiceHook[M](ctrlSpanCtx, consts.PHASE_CREATE_MANY_AFTER, func(spanCtx context.Context) error {
+			serviceCtxAfter = types.NewServiceContext(c, spanCtx).WithPhase(consts.PHASE_IMPORT)
+			return svc.CreateManyAfter(serviceCtxAfter, ml...)
+		}); err != nil {
+			log.Error(err)
+			handleServiceError(c, serviceCtxAfter, err)
+			otel.RecordError(span, err)
+			return
+		}
 		// // record operation log to database.
 		// typ := reflect.TypeOf(*new(M)).Elem()
 		// var tableName string
@@ -3005,6 +3428,11 @@ func ImportFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...
 		// }); err != nil {
 		// 	log.Error("failed to write operation log to database: ", err.Error())
 		// }
-		ResponseJSON(c, CodeSuccess)
+		ResponseJSON(c, CodeSuccess, &importReport{
+			Total:     total,
+			Succeeded: len(ml),
+			Failed:    total - len(ml),
+			Errors:    rowErrs,
+		})
 	}
 }
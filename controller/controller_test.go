@@ -3,8 +3,38 @@ package controller
 import (
 	"fmt"
 	"testing"
+
+	"github.com/forbearing/gst/model"
 )
 
 func TestEncryptPasswd(t *testing.T) {
 	fmt.Println(encryptPasswd("admin"))
 }
+
+func TestMatchETag(t *testing.T) {
+	if tag := etag(&model.Versioned{Version: 3}); tag != `"3"` {
+		t.Fatalf("expected etag \"3\", got %s", tag)
+	}
+	if tag := etag(&model.Base{}); tag != "" {
+		t.Fatalf("expected empty etag for unversioned model, got %s", tag)
+	}
+
+	tests := []struct {
+		header string
+		weak   bool
+		want   bool
+	}{
+		{`"3"`, false, true},
+		{`"1", "3"`, false, true},
+		{`*`, false, true},
+		{`"2"`, false, false},
+		{`W/"3"`, false, false},
+		{`W/"3"`, true, true},
+		{``, true, false},
+	}
+	for _, tt := range tests {
+		if got := matchETag(tt.header, `"3"`, tt.weak); got != tt.want {
+			t.Errorf("matchETag(%q, weak=%v) = %v, want %v", tt.header, tt.weak, got, tt.want)
+		}
+	}
+}
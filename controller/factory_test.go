@@ -0,0 +1,272 @@
+package controller_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/forbearing/gst/authz/abac"
+	"github.com/forbearing/gst/bootstrap"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/controller"
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Order struct {
+	Name    string  `json:"name" validate:"required"`
+	OwnerID string  `json:"owner_id"`
+	Cost    float64 `json:"cost"`
+
+	model.Base
+}
+
+// Note is restricted to its owner, the secret is hidden and kept.
+type Note struct {
+	Title   string `json:"title" schema:"title"`
+	OwnerID string `json:"owner_id" schema:"owner_id"`
+	Secret  string `json:"secret" schema:"secret"`
+
+	model.Base
+}
+
+// Doc is versioned, it's responded with the entity tag.
+type Doc struct {
+	Title string `json:"title"`
+
+	model.Versioned
+	model.Base
+}
+
+func init() {
+	os.Setenv(config.LOGGER_DIR, "/tmp/test_controller")
+	os.Setenv(config.DATABASE_TYPE, string(config.DBSqlite))
+	os.Setenv(config.SQLITE_IS_MEMORY, "false")
+	os.Setenv(config.SQLITE_PATH, "/tmp/test_controller.db")
+	_ = os.Remove("/tmp/test_controller.db")
+
+	model.Register[*model.User]()
+	model.Register[*Order]()
+	model.Register[*Note]()
+	model.Register[*Doc]()
+	abac.Register[*Note](&abac.Policy{
+		Roles:     []string{abac.AnyRole},
+		Rows:      abac.Owner("owner_id"),
+		ReadDeny:  []string{"secret"},
+		WriteDeny: []string{"secret", "owner_id"},
+	})
+	if err := bootstrap.Bootstrap(); err != nil {
+		panic(err)
+	}
+	gin.SetMode(gin.TestMode)
+}
+
+type response struct {
+	Code int             `json:"code"`
+	Data json.RawMessage `json:"data"`
+}
+
+func serve(t *testing.T, r *gin.Engine, req *http.Request) (*httptest.ResponseRecorder, *response) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	rsp := new(response)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), rsp))
+	return w, rsp
+}
+
+func TestImport(t *testing.T) {
+	existing := &Order{Name: "existing", Cost: 1}
+	existing.ID = "import-existing"
+	require.NoError(t, database.Database[*Order](nil).Create(existing))
+	deleted := &Order{Name: "deleted", Cost: 1}
+	deleted.ID = "import-deleted"
+	require.NoError(t, database.Database[*Order](nil).Create(deleted))
+	require.NoError(t, database.Database[*Order](nil).Delete(deleted))
+
+	r := gin.New()
+	r.POST("/orders/import", controller.ImportFactory[*Order, *Order, *Order]())
+
+	body := new(bytes.Buffer)
+	mw := multipart.NewWriter(body)
+	fw, err := mw.CreateFormFile("file", "orders.csv")
+	require.NoError(t, err)
+	_, _ = fw.Write([]byte("id,name,cost\n" +
+		"import-1,first,10\n" +
+		"import-existing,overwrite,20\n" +
+		"import-2,,30\n" +
+		"import-1,duplicated,40\n" +
+		"import-3,third,abc\n" +
+		"import-deleted,revived,50\n"))
+	require.NoError(t, mw.Close())
+	req := httptest.NewRequest(http.MethodPost, "/orders/import", body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w, rsp := serve(t, r, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var report struct {
+		Total     int `json:"total"`
+		Succeeded int `json:"succeeded"`
+		Failed    int `json:"failed"`
+		Errors    []struct {
+			Row    int    `json:"row"`
+			Column string `json:"column"`
+		} `json:"errors"`
+	}
+	require.NoError(t, json.Unmarshal(rsp.Data, &report))
+	assert.Equal(t, 6, report.Total)
+	assert.Equal(t, 1, report.Succeeded)
+	assert.Equal(t, 5, report.Failed)
+	rows := make([]int, 0)
+	for _, e := range report.Errors {
+		rows = append(rows, e.Row)
+	}
+	assert.Equal(t, []int{3, 4, 5, 6, 7}, rows)
+
+	// The existing record is not overwritten, the deleted record is not restored.
+	o := new(Order)
+	require.NoError(t, database.Database[*Order](nil).Get(o, existing.ID))
+	assert.Equal(t, "existing", o.Name)
+	o = new(Order)
+	require.NoError(t, database.DB.Unscoped().Where("id = ?", deleted.ID).First(o).Error)
+	assert.Equal(t, "deleted", o.Name)
+	assert.True(t, o.DeletedAt.Valid)
+	require.NoError(t, database.Database[*Order](nil).Get(o, "import-1"))
+	assert.Equal(t, "first", o.Name)
+}
+
+func TestGetETag(t *testing.T) {
+	doc := &Doc{Title: "doc"}
+	doc.ID = "doc-etag"
+	require.NoError(t, database.Database[*Doc](nil).Create(doc))
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set(consts.PARAMS, []string{"id"}) })
+	r.GET("/docs/:id", controller.GetFactory[*Doc, *Doc, *Doc](&types.ControllerConfig[*Doc]{ParamName: "id"}))
+
+	// The second get is served from the cache.
+	for _, path := range []string{"/docs/doc-etag?_nocache=false", "/docs/doc-etag?_nocache=false", "/docs/doc-etag"} {
+		w, _ := serve(t, r, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, `"1"`, w.Header().Get(consts.HEADER_ETAG), path)
+
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(consts.HEADER_IF_NONE_MATCH, `W/"1"`)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotModified, w.Code, path)
+		assert.Equal(t, `"1"`, w.Header().Get(consts.HEADER_ETAG), path)
+	}
+}
+
+func TestPolicyWrites(t *testing.T) {
+	mine := &Note{Title: "mine", OwnerID: "alice", Secret: "s1"}
+	mine.ID = "note-alice"
+	theirs := &Note{Title: "theirs", OwnerID: "bob", Secret: "s2"}
+	theirs.ID = "note-bob"
+	require.NoError(t, database.Database[*Note](nil).Create(mine, theirs))
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set(consts.CTX_USER_ID, "alice") })
+	r.POST("/notes", controller.CreateFactory[*Note, *Note, *Note]())
+	r.DELETE("/notes", controller.DeleteFactory[*Note, *Note, *Note]())
+	r.DELETE("/notes/batch", controller.DeleteManyFactory[*Note, *Note, *Note]())
+	r.PUT("/notes/batch", controller.UpdateManyFactory[*Note, *Note, *Note]())
+	r.PATCH("/notes/batch", controller.PatchManyFactory[*Note, *Note, *Note]())
+
+	request := func(method, path, body string) *http.Request {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	}
+	get := func(id string) *Note {
+		n := new(Note)
+		require.NoError(t, database.Database[*Note](nil).Get(n, id))
+		return n
+	}
+
+	t.Run("records not owned", func(t *testing.T) {
+		cases := []*http.Request{
+			request(http.MethodPost, "/notes", `{"id":"note-bob","title":"hijacked"}`),
+			request(http.MethodDelete, "/notes?id=note-bob", ""),
+			request(http.MethodDelete, "/notes/batch", `{"ids":["note-alice","note-bob"]}`),
+			request(http.MethodPut, "/notes/batch", `{"items":[{"id":"note-bob","title":"hijacked","owner_id":"bob"}]}`),
+			request(http.MethodPatch, "/notes/batch", `{"items":[{"id":"note-bob","title":"hijacked"}]}`),
+		}
+		for _, req := range cases {
+			w, _ := serve(t, r, req)
+			assert.Equal(t, http.StatusForbidden, w.Code, "%s %s", req.Method, req.URL)
+		}
+		n := get(theirs.ID)
+		assert.Equal(t, "theirs", n.Title)
+		assert.Equal(t, "s2", n.Secret)
+		assert.Equal(t, "mine", get(mine.ID).Title)
+	})
+
+	t.Run("records owned", func(t *testing.T) {
+		w, rsp := serve(t, r, request(http.MethodPut, "/notes/batch", `{"items":[{"id":"note-alice","title":"updated","owner_id":"bob","secret":"changed"}]}`))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.NotContains(t, string(rsp.Data), "s1")
+		n := get(mine.ID)
+		assert.Equal(t, "updated", n.Title)
+		assert.Equal(t, "alice", n.OwnerID)
+		assert.Equal(t, "s1", n.Secret)
+
+		w, rsp = serve(t, r, request(http.MethodPatch, "/notes/batch", `{"items":[{"id":"note-alice","title":"patched","secret":"changed"}]}`))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.NotContains(t, string(rsp.Data), "s1")
+		n = get(mine.ID)
+		assert.Equal(t, "patched", n.Title)
+		assert.Equal(t, "s1", n.Secret)
+
+		w, _ = serve(t, r, request(http.MethodPost, "/notes", `{"id":"note-new","title":"new","owner_id":"bob","secret":"s3"}`))
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		n = get("note-new")
+		assert.Empty(t, n.OwnerID)
+		assert.Empty(t, n.Secret)
+	})
+}
+
+func TestPolicyList(t *testing.T) {
+	mine := &Note{Title: "list", OwnerID: "alice", Secret: "s1"}
+	mine.ID = "list-alice"
+	theirs := &Note{Title: "list", OwnerID: "bob", Secret: "s2"}
+	theirs.ID = "list-bob"
+	require.NoError(t, database.Database[*Note](nil).Create(mine, theirs))
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set(consts.CTX_USER_ID, "alice") })
+	r.GET("/notes", controller.ListFactory[*Note, *Note, *Note]())
+
+	list := func(query string) []*Note {
+		w, rsp := serve(t, r, httptest.NewRequest(http.MethodGet, "/notes?"+query, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var data struct {
+			Items []*Note `json:"items"`
+		}
+		require.NoError(t, json.Unmarshal(rsp.Data, &data))
+		return data.Items
+	}
+
+	// The ORed query fields can't skip the rows granted.
+	notes := list("_or=true&title=list&owner_id=bob")
+	require.Len(t, notes, 1)
+	assert.Equal(t, mine.ID, notes[0].ID)
+	assert.Empty(t, notes[0].Secret)
+
+	// The read denied fields can't be queried, filtered or sorted by.
+	for _, query := range []string{"secret=s1", "secret[eq]=s1", `_filter={"or":[{"field":"secret","op":"eq","values":["s1"]}]}`, "_sortby=secret desc"} {
+		w, _ := serve(t, r, httptest.NewRequest(http.MethodGet, "/notes?"+url.PathEscape(query), nil))
+		assert.Equal(t, http.StatusForbidden, w.Code, query)
+	}
+}
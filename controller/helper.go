@@ -7,10 +7,15 @@ import (
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/forbearing/gst/authz/abac"
 	"github.com/forbearing/gst/config"
 	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/pkg/codec"
+	"github.com/forbearing/gst/pkg/validation"
 	"github.com/forbearing/gst/provider/otel"
 	. "github.com/forbearing/gst/response"
 	"github.com/forbearing/gst/types"
@@ -18,6 +23,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 func patchValue(log types.Logger, typ reflect.Type, oldVal reflect.Value, newVal reflect.Value) {
@@ -382,6 +388,292 @@ func handleServiceError(c *gin.Context, ctx *types.ServiceContext, err error) {
 	ResponseJSON(c, CodeFailure.WithErr(err))
 }
 
+// validateRequest validates the request payload by the `validate` struct tags and
+// the optional types.Validator, responds the field errors and returns false if failed.
+func validateRequest(c *gin.Context, spanCtx context.Context, phase consts.Phase, log types.Logger, span trace.Span, req any) bool {
+	return respondValidation(c, log, span, validation.Validate(types.NewServiceContext(c, spanCtx).WithPhase(phase), req))
+}
+
+// validateItems is the batch version of validateRequest, the field of errors
+// are prefixed by the item index, eg: "items[0].email".
+func validateItems[M types.Model](c *gin.Context, spanCtx context.Context, phase consts.Phase, log types.Logger, span trace.Span, items []M) bool {
+	ctx := types.NewServiceContext(c, spanCtx).WithPhase(phase)
+	var errs validation.Errors
+	for i, item := range items {
+		if err := validation.Validate(ctx, item); err != nil {
+			var itemErrs validation.Errors
+			if !errors.As(err, &itemErrs) {
+				return respondValidation(c, log, span, err)
+			}
+			errs = append(errs, itemErrs.WithPrefix(fmt.Sprintf("items[%d]", i))...)
+		}
+	}
+	if len(errs) > 0 {
+		return respondValidation(c, log, span, errs)
+	}
+	return true
+}
+
+// importRows returns the source row of each record decoded by the default Service.Import,
+// the rows failed to decode are excluded. It returns nil if the rows are unknown, eg: the
+// records are decoded by the customized Service.Import.
+func importRows(c *gin.Context, data []byte, rowErrs codec.RowErrors, n int) []int {
+	format, _ := codec.ParseFormat(c.Query(consts.QUERY_FORMAT))
+	all, err := codec.Rows(format, data)
+	if err != nil {
+		return nil
+	}
+	failed := make(map[int]struct{}, len(rowErrs))
+	for _, e := range rowErrs {
+		failed[e.Row] = struct{}{}
+	}
+	rows := make([]int, 0, n)
+	for _, row := range all {
+		if _, ok := failed[row]; !ok {
+			rows = append(rows, row)
+		}
+	}
+	if len(rows) != n {
+		return nil
+	}
+	return rows
+}
+
+// checkImport validates the imported records like CreateFactory, and rejects the records
+// whose id already exists, because Database.Create overwrites the existing records.
+// The soft deleted records are checked too, they're restored by Database.Create.
+// The rejected records are reported by the row errors, rows are the source rows of records.
+func checkImport[M types.Model](c *gin.Context, spanCtx context.Context, handler func(*types.DatabaseContext) types.Database[M], db any, ml []M, rows []int) ([]M, codec.RowErrors, error) {
+	ids := itemIDs(ml)
+	existing := make(map[string]struct{})
+	if len(ids) > 0 {
+		gdb, _ := db.(*gorm.DB)
+		if gdb == nil {
+			gdb = database.DB
+		}
+		records := make([]M, 0)
+		query := reflect.New(reflect.TypeFor[M]().Elem()).Interface().(M) //nolint:errcheck
+		filter := &types.Filter{Field: "id", Op: types.FilterIn, Values: ids}
+		if err := handler(types.NewDatabaseContext(c)).WithDB(gdb.Unscoped()).WithLimit(-1).WithSelect("id").WithoutHook().
+			WithQuery(query, types.QueryConfig{Filter: filter}).List(&records); err != nil {
+			return nil, nil, err
+		}
+		for _, r := range records {
+			existing[r.GetID()] = struct{}{}
+		}
+	}
+
+	ctx := types.NewServiceContext(c, spanCtx).WithPhase(consts.PHASE_IMPORT)
+	var rowErrs codec.RowErrors
+	valid := make([]M, 0, len(ml))
+	for i, m := range ml {
+		var row int
+		if rows != nil {
+			row = rows[i]
+		}
+		if err := validation.Validate(ctx, m); err != nil {
+			var errs validation.Errors
+			if !errors.As(err, &errs) {
+				errs = validation.Errors{{Message: err.Error()}}
+			}
+			for _, e := range errs {
+				rowErrs = append(rowErrs, codec.RowError{Row: row, Column: e.Field, Error: e.Message})
+			}
+			continue
+		}
+		if id := m.GetID(); len(id) > 0 {
+			if _, ok := existing[id]; ok {
+				rowErrs = append(rowErrs, codec.RowError{Row: row, Column: "id", Error: fmt.Sprintf("record %q already exists", id)})
+				continue
+			}
+			// The later rows with the same id are duplicated.
+			existing[id] = struct{}{}
+		}
+		valid = append(valid, m)
+	}
+	return valid, rowErrs, nil
+}
+
+func respondValidation(c *gin.Context, log types.Logger, span trace.Span, err error) bool {
+	if err == nil {
+		return true
+	}
+	log.Error(err)
+	var errs validation.Errors
+	if errors.As(err, &errs) {
+		ResponseJSON(c, CodeValidationFailed.WithErr(err), errs)
+	} else {
+		ResponseJSON(c, CodeValidationFailed.WithErr(err))
+	}
+	otel.RecordError(span, err)
+	return false
+}
+
+// updateErrorCode returns CodeVersionConflict if the record has been modified
+// by others, otherwise CodeFailure.
+func updateErrorCode(err error) Code {
+	if errors.Is(err, database.ErrVersionConflict) {
+		return CodeVersionConflict
+	}
+	return CodeFailure
+}
+
+// etag returns the entity tag of the versioned model, eg: "3",
+// it returns empty string if the model is not types.Versioned.
+func etag(m any) string {
+	v, ok := m.(types.Versioned)
+	if !ok || v.GetVersion() == 0 {
+		return ""
+	}
+	return `"` + strconv.FormatUint(uint64(v.GetVersion()), 10) + `"`
+}
+
+// setETag sets the response header "ETag" if the model is types.Versioned.
+func setETag(c *gin.Context, m any) {
+	if tag := etag(m); len(tag) > 0 {
+		c.Header(consts.HEADER_ETAG, tag)
+	}
+}
+
+// matchETag reports whether the tag matches any entity tag of header "If-Match" or "If-None-Match".
+// "*" matches any tag, the weak tag such as W/"3" only matches when weak is true.
+func matchETag(header, tag string, weak bool) bool {
+	for item := range strings.SplitSeq(header, ",") {
+		item = strings.TrimSpace(item)
+		if item == "*" {
+			return true
+		}
+		if strings.HasPrefix(item, "W/") {
+			if !weak {
+				continue
+			}
+			item = item[2:]
+		}
+		if item == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch checks the request header "If-Match" against the current record,
+// responds CodePreconditionFailed and returns false if not matched.
+// The header is ignored if the model is not types.Versioned.
+func checkIfMatch(c *gin.Context, log types.Logger, span trace.Span, current any) bool {
+	header := c.GetHeader(consts.HEADER_IF_MATCH)
+	tag := etag(current)
+	if len(header) == 0 || len(tag) == 0 || matchETag(header, tag, false) {
+		return true
+	}
+	err := fmt.Errorf("if-match %s not matched, current etag is %s", header, tag)
+	log.Error(err)
+	c.Header(consts.HEADER_ETAG, tag)
+	ResponseJSON(c, CodePreconditionFailed.WithErr(err))
+	otel.RecordError(span, err)
+	return false
+}
+
+// evaluatePolicies evaluates the attribute-based policies of model M for the current user,
+// see package abac. It responds and returns false if the evaluation failed or no records granted.
+// The returned decision is nil if model M has no policies.
+func evaluatePolicies[M types.Model](c *gin.Context, log types.Logger, span trace.Span) (*abac.Decision, bool) {
+	if !abac.Registered[M]() {
+		return nil, true
+	}
+	sub, err := abac.NewSubject(c.Request.Context(), c.GetString(consts.CTX_USER_ID), c.GetString(consts.CTX_USERNAME), c.GetString(consts.CTX_TENANT_ID))
+	if err != nil {
+		log.Error(err)
+		ResponseJSON(c, CodeFailure.WithErr(err))
+		otel.RecordError(span, err)
+		return nil, false
+	}
+	d := abac.Evaluate[M](sub)
+	if d != nil && d.Denied {
+		log.Errorf("no records of %s granted to user %q", reflect.TypeFor[M]().Elem().Name(), sub.UserID)
+		ResponseJSON(c, CodeForbidden)
+		return nil, false
+	}
+	return d, true
+}
+
+// grantedRecords loads the stored records of the ids granted by the decision, keyed by id,
+// it's used by the write paths to check the targets and keep the write denied fields.
+// It responds 403 and returns false if any of the records exists but is not granted.
+// The ids not stored are absent from the result, they're created by the writes.
+func grantedRecords[M types.Model](c *gin.Context, log types.Logger, span trace.Span, handler func(*types.DatabaseContext) types.Database[M], d *abac.Decision, ids []string) (map[string]M, bool) {
+	granted := make(map[string]M)
+	if d == nil || len(ids) == 0 {
+		return granted, true
+	}
+	records := make([]M, 0, len(ids))
+	query := reflect.New(reflect.TypeFor[M]().Elem()).Interface().(M) //nolint:errcheck
+	filter := &types.Filter{Field: "id", Op: types.FilterIn, Values: ids}
+	if err := handler(types.NewDatabaseContext(c)).WithLimit(-1).WithQuery(query, types.QueryConfig{Filter: d.Scope(filter)}).List(&records); err != nil {
+		log.Error(err)
+		ResponseJSON(c, CodeFailure.WithErr(err))
+		otel.RecordError(span, err)
+		return nil, false
+	}
+	for _, r := range records {
+		granted[r.GetID()] = r
+	}
+	missing := make([]string, 0)
+	for _, id := range ids {
+		if _, ok := granted[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 || d.Rows == nil {
+		return granted, true
+	}
+	// The records not granted may exist.
+	records = records[:0]
+	filter = &types.Filter{Field: "id", Op: types.FilterIn, Values: missing}
+	if err := handler(types.NewDatabaseContext(c)).WithLimit(-1).WithSelect("id").WithoutHook().
+		WithQuery(query, types.QueryConfig{Filter: filter}).List(&records); err != nil {
+		log.Error(err)
+		ResponseJSON(c, CodeFailure.WithErr(err))
+		otel.RecordError(span, err)
+		return nil, false
+	}
+	if len(records) > 0 {
+		ids := make([]string, 0, len(records))
+		for _, r := range records {
+			ids = append(ids, r.GetID())
+		}
+		log.Errorf("records %v of %s not granted to user %q", ids, reflect.TypeFor[M]().Elem().Name(), c.GetString(consts.CTX_USER_ID))
+		ResponseJSON(c, CodeForbidden)
+		return nil, false
+	}
+	return granted, true
+}
+
+// keepWriteDenied copies the write denied fields from the stored records to the records written,
+// the records not stored get the zero values, see abac.Decision.KeepWrite.
+func keepWriteDenied[M types.Model](d *abac.Decision, ml []M, stored map[string]M) {
+	if d == nil {
+		return
+	}
+	for _, m := range ml {
+		src, ok := stored[m.GetID()]
+		if !ok {
+			src = reflect.New(reflect.TypeFor[M]().Elem()).Interface().(M) //nolint:errcheck
+		}
+		d.KeepWrite(m, src)
+	}
+}
+
+// itemIDs returns the non-empty ids of the records.
+func itemIDs[M types.Model](ml []M) []string {
+	ids := make([]string, 0, len(ml))
+	for _, m := range ml {
+		if id := m.GetID(); len(id) > 0 {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
 // logRequest logs the HTTP request using zap logger if enabled in config
 func logRequest(log types.Logger, phase consts.Phase, req any) {
 	if !config.App.Logger.Controller.LogRequest {
@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/cronjob"
+	"github.com/forbearing/gst/logger"
+	"github.com/forbearing/gst/model"
+	. "github.com/forbearing/gst/response"
+	"github.com/forbearing/gst/task"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+	"github.com/gin-gonic/gin"
+)
+
+type job struct{}
+
+// Job inspects and controls the cronjobs and tasks at runtime, the job is identified by
+// path parameters "kind" ("cronjob" or "task") and "name". The controls only apply to the
+// replica serving the request, the distributed cronjobs can only be listed and triggered.
+// The run history is the resource model.JobRun.
+//
+// Example:
+//
+//	router.Auth().GET("/job", controller.Job.List)
+//	router.Auth().POST("/job/:kind/:name/trigger", controller.Job.Trigger)
+//	router.Auth().POST("/job/:kind/:name/pause", controller.Job.Pause)
+//	router.Auth().POST("/job/:kind/:name/resume", controller.Job.Resume)
+//	router.Auth().PUT("/job/:kind/:name/schedule", controller.Job.Reschedule)
+var Job = new(job)
+
+type jobScheduleRequest struct {
+	// Spec is the cron expression of the cronjob, eg: "0 */5 * * * *".
+	Spec string `json:"spec,omitempty"`
+	// Interval is the interval of the task, eg: "5m".
+	Interval string `json:"interval,omitempty"`
+}
+
+// List lists the cronjobs and tasks with their runtime state.
+func (*job) List(c *gin.Context) {
+	jobs := append(cronjob.List(), task.List()...)
+	ResponseJSON(c, CodeSuccess, gin.H{"items": jobs, "total": len(jobs)})
+}
+
+// Trigger runs the job now in background.
+func (*job) Trigger(c *gin.Context) {
+	controlJob(c, "TriggerJob", cronjob.Trigger, task.Trigger)
+}
+
+// Pause stops scheduling the job.
+func (*job) Pause(c *gin.Context) {
+	controlJob(c, "PauseJob", cronjob.Pause, task.Pause)
+}
+
+// Resume resumes scheduling the job paused.
+func (*job) Resume(c *gin.Context) {
+	controlJob(c, "ResumeJob", cronjob.Resume, task.Resume)
+}
+
+// Reschedule changes the cron expression of the cronjob or the interval of the task.
+func (*job) Reschedule(c *gin.Context) {
+	log := logger.Controller.WithControllerContext(types.NewControllerContext(c), consts.Phase("RescheduleJob"))
+	req := new(jobScheduleRequest)
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Error(err)
+		ResponseJSON(c, CodeInvalidParam.WithErr(err))
+		return
+	}
+	controlJob(c, "RescheduleJob",
+		func(name string) error {
+			if len(req.Spec) == 0 {
+				return errors.New("spec is required")
+			}
+			return cronjob.Reschedule(name, req.Spec)
+		},
+		func(name string) error {
+			interval, err := time.ParseDuration(req.Interval)
+			if err != nil {
+				return errors.Wrapf(err, "invalid interval %q", req.Interval)
+			}
+			return task.Reschedule(name, interval)
+		},
+	)
+}
+
+func controlJob(c *gin.Context, phase string, cronjobFn, taskFn func(name string) error) {
+	log := logger.Controller.WithControllerContext(types.NewControllerContext(c), consts.Phase(phase))
+	var fn func(string) error
+	switch c.Param("kind") {
+	case model.JobKindCronjob:
+		fn = cronjobFn
+	case model.JobKindTask:
+		fn = taskFn
+	default:
+		ResponseJSON(c, CodeInvalidParam.WithErr(errors.Newf("invalid job kind %q", c.Param("kind"))))
+		return
+	}
+	if err := fn(c.Param("name")); err != nil {
+		log.Error(err)
+		if errors.Is(err, cronjob.ErrNotFound) || errors.Is(err, task.ErrNotFound) {
+			ResponseJSON(c, CodeNotFound.WithErr(err))
+		} else {
+			ResponseJSON(c, CodeInvalidParam.WithErr(err))
+		}
+		return
+	}
+	ResponseJSON(c, CodeSuccess)
+}
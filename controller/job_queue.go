@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/jobqueue"
+	"github.com/forbearing/gst/logger"
+	. "github.com/forbearing/gst/response"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+	"github.com/gin-gonic/gin"
+)
+
+type jobQueue struct{}
+
+// JobQueue inspects the background job queue and manages the dead letters, the dead
+// letters are the jobs in state "dead".
+//
+// Example:
+//
+//	router.Auth().GET("/jobqueue/stats", controller.JobQueue.Stats)
+//	router.Auth().GET("/jobqueue/jobs", controller.JobQueue.List)
+//	router.Auth().GET("/jobqueue/jobs/:id", controller.JobQueue.Get)
+//	router.Auth().POST("/jobqueue/jobs/:id/retry", controller.JobQueue.Retry)
+//	router.Auth().DELETE("/jobqueue/jobs/:id", controller.JobQueue.Delete)
+var JobQueue = new(jobQueue)
+
+// Stats returns the number of the jobs of each queue by state.
+func (*jobQueue) Stats(c *gin.Context) {
+	log := logger.Controller.WithControllerContext(types.NewControllerContext(c), consts.Phase("JobQueueStats"))
+	stats, err := jobqueue.Stats(c.Request.Context())
+	if err != nil {
+		log.Error(err)
+		ResponseJSON(c, CodeFailure.WithErr(err))
+		return
+	}
+	ResponseJSON(c, CodeSuccess, stats)
+}
+
+// List lists the jobs filtered by the query parameters "queue", "type" and "state",
+// paginated by "page" and "size".
+func (*jobQueue) List(c *gin.Context) {
+	log := logger.Controller.WithControllerContext(types.NewControllerContext(c), consts.Phase("ListJobQueue"))
+	filter := jobqueue.Filter{
+		Queue: c.Query("queue"),
+		Type:  c.Query("type"),
+		State: c.Query("state"),
+	}
+	var err error
+	if page := c.Query("page"); len(page) > 0 {
+		if filter.Page, err = strconv.Atoi(page); err != nil {
+			ResponseJSON(c, CodeInvalidParam.WithErr(errors.Wrapf(err, "invalid page %q", page)))
+			return
+		}
+	}
+	if size := c.Query("size"); len(size) > 0 {
+		if filter.Size, err = strconv.Atoi(size); err != nil {
+			ResponseJSON(c, CodeInvalidParam.WithErr(errors.Wrapf(err, "invalid size %q", size)))
+			return
+		}
+	}
+	jobs, total, err := jobqueue.List(c.Request.Context(), filter)
+	if err != nil {
+		log.Error(err)
+		ResponseJSON(c, CodeFailure.WithErr(err))
+		return
+	}
+	ResponseJSON(c, CodeSuccess, gin.H{"items": jobs, "total": total})
+}
+
+// Get returns the job.
+func (*jobQueue) Get(c *gin.Context) {
+	log := logger.Controller.WithControllerContext(types.NewControllerContext(c), consts.Phase("GetJobQueue"))
+	job, err := jobqueue.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		log.Error(err)
+		responseJobQueueError(c, err)
+		return
+	}
+	ResponseJSON(c, CodeSuccess, job)
+}
+
+// Retry requeues the dead job.
+func (*jobQueue) Retry(c *gin.Context) {
+	log := logger.Controller.WithControllerContext(types.NewControllerContext(c), consts.Phase("RetryJobQueue"))
+	if err := jobqueue.Retry(c.Request.Context(), c.Param("id")); err != nil {
+		log.Error(err)
+		responseJobQueueError(c, err)
+		return
+	}
+	ResponseJSON(c, CodeSuccess)
+}
+
+// Delete deletes the job which is not running.
+func (*jobQueue) Delete(c *gin.Context) {
+	log := logger.Controller.WithControllerContext(types.NewControllerContext(c), consts.Phase("DeleteJobQueue"))
+	if err := jobqueue.Delete(c.Request.Context(), c.Param("id")); err != nil {
+		log.Error(err)
+		responseJobQueueError(c, err)
+		return
+	}
+	ResponseJSON(c, CodeSuccess)
+}
+
+func responseJobQueueError(c *gin.Context, err error) {
+	if errors.Is(err, jobqueue.ErrNotFound) {
+		ResponseJSON(c, CodeNotFound.WithErr(err))
+	} else {
+		ResponseJSON(c, CodeInvalidParam.WithErr(err))
+	}
+}
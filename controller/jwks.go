@@ -0,0 +1,15 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/forbearing/gst/authn/jwt"
+	"github.com/gin-gonic/gin"
+)
+
+// JWKS serves the public keys verifying the jwt tokens in JWK set format,
+// so other services can verify the tokens offline.
+func JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, jwt.JWKS())
+}
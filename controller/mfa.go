@@ -0,0 +1,254 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/authn/jwt"
+	"github.com/forbearing/gst/authn/mfa"
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/logger"
+	"github.com/forbearing/gst/model"
+	. "github.com/forbearing/gst/response"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+	"github.com/gin-gonic/gin"
+	cmap "github.com/orcaman/concurrent-map/v2"
+	"golang.org/x/time/rate"
+)
+
+var mfaRatelimiterMap = cmap.New[*rate.Limiter]()
+
+// mfaChallenge is the response of the first login step of the user required MFA.
+type mfaChallenge struct {
+	MFARequired       bool   `json:"mfa_required"`
+	MFAEnrollRequired bool   `json:"mfa_enroll_required"`
+	MFAToken          string `json:"mfa_token"`
+}
+
+type mfaRequest struct {
+	// MFAToken is the "MFA pending" token of the first login step.
+	MFAToken string `json:"mfa_token,omitempty"`
+	// Code is the TOTP code or the recovery code.
+	Code string `json:"code,omitempty"`
+}
+
+type mfaEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type mfaActivation struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+	*model.User
+}
+
+// MFAEnroll generates the TOTP secret of the current user, it's activated by MFAActivate.
+// The user required MFA but not enrolled calls it with the "MFA pending" token of Login.
+//
+// Example:
+//
+//	router.Pub().POST("/mfa/verify", controller.User.MFAVerify)
+//	router.Pub().POST("/mfa/enroll", controller.User.MFAEnroll)     // with the "MFA pending" token
+//	router.Pub().POST("/mfa/activate", controller.User.MFAActivate) // with the "MFA pending" token
+//	router.Auth().POST("/mfa/enroll", controller.User.MFAEnroll)
+//	router.Auth().POST("/mfa/activate", controller.User.MFAActivate)
+//	router.Auth().POST("/mfa/disable", controller.User.MFADisable)
+//	router.Auth().POST("/mfa/recovery-codes", controller.User.MFARecoveryCodes)
+func (*user) MFAEnroll(c *gin.Context) {
+	log := logger.Controller.WithControllerContext(types.NewControllerContext(c), consts.Phase("MFAEnroll"))
+	req := new(mfaRequest)
+	// The authenticated user enrolls without the request body.
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(req); err != nil {
+			log.Error(err)
+			ResponseJSON(c, CodeInvalidParam.WithErr(err))
+			return
+		}
+	}
+	userID, username, _, err := mfaSubject(c, req)
+	if err != nil {
+		log.Error(err)
+		ResponseJSON(c, CodeUnauthorized)
+		return
+	}
+	secret, uri, err := mfa.Enroll(types.NewDatabaseContext(c), userID, username)
+	if err != nil {
+		log.Error(err)
+		if errors.Is(err, mfa.ErrAlreadyEnrolled) {
+			ResponseJSON(c, CodeAlreadyExist.WithErr(err))
+		} else {
+			ResponseJSON(c, CodeFailure)
+		}
+		return
+	}
+	ResponseJSON(c, CodeSuccess, &mfaEnrollment{Secret: secret, URI: uri})
+}
+
+// MFAActivate activates the enrolled TOTP by the code and responses the recovery codes.
+// The login completes with the tokens if called with the "MFA pending" token.
+func (*user) MFAActivate(c *gin.Context) {
+	log := logger.Controller.WithControllerContext(types.NewControllerContext(c), consts.Phase("MFAActivate"))
+	req := new(mfaRequest)
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Error(err)
+		ResponseJSON(c, CodeInvalidParam.WithErr(err))
+		return
+	}
+	userID, _, pending, err := mfaSubject(c, req)
+	if err != nil {
+		log.Error(err)
+		ResponseJSON(c, CodeUnauthorized)
+		return
+	}
+	if !mfaAllow(userID) {
+		ResponseJSON(c, NewCode(CodeTooManyRequests, http.StatusTooManyRequests, "too many mfa requests"))
+		return
+	}
+	codes, err := mfa.Activate(types.NewDatabaseContext(c), userID, req.Code)
+	if err != nil {
+		log.Error(err)
+		responseMFAError(c, err)
+		return
+	}
+	if !pending {
+		ResponseJSON(c, CodeSuccess, &mfaActivation{RecoveryCodes: codes})
+		return
+	}
+	u, ok := mfaUser(c, log, userID)
+	if !ok {
+		return
+	}
+	if issueTokens(c, log, u) {
+		ResponseJSON(c, CodeSuccess, &mfaActivation{RecoveryCodes: codes, User: u})
+	}
+}
+
+// MFAVerify completes the login by the "MFA pending" token and the TOTP or recovery code.
+func (*user) MFAVerify(c *gin.Context) {
+	log := logger.Controller.WithControllerContext(types.NewControllerContext(c), consts.Phase("MFAVerify"))
+	req := new(mfaRequest)
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Error(err)
+		ResponseJSON(c, CodeInvalidParam.WithErr(err))
+		return
+	}
+	claims, err := jwt.ParseMFAToken(req.MFAToken)
+	if err != nil {
+		log.Error(err)
+		ResponseJSON(c, CodeUnauthorized)
+		return
+	}
+	if !mfaAllow(claims.UserID) {
+		ResponseJSON(c, NewCode(CodeTooManyRequests, http.StatusTooManyRequests, "too many mfa requests"))
+		return
+	}
+	if err = mfa.Verify(types.NewDatabaseContext(c), claims.UserID, req.Code); err != nil {
+		log.Error(err)
+		responseMFAError(c, err)
+		return
+	}
+	u, ok := mfaUser(c, log, claims.UserID)
+	if !ok {
+		return
+	}
+	if issueTokens(c, log, u) {
+		ResponseJSON(c, CodeSuccess, u)
+	}
+}
+
+// MFADisable removes the TOTP and recovery codes of the current user after verifying the code.
+func (*user) MFADisable(c *gin.Context) {
+	log := logger.Controller.WithControllerContext(types.NewControllerContext(c), consts.Phase("MFADisable"))
+	req := new(mfaRequest)
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Error(err)
+		ResponseJSON(c, CodeInvalidParam.WithErr(err))
+		return
+	}
+	userID := c.GetString(consts.CTX_USER_ID)
+	if !mfaAllow(userID) {
+		ResponseJSON(c, NewCode(CodeTooManyRequests, http.StatusTooManyRequests, "too many mfa requests"))
+		return
+	}
+	if err := mfa.Disable(types.NewDatabaseContext(c), userID, req.Code); err != nil {
+		log.Error(err)
+		responseMFAError(c, err)
+		return
+	}
+	ResponseJSON(c, CodeSuccess)
+}
+
+// MFARecoveryCodes replaces the recovery codes of the current user after verifying the code.
+func (*user) MFARecoveryCodes(c *gin.Context) {
+	log := logger.Controller.WithControllerContext(types.NewControllerContext(c), consts.Phase("MFARecoveryCodes"))
+	req := new(mfaRequest)
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Error(err)
+		ResponseJSON(c, CodeInvalidParam.WithErr(err))
+		return
+	}
+	userID := c.GetString(consts.CTX_USER_ID)
+	if !mfaAllow(userID) {
+		ResponseJSON(c, NewCode(CodeTooManyRequests, http.StatusTooManyRequests, "too many mfa requests"))
+		return
+	}
+	codes, err := mfa.RegenerateRecoveryCodes(types.NewDatabaseContext(c), userID, req.Code)
+	if err != nil {
+		log.Error(err)
+		responseMFAError(c, err)
+		return
+	}
+	ResponseJSON(c, CodeSuccess, &mfaActivation{RecoveryCodes: codes})
+}
+
+// mfaSubject returns the user authenticated by JwtAuth, or by the "MFA pending" token.
+func mfaSubject(c *gin.Context, req *mfaRequest) (userID, username string, pending bool, err error) {
+	if userID = c.GetString(consts.CTX_USER_ID); len(userID) > 0 {
+		return userID, c.GetString(consts.CTX_USERNAME), false, nil
+	}
+	claims, err := jwt.ParseMFAToken(req.MFAToken)
+	if err != nil {
+		return "", "", false, err
+	}
+	return claims.UserID, claims.Username, true, nil
+}
+
+func mfaUser(c *gin.Context, log types.Logger, userID string) (*model.User, bool) {
+	u := new(model.User)
+	if err := database.Database[*model.User](types.NewDatabaseContext(c)).Get(u, userID); err != nil {
+		log.Error(err)
+		ResponseJSON(c, CodeFailure)
+		return nil, false
+	}
+	if len(u.ID) == 0 {
+		log.Errorf("user %s not found", userID)
+		ResponseJSON(c, CodeUnauthorized)
+		return nil, false
+	}
+	return u, true
+}
+
+// mfaAllow limits the code guessing of the user.
+func mfaAllow(userID string) bool {
+	limiter, found := mfaRatelimiterMap.Get(userID)
+	if !found {
+		limiter = rate.NewLimiter(rate.Every(10*time.Second), 5)
+		mfaRatelimiterMap.Set(userID, limiter)
+	}
+	return limiter.Allow()
+}
+
+func responseMFAError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, mfa.ErrInvalidCode):
+		ResponseJSON(c, CodeUnauthorized.WithErr(err))
+	case errors.Is(err, mfa.ErrNotEnrolled):
+		ResponseJSON(c, CodeNotFound.WithErr(err))
+	case errors.Is(err, mfa.ErrAlreadyEnrolled):
+		ResponseJSON(c, CodeAlreadyExist.WithErr(err))
+	default:
+		ResponseJSON(c, CodeFailure)
+	}
+}
@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/authz/rbac"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/logger"
+	"github.com/forbearing/gst/model"
+	modelauthz "github.com/forbearing/gst/model/authz"
+	. "github.com/forbearing/gst/response"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+	"github.com/gin-gonic/gin"
+)
+
+type permission struct{}
+
+// Permission answers what the RBAC would decide for the current user, so the frontend
+// needn't hard-code which buttons to show. The admin can query other users by the
+// query parameter "user_id".
+//
+// Example:
+//
+//	router.Auth().GET("/permission/roles", controller.Permission.Roles)
+//	router.Auth().GET("/permission/effective", controller.Permission.Effective)
+//	router.Auth().POST("/permission/can", controller.Permission.Can)
+//	router.Auth().GET("/permission/explain", controller.Permission.Explain)
+var Permission = new(permission)
+
+// Roles responses the roles of the user, including the inherited ones.
+func (*permission) Roles(c *gin.Context) {
+	log := logger.Controller.WithControllerContext(types.NewControllerContext(c), consts.Phase("PermissionRoles"))
+	sub, ok := introspectSubject(c, log)
+	if !ok {
+		return
+	}
+	roles, err := introspector(c).Roles(sub)
+	if err != nil {
+		log.Error(err)
+		responseIntrospectError(c, err)
+		return
+	}
+	ResponseJSON(c, CodeSuccess, roles)
+}
+
+// Effective responses the permissions of the routes the user can access,
+// the resources are the same as the permissions derived from the routes.
+func (*permission) Effective(c *gin.Context) {
+	log := logger.Controller.WithControllerContext(types.NewControllerContext(c), consts.Phase("PermissionEffective"))
+	sub, ok := introspectSubject(c, log)
+	if !ok {
+		return
+	}
+	permissions := make([]*modelauthz.Permission, 0)
+	if err := database.Database[*modelauthz.Permission](types.NewDatabaseContext(c)).WithLimit(-1).List(&permissions); err != nil {
+		log.Error(err)
+		ResponseJSON(c, CodeFailure)
+		return
+	}
+	checks := make([]types.PermissionCheck, len(permissions))
+	for i, p := range permissions {
+		checks[i] = types.PermissionCheck{Resource: p.Resource, Action: p.Action}
+	}
+	checks, err := introspector(c).Can(sub, checks...)
+	if err != nil {
+		log.Error(err)
+		responseIntrospectError(c, err)
+		return
+	}
+	allowed := make([]types.PermissionCheck, 0, len(checks))
+	for _, check := range checks {
+		if check.Allowed {
+			allowed = append(allowed, check)
+		}
+	}
+	ResponseJSON(c, CodeSuccess, allowed)
+}
+
+// Can checks the permissions in batch, the request body is the list of resource and action,
+// the resource can be either gin route path or casbin keyMatch3 path, eg:
+//
+//	[{"resource": "/api/user/:id", "action": "DELETE"}, {"resource": "/api/user", "action": "POST"}]
+func (*permission) Can(c *gin.Context) {
+	log := logger.Controller.WithControllerContext(types.NewControllerContext(c), consts.Phase("PermissionCan"))
+	checks := make([]types.PermissionCheck, 0)
+	if err := c.ShouldBindJSON(&checks); err != nil {
+		log.Error(err)
+		ResponseJSON(c, CodeInvalidParam.WithErr(err))
+		return
+	}
+	sub, ok := introspectSubject(c, log)
+	if !ok {
+		return
+	}
+	checks, err := introspector(c).Can(sub, checks...)
+	if err != nil {
+		log.Error(err)
+		responseIntrospectError(c, err)
+		return
+	}
+	ResponseJSON(c, CodeSuccess, checks)
+}
+
+// Explain responses the decision of the request specified by query parameters "resource"
+// and "action", and the policy line allowed it.
+func (*permission) Explain(c *gin.Context) {
+	log := logger.Controller.WithControllerContext(types.NewControllerContext(c), consts.Phase("PermissionExplain"))
+	resource, action := c.Query("resource"), c.Query("action")
+	if len(resource) == 0 || len(action) == 0 {
+		ResponseJSON(c, CodeInvalidParam.WithErr(errors.New("resource and action are required")))
+		return
+	}
+	sub, ok := introspectSubject(c, log)
+	if !ok {
+		return
+	}
+	explanation, err := introspector(c).Explain(sub, resource, action)
+	if err != nil {
+		log.Error(err)
+		responseIntrospectError(c, err)
+		return
+	}
+	ResponseJSON(c, CodeSuccess, explanation)
+}
+
+// introspector returns the introspector of the tenant RBAC in the tenant of the request if
+// it's enabled, the same as middleware.TenantAuthz enforces.
+func introspector(c *gin.Context) types.RBACIntrospector {
+	if config.App.Auth.TenantRBACEnable {
+		return rbac.TenantIntrospector(c.GetString(consts.CTX_TENANT_ID))
+	}
+	return rbac.Introspector()
+}
+
+// introspectSubject returns the casbin subject of the current user, or the user of query
+// parameter "user_id" if the current user is admin.
+func introspectSubject(c *gin.Context, log types.Logger) (string, bool) {
+	sub := rbac.Subject(c.GetString(consts.CTX_USER_ID), c.GetString(consts.CTX_USERNAME))
+	userID := c.Query("user_id")
+	if len(userID) == 0 || userID == c.GetString(consts.CTX_USER_ID) {
+		return sub, true
+	}
+	roles, err := introspector(c).Roles(sub)
+	if err != nil {
+		log.Error(err)
+		responseIntrospectError(c, err)
+		return "", false
+	}
+	if sub != consts.ROOT && sub != consts.ADMIN && !slices.Contains(roles, consts.ADMIN) && !slices.Contains(roles, rbac.SuperAdmin) {
+		ResponseJSON(c, CodeForbidden)
+		return "", false
+	}
+	u := new(model.User)
+	if err = database.Database[*model.User](types.NewDatabaseContext(c)).Get(u, userID); err != nil {
+		log.Error(err)
+		ResponseJSON(c, CodeFailure)
+		return "", false
+	}
+	if len(u.ID) == 0 {
+		ResponseJSON(c, CodeNotFound)
+		return "", false
+	}
+	return rbac.Subject(u.ID, u.Name), true
+}
+
+func responseIntrospectError(c *gin.Context, err error) {
+	if errors.Is(err, rbac.ErrRBACDisabled) {
+		ResponseJSON(c, NewCode(CodeFailure, http.StatusNotImplemented, err.Error()))
+		return
+	}
+	ResponseJSON(c, CodeFailure)
+}
@@ -5,14 +5,18 @@ import (
 	"net/http"
 	"regexp"
 	"time"
-	"unicode"
 
 	"github.com/cockroachdb/errors"
 	"github.com/forbearing/gst/authn/jwt"
+	authnldap "github.com/forbearing/gst/authn/ldap"
+	"github.com/forbearing/gst/authn/mfa"
+	"github.com/forbearing/gst/authn/oidc"
+	"github.com/forbearing/gst/authn/passwd"
 	"github.com/forbearing/gst/config"
 	"github.com/forbearing/gst/database"
 	"github.com/forbearing/gst/logger"
 	"github.com/forbearing/gst/model"
+	modellog "github.com/forbearing/gst/model/log"
 	. "github.com/forbearing/gst/response"
 	"github.com/forbearing/gst/types"
 	"github.com/forbearing/gst/types/consts"
@@ -20,13 +24,13 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/mssola/useragent"
 	cmap "github.com/orcaman/concurrent-map/v2"
-	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/time/rate"
 )
 
 var (
-	loginRatelimiterMap  = cmap.New[*rate.Limiter]()
-	signupRatelimiterMap = cmap.New[*rate.Limiter]()
+	loginRatelimiterMap        = cmap.New[*rate.Limiter]()
+	signupRatelimiterMap       = cmap.New[*rate.Limiter]()
+	changePasswdRatelimiterMap = cmap.New[*rate.Limiter]()
 )
 
 type user struct{}
@@ -54,57 +58,139 @@ func (*user) Login(c *gin.Context) {
 		ResponseJSON(c, CodeInvalidLogin)
 		return
 	}
+	// The lockout applies to both LDAP and the local users, so the account is checked
+	// and the failure is counted whichever backend authenticates it.
 	users := make([]*model.User, 0)
 	if err = database.Database[*model.User](types.NewDatabaseContext(c)).WithLimit(1).WithQuery(&model.User{Name: req.Name}).List(&users); err != nil {
 		log.Error(err)
 		ResponseJSON(c, CodeInvalidLogin)
 		return
 	}
-	if len(users) == 0 {
+	var u *model.User
+	if len(users) == 1 {
+		u = users[0]
+		if !checkLocked(c, log, u) {
+			return
+		}
+	}
+	// Try LDAP first, fall back to the local users, eg: the built-in admin.
+	if authnldap.Enabled() {
+		lu, err := authnldap.Login(types.NewDatabaseContext(c), req.Name, req.Password)
+		if err == nil {
+			// The LDAP user may be linked to the local user with the other name.
+			if !checkLocked(c, log, lu) {
+				return
+			}
+			if err = passwd.Succeed(types.NewDatabaseContext(c), lu); err != nil {
+				log.Error(err)
+				ResponseJSON(c, CodeFailure)
+				return
+			}
+			login(c, log, lu)
+			return
+		}
+		if !errors.Is(err, authnldap.ErrInvalidCredentials) {
+			log.Error(err)
+		}
+	}
+	if u == nil {
 		log.Error("not found any accounts")
+		writeLoginLog(c, &modellog.LoginLog{Username: req.Name, Status: modellog.LoginStatusFailure, Reason: "user not found"})
 		ResponseJSON(c, CodeInvalidLogin)
 		return
 	}
-	if len(users) != 1 {
-		log.Errorf("too many accounts: %d", len(users))
-		ResponseJSON(c, CodeFailure)
-		return
-	}
-	u := users[0]
 	if err = comparePasswd(req.Password, u.Password); err != nil {
 		log.Errorf("user password not match: %v", err)
+		writeLoginLog(c, &modellog.LoginLog{UserID: u.ID, Username: u.Name, Status: modellog.LoginStatusFailure, Reason: "invalid password"})
+		if _, err = passwd.Fail(types.NewDatabaseContext(c), u); err != nil {
+			log.Error(err)
+		}
 		ResponseJSON(c, CodeInvalidLogin)
 		return
 	}
+	if err = passwd.Succeed(types.NewDatabaseContext(c), u); err != nil {
+		log.Error(err)
+		ResponseJSON(c, CodeFailure)
+		return
+	}
+	// No token is issued for the expired password, the user must change it by ChangePasswd first.
+	if passwd.Expired(u) {
+		log.Errorf("password of account %s expired", u.Name)
+		writeLoginLog(c, &modellog.LoginLog{UserID: u.ID, Username: u.Name, Status: modellog.LoginStatusFailure, Reason: "password expired"})
+		ResponseJSON(c, CodePasswordExpired, &model.User{Base: model.Base{ID: u.ID}, Name: u.Name, PasswordExpired: true})
+		return
+	}
 	// TODO: 把以前的 token 失效掉
-	aToken, rToken, err := jwt.GenTokens(u.ID, req.Name, createSession(c))
+	login(c, log, u)
+}
+
+// checkLocked responses the error and returns false if the account is locked.
+func checkLocked(c *gin.Context, log types.Logger, u *model.User) bool {
+	until, locked := passwd.Locked(u)
+	if !locked {
+		return true
+	}
+	log.Errorf("account %s locked until %s", u.Name, until)
+	writeLoginLog(c, &modellog.LoginLog{UserID: u.ID, Username: u.Name, Status: modellog.LoginStatusLocked, Reason: "account locked"})
+	ResponseJSON(c, CodeAccountLocked.WithErr(fmt.Errorf("account is locked until %s", until.Format(time.RFC3339))))
+	return false
+}
+
+// OIDCLogin redirects the user agent to the authorization url of the OIDC provider by path parameter "provider".
+//
+// Example:
+//
+//	router.Pub().GET("/oidc/:provider/login", controller.User.OIDCLogin)
+//	router.Pub().GET("/oidc/:provider/callback", controller.User.OIDCCallback)
+func (*user) OIDCLogin(c *gin.Context) {
+	log := logger.Controller.WithControllerContext(types.NewControllerContext(c), consts.Phase("OIDCLogin"))
+	provider, ok := oidc.Get(c.Param("provider"))
+	if !ok {
+		log.Error(oidc.ErrProviderNotFound)
+		ResponseJSON(c, CodeNotFound.WithErr(oidc.ErrProviderNotFound))
+		return
+	}
+	url, err := provider.AuthCodeURL(c.Request.Context())
 	if err != nil {
-		ResponseJSON(c, CodeFailure)
+		log.Error(err)
+		ResponseJSON(c, CodeFailure.WithErr(err))
 		return
 	}
-	u.Token = aToken
-	u.AccessToken = aToken
-	u.RefreshToken = rToken
-	u.SessionID = util.UUID()
-	fmt.Println("SessionId: ", u.SessionID)
-	u.TokenExpiration = util.ValueOf(model.GormTime(time.Now().Add(config.App.AccessTokenExpireDuration)))
-	writeLocalSessionAndCookie(c, aToken, rToken, u)
-	// WARN: you must clean password before response to user.
-	u.Password = ""
+	c.Redirect(http.StatusFound, url)
+}
 
-	u.LastLoginAt = util.ValueOf(model.GormTime(time.Now()))
-	u.LastLoginIP = util.IPv6ToIPv4(c.ClientIP())
-	if err = database.Database[*model.User](types.NewDatabaseContext(c)).UpdateByID(u.ID, "last_login", u.LastLoginAt); err != nil {
+// OIDCCallback verifies the authorization response of the OIDC provider, links the user
+// and issues the tokens like Login.
+func (*user) OIDCCallback(c *gin.Context) {
+	log := logger.Controller.WithControllerContext(types.NewControllerContext(c), consts.Phase("OIDCCallback"))
+	provider, ok := oidc.Get(c.Param("provider"))
+	if !ok {
+		log.Error(oidc.ErrProviderNotFound)
+		ResponseJSON(c, CodeNotFound.WithErr(oidc.ErrProviderNotFound))
+		return
+	}
+	if e := c.Query("error"); len(e) > 0 {
+		log.Errorf("oidc authorization failed: %s: %s", e, c.Query("error_description"))
+		ResponseJSON(c, CodeUnauthorized)
+		return
+	}
+	claims, err := provider.Exchange(c.Request.Context(), c.Query("code"), c.Query("state"))
+	if err != nil {
 		log.Error(err)
-		ResponseJSON(c, CodeFailure)
+		ResponseJSON(c, CodeUnauthorized)
 		return
 	}
-	if err = database.Database[*model.User](types.NewDatabaseContext(c)).UpdateByID(u.ID, "last_login_ip", u.LastLoginIP); err != nil {
+	u, err := provider.User(types.NewDatabaseContext(c), claims)
+	if err != nil {
 		log.Error(err)
-		ResponseJSON(c, CodeFailure)
+		if errors.Is(err, oidc.ErrUserNotLinked) {
+			ResponseJSON(c, CodeForbidden.WithErr(err))
+		} else {
+			ResponseJSON(c, CodeFailure)
+		}
 		return
 	}
-	ResponseJSON(c, CodeSuccess, u)
+	login(c, log, u)
 }
 
 func (*user) Logout(c *gin.Context) {
@@ -115,11 +201,72 @@ func (*user) Logout(c *gin.Context) {
 		ResponseJSON(c, CodeFailure)
 		return
 	}
-	jwt.RevokeTokens(claims.Subject)
+	// Only log out the current session, the token without session id is the legacy one.
+	if len(claims.Sid) > 0 {
+		err = jwt.RevokeSession(claims.Sid)
+	} else {
+		err = jwt.RevokeTokens(claims.Subject)
+	}
+	if err != nil {
+		log.Error(err)
+		ResponseJSON(c, CodeFailure)
+		return
+	}
 
 	ResponseJSON(c, CodeSuccess)
 }
 
+// Sessions lists the login sessions of the current user, or the user by path parameter "id".
+//
+// Example:
+//
+//	router.Auth().GET("/session", controller.User.Sessions)
+//	router.Auth().DELETE("/session/:session_id", controller.User.KillSession)
+//	router.Auth().GET("/user/:id/session", controller.User.Sessions)                   // admin
+//	router.Auth().DELETE("/user/:id/session/:session_id", controller.User.KillSession) // admin
+func (*user) Sessions(c *gin.Context) {
+	log := logger.Controller.WithControllerContext(types.NewControllerContext(c), consts.Phase("Sessions"))
+	sessions, err := jwt.ListSessions(sessionOwner(c))
+	if err != nil {
+		log.Error(err)
+		ResponseJSON(c, CodeFailure)
+		return
+	}
+	// WARN: you must clean tokens before response to user.
+	for _, s := range sessions {
+		s.AccessToken = ""
+		s.RefreshToken = ""
+	}
+	ResponseJSON(c, CodeSuccess, gin.H{"items": sessions, "total": len(sessions)})
+}
+
+// KillSession logs out the session by path parameter "session_id" on all the nodes.
+func (*user) KillSession(c *gin.Context) {
+	log := logger.Controller.WithControllerContext(types.NewControllerContext(c), consts.Phase("KillSession"))
+	sessionID := c.Param("session_id")
+	session, found := jwt.GetSession(sessionID)
+	if !found || session.UserID != sessionOwner(c) {
+		log.Errorf("session %q not found", sessionID)
+		ResponseJSON(c, CodeNotFound)
+		return
+	}
+	if err := jwt.RevokeSession(sessionID); err != nil {
+		log.Error(err)
+		ResponseJSON(c, CodeFailure)
+		return
+	}
+	log.Infow("session killed", "session_id", sessionID, "user_id", session.UserID, "operator", c.GetString(consts.CTX_USERNAME))
+	ResponseJSON(c, CodeSuccess)
+}
+
+// sessionOwner returns the user of path parameter "id" for the admin routes, or the current user.
+func sessionOwner(c *gin.Context) string {
+	if id := c.Param(consts.PARAM_ID); len(id) > 0 {
+		return id
+	}
+	return c.GetString(consts.CTX_USER_ID)
+}
+
 func (*user) RefreshToken(c *gin.Context) {
 }
 
@@ -143,12 +290,14 @@ func (*user) Signup(c *gin.Context) {
 		ResponseJSON(c, CodeInvalidSignup)
 		return
 	}
+	// The tenant of the user is assigned by the admin, never by the user self.
+	req.TenantID = ""
 	if err = validateUsername(req.Name); err != nil {
 		log.Error(err)
 		ResponseJSON(c, NewCode(CodeFailure, http.StatusBadRequest, err.Error()))
 		return
 	}
-	if err = validatePassword(req.Password); err != nil {
+	if err = passwd.Validate(req.Password); err != nil {
 		log.Error(err)
 		ResponseJSON(c, NewCode(CodeFailure, http.StatusBadRequest, err.Error()))
 		return
@@ -180,6 +329,7 @@ func (*user) Signup(c *gin.Context) {
 	req.ID = util.UUID()
 	req.LastLoginAt = util.ValueOf(model.GormTime(time.Now()))
 	req.LastLoginIP = util.IPv6ToIPv4(c.ClientIP())
+	req.LastPasswordChangeAt = util.ValueOf(model.GormTime(time.Now()))
 	if err := database.Database[*model.User](types.NewDatabaseContext(c)).Create(req); err != nil {
 		log.Error(err)
 		ResponseJSON(c, CodeFailure)
@@ -190,6 +340,16 @@ func (*user) Signup(c *gin.Context) {
 
 func (*user) ChangePasswd(c *gin.Context) {
 	log := logger.Controller.WithControllerContext(types.NewControllerContext(c), consts.Phase("ChangePasswd"))
+	limiter, found := changePasswdRatelimiterMap.Get(c.ClientIP())
+	if !found {
+		limiter = rate.NewLimiter(rate.Every(1000*time.Millisecond), 10)
+		changePasswdRatelimiterMap.Set(c.ClientIP(), limiter)
+	}
+	if !limiter.Allow() {
+		log.Error("too many change password requests")
+		ResponseJSON(c, NewCode(CodeTooManyRequests, http.StatusTooManyRequests, "too many change password requests"))
+		return
+	}
 
 	req := new(model.User)
 	if err := c.ShouldBindJSON(req); err != nil {
@@ -213,96 +373,111 @@ func (*user) ChangePasswd(c *gin.Context) {
 		ResponseJSON(c, CodeNotFoundUser)
 		return
 	}
-	hashedPasswd, err := encryptPasswd(req.Password)
-	if err != nil {
-		log.Error(err)
-		ResponseJSON(c, CodeFailure)
+	// The old password is checked as the login does, so it can't be guessed here.
+	if !checkLocked(c, log, u) {
 		return
 	}
-	if hashedPasswd != u.Password {
+	if err := comparePasswd(req.Password, u.Password); err != nil {
 		log.Error(CodeOldPasswordNotMatch)
+		writeLoginLog(c, &modellog.LoginLog{UserID: u.ID, Username: u.Name, Status: modellog.LoginStatusFailure, Reason: "old password not match"})
+		if _, err = passwd.Fail(types.NewDatabaseContext(c), u); err != nil {
+			log.Error(err)
+		}
 		ResponseJSON(c, CodeOldPasswordNotMatch)
 		return
 	}
+	if err := passwd.Succeed(types.NewDatabaseContext(c), u); err != nil {
+		log.Error(err)
+		ResponseJSON(c, CodeFailure)
+		return
+	}
+	u.NumWrong, u.LockExpire = 0, 0
 	if req.NewPassword != req.RePassword {
 		log.Error(CodeNewPasswordNotMatch)
 		ResponseJSON(c, CodeNewPasswordNotMatch)
 		return
 	}
-	hashedPasswd, err = encryptPasswd(req.NewPassword)
+	if err := passwd.Validate(req.NewPassword); err != nil {
+		log.Error(err)
+		ResponseJSON(c, NewCode(CodeFailure, http.StatusBadRequest, err.Error()))
+		return
+	}
+	if err := passwd.CheckHistory(types.NewDatabaseContext(c), u, req.NewPassword); err != nil {
+		log.Error(err)
+		if errors.Is(err, passwd.ErrPasswordReused) {
+			ResponseJSON(c, CodePasswordReused)
+		} else {
+			ResponseJSON(c, CodeFailure)
+		}
+		return
+	}
+	hashedPasswd, err := encryptPasswd(req.NewPassword)
 	if err != nil {
 		log.Error(err)
 		ResponseJSON(c, CodeFailure)
 		return
 	}
+	if err = passwd.Remember(types.NewDatabaseContext(c), u.ID, u.Password); err != nil {
+		log.Error(err)
+		ResponseJSON(c, CodeFailure)
+		return
+	}
 	u.Password = hashedPasswd
+	u.LastPasswordChangeAt = util.ValueOf(model.GormTime(time.Now()))
 	if err = database.Database[*model.User](types.NewDatabaseContext(c)).Update(u); err != nil {
 		log.Error(err)
 		ResponseJSON(c, CodeFailure)
 		return
 	}
-	_, claims, err := jwt.ParseTokenFromHeader(c.Request.Header)
-	if err != nil {
+	// The password changed, log out all the sessions of the user.
+	if err = jwt.RevokeTokens(u.ID); err != nil {
 		log.Error(err)
 		ResponseJSON(c, CodeFailure)
 		return
 	}
-	jwt.RevokeTokens(claims.Subject)
+	writeLoginLog(c, &modellog.LoginLog{UserID: u.ID, Username: u.Name, Status: modellog.LoginStatusSuccess, Reason: "password changed"})
 	ResponseJSON(c, CodeSuccess)
 }
 
-func encryptPasswd(pass string) (string, error) {
-	hashed, err := bcrypt.GenerateFromPassword([]byte(pass), 8)
-	if err != nil {
-		return "", err
-	}
-	return string(hashed), nil
-}
-
-func comparePasswd(pass string, hashed string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hashed), []byte(pass))
-}
-
-func validatePassword(password string) error {
-	if len(password) < 8 {
-		return errors.New("password too short")
-	}
-	var (
-		hasNumber      = false
-		hasLowerCase   = false
-		hasUpperCase   = false
-		hasSpecialChar = false
-	)
-	for _, c := range password {
-		switch {
-		case unicode.IsNumber(c):
-			hasNumber = true
-		case unicode.IsLower(c):
-			hasLowerCase = true
-		case unicode.IsUpper(c):
-			hasUpperCase = true
-		case unicode.IsPunct(c) || unicode.IsSymbol(c):
-			hasSpecialChar = true
-		}
-	}
-
-	if !hasNumber {
-		return errors.New("password must contain at least one number")
+// Unlock unlocks the account locked by the login failures, the user id is the path parameter "id".
+//
+// Example:
+//
+//	router.Auth().POST("/user/:id/unlock", controller.User.Unlock)
+func (*user) Unlock(c *gin.Context) {
+	log := logger.Controller.WithControllerContext(types.NewControllerContext(c), consts.Phase("Unlock"))
+	id := c.Param(consts.PARAM_ID)
+	if len(id) == 0 {
+		log.Error(CodeNotFoundUserID)
+		ResponseJSON(c, CodeNotFoundUserID)
+		return
 	}
-	if !hasLowerCase {
-		return errors.New("password must contain at least one lowercase letter")
+	u := new(model.User)
+	if err := database.Database[*model.User](types.NewDatabaseContext(c)).Get(u, id); err != nil {
+		log.Error(err)
+		ResponseJSON(c, CodeFailure)
+		return
 	}
-	if !hasUpperCase {
-		return errors.New("password must contain at least one uppercase letter")
+	if len(u.ID) == 0 {
+		log.Error(CodeNotFoundUser)
+		ResponseJSON(c, CodeNotFoundUser)
+		return
 	}
-	if !hasSpecialChar {
-		return errors.New("password must contain at least one special character")
+	if err := passwd.Unlock(types.NewDatabaseContext(c), u.ID); err != nil {
+		log.Error(err)
+		ResponseJSON(c, CodeFailure)
+		return
 	}
+	log.Infow("account unlocked", "user_id", u.ID, "username", u.Name, "operator", c.GetString(consts.CTX_USERNAME))
+	ResponseJSON(c, CodeSuccess)
+}
 
-	// if !hasNumber || !hasLowerCase || !hasUpperCase || !hasSpecialChar {
-	// 	return fmt.Errorf("password too weak")
-	// }
-	return nil
+func encryptPasswd(pass string) (string, error) {
+	return passwd.Hash(pass)
+}
+
+func comparePasswd(pass string, hashed string) error {
+	return passwd.Compare(pass, hashed)
 }
 
 func validateUsername(username string) error {
@@ -315,6 +490,65 @@ func validateUsername(username string) error {
 	return nil
 }
 
+// login completes the login of the authenticated user, the user required MFA gets the
+// "MFA pending" token instead, which is exchanged for the tokens by MFAVerify or MFAActivate.
+func login(c *gin.Context, log types.Logger, u *model.User) {
+	enrolled, required, err := mfa.Status(types.NewDatabaseContext(c), u.ID)
+	if err != nil {
+		log.Error(err)
+		ResponseJSON(c, CodeFailure)
+		return
+	}
+	if !required {
+		if issueTokens(c, log, u) {
+			ResponseJSON(c, CodeSuccess, u)
+		}
+		return
+	}
+	token, err := jwt.GenMFAToken(u.ID, u.Name, u.TenantID)
+	if err != nil {
+		log.Error(err)
+		ResponseJSON(c, CodeFailure)
+		return
+	}
+	ResponseJSON(c, CodeSuccess, &mfaChallenge{MFARequired: true, MFAEnrollRequired: !enrolled, MFAToken: token})
+}
+
+// issueTokens issues the tokens to the authenticated user, it responses the error and
+// returns false if failed, the caller responses the user if succeeded.
+func issueTokens(c *gin.Context, log types.Logger, u *model.User) bool {
+	session := createSession(c)
+	session.TenantID = u.TenantID
+	aToken, rToken, err := jwt.GenTokens(u.ID, u.Name, session)
+	if err != nil {
+		ResponseJSON(c, CodeFailure)
+		return false
+	}
+	u.Token = aToken
+	u.AccessToken = aToken
+	u.RefreshToken = rToken
+	u.SessionID = session.ID
+	u.TokenExpiration = util.ValueOf(model.GormTime(time.Now().Add(config.App.AccessTokenExpireDuration)))
+	writeLocalSessionAndCookie(c, aToken, rToken, u)
+	// WARN: you must clean password before response to user.
+	u.Password = ""
+
+	u.LastLoginAt = util.ValueOf(model.GormTime(time.Now()))
+	u.LastLoginIP = util.IPv6ToIPv4(c.ClientIP())
+	if err = database.Database[*model.User](types.NewDatabaseContext(c)).UpdateByID(u.ID, "last_login", u.LastLoginAt); err != nil {
+		log.Error(err)
+		ResponseJSON(c, CodeFailure)
+		return false
+	}
+	if err = database.Database[*model.User](types.NewDatabaseContext(c)).UpdateByID(u.ID, "last_login_ip", u.LastLoginIP); err != nil {
+		log.Error(err)
+		ResponseJSON(c, CodeFailure)
+		return false
+	}
+	writeLoginLog(c, &modellog.LoginLog{UserID: u.ID, Username: u.Name, Status: modellog.LoginStatusSuccess})
+	return true
+}
+
 func createSession(c *gin.Context) *model.Session {
 	ua := useragent.New(c.Request.UserAgent())
 	engineName, engineVersion := ua.Engine()
@@ -0,0 +1,59 @@
+package controller_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/forbearing/gst/authn/passwd"
+	"github.com/forbearing/gst/controller"
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/model"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignup(t *testing.T) {
+	r := gin.New()
+	r.POST("/signup", controller.User.Signup)
+
+	// The user can't choose the tenant.
+	req := httptest.NewRequest(http.MethodPost, "/signup", bytes.NewBufferString(`{"name":"signup-user","password":"Passw0rd!x","re_password":"Passw0rd!x","tenant_id":"acme"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w, _ := serve(t, r, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	users := make([]*model.User, 0)
+	require.NoError(t, database.Database[*model.User](nil).WithQuery(&model.User{Name: "signup-user"}).List(&users))
+	require.Len(t, users, 1)
+	assert.Empty(t, users[0].TenantID)
+}
+
+func TestChangePasswd(t *testing.T) {
+	r := gin.New()
+	r.POST("/passwd", controller.User.ChangePasswd)
+
+	hashed, err := passwd.Hash("Passw0rd!x")
+	require.NoError(t, err)
+	id := "passwd-user"
+	require.NoError(t, database.Database[*model.User](nil).Create(&model.User{Base: model.Base{ID: id}, Name: id, Password: hashed}))
+
+	// The wrong old password is counted as the login failure.
+	req := httptest.NewRequest(http.MethodPost, "/passwd", bytes.NewBufferString(`{"id":"`+id+`","password":"wrong","new_password":"N3wPassw0rd!","re_password":"N3wPassw0rd!"}`))
+	req.Header.Set("Content-Type", "application/json")
+	serve(t, r, req)
+	u := new(model.User)
+	require.NoError(t, database.Database[*model.User](nil).Get(u, id))
+	assert.Equal(t, 1, u.NumWrong)
+
+	// The correct old password resets it.
+	req = httptest.NewRequest(http.MethodPost, "/passwd", bytes.NewBufferString(`{"id":"`+id+`","password":"Passw0rd!x","new_password":"N3wPassw0rd!","re_password":"N3wPassw0rd!"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w, _ := serve(t, r, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	u = new(model.User)
+	require.NoError(t, database.Database[*model.User](nil).Get(u, id))
+	assert.Equal(t, 0, u.NumWrong)
+}
@@ -1,11 +1,20 @@
 package cronjob
 
 import (
+	"context"
 	"fmt"
+	"math/rand/v2"
+	"os"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/internal/jobrun"
 	pkgzap "github.com/forbearing/gst/logger/zap"
+	"github.com/forbearing/gst/model"
 	"github.com/forbearing/gst/util"
 	"github.com/robfig/cron/v3"
 	"go.uber.org/zap"
@@ -18,15 +27,75 @@ var (
 	parser   cron.Parser
 	mu       sync.Mutex
 
+	// locker claims the ticks across the replicas, nil if the distributed mode disabled.
+	locker Locker
+	// owner identifies the replica holding the locks.
+	owner string
+
+	// ctx is the context of all the runs, it's canceled by Stop.
+	ctx, cancel = context.WithCancel(context.Background())
+	// inflight tracks the running cronjobs, no run starts once stopped.
+	inflight sync.WaitGroup
+	stopped  bool
+
 	inited bool
 )
 
+// Func is the function of the cronjob, either func() error or func(context.Context) error.
+// The context is canceled on shutdown or Config.Timeout.
+type Func = jobrun.Func
+
+// Overlap is the policy of the run scheduled while the previous run is still running.
+type Overlap string
+
+const (
+	// OverlapAllow runs concurrently with the previous run, it's the default policy.
+	OverlapAllow Overlap = "allow"
+	// OverlapSkip skips the run.
+	OverlapSkip Overlap = "skip"
+	// OverlapQueue waits for the previous run to finish.
+	OverlapQueue Overlap = "queue"
+)
+
+// Misfire is the policy of the run starting too late, eg: queued by OverlapQueue.
+type Misfire string
+
+const (
+	// MisfireRun runs anyway, it's the default policy.
+	MisfireRun Misfire = "run"
+	// MisfireSkip skips the run and waits for the next tick.
+	MisfireSkip Misfire = "skip"
+)
+
+const (
+	defaultMisfireThreshold = time.Minute
+	defaultBackoff          = time.Second
+	defaultMaxBackoff       = time.Minute
+)
+
 type cronjob struct {
 	name           string
 	spec           string
-	fn             func() error
+	fn             func(context.Context) error
 	sched          cron.Schedule
 	runImmediately bool
+
+	local            bool
+	jitter           time.Duration
+	overlap          Overlap
+	misfire          Misfire
+	misfireThreshold time.Duration
+	policy           jobrun.Policy
+
+	// mu guards the schedule which can be changed at runtime, see Reschedule.
+	mu      sync.Mutex
+	tick    *tickSchedule
+	entryID cron.EntryID
+	paused  bool
+
+	running atomic.Bool
+	queue   sync.Mutex
+	state   jobrun.State
 }
 
 // Config defines the configuration for cronjob package
@@ -34,23 +103,68 @@ type Config struct {
 	// RunImmediately indicates whether to run the cronjob immediately after registration
 	// in addition to the scheduled execution
 	RunImmediately bool `json:"run_immediately" yaml:"run_immediately" toml:"run_immediately"`
+
+	// Local runs the cronjob on every replica even if config "cronjob.locker" is set,
+	// eg: the cronjob cleans up the local files.
+	Local bool `json:"local" yaml:"local" toml:"local"`
+	// Jitter delays each run by a random duration in [0, Jitter), so the cronjobs
+	// scheduled at the same time don't hit the backends at once.
+	Jitter time.Duration `json:"jitter" yaml:"jitter" toml:"jitter"`
+	// Overlap is the policy of the run scheduled while the previous run is still running,
+	// it applies across the replicas in the distributed mode. Default to OverlapAllow.
+	Overlap Overlap `json:"overlap" yaml:"overlap" toml:"overlap"`
+	// Misfire is the policy of the run starting later than the tick by more than MisfireThreshold.
+	// Default to MisfireRun.
+	Misfire Misfire `json:"misfire" yaml:"misfire" toml:"misfire"`
+	// MisfireThreshold default to 1 minute, the jitter is not counted.
+	MisfireThreshold time.Duration `json:"misfire_threshold" yaml:"misfire_threshold" toml:"misfire_threshold"`
+
+	// Timeout cancels the context of each attempt, zero means no timeout.
+	Timeout time.Duration `json:"timeout" yaml:"timeout" toml:"timeout"`
+	// Retries is the max retries of the failed run, zero means no retry.
+	Retries int `json:"retries" yaml:"retries" toml:"retries"`
+	// Backoff is the delay before the first retry, default to 1 second.
+	// It doubles every retry up to MaxBackoff, default to 1 minute.
+	Backoff    time.Duration `json:"backoff" yaml:"backoff" toml:"backoff"`
+	MaxBackoff time.Duration `json:"max_backoff" yaml:"max_backoff" toml:"max_backoff"`
 }
 
 func init() {
 	parser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+	hostname, _ := os.Hostname()
+	owner = hostname + ":" + strconv.Itoa(os.Getpid()) + ":" + util.UUID()
 }
 
+// Init starts the cronjobs registered.
+//
+// If config "cronjob.locker" is set, the cronjobs run in distributed mode: every replica
+// schedules the cronjobs, but each tick is claimed and executed by only one replica.
 func Init() (err error) {
 	if log == nil {
 		log = pkgzap.New("cronjob.log")
 	}
+	if locker == nil {
+		if locker, err = newLocker(config.App.Cronjob.Locker); err != nil {
+			return err
+		}
+	}
+	// The lock without ttl never expires in redis but expires at once in database.
+	if locker != nil && config.App.Cronjob.LockTTL <= 0 {
+		return errors.Newf("invalid cronjob lock ttl %s, it must be positive", config.App.Cronjob.LockTTL)
+	}
 	if c == nil {
 		c = cron.New(cron.WithSeconds())
 	}
+	if config.App.Cronjob.History && config.App.Cronjob.HistoryRetention > 0 && !inited {
+		Register(jobrun.Cleanup, "0 0 * * * *", "cleanup job run history")
+	}
 
+	mu.Lock()
 	for _, cj := range cronjobs {
 		register(cj)
 	}
+	mu.Unlock()
 
 	c.Start()
 
@@ -60,25 +174,51 @@ func Init() (err error) {
 
 // Register cronjob can be called at any point before or after Init().
 // The config parameter is optional and can be used to customize cronjob behavior.
-func Register(fn func() error, spec string, name string, config ...Config) {
+// The fn is either func() error or func(context.Context) error.
+func Register[F Func](fn F, spec string, name string, config ...Config) {
 	var cfg Config
 	if len(config) > 0 {
 		cfg = config[0]
 	}
+	if len(cfg.Overlap) == 0 {
+		cfg.Overlap = OverlapAllow
+	}
+	if len(cfg.Misfire) == 0 {
+		cfg.Misfire = MisfireRun
+	}
+	if cfg.MisfireThreshold <= 0 {
+		cfg.MisfireThreshold = defaultMisfireThreshold
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = defaultBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = defaultMaxBackoff
+	}
 
 	mu.Lock()
 	defer mu.Unlock()
 	cj := &cronjob{
-		name:           name,
-		spec:           spec,
-		fn:             fn,
-		runImmediately: cfg.RunImmediately,
+		name:             name,
+		spec:             spec,
+		fn:               jobrun.Wrap(fn),
+		runImmediately:   cfg.RunImmediately,
+		local:            cfg.Local,
+		jitter:           cfg.Jitter,
+		overlap:          cfg.Overlap,
+		misfire:          cfg.Misfire,
+		misfireThreshold: cfg.MisfireThreshold,
+		policy: jobrun.Policy{
+			Timeout:    cfg.Timeout,
+			Retries:    cfg.Retries,
+			Backoff:    cfg.Backoff,
+			MaxBackoff: cfg.MaxBackoff,
+		},
 	}
 
+	cronjobs = append(cronjobs, cj)
 	if inited {
 		register(cj)
-	} else {
-		cronjobs = append(cronjobs, cj)
 	}
 }
 
@@ -97,36 +237,251 @@ func register(cj *cronjob) {
 
 	// Execute immediately if configured to do so
 	if cj.runImmediately {
-		go func() {
-			defer func() {
-				if err := recover(); err != nil {
-					log.Errorw(fmt.Sprintf("cronjob immediate execution panic: %s", err), "name", cj.name, "spec", cj.spec)
+		go cj.run(time.Now(), model.JobTriggerImmediate)
+	}
+
+	cj.mu.Lock()
+	if !cj.paused {
+		cj.schedule()
+	}
+	cj.mu.Unlock()
+	log.Infoz("successfully add cronjob", zap.String("name", cj.name), zap.String("spec", cj.spec), zap.Bool("run_immediately", cj.runImmediately), zap.Bool("distributed", cj.distributed()))
+}
+
+// schedule adds the cronjob to the cron with the current spec, the caller must hold cj.mu.
+func (cj *cronjob) schedule() {
+	tick := &tickSchedule{Schedule: cj.sched}
+	cj.tick = tick
+	cj.entryID = c.Schedule(tick, cron.FuncJob(func() { cj.run(tick.Fired(), model.JobTriggerSchedule) }))
+}
+
+func (cj *cronjob) distributed() bool { return locker != nil && !cj.local }
+
+// run executes the run fired at the tick by the trigger. Only the scheduled run is checked by
+// the misfire policy, and the manual run is not claimed across the replicas.
+func (cj *cronjob) run(tick time.Time, trigger string) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Errorw(fmt.Sprintf("cronjob panic: %s", err), "name", cj.name, "spec", cj.spec)
+		}
+	}()
+	if !track() {
+		return
+	}
+	defer inflight.Done()
+	ttl := config.App.Cronjob.LockTTL
+	// The run is canceled once any lock it holds is lost, the other replica may run it then.
+	runCtx, cancelRun := context.WithCancel(ctx)
+	defer cancelRun()
+	cj.mu.Lock()
+	spec, sched := cj.spec, cj.sched
+	cj.mu.Unlock()
+
+	if cj.distributed() && trigger != model.JobTriggerManual {
+		// The replicas starting within the same interval run the immediate cronjob once.
+		key := keyPrefix + cj.name + ":" + strconv.FormatInt(tick.Unix(), 10)
+		if trigger == model.JobTriggerImmediate {
+			key = keyPrefix + cj.name + ":immediate:" + strconv.FormatInt(sched.Next(tick).Unix(), 10)
+		}
+		ok, err := locker.Acquire(ctx, key, owner, ttl)
+		if err != nil {
+			log.Errorz(fmt.Sprintf("failed to claim cronjob: %s", err), zap.String("name", cj.name), zap.Time("tick", tick))
+			return
+		}
+		if !ok {
+			log.Debugz("cronjob claimed by other replica", zap.String("name", cj.name), zap.Time("tick", tick))
+			return
+		}
+		// The claim outlives the run, so the tick isn't claimed again by the replicas firing late.
+		defer cj.hold(key, ttl, cancelRun)()
+	}
+
+	var jitter time.Duration
+	if cj.jitter > 0 {
+		jitter = rand.N(cj.jitter)
+		if !sleep(jitter) {
+			return
+		}
+	}
+
+	switch cj.overlap {
+	case OverlapSkip:
+		if !cj.running.CompareAndSwap(false, true) {
+			log.Warnz("cronjob skipped, the previous run is still running", zap.String("name", cj.name), zap.Time("tick", tick))
+			cj.state.Skip(model.JobKindCronjob, cj.name, trigger, "the previous run is still running")
+			return
+		}
+		defer cj.running.Store(false)
+		if cj.distributed() {
+			ok, err := locker.Acquire(ctx, cj.runningKey(), owner, ttl)
+			if err != nil || !ok {
+				log.Warnz("cronjob skipped, the previous run is still running", zap.String("name", cj.name), zap.Time("tick", tick), zap.Error(err))
+				cj.state.Skip(model.JobKindCronjob, cj.name, trigger, "the previous run is still running")
+				return
+			}
+			defer cj.release(ctx)
+			defer cj.hold(cj.runningKey(), ttl, cancelRun)()
+		}
+	case OverlapQueue:
+		cj.queue.Lock()
+		defer cj.queue.Unlock()
+		if cj.distributed() {
+			for {
+				ok, err := locker.Acquire(ctx, cj.runningKey(), owner, ttl)
+				if err != nil {
+					log.Errorz(fmt.Sprintf("failed to lock cronjob: %s", err), zap.String("name", cj.name), zap.Time("tick", tick))
+					return
+				}
+				if ok {
+					break
+				}
+				if !sleep(time.Second) {
+					return
 				}
-			}()
-			begin := time.Now()
-			if err = cj.fn(); err != nil {
-				log.Errorz(fmt.Sprintf("finished immediate cronjob execution with error: %s", err), zap.String("name", cj.name), zap.String("spec", cj.spec), zap.String("cost", util.FormatDurationSmart(time.Since(begin))))
-			} else {
-				log.Infoz("finished immediate cronjob execution", zap.String("name", cj.name), zap.String("spec", cj.spec), zap.String("cost", util.FormatDurationSmart(time.Since(begin))))
 			}
-		}()
+			defer cj.release(ctx)
+			defer cj.hold(cj.runningKey(), ttl, cancelRun)()
+		}
 	}
 
-	if _, err = c.AddFunc(cj.spec, func() {
-		defer func() {
-			if err := recover(); err != nil {
-				log.Errorw(fmt.Sprintf("cronjob panic: %s", err), "name", cj.name, "spec", cj.spec)
-			}
-		}()
-		begin := time.Now()
-		if err = cj.fn(); err != nil {
-			log.Errorz(fmt.Sprintf("finished cronjob with error: %s", err), zap.String("name", cj.name), zap.String("spec", cj.spec), zap.Time("next", cj.sched.Next(begin)), zap.String("cost", util.FormatDurationSmart(time.Since(begin))))
-		} else {
-			log.Infoz("finished cronjob", zap.String("name", cj.name), zap.String("spec", cj.spec), zap.Time("next", cj.sched.Next(begin)), zap.String("cost", util.FormatDurationSmart(time.Since(begin))))
+	if delay := time.Since(tick) - jitter; trigger == model.JobTriggerSchedule && delay > cj.misfireThreshold {
+		if cj.misfire == MisfireSkip {
+			log.Warnz("cronjob misfired, skipped", zap.String("name", cj.name), zap.Time("tick", tick), zap.String("delay", util.FormatDurationSmart(delay)))
+			cj.state.Skip(model.JobKindCronjob, cj.name, trigger, "misfired, delayed "+util.FormatDurationSmart(delay))
+			return
 		}
-	}); err != nil {
-		log.Errorz(fmt.Sprintf("failed to add cronjob: %s", err), zap.String("name", cj.name), zap.String("spec", cj.spec))
+		log.Warnz("cronjob misfired, run anyway", zap.String("name", cj.name), zap.Time("tick", tick), zap.String("delay", util.FormatDurationSmart(delay)))
+	}
+
+	begin := time.Now()
+	cj.state.Start()
+	err := cj.policy.Call(runCtx, cj.fn, func(attempt int, delay time.Duration, err error) {
+		log.Warnz(fmt.Sprintf("cronjob failed, retry: %s", err), zap.String("name", cj.name), zap.Int("attempt", attempt), zap.String("delay", util.FormatDurationSmart(delay)))
+	})
+	cj.state.Finish(model.JobKindCronjob, cj.name, trigger, begin, err)
+	if err != nil {
+		log.Errorz(fmt.Sprintf("finished cronjob with error: %s", err), zap.String("name", cj.name), zap.String("spec", spec), zap.String("trigger", trigger), zap.Time("next", sched.Next(begin)), zap.String("cost", util.FormatDurationSmart(time.Since(begin))))
 	} else {
-		log.Infoz("successfully add cronjob", zap.String("name", cj.name), zap.String("spec", cj.spec), zap.Bool("run_immediately", cj.runImmediately))
+		log.Infoz("finished cronjob", zap.String("name", cj.name), zap.String("spec", spec), zap.String("trigger", trigger), zap.Time("next", sched.Next(begin)), zap.String("cost", util.FormatDurationSmart(time.Since(begin))))
+	}
+}
+
+// track tracks the run starting, it returns false if stopped.
+func track() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	if stopped {
+		return false
+	}
+	inflight.Add(1)
+	return true
+}
+
+// sleep sleeps for the duration, it returns false if stopped.
+func sleep(d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// Stop stops scheduling the cronjobs and cancels the context of the running ones,
+// then waits for them to finish within config "cronjob.drain_timeout".
+// It's registered to bootstrap.Cleanup.
+func Stop() {
+	mu.Lock()
+	stopped = true
+	if c != nil {
+		c.Stop()
+	}
+	mu.Unlock()
+	cancel()
+	if !jobrun.Wait(&inflight, config.App.Cronjob.DrainTimeout) && log != nil {
+		log.Warnz("cronjobs not finished within the drain timeout", zap.Duration("timeout", config.App.Cronjob.DrainTimeout))
+	}
+}
+
+func (cj *cronjob) runningKey() string { return keyPrefix + cj.name + ":running" }
+
+func (cj *cronjob) release(ctx context.Context) {
+	if err := locker.Release(ctx, cj.runningKey(), owner); err != nil {
+		log.Errorz(fmt.Sprintf("failed to unlock cronjob: %s", err), zap.String("name", cj.name))
+	}
+}
+
+// hold renews the lock of the key every third of the ttl until the returned function called,
+// so the lock is held by the run longer than the ttl. The lock is never renewed once stopped,
+// and lost is called if the lock is lost.
+func (cj *cronjob) hold(key string, ttl time.Duration, lost context.CancelFunc) (stop func()) {
+	if ttl <= 0 {
+		return func() {}
+	}
+	hbCtx, stopHeartbeat := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if !cj.heartbeat(hbCtx, key, ttl) {
+			lost()
+		}
+	}()
+	return func() {
+		stopHeartbeat()
+		<-done
+	}
+}
+
+// heartbeat renews the lock of the key until ctx done, it returns false if the lock is lost.
+func (cj *cronjob) heartbeat(ctx context.Context, key string, ttl time.Duration) bool {
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return true
+		case <-ticker.C:
+			ok, err := locker.Renew(ctx, key, owner, ttl)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Warnz(fmt.Sprintf("failed to renew cronjob lock: %s", err), zap.String("name", cj.name), zap.String("key", key))
+				}
+				continue
+			}
+			if !ok {
+				log.Warnz("cronjob lock lost, cancel the run, it may be claimed by the other replica", zap.String("name", cj.name), zap.String("key", key))
+				return false
+			}
+		}
+	}
+}
+
+// tickSchedule records the ticks of the cronjob. robfig/cron starts the job and computes
+// the next tick concurrently, so the tick being fired is either the recorded next tick
+// or the previous one if the next tick was advanced.
+type tickSchedule struct {
+	cron.Schedule
+	ticks atomic.Pointer[[2]time.Time] // prev, next
+}
+
+func (s *tickSchedule) Next(t time.Time) time.Time {
+	next := s.Schedule.Next(t)
+	var prev time.Time
+	if ticks := s.ticks.Load(); ticks != nil {
+		prev = ticks[1]
+	}
+	s.ticks.Store(&[2]time.Time{prev, next})
+	return next
+}
+
+// Fired returns the tick being fired.
+func (s *tickSchedule) Fired() time.Time {
+	ticks := s.ticks.Load()
+	if ticks == nil {
+		return time.Now()
+	}
+	if ticks[1].After(time.Now()) {
+		return ticks[0]
 	}
+	return ticks[1]
 }
@@ -0,0 +1,213 @@
+package cronjob
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/internal/jobrun"
+	pkgzap "github.com/forbearing/gst/logger/zap"
+	"github.com/forbearing/gst/model"
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestCronjob(name string, fn func() error, cfg Config) *cronjob {
+	cj := &cronjob{name: name, spec: "* * * * * *", fn: jobrun.Wrap(fn), overlap: cfg.Overlap, misfire: cfg.Misfire, misfireThreshold: cfg.MisfireThreshold}
+	cj.sched, _ = parser.Parse(cj.spec)
+	return cj
+}
+
+func TestDistributedRun(t *testing.T) {
+	log = pkgzap.New()
+	locker = newMemoryLocker()
+	config.App.Cronjob.LockTTL = time.Minute
+	defer func() { locker = nil }()
+
+	t.Run("each tick runs once", func(t *testing.T) {
+		var count atomic.Int32
+		fn := func() error { count.Add(1); return nil }
+		tick := time.Now().Truncate(time.Second)
+
+		// The same cronjob scheduled by 3 replicas.
+		var wg sync.WaitGroup
+		for range 3 {
+			cj := newTestCronjob("once", fn, Config{Misfire: MisfireRun, MisfireThreshold: time.Minute})
+			wg.Add(1)
+			go func() { defer wg.Done(); cj.run(tick, model.JobTriggerSchedule) }()
+		}
+		wg.Wait()
+		assert.Equal(t, int32(1), count.Load())
+
+		newTestCronjob("once", fn, Config{}).run(tick.Add(time.Second), model.JobTriggerSchedule)
+		assert.Equal(t, int32(2), count.Load())
+	})
+
+	t.Run("overlap skip", func(t *testing.T) {
+		var count atomic.Int32
+		release := make(chan struct{})
+		fn := func() error { count.Add(1); <-release; return nil }
+		tick := time.Now().Truncate(time.Second)
+
+		replica1 := newTestCronjob("skip", fn, Config{Overlap: OverlapSkip, MisfireThreshold: time.Minute})
+		replica2 := newTestCronjob("skip", fn, Config{Overlap: OverlapSkip, MisfireThreshold: time.Minute})
+		done := make(chan struct{})
+		go func() { replica1.run(tick, model.JobTriggerSchedule); close(done) }()
+		assert.Eventually(t, func() bool { return count.Load() == 1 }, time.Second, 10*time.Millisecond)
+
+		// The next tick is claimed by another replica but skipped, the previous run is still running.
+		replica2.run(tick.Add(time.Second), model.JobTriggerSchedule)
+		assert.Equal(t, int32(1), count.Load())
+		close(release)
+		<-done
+
+		replica2.run(tick.Add(2*time.Second), model.JobTriggerSchedule)
+		assert.Equal(t, int32(2), count.Load())
+	})
+
+	t.Run("lock renewed", func(t *testing.T) {
+		config.App.Cronjob.LockTTL = 150 * time.Millisecond
+		defer func() { config.App.Cronjob.LockTTL = time.Minute }()
+
+		var count atomic.Int32
+		release := make(chan struct{})
+		// Only the first run blocks.
+		fn := func() error {
+			if count.Add(1) == 1 {
+				<-release
+			}
+			return nil
+		}
+		tick := time.Now().Truncate(time.Second)
+
+		replica1 := newTestCronjob("renew", fn, Config{Overlap: OverlapSkip, MisfireThreshold: time.Minute})
+		replica2 := newTestCronjob("renew", fn, Config{Overlap: OverlapSkip, MisfireThreshold: time.Minute})
+		done := make(chan struct{})
+		go func() { replica1.run(tick, model.JobTriggerSchedule); close(done) }()
+		assert.Eventually(t, func() bool { return count.Load() == 1 }, time.Second, 10*time.Millisecond)
+
+		// The run lasts longer than the ttl, the locks are still held.
+		time.Sleep(500 * time.Millisecond)
+		replica2.run(tick, model.JobTriggerSchedule)
+		replica2.run(tick.Add(time.Second), model.JobTriggerSchedule)
+		assert.Equal(t, int32(1), count.Load())
+		close(release)
+		<-done
+
+		replica2.run(tick.Add(2*time.Second), model.JobTriggerSchedule)
+		assert.Equal(t, int32(2), count.Load())
+	})
+
+	t.Run("lock lost", func(t *testing.T) {
+		config.App.Cronjob.LockTTL = 150 * time.Millisecond
+		defer func() { config.App.Cronjob.LockTTL = time.Minute }()
+
+		started := make(chan struct{})
+		var canceled atomic.Bool
+		fn := func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			canceled.Store(true)
+			return ctx.Err()
+		}
+		cj := &cronjob{name: "lost", spec: "* * * * * *", fn: fn, overlap: OverlapSkip, misfireThreshold: time.Minute}
+		cj.sched, _ = parser.Parse(cj.spec)
+		done := make(chan struct{})
+		go func() { cj.run(time.Now().Truncate(time.Second), model.JobTriggerSchedule); close(done) }()
+		<-started
+
+		// The lock is taken over by the other replica, the run is canceled.
+		ml := locker.(*memoryLocker) //nolint:errcheck
+		ml.mu.Lock()
+		ml.locks[cj.runningKey()] = memoryLock{owner: "other", expires: time.Now().Add(time.Minute)}
+		ml.mu.Unlock()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("the run is not canceled")
+		}
+		assert.True(t, canceled.Load())
+	})
+
+	t.Run("misfire skip", func(t *testing.T) {
+		var count atomic.Int32
+		cj := newTestCronjob("misfire", func() error { count.Add(1); return nil }, Config{Misfire: MisfireSkip, MisfireThreshold: time.Second})
+		cj.run(time.Now().Add(-time.Minute), model.JobTriggerSchedule)
+		assert.Equal(t, int32(0), count.Load())
+		cj.run(time.Now(), model.JobTriggerSchedule)
+		assert.Equal(t, int32(1), count.Load())
+	})
+}
+
+func TestRegistry(t *testing.T) {
+	log = pkgzap.New()
+	c = cron.New(cron.WithSeconds())
+	c.Start()
+	inited = true
+	defer func() { c.Stop(); c, inited, cronjobs = nil, false, nil }()
+
+	var count atomic.Int32
+	Register(func() error { count.Add(1); return errors.New("boom") }, "0 0 0 1 1 *", "registry")
+
+	assert.ErrorIs(t, Trigger("unknown"), ErrNotFound)
+	assert.NoError(t, Trigger("registry"))
+	assert.Eventually(t, func() bool { return List()[0].Runs == 1 }, time.Second, 10*time.Millisecond)
+	info := List()[0]
+	assert.Equal(t, model.JobStatusFailure, info.LastStatus)
+	assert.Equal(t, "boom", info.LastError)
+	assert.Equal(t, int64(1), info.Failures)
+	assert.NotNil(t, info.Next)
+
+	assert.NoError(t, Pause("registry"))
+	info = List()[0]
+	assert.True(t, info.Paused)
+	assert.Nil(t, info.Next)
+
+	assert.Error(t, Reschedule("registry", "invalid"))
+	assert.NoError(t, Reschedule("registry", "* * * * * *"))
+	assert.Equal(t, "* * * * * *", List()[0].Spec)
+	assert.NoError(t, Resume("registry"))
+	assert.Eventually(t, func() bool { return count.Load() >= 2 }, 3*time.Second, 50*time.Millisecond)
+}
+
+func TestDistributedControl(t *testing.T) {
+	log = pkgzap.New()
+	locker = newMemoryLocker()
+	defer func() { locker, cronjobs = nil, nil }()
+
+	Register(func() error { return nil }, "0 0 0 1 1 *", "distributed")
+	Register(func() error { return nil }, "0 0 0 1 1 *", "local", Config{Local: true})
+
+	// The schedule of the distributed cronjob is shared by the replicas.
+	assert.ErrorIs(t, Pause("distributed"), ErrDistributed)
+	assert.ErrorIs(t, Resume("distributed"), ErrDistributed)
+	assert.ErrorIs(t, Reschedule("distributed", "* * * * * *"), ErrDistributed)
+	assert.NoError(t, Pause("local"))
+	assert.NoError(t, Resume("local"))
+}
+
+func TestTimeoutRetry(t *testing.T) {
+	log = pkgzap.New()
+	defer func() { cronjobs = nil }()
+
+	var attempts atomic.Int32
+	fn := func(ctx context.Context) error {
+		attempts.Add(1)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	Register(fn, "0 0 0 1 1 *", "timeout", Config{Timeout: 20 * time.Millisecond, Retries: 2, Backoff: 10 * time.Millisecond})
+
+	assert.NoError(t, Trigger("timeout"))
+	assert.Eventually(t, func() bool { return List()[0].Runs == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(3), attempts.Load())
+	assert.Equal(t, context.DeadlineExceeded.Error(), List()[0].LastError)
+
+	assert.NoError(t, Unregister("timeout"))
+	assert.ErrorIs(t, Unregister("timeout"), ErrNotFound)
+	assert.Empty(t, List())
+}
@@ -0,0 +1,227 @@
+package cronjob
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/provider/etcd"
+	"github.com/forbearing/gst/provider/redis"
+	goredis "github.com/redis/go-redis/v9"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"gorm.io/gorm/clause"
+)
+
+const keyPrefix = "gst:cronjob:"
+
+// Locker claims the scheduled runs across the replicas, the key is owned by only one
+// replica until released or expired.
+type Locker interface {
+	// Acquire acquires the key for the owner, it returns false if the key is owned by others.
+	Acquire(ctx context.Context, key, owner string, ttl time.Duration) (bool, error)
+	// Renew extends the key by the ttl if it's owned by the owner, it returns false if the key is lost.
+	Renew(ctx context.Context, key, owner string, ttl time.Duration) (bool, error)
+	// Release releases the key if it's owned by the owner.
+	Release(ctx context.Context, key, owner string) error
+}
+
+// newLocker creates the locker of config "cronjob.locker", nil if the distributed mode disabled.
+func newLocker(typ config.CronjobLocker) (Locker, error) {
+	switch typ {
+	case config.CronjobLockerNone:
+		return nil, nil
+	case config.CronjobLockerMemory:
+		return newMemoryLocker(), nil
+	case config.CronjobLockerDatabase:
+		if database.DB == nil {
+			return nil, database.ErrInvalidDB
+		}
+		if err := database.DB.AutoMigrate(new(cronjobLock)); err != nil {
+			return nil, errors.Wrap(err, "failed to migrate cronjob lock table")
+		}
+		return new(databaseLocker), nil
+	case config.CronjobLockerRedis:
+		if redis.Client() == nil {
+			return nil, redis.ErrRedisIsDisabled
+		}
+		return new(redisLocker), nil
+	case config.CronjobLockerEtcd:
+		if etcd.Client() == nil {
+			return nil, errors.New("etcd is disabled")
+		}
+		return new(etcdLocker), nil
+	default:
+		return nil, errors.Newf("unknown cronjob locker %q", typ)
+	}
+}
+
+// memoryLocker claims the runs in process, it's the fallback for tests.
+type memoryLocker struct {
+	mu    sync.Mutex
+	locks map[string]memoryLock
+}
+
+type memoryLock struct {
+	owner   string
+	expires time.Time
+}
+
+func newMemoryLocker() *memoryLocker {
+	return &memoryLocker{locks: make(map[string]memoryLock)}
+}
+
+func (l *memoryLocker) Acquire(_ context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	for k, lock := range l.locks {
+		if now.After(lock.expires) {
+			delete(l.locks, k)
+		}
+	}
+	if _, ok := l.locks[key]; ok {
+		return false, nil
+	}
+	l.locks[key] = memoryLock{owner: owner, expires: now.Add(ttl)}
+	return true, nil
+}
+
+func (l *memoryLocker) Renew(_ context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	lock, ok := l.locks[key]
+	if !ok || lock.owner != owner || now.After(lock.expires) {
+		return false, nil
+	}
+	l.locks[key] = memoryLock{owner: owner, expires: now.Add(ttl)}
+	return true, nil
+}
+
+func (l *memoryLocker) Release(_ context.Context, key, owner string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.locks[key].owner == owner {
+		delete(l.locks, key)
+	}
+	return nil
+}
+
+// cronjobLock is the lock stored in database, the primary key makes sure the key
+// is inserted by only one replica.
+type cronjobLock struct {
+	Name      string `gorm:"primaryKey;size:255"`
+	Owner     string `gorm:"size:255"`
+	ExpiresAt int64  `gorm:"index"` // unix milliseconds
+}
+
+func (cronjobLock) TableName() string { return "cronjob_locks" }
+
+type databaseLocker struct{}
+
+func (databaseLocker) Acquire(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	db := database.DB.WithContext(ctx)
+	// Remove the expired locks, including the claims of the past ticks.
+	if err := db.Where("expires_at < ?", now.UnixMilli()).Delete(new(cronjobLock)).Error; err != nil {
+		return false, err
+	}
+	res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&cronjobLock{Name: key, Owner: owner, ExpiresAt: now.Add(ttl).UnixMilli()})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (databaseLocker) Renew(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	res := database.DB.WithContext(ctx).Model(new(cronjobLock)).
+		Where("name = ? AND owner = ? AND expires_at >= ?", key, owner, now.UnixMilli()).
+		Update("expires_at", now.Add(ttl).UnixMilli())
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (databaseLocker) Release(ctx context.Context, key, owner string) error {
+	return database.DB.WithContext(ctx).Where("name = ? AND owner = ?", key, owner).Delete(new(cronjobLock)).Error
+}
+
+// releaseScript deletes the key only if it's still owned by the owner.
+var releaseScript = goredis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// renewScript extends the key only if it's still owned by the owner.
+var renewScript = goredis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+type redisLocker struct{}
+
+func (redisLocker) Acquire(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	return redis.Client().SetNX(ctx, key, owner, ttl).Result()
+}
+
+func (redisLocker) Renew(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	n, err := renewScript.Run(ctx, redis.Client(), []string{key}, owner, ttl.Milliseconds()).Int()
+	return n > 0, err
+}
+
+func (redisLocker) Release(ctx context.Context, key, owner string) error {
+	return releaseScript.Run(ctx, redis.Client(), []string{key}, owner).Err()
+}
+
+type etcdLocker struct{}
+
+func (etcdLocker) Acquire(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	cli := etcd.Client()
+	lease, err := cli.Grant(ctx, max(int64(ttl.Seconds()), 1))
+	if err != nil {
+		return false, err
+	}
+	resp, err := cli.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+		Then(clientv3.OpPut(key, owner, clientv3.WithLease(lease.ID))).
+		Commit()
+	if err != nil || !resp.Succeeded {
+		_, _ = cli.Revoke(context.Background(), lease.ID)
+		return false, err
+	}
+	return true, nil
+}
+
+// Renew keeps the lease of the key alive, the lease is granted with the ttl by Acquire.
+func (etcdLocker) Renew(ctx context.Context, key, owner string, _ time.Duration) (bool, error) {
+	cli := etcd.Client()
+	resp, err := cli.Get(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if len(resp.Kvs) == 0 || string(resp.Kvs[0].Value) != owner || resp.Kvs[0].Lease == 0 {
+		return false, nil
+	}
+	// The key is deleted with its expired lease.
+	if _, err = cli.KeepAliveOnce(ctx, clientv3.LeaseID(resp.Kvs[0].Lease)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (etcdLocker) Release(ctx context.Context, key, owner string) error {
+	_, err := etcd.Client().Txn(ctx).
+		If(clientv3.Compare(clientv3.Value(key), "=", owner)).
+		Then(clientv3.OpDelete(key)).
+		Commit()
+	return err
+}
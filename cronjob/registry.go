@@ -0,0 +1,169 @@
+package cronjob
+
+import (
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/util"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrNotFound is returned if no cronjob registered with the name.
+	ErrNotFound = errors.New("cronjob not found")
+	// ErrDistributed is returned by Pause, Resume and Reschedule in the distributed mode.
+	ErrDistributed = errors.New("distributed cronjob can't be paused or rescheduled at runtime")
+)
+
+// The runtime control below only applies to the current replica. The schedule of the
+// distributed cronjob can't be changed, otherwise the replicas would claim the different
+// ticks and run it twice, or the cronjob paused here would still be run by the others.
+
+// List returns the runtime state of the registered cronjobs.
+func List() []types.JobInfo {
+	mu.Lock()
+	defer mu.Unlock()
+	infos := make([]types.JobInfo, 0, len(cronjobs))
+	for _, cj := range cronjobs {
+		infos = append(infos, cj.info())
+	}
+	return infos
+}
+
+// Trigger runs the cronjob now in background, the run is not claimed across the replicas
+// but the overlap policy still applies.
+func Trigger(name string) error {
+	cj, err := find(name)
+	if err != nil {
+		return err
+	}
+	go cj.run(time.Now(), model.JobTriggerManual)
+	return nil
+}
+
+// Pause stops scheduling the cronjob, the running one is not interrupted.
+func Pause(name string) error {
+	cj, err := controllable(name)
+	if err != nil {
+		return err
+	}
+	cj.mu.Lock()
+	defer cj.mu.Unlock()
+	if cj.paused {
+		return nil
+	}
+	if cj.tick != nil {
+		c.Remove(cj.entryID)
+	}
+	cj.paused = true
+	log.Infoz("cronjob paused", zap.String("name", cj.name))
+	return nil
+}
+
+// Resume resumes scheduling the cronjob paused.
+func Resume(name string) error {
+	cj, err := controllable(name)
+	if err != nil {
+		return err
+	}
+	cj.mu.Lock()
+	defer cj.mu.Unlock()
+	if !cj.paused {
+		return nil
+	}
+	cj.paused = false
+	if inited && cj.sched != nil {
+		cj.schedule()
+	}
+	log.Infoz("cronjob resumed", zap.String("name", cj.name))
+	return nil
+}
+
+// Reschedule changes the cron expression of the cronjob without restart.
+func Reschedule(name, spec string) error {
+	sched, err := parser.Parse(spec)
+	if err != nil {
+		return errors.Wrapf(err, "invalid cronjob spec %q", spec)
+	}
+	cj, err := controllable(name)
+	if err != nil {
+		return err
+	}
+	cj.mu.Lock()
+	defer cj.mu.Unlock()
+	if cj.tick != nil && !cj.paused {
+		c.Remove(cj.entryID)
+	}
+	old := cj.spec
+	cj.spec, cj.sched = spec, sched
+	if inited && !cj.paused {
+		cj.schedule()
+	}
+	log.Infoz("cronjob rescheduled", zap.String("name", cj.name), zap.String("old", old), zap.String("spec", spec))
+	return nil
+}
+
+// Unregister removes the cronjob, the running one is not interrupted.
+func Unregister(name string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	for i, cj := range cronjobs {
+		if cj.name != name {
+			continue
+		}
+		cj.mu.Lock()
+		if cj.tick != nil && !cj.paused {
+			c.Remove(cj.entryID)
+		}
+		cj.paused = true
+		cj.mu.Unlock()
+		cronjobs = append(cronjobs[:i], cronjobs[i+1:]...)
+		log.Infoz("cronjob unregistered", zap.String("name", name))
+		return nil
+	}
+	return errors.Wrapf(ErrNotFound, "cronjob %q", name)
+}
+
+func find(name string) (*cronjob, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, cj := range cronjobs {
+		if cj.name == name {
+			return cj, nil
+		}
+	}
+	return nil, errors.Wrapf(ErrNotFound, "cronjob %q", name)
+}
+
+// controllable returns the cronjob whose schedule can be changed at runtime.
+func controllable(name string) (*cronjob, error) {
+	cj, err := find(name)
+	if err != nil {
+		return nil, err
+	}
+	if cj.distributed() {
+		return nil, errors.Wrapf(ErrDistributed, "cronjob %q", name)
+	}
+	return cj, nil
+}
+
+func (cj *cronjob) info() types.JobInfo {
+	cj.mu.Lock()
+	info := types.JobInfo{
+		Kind:        model.JobKindCronjob,
+		Name:        cj.name,
+		Spec:        cj.spec,
+		Paused:      cj.paused,
+		Distributed: cj.distributed(),
+	}
+	if cj.tick != nil && !cj.paused {
+		if next := c.Entry(cj.entryID).Next; !next.IsZero() {
+			info.Next = util.ValueOf(next)
+		}
+	}
+	cj.mu.Unlock()
+	cj.state.Fill(&info)
+	return info
+}
@@ -23,6 +23,7 @@ import (
 	"gorm.io/gorm/clause"
 	glogger "gorm.io/gorm/logger"
 	"gorm.io/hints"
+	"gorm.io/plugin/dbresolver"
 )
 
 // references:
@@ -38,6 +39,9 @@ var (
 	ErrNotSetSlice         = errors.New("slice cannot set")
 	ErrIDRequired          = errors.New("id is required")
 	ErrManualRollback      = errors.New("manual rollback requested")
+	ErrVersionConflict     = errors.New("version conflict, the record has been modified by others")
+	ErrTenantRequired      = errors.New("tenant id is required for tenant scoped model")
+	ErrCrossTenant         = errors.New("record belongs to another tenant")
 )
 
 var (
@@ -265,7 +269,8 @@ func (db *database[M]) WithAnd(flag ...bool) types.Database[M] {
 
 // WithOr sets the query condition combination mode to OR.
 // This method must be called before WithQuery to take effect.
-// All query conditions will be combined using OR logic.
+// The query fields of WithQuery will be combined using OR logic, the group is
+// combined with the filter and raw query using AND logic.
 func (db *database[M]) WithOr(flag ...bool) types.Database[M] {
 	db.mu.Lock()
 	defer db.mu.Unlock()
@@ -352,6 +357,10 @@ func (db *database[M]) WithQuery(query M, config ...types.QueryConfig) types.Dat
 	if len(cfg.RawQuery) > 0 {
 		db.ins = db.ins.Where(cfg.RawQuery, cfg.RawQueryArgs...)
 	}
+	hasFilter := !cfg.Filter.IsEmpty()
+	if hasFilter {
+		db.applyFilter(cfg.Filter)
+	}
 
 	typ := reflect.TypeOf(query).Elem()
 	val := reflect.ValueOf(query).Elem()
@@ -483,6 +492,10 @@ func (db *database[M]) WithQuery(query M, config ...types.QueryConfig) types.Dat
 	// By default, empty queries are blocked by adding "WHERE 1 = 0" condition.
 	// To allow empty queries, use: WithQuery(&User{}, QueryConfig{AllowEmpty: true})
 	if len(q) == 0 {
+		// The filter conditions are not empty, no need to add safety condition.
+		if hasFilter {
+			return db
+		}
 		if !cfg.AllowEmpty {
 			logger.Database.WithDatabaseContext(db.ctx, consts.Phase("WithQuery")).Warn("all query fields are empty, adding safety condition to prevent matching all records")
 			db.ins = db.ins.Where("1 = 0")
@@ -493,6 +506,9 @@ func (db *database[M]) WithQuery(query M, config ...types.QueryConfig) types.Dat
 		return db
 	}
 
+	// The ORed query fields are grouped, so they never skip the other conditions,
+	// eg: cfg.Filter, cfg.RawQuery and the tenant condition.
+	ors := make([]clause.Expression, 0)
 	if cfg.FuzzyMatch {
 		// // Deprecated!
 		// for k, v := range q {
@@ -522,14 +538,14 @@ func (db *database[M]) WithQuery(query M, config ...types.QueryConfig) types.Dat
 				regexpVal = strings.TrimPrefix(regexpVal, "|")
 				// db.db = db.db.Where(fmt.Sprintf("`%s` REGEXP ?", k), regexpVal)
 				if db.orQuery {
-					db.ins = db.ins.Or(fmt.Sprintf("`%s` REGEXP ?", k), regexpVal)
+					ors = append(ors, clause.Expr{SQL: fmt.Sprintf("`%s` REGEXP ?", k), Vars: []any{regexpVal}})
 				} else {
 					db.ins = db.ins.Where(fmt.Sprintf("`%s` REGEXP ?", k), regexpVal)
 				}
 			} else { // If the query string has only one value, using LIKE
 				// db.db = db.db.Where(fmt.Sprintf("`%s` LIKE ?", k), fmt.Sprintf("%%%v%%", v))
 				if db.orQuery {
-					db.ins = db.ins.Or(fmt.Sprintf("`%s` LIKE ?", k), fmt.Sprintf("%%%v%%", v))
+					ors = append(ors, clause.Expr{SQL: fmt.Sprintf("`%s` LIKE ?", k), Vars: []any{fmt.Sprintf("%%%v%%", v)}})
 				} else {
 					db.ins = db.ins.Where(fmt.Sprintf("`%s` LIKE ?", k), fmt.Sprintf("%%%v%%", v))
 				}
@@ -538,7 +554,7 @@ func (db *database[M]) WithQuery(query M, config ...types.QueryConfig) types.Dat
 		// CRITICAL: Check if all query values are empty after filtering
 		// Even if query map is not empty, all values might be empty strings
 		// Example: &User{Name: "", Email: ""} has fields but all values are empty
-		if !hasValidCondition {
+		if !hasValidCondition && !hasFilter {
 			if !cfg.AllowEmpty {
 				logger.Database.WithDatabaseContext(db.ctx, consts.Phase("WithQuery")).Warn("all query values are empty, adding safety condition to prevent matching all records")
 				db.ins = db.ins.Where("1 = 0")
@@ -564,7 +580,7 @@ func (db *database[M]) WithQuery(query M, config ...types.QueryConfig) types.Dat
 			hasValidCondition = true
 			// db.db = db.db.Where(fmt.Sprintf("`%s` IN (?)", k), items)
 			if db.orQuery {
-				db.ins = db.ins.Or(fmt.Sprintf("`%s` IN (?)", k), items)
+				ors = append(ors, clause.Expr{SQL: fmt.Sprintf("`%s` IN (?)", k), Vars: []any{items}})
 			} else {
 				db.ins = db.ins.Where(fmt.Sprintf("`%s` IN (?)", k), items)
 			}
@@ -572,7 +588,7 @@ func (db *database[M]) WithQuery(query M, config ...types.QueryConfig) types.Dat
 		// CRITICAL: Check if all query values are empty after filtering
 		// Even if query map is not empty, all values might be empty strings
 		// Example: &User{Name: "", Email: ""} has fields but all values are empty
-		if !hasValidCondition {
+		if !hasValidCondition && !hasFilter {
 			if !cfg.AllowEmpty {
 				logger.Database.WithDatabaseContext(db.ctx, consts.Phase("WithQuery")).Warn("all query values are empty, adding safety condition to prevent matching all records")
 				db.ins = db.ins.Where("1 = 0")
@@ -581,6 +597,9 @@ func (db *database[M]) WithQuery(query M, config ...types.QueryConfig) types.Dat
 			}
 		}
 	}
+	if len(ors) > 0 {
+		db.ins = db.ins.Where(clause.And(clause.Or(ors...)))
+	}
 	return db
 }
 
@@ -751,6 +770,11 @@ func (db *database[M]) WithCursor(cursorValue string, next bool, fields ...strin
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
+	// TODO: support multiple cursor fields
+	// The cursor field is kept even if cursorValue is empty, Iterate starts from the beginning with it.
+	if len(fields) > 0 {
+		db.cursorField = fields[0]
+	}
 	if len(cursorValue) == 0 {
 		return db
 	}
@@ -758,11 +782,6 @@ func (db *database[M]) WithCursor(cursorValue string, next bool, fields ...strin
 	db.enableCursor = true
 	db.cursorValue = cursorValue
 	db.cursorNext = next
-
-	// TODO: support multiple cursor fields
-	if len(fields) > 0 {
-		db.cursorField = fields[0]
-	}
 	// Default cursor field is "id" if not specified
 	if db.cursorField == "" {
 		db.cursorField = "id"
@@ -1548,6 +1567,20 @@ func (db *database[M]) WithoutHook() types.Database[M] {
 	return db
 }
 
+// WithPrimary forces the reads to the primary database instead of the read replicas.
+// Use it when the reads must see the latest writes, eg: read after write.
+// It's a no-op if the database has no read replicas, see UseReplicas.
+//
+// Example:
+//
+//	WithPrimary().Get(&user, id)  // Get the user just updated
+func (db *database[M]) WithPrimary() types.Database[M] {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.ins = db.ins.Clauses(dbresolver.Write)
+	return db
+}
+
 // Create inserts one or multiple records into the database.
 // Automatically sets ID (if empty), created_at, and updated_at timestamps.
 // Executes CreateBefore and CreateAfter model hooks unless disabled with WithoutHook.
@@ -1608,6 +1641,9 @@ func (db *database[M]) Create(objs ...M) (err error) {
 	for i := range objs {
 		if !reflect.DeepEqual(empty, objs[i]) {
 			objs[i].SetID() // set id when id is empty.
+			if v, ok := any(objs[i]).(types.Versioned); ok && v.GetVersion() == 0 {
+				v.SetVersion(1)
+			}
 		}
 	}
 
@@ -1630,11 +1666,16 @@ func (db *database[M]) Create(objs ...M) (err error) {
 		objs[i].SetCreatedAt(now)
 		objs[i].SetUpdatedAt(now)
 	}
-	for i := 0; i < len(objs); i += batchSize {
-		end := min(i+batchSize, len(objs))
-		if err = db.ins.Session(&gorm.Session{DryRun: db.tryRun}).Table(tableName).Save(objs[i:end]).Error; err != nil {
-			return err
+	if err = db.withEvents(events(consts.PHASE_CREATE, tableName, objs), func(ins *gorm.DB) error {
+		for i := 0; i < len(objs); i += batchSize {
+			end := min(i+batchSize, len(objs))
+			if err := ins.Session(&gorm.Session{DryRun: db.tryRun}).Table(tableName).Save(objs[i:end]).Error; err != nil {
+				return err
+			}
 		}
+		return nil
+	}); err != nil {
+		return err
 	}
 	if db.enableCache {
 		for i := range objs {
@@ -1751,47 +1792,52 @@ func (db *database[M]) Delete(objs ...M) (err error) {
 	if len(db.tableName) > 0 {
 		tableName = db.tableName
 	}
-	if util.Deref(db.enablePurge) {
-		// delete permanently.
-		// if err = db.db.Unscoped().Delete(objs).Error; err != nil {
-		// if err = db.db.Table(db.tableName).Unscoped().Delete(objs).Error; err != nil {
-		// 	return err
-		// }
-		//
-		batchSize := defaultDeleteBatchSize
-		if db.batchSize > 0 {
-			batchSize = db.batchSize
-		}
-		for i := 0; i < len(objs); i += batchSize {
-			end := min(i+batchSize, len(objs))
-			if err = db.ins.Session(&gorm.Session{DryRun: db.tryRun}).Table(tableName).Unscoped().Delete(objs[i:end]).Error; err != nil {
-				return err
+	if err = db.withEvents(events(consts.PHASE_DELETE, tableName, objs), func(ins *gorm.DB) error {
+		if util.Deref(db.enablePurge) {
+			// delete permanently.
+			// if err = db.db.Unscoped().Delete(objs).Error; err != nil {
+			// if err = db.db.Table(db.tableName).Unscoped().Delete(objs).Error; err != nil {
+			// 	return err
+			// }
+			//
+			batchSize := defaultDeleteBatchSize
+			if db.batchSize > 0 {
+				batchSize = db.batchSize
 			}
-			if db.enableCache {
-				_ = cache.Cache[M]().WithContext(ctx).Delete(objs[i].GetID())
+			for i := 0; i < len(objs); i += batchSize {
+				end := min(i+batchSize, len(objs))
+				if err := ins.Session(&gorm.Session{DryRun: db.tryRun}).Table(tableName).Unscoped().Delete(objs[i:end]).Error; err != nil {
+					return err
+				}
+				if db.enableCache {
+					_ = cache.Cache[M]().WithContext(ctx).Delete(objs[i].GetID())
+				}
 			}
-		}
-	} else {
-		// Delete() method just update field "delete_at" to currrent time.
-		// DO NOT FORGET update the "created_at" field when create/update if record already exists.
-		// if err = db.db.Delete(objs).Error; err != nil {
-		// if err = db.db.Table(db.tableName).Delete(objs).Error; err != nil {
-		// 	return err
-		// }
-		//
-		batchSize := defaultDeleteBatchSize
-		if db.batchSize > 0 {
-			batchSize = db.batchSize
-		}
-		for i := 0; i < len(objs); i += batchSize {
-			end := min(i+batchSize, len(objs))
-			if err = db.ins.Session(&gorm.Session{DryRun: db.tryRun}).Table(tableName).Delete(objs[i:end]).Error; err != nil {
-				return err
+		} else {
+			// Delete() method just update field "delete_at" to currrent time.
+			// DO NOT FORGET update the "created_at" field when create/update if record already exists.
+			// if err = db.db.Delete(objs).Error; err != nil {
+			// if err = db.db.Table(db.tableName).Delete(objs).Error; err != nil {
+			// 	return err
+			// }
+			//
+			batchSize := defaultDeleteBatchSize
+			if db.batchSize > 0 {
+				batchSize = db.batchSize
 			}
-			if db.enableCache {
-				_ = cache.Cache[M]().WithContext(ctx).Delete(objs[i].GetID())
+			for i := 0; i < len(objs); i += batchSize {
+				end := min(i+batchSize, len(objs))
+				if err := ins.Session(&gorm.Session{DryRun: db.tryRun}).Table(tableName).Delete(objs[i:end]).Error; err != nil {
+					return err
+				}
+				if db.enableCache {
+					_ = cache.Cache[M]().WithContext(ctx).Delete(objs[i].GetID())
+				}
 			}
 		}
+		return nil
+	}); err != nil {
+		return err
 	}
 	// Invoke model hook: DeleteAfter.
 	if !db.noHook {
@@ -1824,6 +1870,8 @@ func (db *database[M]) Delete(objs ...M) (err error) {
 //   - Updates all fields of the model
 //   - Supports batch processing for performance
 //   - Clears related cache entries
+//   - Checks and increases the version of types.Versioned model, returns ErrVersionConflict
+//     if any record has been modified by others, no record is updated in this case
 //
 // Example:
 //
@@ -1887,21 +1935,31 @@ func (db *database[M]) Update(objs ...M) (err error) {
 	if len(db.tableName) > 0 {
 		tableName = db.tableName
 	}
-	batchSize := defaultBatchSize
-	if db.batchSize > 0 {
-		batchSize = db.batchSize
-	}
-	for i := 0; i < len(objs); i += batchSize {
-		end := min(i+batchSize, len(objs))
-		if err = db.ins.Session(&gorm.Session{DryRun: db.tryRun}).Table(tableName).Save(objs[i:end]).Error; err != nil {
-			zap.S().Error(err)
-			return err
+	// The versioned records are updated one by one within a transaction,
+	// each update checks and increases the version atomically.
+	if err = db.withEvents(events(consts.PHASE_UPDATE, tableName, objs), func(ins *gorm.DB) error {
+		if _, versioned := any(db.m).(types.Versioned); versioned && !db.tryRun {
+			return db.updateVersioned(ins, tableName, objs)
+		}
+		batchSize := defaultBatchSize
+		if db.batchSize > 0 {
+			batchSize = db.batchSize
 		}
-		if db.enableCache {
-			for j := range objs[i:end] {
-				_ = cache.Cache[M]().WithContext(ctx).Delete(objs[j].GetID())
+		for i := 0; i < len(objs); i += batchSize {
+			end := min(i+batchSize, len(objs))
+			if err := ins.Session(&gorm.Session{DryRun: db.tryRun}).Table(tableName).Save(objs[i:end]).Error; err != nil {
+				return err
 			}
 		}
+		return nil
+	}); err != nil {
+		zap.S().Error(err)
+		return err
+	}
+	if db.enableCache {
+		for i := range objs {
+			_ = cache.Cache[M]().WithContext(ctx).Delete(objs[i].GetID())
+		}
 	}
 	// Invoke model hook: UpdateAfter.
 	if !db.noHook {
@@ -1931,6 +1989,7 @@ func (db *database[M]) Update(objs ...M) (err error) {
 //   - val: The new value for the field
 //
 // Note: Does not invoke UpdateBefore/UpdateAfter hooks for performance reasons.
+// The version of types.Versioned model is increased without check.
 //
 // Example:
 //
@@ -1965,7 +2024,18 @@ func (db *database[M]) UpdateByID(id string, key string, val any) (err error) {
 	if len(db.tableName) > 0 {
 		tableName = db.tableName
 	}
-	if err = db.ins.Session(&gorm.Session{DryRun: db.tryRun}).Table(tableName).Model(*new(M)).Where("id = ?", id).Update(key, val).Error; err != nil {
+	if err = db.withRecordEvents(consts.PHASE_UPDATE, tableName, id, func(ins *gorm.DB) error {
+		tx := ins.Session(&gorm.Session{DryRun: db.tryRun}).Table(tableName).Model(*new(M)).Where("id = ?", id)
+		if _, versioned := any(db.m).(types.Versioned); versioned {
+			// Increase the version to make the concurrent updates with stale version fail.
+			column, err := db.versionColumn()
+			if err != nil {
+				return err
+			}
+			return tx.Updates(map[string]any{key: val, column: gorm.Expr("? + 1", clause.Column{Name: column})}).Error
+		}
+		return tx.Update(key, val).Error
+	}); err != nil {
 		return err
 	}
 	if db.enableCache {
@@ -2151,6 +2221,134 @@ QUERY:
 	return nil
 }
 
+// Iterate retrieves all records matching the query conditions batch by batch and
+// calls fn with each batch, so arbitrarily large tables can be processed in bounded memory.
+// The iteration stops when all records are consumed or fn returns an error.
+//
+// Records are fetched by keyset pagination on the cursor field:
+//   - The cursor field defaults to "id", WithCursor can change the cursor field and
+//     the starting cursor value and direction.
+//   - The batch size is set by WithBatchSize, default to 1000.
+//   - WithLimit limits the total number of records iterated, not the batch size.
+//   - Records are ordered by the cursor field only, WithOrder and WithPagination are ignored.
+//
+// The slice passed to fn is reused for the next batch, don't retain it after fn returns.
+// Executes ListAfter model hooks for each batch unless disabled with WithoutHook.
+//
+// Example:
+//
+//	err := database.Database[*model.User](nil).WithBatchSize(500).WithQuery(query).Iterate(func(users []*model.User) error {
+//		return encoder.Encode(users...)
+//	})
+func (db *database[M]) Iterate(fn func(batch []M) error) (err error) {
+	if err = db.prepare(); err != nil {
+		return err
+	}
+	defer db.reset()
+	done, _, span := db.trace("Iterate")
+	defer done(err)
+	if fn == nil {
+		return nil
+	}
+
+	tableName := db.m.GetTableName() //nolint:errcheck
+	if len(db.tableName) > 0 {
+		tableName = db.tableName
+	}
+	batchSize := defaultBatchSize
+	if db.batchSize > 0 {
+		batchSize = db.batchSize
+	}
+	field := db.cursorField
+	if len(field) == 0 {
+		field = "id"
+	}
+	next := true
+	var cursor any
+	if db.enableCursor {
+		cursor, next = db.cursorValue, db.cursorNext
+	}
+	remaining := -1 // no limit
+	if c, ok := db.ins.Statement.Clauses["LIMIT"]; ok {
+		if l, ok := c.Expression.(clause.Limit); ok && l.Limit != nil && *l.Limit > 0 {
+			remaining = *l.Limit
+		}
+	}
+
+	stmt := &gorm.Statement{DB: db.ins}
+	if err = stmt.Parse(db.m); err != nil {
+		return err
+	}
+	cursorField := stmt.Schema.LookUpField(field)
+	if cursorField == nil {
+		return errors.Newf("cursor field %q not found in %s", field, db.typ.Name())
+	}
+	// The clauses quote the column by the dialect.
+	column := clause.Column{Name: cursorField.DBName}
+	orderBy := clause.OrderByColumn{Column: column, Desc: !next}
+	after := func(v any) clause.Expression {
+		if next {
+			return clause.Gt{Column: column, Value: v}
+		}
+		return clause.Lt{Column: column, Value: v}
+	}
+
+	var empty M // call nil value M will cause panic.
+	// Records are ordered by cursor field only, remove the ORDER BY clause set by WithOrder.
+	// The statement is cloned by Table, so the original one is not affected.
+	base := db.ins.Session(&gorm.Session{}).Table(tableName)
+	delete(base.Statement.Clauses, "ORDER BY")
+	base = base.Session(&gorm.Session{})
+	batch := make([]M, 0, batchSize)
+	for remaining != 0 {
+		size := batchSize
+		if remaining > 0 {
+			size = min(size, remaining)
+		}
+		tx := base
+		if cursor != nil {
+			tx = tx.Clauses(clause.Where{Exprs: []clause.Expression{after(cursor)}})
+		}
+		batch = batch[:0]
+		if err = tx.Order(orderBy).Limit(size).Find(&batch).Error; err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		// Invoke model hook: ListAfter()
+		if !db.noHook {
+			if err = traceModelHook[M](db.ctx, consts.PHASE_LIST_AFTER, span, func(spanCtx context.Context) error {
+				for i := range batch {
+					if !reflect.DeepEqual(empty, batch[i]) {
+						if err = batch[i].ListAfter(types.NewModelContext(db.ctx, spanCtx)); err != nil {
+							return err
+						}
+					}
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+		// Read the cursor value before fn, because fn may modify the records.
+		var zero bool
+		if cursor, zero = cursorField.ValueOf(db.ctx.Context(), reflect.ValueOf(batch[len(batch)-1])); zero {
+			return errors.Newf("cursor field %q of the last record is empty", field)
+		}
+		if err = fn(batch); err != nil {
+			return err
+		}
+		if remaining > 0 {
+			remaining -= len(batch)
+		}
+		if len(batch) < size {
+			break
+		}
+	}
+	return nil
+}
+
 // // Find equal to WithQuery(condition).List()
 // // More detail see `List` document.
 // func (db *database[T]) Find(dest *[]T, query T) error {
@@ -2943,6 +3141,7 @@ func (db *database[M]) Cleanup() (err error) {
 //  1. Database connection test with SELECT 1 query
 //  2. Connection pool status and capacity validation
 //  3. Database ping test for response time measurement
+//  4. Read replicas ping test, see UseReplicas
 //
 // Returns database errors if any health check fails.
 //
@@ -3005,6 +3204,15 @@ func (db *database[M]) Health() error {
 		return fmt.Errorf("database ping failed: %w", err)
 	}
 
+	// 4.check database read replicas
+	if err := checkReplicas(sqlDB); err != nil {
+		logger.Database.WithDatabaseContext(db.ctx, consts.Phase("Health")).Errorz("database replica check failed",
+			zap.Error(err),
+			zap.String("cost", util.FormatDurationSmart(time.Since(begin))),
+		)
+		return err
+	}
+
 	logger.Database.WithDatabaseContext(db.ctx, consts.Phase("Health")).Infoz("database health check passed",
 		zap.Int("open_connections", stats.OpenConnections),
 		zap.Int("in_use_connections", stats.InUse),
@@ -3,6 +3,7 @@ package database_test
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -13,6 +14,9 @@ import (
 	"github.com/forbearing/gst/types"
 	"github.com/forbearing/gst/types/consts"
 	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
 )
 
 // TestUser test user model
@@ -50,6 +54,41 @@ type TestCategory struct {
 	model.Base
 }
 
+// TestAuthor test author model with has-many association
+type TestAuthor struct {
+	Name  string      `json:"name"`
+	Books []*TestBook `json:"books,omitempty" gorm:"foreignKey:AuthorID"`
+
+	model.Base
+}
+
+func (*TestAuthor) Expands() []string { return []string{"Books"} }
+
+// TestBook test book model
+type TestBook struct {
+	Title    string `json:"title"`
+	Pages    int    `json:"pages"`
+	AuthorID string `json:"author_id"`
+
+	model.Base
+}
+
+// TestArticle test model with optimistic concurrency control
+type TestArticle struct {
+	Title string `json:"title"`
+
+	model.Base
+	model.Versioned
+}
+
+// TestProject test model isolated by tenant
+type TestProject struct {
+	Name string `json:"name"`
+
+	model.Base
+	model.TenantScoped
+}
+
 // DatabaseTestSuite defines the test suite for database operations
 type DatabaseTestSuite struct {
 	suite.Suite
@@ -89,6 +128,10 @@ func init() {
 	model.Register[*TestUser]()
 	model.Register[*TestProduct]()
 	model.Register[*TestCategory]()
+	model.Register[*TestAuthor]()
+	model.Register[*TestBook]()
+	model.Register[*TestArticle]()
+	model.Register[*TestProject]()
 
 	if err := bootstrap.Bootstrap(); err != nil {
 		panic(err)
@@ -774,6 +817,208 @@ func (suite *DatabaseTestSuite) TestList() {
 	}
 }
 
+// TestIterate tests the Iterate method
+func (suite *DatabaseTestSuite) TestIterate() {
+	// Create test data
+	users := make([]*TestUser, 0, 7)
+	for i := range 7 {
+		users = append(users, &TestUser{Name: fmt.Sprintf("IterUser%d", i), Email: "iterate@example.com", Age: 77})
+	}
+	err := database.Database[*TestUser](nil).Create(users...)
+	suite.NoError(err)
+
+	// Test iterate all records in batches
+	query := &TestUser{Email: "iterate@example.com"}
+	var batches []int
+	seen := make(map[string]struct{})
+	err = database.Database[*TestUser](nil).WithQuery(query).WithBatchSize(3).WithOrder("name desc").Iterate(func(batch []*TestUser) error {
+		batches = append(batches, len(batch))
+		for _, u := range batch {
+			seen[u.ID] = struct{}{}
+		}
+		return nil
+	})
+	suite.NoError(err)
+	suite.Equal([]int{3, 3, 1}, batches)
+	suite.Len(seen, 7)
+
+	// Test iterate with total limit
+	var count int
+	err = database.Database[*TestUser](nil).WithQuery(query).WithBatchSize(3).WithLimit(5).Iterate(func(batch []*TestUser) error {
+		count += len(batch)
+		return nil
+	})
+	suite.NoError(err)
+	suite.Equal(5, count)
+
+	// Test stop iteration by returning error
+	stop := fmt.Errorf("stop")
+	count = 0
+	err = database.Database[*TestUser](nil).WithQuery(query).WithBatchSize(2).Iterate(func(batch []*TestUser) error {
+		count += len(batch)
+		return stop
+	})
+	suite.ErrorIs(err, stop)
+	suite.Equal(2, count)
+}
+
+// TestIterateDialect tests the Iterate quotes the cursor column by the dialect
+func (suite *DatabaseTestSuite) TestIterateDialect() {
+	pg, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost user=gst dbname=gst sslmode=disable"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	suite.Require().NoError(err)
+	var sqls []string
+	suite.Require().NoError(pg.Callback().Query().After("gorm:query").Register("test:capture", func(tx *gorm.DB) {
+		sqls = append(sqls, tx.Statement.SQL.String())
+	}))
+
+	err = database.Database[*TestUser](nil).WithDB(pg).WithCursor("cursor-id", true).Iterate(func([]*TestUser) error { return nil })
+	suite.NoError(err)
+	suite.Require().Len(sqls, 1)
+	suite.Contains(sqls[0], `"id" > $1`)
+	suite.Contains(sqls[0], `ORDER BY "id"`)
+	suite.NotContains(sqls[0], "`")
+
+	sqls = sqls[:0]
+	err = database.Database[*TestUser](nil).WithDB(pg).WithCursor("cursor-id", false, "created_at").Iterate(func([]*TestUser) error { return nil })
+	suite.NoError(err)
+	suite.Require().Len(sqls, 1)
+	suite.Contains(sqls[0], `"created_at" < $1`)
+	suite.Contains(sqls[0], `ORDER BY "created_at" DESC`)
+}
+
+// TestWithQueryFilter tests the WithQuery method with operator-aware Filter
+func (suite *DatabaseTestSuite) TestWithQueryFilter() {
+	users := []*TestUser{
+		{Name: "FilterUser1", Email: "filter@example.com", Age: 10},
+		{Name: "FilterUser2", Email: "filter@example.com", Age: 20, IsActive: true},
+		{Name: "FilterUser3", Email: "filter@example.com", Age: 30, IsActive: true},
+		{Name: "FilterUser4", Email: "filter@example.com", Age: 40},
+	}
+	suite.NoError(database.Database[*TestUser](nil).Create(users...))
+
+	list := func(f *types.Filter) ([]string, error) {
+		data := make([]*TestUser, 0)
+		err := database.Database[*TestUser](nil).
+			WithQuery(&TestUser{Email: "filter@example.com"}, types.QueryConfig{Filter: f}).
+			WithOrder("age").
+			List(&data)
+		names := make([]string, 0, len(data))
+		for _, u := range data {
+			names = append(names, u.Name)
+		}
+		return names, err
+	}
+
+	names, err := list(&types.Filter{Field: "age", Op: types.FilterGte, Values: []string{"20"}})
+	suite.NoError(err)
+	suite.Equal([]string{"FilterUser2", "FilterUser3", "FilterUser4"}, names)
+
+	names, err = list(&types.Filter{And: []*types.Filter{
+		{Field: "name", Op: types.FilterNe, Values: []string{"FilterUser3"}},
+		{Field: "Age", Op: types.FilterBetween, Values: []string{"15", "45"}},
+	}})
+	suite.NoError(err)
+	suite.Equal([]string{"FilterUser2", "FilterUser4"}, names)
+
+	// age < 15 OR (is_active AND name IN (...))
+	names, err = list(&types.Filter{Or: []*types.Filter{
+		{Field: "age", Op: types.FilterLt, Values: []string{"15"}},
+		{And: []*types.Filter{
+			{Field: "is_active", Op: types.FilterEq, Values: []string{"true"}},
+			{Field: "name", Op: types.FilterIn, Values: []string{"FilterUser3", "FilterUser4"}},
+		}},
+	}})
+	suite.NoError(err)
+	suite.Equal([]string{"FilterUser1", "FilterUser3"}, names)
+
+	names, err = list(&types.Filter{Field: "remark", Op: types.FilterNull, Values: []string{"true"}})
+	suite.NoError(err)
+	suite.Len(names, 4)
+
+	// Empty model query with filter only should not be blocked by the safety condition.
+	data := make([]*TestUser, 0)
+	suite.NoError(database.Database[*TestUser](nil).
+		WithQuery(&TestUser{}, types.QueryConfig{Filter: &types.Filter{Field: "name", Op: types.FilterLike, Values: []string{"FilterUser"}}}).
+		List(&data))
+	suite.Len(data, 4)
+
+	// Unknown field and invalid value are rejected.
+	_, err = list(&types.Filter{Field: "unknown", Op: types.FilterEq, Values: []string{"x"}})
+	suite.Error(err)
+	_, err = list(&types.Filter{Field: "age", Op: types.FilterGt, Values: []string{"abc"}})
+	suite.Error(err)
+
+	// Filter on has-many association.
+	authors := []*TestAuthor{{Name: "FilterAuthor1"}, {Name: "FilterAuthor2"}}
+	suite.NoError(database.Database[*TestAuthor](nil).Create(authors...))
+	books := []*TestBook{
+		{Title: "Short", Pages: 50, AuthorID: authors[0].ID},
+		{Title: "Long", Pages: 500, AuthorID: authors[1].ID},
+	}
+	suite.NoError(database.Database[*TestBook](nil).Create(books...))
+
+	result := make([]*TestAuthor, 0)
+	suite.NoError(database.Database[*TestAuthor](nil).
+		WithQuery(&TestAuthor{}, types.QueryConfig{Filter: &types.Filter{Field: "Books.pages", Op: types.FilterGte, Values: []string{"100"}}}).
+		List(&result))
+	suite.Require().Len(result, 1)
+	suite.Equal("FilterAuthor2", result[0].Name)
+
+	result = result[:0]
+	suite.NoError(database.Database[*TestAuthor](nil).
+		WithQuery(&TestAuthor{}, types.QueryConfig{Filter: &types.Filter{Field: "books.title", Op: types.FilterEq, Values: []string{"Short"}}}).
+		List(&result))
+	suite.Require().Len(result, 1)
+	suite.Equal("FilterAuthor1", result[0].Name)
+
+	_ = database.Database[*TestBook](nil).WithPurge().Delete(books...)
+	_ = database.Database[*TestAuthor](nil).WithPurge().Delete(authors...)
+}
+
+// TestUpdateVersioned tests the optimistic concurrency control of versioned model
+func (suite *DatabaseTestSuite) TestUpdateVersioned() {
+	article := &TestArticle{Title: "v1"}
+	suite.NoError(database.Database[*TestArticle](nil).Create(article))
+	suite.Equal(uint(1), article.Version)
+
+	// Two writers hold the same version, the later one fails.
+	first := &TestArticle{Title: "first", Base: model.Base{ID: article.ID}, Versioned: model.Versioned{Version: 1}}
+	second := &TestArticle{Title: "second", Base: model.Base{ID: article.ID}, Versioned: model.Versioned{Version: 1}}
+	suite.NoError(database.Database[*TestArticle](nil).Update(first))
+	suite.Equal(uint(2), first.Version)
+	err := database.Database[*TestArticle](nil).Update(second)
+	suite.ErrorIs(err, database.ErrVersionConflict)
+	suite.Equal(uint(1), second.Version)
+
+	stored := new(TestArticle)
+	suite.NoError(database.Database[*TestArticle](nil).Get(stored, article.ID))
+	suite.Equal("first", stored.Title)
+	suite.Equal(uint(2), stored.Version)
+
+	// The conflict rolls back the other records updated in the same call.
+	other := &TestArticle{Title: "other"}
+	suite.NoError(database.Database[*TestArticle](nil).Create(other))
+	other.Title = "other updated"
+	suite.ErrorIs(database.Database[*TestArticle](nil).Update(other, second), database.ErrVersionConflict)
+	suite.NoError(database.Database[*TestArticle](nil).Get(stored, other.ID))
+	suite.Equal("other", stored.Title)
+	suite.Equal(uint(1), other.Version)
+
+	// The zero version overwrites unconditionally and still increases the version.
+	blind := &TestArticle{Title: "blind", Base: model.Base{ID: article.ID}}
+	suite.NoError(database.Database[*TestArticle](nil).Update(blind))
+	suite.Equal(uint(3), blind.Version)
+	suite.ErrorIs(database.Database[*TestArticle](nil).Update(first), database.ErrVersionConflict)
+
+	suite.NoError(database.Database[*TestArticle](nil).UpdateByID(article.ID, "title", "by id"))
+	stored = new(TestArticle)
+	suite.NoError(database.Database[*TestArticle](nil).Get(stored, article.ID))
+	suite.Equal("by id", stored.Title)
+	suite.Equal(uint(4), stored.Version)
+
+	_ = database.Database[*TestArticle](nil).WithPurge().Delete(article, other)
+}
+
 // TestGet tests the Get method
 func (suite *DatabaseTestSuite) TestGet() {
 	db := suite.userDB
@@ -1390,6 +1635,136 @@ func (suite *DatabaseTestSuite) TestEmptyQueryConditionSafety() {
 	}
 }
 
+// TestReplicas tests the reads are routed to the replicas and the writes to the primary
+func (suite *DatabaseTestSuite) TestReplicas() {
+	dir := suite.T().TempDir()
+	primaryPath := filepath.Join(dir, "primary.db")
+	replicaPath := filepath.Join(dir, "replica.db")
+
+	primary, err := gorm.Open(sqlite.Open(primaryPath), &gorm.Config{})
+	suite.Require().NoError(err)
+	replica, err := gorm.Open(sqlite.Open(replicaPath), &gorm.Config{})
+	suite.Require().NoError(err)
+	for _, db := range []*gorm.DB{primary, replica} {
+		suite.Require().NoError(db.AutoMigrate(&TestUser{}))
+	}
+	suite.Require().NoError(primary.Create(&TestUser{Name: "primary", Base: model.Base{ID: "primary"}}).Error)
+	suite.Require().NoError(replica.Create(&TestUser{Name: "replica", Base: model.Base{ID: "replica"}}).Error)
+
+	suite.Require().NoError(database.UseReplicas(primary, map[string]gorm.Dialector{"replica": sqlite.Open(replicaPath)}))
+	orig := database.DB
+	database.DB = primary
+	defer func() { database.DB = orig }()
+
+	names := func(users []*TestUser) []string {
+		list := make([]string, 0, len(users))
+		for _, u := range users {
+			list = append(list, u.Name)
+		}
+		return list
+	}
+
+	// The reads are routed to the replica.
+	users := make([]*TestUser, 0)
+	suite.NoError(database.Database[*TestUser](nil).List(&users))
+	suite.Equal([]string{"replica"}, names(users))
+	user := new(TestUser)
+	suite.NoError(database.Database[*TestUser](nil).Get(user, "replica"))
+	suite.Equal("replica", user.Name)
+
+	// WithPrimary forces the reads to the primary.
+	users = make([]*TestUser, 0)
+	suite.NoError(database.Database[*TestUser](nil).WithPrimary().List(&users))
+	suite.Equal([]string{"primary"}, names(users))
+
+	// The writes and transactions are routed to the primary.
+	suite.NoError(database.Database[*TestUser](nil).Create(&TestUser{Name: "created", Base: model.Base{ID: "created"}}))
+	var count int64
+	suite.NoError(database.Database[*TestUser](nil).WithPrimary().Count(&count))
+	suite.Equal(int64(2), count)
+	suite.NoError(database.Database[*TestUser](nil).Count(&count))
+	suite.Equal(int64(1), count)
+	suite.NoError(database.Database[*TestUser](nil).TransactionFunc(func(tx any) error {
+		users = make([]*TestUser, 0)
+		return database.Database[*TestUser](nil).WithTx(tx).List(&users)
+	}))
+	suite.Len(users, 2)
+
+	// Health checks the replicas.
+	suite.NoError(database.Database[*TestUser](nil).Health())
+}
+
+// TestTenantIsolation tests the tenant scoped model never leaks across tenants
+func (suite *DatabaseTestSuite) TestTenantIsolation() {
+	ctxA := &types.DatabaseContext{TenantID: "tenant-a"}
+	ctxB := &types.DatabaseContext{TenantID: "tenant-b"}
+	defer func() {
+		_ = database.Database[*TestProject](nil).WithoutTenant().WithPurge().Delete(&TestProject{})
+	}()
+
+	a1 := &TestProject{Name: "a1"}
+	a2 := &TestProject{Name: "a2"}
+	b1 := &TestProject{Name: "b1"}
+	suite.Require().NoError(database.Database[*TestProject](ctxA).Create(a1, a2))
+	suite.Require().NoError(database.Database[*TestProject](ctxB).Create(b1))
+	suite.Equal("tenant-a", a1.TenantID)
+	suite.Equal("tenant-b", b1.TenantID)
+
+	names := func(ctx *types.DatabaseContext) []string {
+		projects := make([]*TestProject, 0)
+		suite.Require().NoError(database.Database[*TestProject](ctx).WithOrder("name").List(&projects))
+		list := make([]string, 0, len(projects))
+		for _, p := range projects {
+			list = append(list, p.Name)
+		}
+		return list
+	}
+
+	// List and Count only see the records of current tenant.
+	suite.Equal([]string{"a1", "a2"}, names(ctxA))
+	suite.Equal([]string{"b1"}, names(ctxB))
+	var count int64
+	suite.NoError(database.Database[*TestProject](ctxB).Count(&count))
+	suite.Equal(int64(1), count)
+
+	// The ORed conditions, eg: query parameter `_or=true`, can't skip the tenant condition.
+	projects := make([]*TestProject, 0)
+	suite.NoError(database.Database[*TestProject](ctxB).WithOr().WithQuery(&TestProject{Name: "a1", Base: model.Base{ID: b1.ID}}).List(&projects))
+	suite.Require().Len(projects, 1)
+	suite.Equal("b1", projects[0].Name)
+
+	// Get can't read the record of other tenant, even with cache.
+	project := new(TestProject)
+	suite.NoError(database.Database[*TestProject](ctxA).WithCache().Get(project, a1.ID))
+	suite.Equal("a1", project.Name)
+	project = new(TestProject)
+	suite.NoError(database.Database[*TestProject](ctxB).WithCache().Get(project, a1.ID))
+	suite.Empty(project.ID)
+
+	// Update can't overwrite the record of other tenant.
+	suite.ErrorIs(database.Database[*TestProject](ctxB).Update(&TestProject{Name: "hacked", Base: model.Base{ID: a1.ID}}), database.ErrCrossTenant)
+	suite.ErrorIs(database.Database[*TestProject](ctxB).Update(&TestProject{Name: "hacked", Base: model.Base{ID: b1.ID}, TenantScoped: model.TenantScoped{TenantID: "tenant-a"}}), database.ErrCrossTenant)
+	suite.ErrorIs(database.Database[*TestProject](ctxB).Create(&TestProject{Name: "hacked", Base: model.Base{ID: a2.ID}}), database.ErrCrossTenant)
+	suite.NoError(database.Database[*TestProject](ctxB).UpdateByID(a1.ID, "name", "hacked"))
+	suite.Equal([]string{"a1", "a2"}, names(ctxA))
+
+	// Batch update and delete only affect the records of current tenant.
+	a1.Name, a2.Name = "a1-updated", "a2-updated"
+	suite.NoError(database.Database[*TestProject](ctxA).Update(a1, a2))
+	suite.ErrorIs(database.Database[*TestProject](ctxB).Update(b1, a1), database.ErrCrossTenant)
+	suite.NoError(database.Database[*TestProject](ctxB).WithPurge().Delete(a1, a2))
+	suite.Equal([]string{"a1-updated", "a2-updated"}, names(ctxA))
+	suite.Equal([]string{"b1"}, names(ctxB))
+
+	// The operations without tenant fail.
+	suite.ErrorIs(database.Database[*TestProject](nil).List(&projects), database.ErrTenantRequired)
+	suite.ErrorIs(database.Database[*TestProject](nil).Create(&TestProject{Name: "orphan"}), database.ErrTenantRequired)
+
+	// WithoutTenant accesses across tenants.
+	suite.NoError(database.Database[*TestProject](nil).WithoutTenant().List(&projects))
+	suite.Len(projects, 3)
+}
+
 // BenchmarkQueryBuilder benchmarks query building methods
 func BenchmarkQueryBuilder(b *testing.B) {
 	db := database.Database[*TestUser](nil)
@@ -0,0 +1,303 @@
+package database
+
+import (
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+	"github.com/stoewer/go-strcase"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// applyFilter translates the filter into where conditions, the field names and
+// values are resolved by the model schema and always passed as query arguments,
+// so the filter from client is safe to apply.
+// The error is added to db.ins and returned by the finisher method, eg: List, Count.
+func (db *database[M]) applyFilter(f *types.Filter) {
+	// db.m is not initialized until the finisher method called.
+	m := reflect.New(reflect.TypeFor[M]().Elem()).Interface().(M) //nolint:errcheck
+	stmt := &gorm.Statement{DB: db.ins}
+	if err := stmt.Parse(m); err != nil {
+		_ = db.ins.AddError(errors.Wrap(err, "failed to parse model schema"))
+		return
+	}
+	b := &filterBuilder{tx: db.ins, expands: m.Expands()}
+	expr, err := b.build(stmt.Schema, f, true)
+	if err != nil {
+		_ = db.ins.AddError(err)
+		return
+	}
+	if expr != nil {
+		db.ins = db.ins.Where(expr)
+	}
+}
+
+type filterBuilder struct {
+	tx      *gorm.DB
+	expands []string
+}
+
+// build builds the expression of the filter node, the And and Or children
+// are combined with AND if both present: (and1 AND and2) AND (or1 OR or2).
+func (b *filterBuilder) build(sch *schema.Schema, f *types.Filter, top bool) (clause.Expression, error) {
+	if f == nil {
+		return nil, nil
+	}
+	if len(f.Field) > 0 {
+		return b.condition(sch, f.Field, f, top)
+	}
+
+	ands := make([]clause.Expression, 0, len(f.And))
+	for _, sub := range f.And {
+		expr, err := b.build(sch, sub, top)
+		if err != nil {
+			return nil, err
+		}
+		if expr != nil {
+			ands = append(ands, expr)
+		}
+	}
+	ors := make([]clause.Expression, 0, len(f.Or))
+	for _, sub := range f.Or {
+		expr, err := b.build(sch, sub, top)
+		if err != nil {
+			return nil, err
+		}
+		if expr != nil {
+			ors = append(ors, expr)
+		}
+	}
+	if len(ors) > 0 {
+		ands = append(ands, clause.Or(ors...))
+	}
+	switch len(ands) {
+	case 0:
+		return nil, nil
+	case 1:
+		return ands[0], nil
+	default:
+		return clause.And(ands...), nil
+	}
+}
+
+// condition builds the expression of a leaf node, path is the field name
+// optionally prefixed by association names, eg: "Profile.Address.city".
+func (b *filterBuilder) condition(sch *schema.Schema, path string, f *types.Filter, top bool) (clause.Expression, error) {
+	name, rest, nested := strings.Cut(path, ".")
+	if nested {
+		rel := lookupRelation(sch, name)
+		if rel == nil {
+			return nil, errors.Newf("unknown association %q of %s", name, sch.Name)
+		}
+		if top && !slices.ContainsFunc(b.expands, func(e string) bool {
+			first, _, _ := strings.Cut(e, ".")
+			return strings.EqualFold(first, rel.Name)
+		}) {
+			return nil, errors.Newf("association %q of %s is not expandable", rel.Name, sch.Name)
+		}
+		inner, err := b.condition(rel.FieldSchema, rest, f, false)
+		if err != nil {
+			return nil, err
+		}
+		return b.relation(rel, inner)
+	}
+
+	field := lookupFilterField(sch, name, f.Op == types.FilterNull)
+	if field == nil {
+		return nil, errors.Newf("unknown filter field %q of %s", name, sch.Name)
+	}
+	col := clause.Column{Table: clause.CurrentTable, Name: field.DBName}
+	values := make([]any, 0, len(f.Values))
+	if f.Op != types.FilterNull && f.Op != types.FilterLike {
+		for _, s := range f.Values {
+			v, err := convertFilterValue(field, s)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid value of filter field %q", name)
+			}
+			values = append(values, v)
+		}
+	}
+	// the values count has been checked by filter.Validate, here only guard against panic.
+	if len(values) == 0 && f.Op != types.FilterNull && f.Op != types.FilterLike {
+		return nil, errors.Newf("filter %s[%s] requires value", name, f.Op)
+	}
+
+	switch f.Op {
+	case types.FilterEq, "":
+		return clause.Eq{Column: col, Value: values[0]}, nil
+	case types.FilterNe:
+		return clause.Neq{Column: col, Value: values[0]}, nil
+	case types.FilterGt:
+		return clause.Gt{Column: col, Value: values[0]}, nil
+	case types.FilterGte:
+		return clause.Gte{Column: col, Value: values[0]}, nil
+	case types.FilterLt:
+		return clause.Lt{Column: col, Value: values[0]}, nil
+	case types.FilterLte:
+		return clause.Lte{Column: col, Value: values[0]}, nil
+	case types.FilterIn:
+		return clause.IN{Column: col, Values: values}, nil
+	case types.FilterNin:
+		return clause.Not(clause.IN{Column: col, Values: values}), nil
+	case types.FilterBetween:
+		if len(values) != 2 {
+			return nil, errors.Newf("filter %s[%s] requires exactly two values", name, f.Op)
+		}
+		return clause.Expr{SQL: "? BETWEEN ? AND ?", Vars: []any{col, values[0], values[1]}}, nil
+	case types.FilterLike:
+		if len(f.Values) == 0 {
+			return nil, errors.Newf("filter %s[%s] requires value", name, f.Op)
+		}
+		return clause.Like{Column: col, Value: "%" + f.Values[0] + "%"}, nil
+	case types.FilterNull:
+		isNull := true
+		if len(f.Values) > 0 && len(f.Values[0]) > 0 {
+			var err error
+			if isNull, err = strconv.ParseBool(f.Values[0]); err != nil {
+				return nil, errors.Wrapf(err, "invalid value of filter field %q", name)
+			}
+		}
+		if isNull {
+			return clause.Eq{Column: col, Value: nil}, nil
+		}
+		return clause.Neq{Column: col, Value: nil}, nil
+	default:
+		return nil, errors.Newf("unknown filter operator %q of field %q", f.Op, name)
+	}
+}
+
+// relation builds the sub query to filter the records by their associated records:
+//
+//	has one/has many: own.id IN (SELECT owner_id FROM assoc WHERE ...)
+//	belongs to:       own.assoc_id IN (SELECT id FROM assoc WHERE ...)
+//	many to many:     own.id IN (SELECT own_id FROM join WHERE assoc_id IN (SELECT id FROM assoc WHERE ...))
+func (b *filterBuilder) relation(rel *schema.Relationship, inner clause.Expression) (clause.Expression, error) {
+	var own, other *schema.Field
+	conds := []clause.Expression{inner}
+
+	switch rel.Type {
+	case schema.HasOne, schema.HasMany:
+		for _, ref := range rel.References {
+			switch {
+			case ref.OwnPrimaryKey:
+				if own != nil {
+					return nil, errors.Newf("composite key of association %q is not supported", rel.Name)
+				}
+				own, other = ref.PrimaryKey, ref.ForeignKey
+			case len(ref.PrimaryValue) > 0: // polymorphic type column
+				conds = append(conds, clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: ref.ForeignKey.DBName}, Value: ref.PrimaryValue})
+			}
+		}
+	case schema.BelongsTo:
+		for _, ref := range rel.References {
+			if !ref.OwnPrimaryKey && ref.PrimaryKey != nil {
+				if own != nil {
+					return nil, errors.Newf("composite key of association %q is not supported", rel.Name)
+				}
+				own, other = ref.ForeignKey, ref.PrimaryKey
+			}
+		}
+	case schema.Many2Many:
+		var joinOwn, joinOther, assocKey *schema.Field
+		for _, ref := range rel.References {
+			if ref.OwnPrimaryKey {
+				if own != nil {
+					return nil, errors.Newf("composite key of association %q is not supported", rel.Name)
+				}
+				own, joinOwn = ref.PrimaryKey, ref.ForeignKey
+			} else {
+				if assocKey != nil {
+					return nil, errors.Newf("composite key of association %q is not supported", rel.Name)
+				}
+				assocKey, joinOther = ref.PrimaryKey, ref.ForeignKey
+			}
+		}
+		if own == nil || assocKey == nil || rel.JoinTable == nil {
+			return nil, errors.Newf("invalid many to many association %q", rel.Name)
+		}
+		assocQuery := b.subQuery(rel.FieldSchema).Select(assocKey.DBName).Where(clause.And(conds...))
+		joinQuery := b.tx.Session(&gorm.Session{NewDB: true}).Table(rel.JoinTable.Table).Select(joinOwn.DBName).
+			Where(clause.Expr{SQL: "? IN (?)", Vars: []any{clause.Column{Table: clause.CurrentTable, Name: joinOther.DBName}, assocQuery}})
+		return clause.Expr{SQL: "? IN (?)", Vars: []any{clause.Column{Table: clause.CurrentTable, Name: own.DBName}, joinQuery}}, nil
+	}
+	if own == nil || other == nil {
+		return nil, errors.Newf("unsupported association %q", rel.Name)
+	}
+
+	query := b.subQuery(rel.FieldSchema).Select(other.DBName).Where(clause.And(conds...))
+	return clause.Expr{SQL: "? IN (?)", Vars: []any{clause.Column{Table: clause.CurrentTable, Name: own.DBName}, query}}, nil
+}
+
+// subQuery creates a new query of the association model, the soft deleted records
+// of association are excluded by gorm query callbacks.
+func (b *filterBuilder) subQuery(sch *schema.Schema) *gorm.DB {
+	return b.tx.Session(&gorm.Session{NewDB: true}).Model(reflect.New(sch.ModelType).Interface())
+}
+
+func lookupRelation(sch *schema.Schema, name string) *schema.Relationship {
+	if rel, ok := sch.Relationships.Relations[name]; ok {
+		return rel
+	}
+	for _, rel := range sch.Relationships.Relations {
+		jsonName, _, _ := strings.Cut(rel.Field.Tag.Get(consts.TAG_JSON), ",")
+		if strings.EqualFold(rel.Name, name) || (len(jsonName) > 0 && jsonName == name) {
+			return rel
+		}
+	}
+	return nil
+}
+
+// lookupFilterField finds the field by column name, json name or structure field name.
+// The fields not stored in database are not filterable, the fields hidden from json,
+// eg: password, deleted_at, are only filterable when hidden is true.
+func lookupFilterField(sch *schema.Schema, name string, hidden bool) *schema.Field {
+	field := sch.LookUpField(name)
+	if field == nil {
+		for _, f := range sch.Fields {
+			if jsonName, _, _ := strings.Cut(f.Tag.Get(consts.TAG_JSON), ","); jsonName == name {
+				field = f
+				break
+			}
+		}
+	}
+	if field == nil {
+		field = sch.LookUpField(strcase.SnakeCase(name))
+	}
+	if field == nil || len(field.DBName) == 0 {
+		return nil
+	}
+	if jsonName, _, _ := strings.Cut(field.Tag.Get(consts.TAG_JSON), ","); jsonName == "-" && !hidden {
+		return nil
+	}
+	return field
+}
+
+// convertFilterValue converts the string value to the field data type.
+func convertFilterValue(field *schema.Field, s string) (any, error) {
+	switch field.DataType {
+	case schema.Bool:
+		return strconv.ParseBool(s)
+	case schema.Int:
+		return strconv.ParseInt(s, 10, 64)
+	case schema.Uint:
+		return strconv.ParseUint(s, 10, 64)
+	case schema.Float:
+		return strconv.ParseFloat(s, 64)
+	case schema.Time:
+		for _, layout := range []string{consts.DATE_TIME_LAYOUT, time.RFC3339Nano, time.DateOnly} {
+			if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+				return t, nil
+			}
+		}
+		return nil, errors.Newf("invalid time %q", s)
+	default:
+		return s, nil
+	}
+}
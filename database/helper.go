@@ -197,6 +197,12 @@ func buildCacheKey(stmt *gorm.Statement, action string, id ...string) (prefix, t
 	default:
 		key = strings.Join([]string{config.App.Redis.Namespace, stmt.Table, action, stmt.SQL.String()}, ":")
 	}
+	// The records of tenant scoped model are cached per tenant.
+	if stmt.Schema != nil && isTenantScoped(stmt.Schema.ModelType) {
+		if val, exists := stmt.Settings.Load(withoutTenantKey); !exists || val != true {
+			key = key + ":tenant:" + tenantFromContext(stmt.Context)
+		}
+	}
 	return prefix, table, key
 }
 
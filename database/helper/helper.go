@@ -2,12 +2,16 @@ package helper
 
 import (
 	"fmt"
+	"net"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/config"
 	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/database/migrate"
 	"github.com/forbearing/gst/model"
 	"github.com/forbearing/gst/util"
 	"github.com/uptrace/opentelemetry-go-extra/otelgorm"
@@ -53,6 +57,15 @@ func InitDatabase(db *gorm.DB, dbmap map[string]*gorm.DB) (err error) {
 	}
 	zap.S().Infow("database create table", "cost", util.FormatDurationSmart(time.Since(begin)))
 
+	// apply the versioned migrations after AutoMigrate, so they always see the latest tables.
+	if config.App.Database.Migrate {
+		begin = time.Now()
+		if err = migrate.Up(db, dbmap); err != nil {
+			return errors.Wrap(err, "failed to apply migrations")
+		}
+		zap.S().Infow("database apply migrations", "cost", util.FormatDurationSmart(time.Since(begin)))
+	}
+
 	begin = time.Now()
 	// create the table records that must be pre-exists before database curds.
 	for _, r := range model.Records {
@@ -66,12 +79,39 @@ func InitDatabase(db *gorm.DB, dbmap map[string]*gorm.DB) (err error) {
 	}
 	zap.S().Infow("database create table records", "cost", util.FormatDurationSmart(time.Since(begin)))
 
+	// isolate the tenant scoped models after the tables and records created.
+	if err = db.Use(database.TenantPlugin()); err != nil {
+		return errors.Wrap(err, "failed to register tenant plugin")
+	}
+	for _, customDB := range dbmap {
+		if err = customDB.Use(database.TenantPlugin()); err != nil {
+			return errors.Wrap(err, "failed to register tenant plugin for custom DB")
+		}
+	}
+
 	// set default database to 'Default'.
 	database.DB = db
 
 	return nil
 }
 
+// ReplicaAddr parses the replica address "host:port", the port defaults to
+// the primary port if not specified.
+func ReplicaAddr(addr string, port uint) (string, uint, error) {
+	if !strings.Contains(addr, ":") {
+		return addr, port, nil
+	}
+	host, p, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", 0, errors.Wrapf(err, "invalid replica address %q", addr)
+	}
+	_port, err := strconv.ParseUint(p, 10, 16)
+	if err != nil {
+		return "", 0, errors.Wrapf(err, "invalid replica port %q", addr)
+	}
+	return host, uint(_port), nil
+}
+
 // Transaction start a transaction as a block, return error will rollback, otherwise to commit.
 // Transaction executes an arbitrary number of commands in fc within a transaction.
 // On success the changes are committed; if an error occurs they are rolled back.
@@ -0,0 +1,110 @@
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/util"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var (
+	// LockTimeout is the max duration to wait for the migration lock held by other replicas.
+	LockTimeout = 10 * time.Minute
+	// LockExpiration is the duration after which a lock not refreshed is considered
+	// abandoned by a crashed replica and may be taken over.
+	LockExpiration = 1 * time.Minute
+
+	lockPollInterval = 1 * time.Second
+)
+
+// schemaMigrationLock is the single row lock in table "schema_migrations_lock".
+// The primary key guarantees only one replica inserts the row, which works with
+// all the databases without relying on advisory locks.
+type schemaMigrationLock struct {
+	ID       int    `gorm:"primaryKey;autoIncrement:false"`
+	Owner    string `gorm:"size:128"`
+	LockedAt time.Time
+}
+
+func (schemaMigrationLock) TableName() string { return "schema_migrations_lock" }
+
+const lockID = 1
+
+// withLock runs fn while holding the migration lock of the database.
+// The lock is refreshed periodically until fn returns.
+func withLock(db *gorm.DB, fn func() error) error {
+	if err := db.AutoMigrate(&schemaMigrationLock{}); err != nil {
+		return errors.Wrap(err, "failed to create table schema_migrations_lock")
+	}
+	hostname, _ := os.Hostname()
+	owner := fmt.Sprintf("%s:%d:%s", hostname, os.Getpid(), util.UUID())
+
+	deadline := time.Now().Add(LockTimeout)
+	for {
+		acquired, err := tryLock(db, owner)
+		if err != nil {
+			return err
+		}
+		if acquired {
+			break
+		}
+		if time.Now().After(deadline) {
+			return errors.Newf("timeout waiting for migration lock after %s", LockTimeout)
+		}
+		time.Sleep(lockPollInterval)
+	}
+	defer func() {
+		if err := db.Where("id = ? AND owner = ?", lockID, owner).Delete(&schemaMigrationLock{}).Error; err != nil {
+			zap.S().Errorw("failed to release migration lock", "owner", owner, "error", err)
+		}
+	}()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(LockExpiration / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := db.Model(&schemaMigrationLock{}).Where("id = ? AND owner = ?", lockID, owner).Update("locked_at", time.Now()).Error; err != nil {
+					zap.S().Warnw("failed to refresh migration lock", "owner", owner, "error", err)
+				}
+			}
+		}
+	}()
+
+	return fn()
+}
+
+// tryLock inserts the lock row, the expired lock is taken over.
+func tryLock(db *gorm.DB, owner string) (bool, error) {
+	// The duplicate key error is expected when the lock is held, don't log it.
+	silent := db.Session(&gorm.Session{Logger: db.Logger.LogMode(logger.Silent)})
+	if err := silent.Create(&schemaMigrationLock{ID: lockID, Owner: owner, LockedAt: time.Now()}).Error; err == nil {
+		return true, nil
+	}
+	current := new(schemaMigrationLock)
+	if err := db.Where("id = ?", lockID).Limit(1).Find(current).Error; err != nil {
+		return false, errors.Wrap(err, "failed to get migration lock")
+	}
+	if current.ID == 0 {
+		// The lock was just released, try again.
+		return false, nil
+	}
+	if time.Since(current.LockedAt) > LockExpiration {
+		zap.S().Warnw("take over the expired migration lock", "owner", current.Owner, "locked_at", current.LockedAt)
+		res := db.Where("id = ? AND owner = ? AND locked_at = ?", lockID, current.Owner, current.LockedAt).Delete(&schemaMigrationLock{})
+		if res.Error != nil {
+			return false, errors.Wrap(res.Error, "failed to remove expired migration lock")
+		}
+	}
+	return false, nil
+}
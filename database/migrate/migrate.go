@@ -0,0 +1,390 @@
+// Package migrate provides versioned schema migrations that run alongside GORM AutoMigrate.
+//
+// AutoMigrate only creates tables and adds columns, it cannot rename or drop columns,
+// backfill data, or be reviewed and rolled back. The migrations fill the gap:
+//   - Go migrations are registered by Register/RegisterTo with up and down functions.
+//   - SQL migrations are files named "<version>_<name>.up.sql" and "<version>_<name>.down.sql",
+//     registered by RegisterFS/RegisterFSTo, usually from an embed.FS.
+//
+// The applied versions are tracked in table "schema_migrations" of every database, and
+// a lock row in table "schema_migrations_lock" makes sure only one replica migrates at a time.
+//
+// The pending migrations are applied by database/helper.InitDatabase after AutoMigrate
+// when config "database.migrate" is enabled, so the migrations always see the latest tables.
+// Use "gg migrate new|up|down|status" to create, apply, roll back and inspect migrations.
+package migrate
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Migration is one versioned schema change.
+type Migration struct {
+	// Version orders the migrations, it's usually a timestamp like 20060102150405.
+	Version int64
+	// Name describes the migration, eg: add_user_email.
+	Name string
+	// DBName is the database the migration applies to, empty means the default database.
+	DBName string
+
+	// Up and Down are the Go migration functions, they are called within a transaction.
+	Up   func(tx *gorm.DB) error
+	Down func(tx *gorm.DB) error
+
+	// UpSQL and DownSQL are the statements of SQL migration, executed one by one within a transaction.
+	UpSQL   string
+	DownSQL string
+}
+
+func (m *Migration) String() string { return fmt.Sprintf("%d_%s", m.Version, m.Name) }
+
+func (m *Migration) hasDown() bool { return m.Down != nil || len(strings.TrimSpace(m.DownSQL)) > 0 }
+
+func (m *Migration) up(tx *gorm.DB) error {
+	if m.Up != nil {
+		return m.Up(tx)
+	}
+	return execSQL(tx, m.UpSQL)
+}
+
+func (m *Migration) down(tx *gorm.DB) error {
+	if m.Down != nil {
+		return m.Down(tx)
+	}
+	return execSQL(tx, m.DownSQL)
+}
+
+// SchemaMigration is the record of an applied migration, stored in table "schema_migrations".
+type SchemaMigration struct {
+	Version   int64     `json:"version" gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `json:"name" gorm:"size:255"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+func (SchemaMigration) TableName() string { return "schema_migrations" }
+
+// MigrationStatus is the state of a migration in its database.
+type MigrationStatus struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	DBName    string     `json:"db_name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	// Missing reports the migration was applied but is no longer registered.
+	Missing bool `json:"missing"`
+}
+
+var (
+	mu         sync.Mutex
+	migrations []*Migration
+
+	fileRegexp = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+)
+
+// Register registers a Go migration on the default database.
+// down may be nil if the migration cannot be rolled back.
+//
+// Example:
+//
+//	func init() {
+//		migrate.Register(20250101120000, "rename_user_nickname",
+//			func(tx *gorm.DB) error { return tx.Migrator().RenameColumn("users", "nick", "nickname") },
+//			func(tx *gorm.DB) error { return tx.Migrator().RenameColumn("users", "nickname", "nick") },
+//		)
+//	}
+//
+// NOTE: Always call this function in init(), it panics if the version is already registered.
+func Register(version int64, name string, up, down func(tx *gorm.DB) error) {
+	RegisterTo("", version, name, up, down)
+}
+
+// RegisterTo works identically to Register(), but registers the migration on the specified database instance.
+func RegisterTo(dbname string, version int64, name string, up, down func(tx *gorm.DB) error) {
+	if up == nil {
+		panic(fmt.Sprintf("migrate: migration %d_%s has no up function", version, name))
+	}
+	add(&Migration{Version: version, Name: name, DBName: strings.ToLower(dbname), Up: up, Down: down})
+}
+
+// RegisterFS registers the SQL migrations in the directory dir of fsys on the default database.
+// The files are named "<version>_<name>.up.sql" and "<version>_<name>.down.sql", other files are ignored.
+//
+// Example:
+//
+//	//go:embed *.sql
+//	var files embed.FS
+//
+//	func init() { migrate.RegisterFS(files, ".") }
+//
+// NOTE: Always call this function in init(), it panics if the files are invalid.
+func RegisterFS(fsys fs.FS, dir string) {
+	RegisterFSTo("", fsys, dir)
+}
+
+// RegisterFSTo works identically to RegisterFS(), but registers the migrations on the specified database instance.
+func RegisterFSTo(dbname string, fsys fs.FS, dir string) {
+	list, err := LoadFS(fsys, dir)
+	if err != nil {
+		panic(fmt.Sprintf("migrate: %v", err))
+	}
+	for _, m := range list {
+		m.DBName = strings.ToLower(dbname)
+		add(m)
+	}
+}
+
+// LoadFS parses the SQL migrations in the directory dir of fsys, ordered by version.
+func LoadFS(fsys fs.FS, dir string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read migration dir %q", dir)
+	}
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		matches := fileRegexp.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid migration version of %q", entry.Name())
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read migration %q", entry.Name())
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = m
+		} else if m.Name != matches[2] {
+			return nil, errors.Newf("migration version %d has different names %q and %q", version, m.Name, matches[2])
+		}
+		if matches[3] == "up" {
+			m.UpSQL = string(data)
+		} else {
+			m.DownSQL = string(data)
+		}
+	}
+	list := make([]*Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if len(strings.TrimSpace(m.UpSQL)) == 0 {
+			return nil, errors.Newf("migration %s has no up sql", m)
+		}
+		list = append(list, m)
+	}
+	sortMigrations(list)
+	return list, nil
+}
+
+func add(m *Migration) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, existing := range migrations {
+		if existing.Version == m.Version && existing.DBName == m.DBName {
+			panic(fmt.Sprintf("migrate: duplicate migration version %d: %s and %s", m.Version, existing, m))
+		}
+	}
+	migrations = append(migrations, m)
+}
+
+// Migrations returns all the registered migrations ordered by version.
+func Migrations() []*Migration {
+	mu.Lock()
+	defer mu.Unlock()
+	list := slices.Clone(migrations)
+	sortMigrations(list)
+	return list
+}
+
+func sortMigrations(list []*Migration) {
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+}
+
+// group is the migrations sharing the same database handler.
+type group struct {
+	name       string
+	db         *gorm.DB
+	migrations []*Migration
+}
+
+// groups resolves the database of every registered migration, the same as model.RegisterTo:
+// the migration falls back to the default database if its database not exists in dbmap.
+func groups(db *gorm.DB, dbmap map[string]*gorm.DB) []*group {
+	var list []*group
+	index := make(map[*gorm.DB]*group)
+	for _, m := range Migrations() {
+		name, handler := "", db
+		if val, exists := dbmap[m.DBName]; exists && len(m.DBName) > 0 {
+			name, handler = m.DBName, val
+		}
+		g, ok := index[handler]
+		if !ok {
+			g = &group{name: name, db: handler}
+			index[handler] = g
+			list = append(list, g)
+		}
+		g.migrations = append(g.migrations, m)
+	}
+	return list
+}
+
+// Up applies all the pending migrations of the default database and the databases in dbmap.
+func Up(db *gorm.DB, dbmap map[string]*gorm.DB) error {
+	for _, g := range groups(db, dbmap) {
+		if err := g.up(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *group) up() error {
+	return withLock(g.db, func() error {
+		applied, err := appliedVersions(g.db)
+		if err != nil {
+			return err
+		}
+		for _, m := range g.migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			begin := time.Now()
+			if err := g.db.Transaction(func(tx *gorm.DB) error {
+				if err := m.up(tx); err != nil {
+					return err
+				}
+				return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+			}); err != nil {
+				return errors.Wrapf(err, "failed to apply migration %s", m)
+			}
+			zap.S().Infow("migration applied", "database", g.name, "migration", m.String(), "cost", time.Since(begin).String())
+		}
+		return nil
+	})
+}
+
+// Down rolls back the latest steps applied migrations, across the default database and the
+// databases in dbmap, in the reverse order of version.
+func Down(db *gorm.DB, dbmap map[string]*gorm.DB, steps int) error {
+	if steps <= 0 {
+		return nil
+	}
+	type target struct {
+		g       *group
+		m       *Migration
+		missing bool
+	}
+	targets := make([]target, 0)
+	for _, g := range groups(db, dbmap) {
+		applied, err := appliedVersions(g.db)
+		if err != nil {
+			return err
+		}
+		registered := make(map[int64]*Migration, len(g.migrations))
+		for _, m := range g.migrations {
+			registered[m.Version] = m
+		}
+		for version, record := range applied {
+			if m, ok := registered[version]; ok {
+				targets = append(targets, target{g: g, m: m})
+			} else {
+				targets = append(targets, target{g: g, m: &Migration{Version: version, Name: record.Name}, missing: true})
+			}
+		}
+	}
+	sort.SliceStable(targets, func(i, j int) bool { return targets[i].m.Version > targets[j].m.Version })
+	if len(targets) > steps {
+		targets = targets[:steps]
+	}
+
+	for _, t := range targets {
+		if t.missing {
+			return errors.Newf("migration %s is applied but not registered", t.m)
+		}
+		if !t.m.hasDown() {
+			return errors.Newf("migration %s cannot be rolled back", t.m)
+		}
+		if err := withLock(t.g.db, func() error {
+			return t.g.db.Transaction(func(tx *gorm.DB) error {
+				res := tx.Where("version = ?", t.m.Version).Delete(&SchemaMigration{})
+				if res.Error != nil {
+					return res.Error
+				}
+				if res.RowsAffected == 0 {
+					// Another replica has rolled it back.
+					return nil
+				}
+				return t.m.down(tx)
+			})
+		}); err != nil {
+			return errors.Wrapf(err, "failed to roll back migration %s", t.m)
+		}
+		zap.S().Infow("migration rolled back", "database", t.g.name, "migration", t.m.String())
+	}
+	return nil
+}
+
+// Status returns the state of all the registered and applied migrations ordered by version.
+func Status(db *gorm.DB, dbmap map[string]*gorm.DB) ([]MigrationStatus, error) {
+	list := make([]MigrationStatus, 0)
+	for _, g := range groups(db, dbmap) {
+		applied, err := appliedVersions(g.db)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range g.migrations {
+			status := MigrationStatus{Version: m.Version, Name: m.Name, DBName: g.name}
+			if record, ok := applied[m.Version]; ok {
+				status.Applied = true
+				status.AppliedAt = &record.AppliedAt
+				delete(applied, m.Version)
+			}
+			list = append(list, status)
+		}
+		for _, record := range applied {
+			list = append(list, MigrationStatus{
+				Version:   record.Version,
+				Name:      record.Name,
+				DBName:    g.name,
+				Applied:   true,
+				AppliedAt: &record.AppliedAt,
+				Missing:   true,
+			})
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// appliedVersions creates the table "schema_migrations" if not exists and returns the applied migrations.
+func appliedVersions(db *gorm.DB) (map[int64]*SchemaMigration, error) {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, errors.Wrap(err, "failed to create table schema_migrations")
+	}
+	records := make([]*SchemaMigration, 0)
+	if err := db.Order("version").Find(&records).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to list applied migrations")
+	}
+	applied := make(map[int64]*SchemaMigration, len(records))
+	for _, r := range records {
+		applied[r.Version] = r
+	}
+	return applied, nil
+}
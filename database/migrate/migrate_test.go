@@ -0,0 +1,89 @@
+package migrate
+
+import (
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestSplitSQL(t *testing.T) {
+	stmts := splitSQL(`
+-- create table; with comment
+CREATE TABLE t (id INT, name TEXT DEFAULT 'a;b'); /* block; comment */
+INSERT INTO t VALUES (1, "x;y");
+`)
+	assert.Equal(t, []string{
+		"CREATE TABLE t (id INT, name TEXT DEFAULT 'a;b')",
+		`INSERT INTO t VALUES (1, "x;y")`,
+	}, stmts)
+}
+
+func TestMigrate(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	// Every connection of in-memory sqlite is a separate database.
+	sqlDB.SetMaxOpenConns(1)
+
+	RegisterFS(fstest.MapFS{
+		"1_create_users.up.sql":   {Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY, nick TEXT); INSERT INTO users VALUES (1, 'root');")},
+		"1_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+		"README.md":               {Data: []byte("ignored")},
+	}, ".")
+	Register(2, "rename_user_nick",
+		func(tx *gorm.DB) error { return tx.Migrator().RenameColumn("users", "nick", "nickname") },
+		func(tx *gorm.DB) error { return tx.Migrator().RenameColumn("users", "nickname", "nick") },
+	)
+	Register(3, "backfill_user_nickname",
+		func(tx *gorm.DB) error { return tx.Exec("UPDATE users SET nickname = 'admin' WHERE id = 1").Error },
+		nil,
+	)
+	assert.Panics(t, func() { Register(3, "duplicated", func(*gorm.DB) error { return nil }, nil) })
+
+	// The concurrent replicas apply the migrations only once.
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, Up(db, nil))
+		}()
+	}
+	wg.Wait()
+
+	var nickname string
+	require.NoError(t, db.Raw("SELECT nickname FROM users WHERE id = 1").Scan(&nickname).Error)
+	assert.Equal(t, "admin", nickname)
+
+	status, err := Status(db, nil)
+	require.NoError(t, err)
+	require.Len(t, status, 3)
+	for _, s := range status {
+		assert.True(t, s.Applied, s.Name)
+	}
+
+	// The migration 3 has no down function.
+	require.Error(t, Down(db, nil, 1))
+
+	require.NoError(t, db.Where("version = ?", 3).Delete(&SchemaMigration{}).Error)
+	require.NoError(t, Down(db, nil, 2))
+	assert.False(t, db.Migrator().HasTable("users"))
+
+	status, err = Status(db, nil)
+	require.NoError(t, err)
+	assert.False(t, status[0].Applied)
+	assert.False(t, status[1].Applied)
+	assert.False(t, status[2].Applied)
+	assert.False(t, db.Migrator().HasTable("users"))
+
+	var count int64
+	require.NoError(t, db.Model(&schemaMigrationLock{}).Count(&count).Error)
+	assert.Zero(t, count)
+}
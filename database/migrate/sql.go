@@ -0,0 +1,72 @@
+package migrate
+
+import (
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"gorm.io/gorm"
+)
+
+// execSQL executes the statements of the sql migration one by one,
+// not all the drivers support multiple statements in one Exec.
+func execSQL(tx *gorm.DB, sql string) error {
+	for _, stmt := range splitSQL(sql) {
+		if err := tx.Exec(stmt).Error; err != nil {
+			return errors.Wrapf(err, "failed to execute %q", stmt)
+		}
+	}
+	return nil
+}
+
+// splitSQL splits the sql script into statements by the semicolons,
+// the semicolons within quotes and comments are ignored.
+//
+// The statement containing semicolons itself, eg: a function body in postgres, should
+// be written in a Go migration instead.
+func splitSQL(sql string) []string {
+	var (
+		stmts []string
+		buf   strings.Builder
+		quote byte
+	)
+	flush := func() {
+		if stmt := strings.TrimSpace(buf.String()); len(stmt) > 0 {
+			stmts = append(stmts, stmt)
+		}
+		buf.Reset()
+	}
+	for i := 0; i < len(sql); i++ {
+		ch := sql[i]
+		switch {
+		case quote != 0:
+			buf.WriteByte(ch)
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote = ch
+			buf.WriteByte(ch)
+		case ch == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			// Skip the line comment.
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+			buf.WriteByte('\n')
+		case ch == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			// Skip the block comment.
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += end + 3
+			}
+			buf.WriteByte(' ')
+		case ch == ';':
+			flush()
+		default:
+			buf.WriteByte(ch)
+		}
+	}
+	flush()
+	return stmts
+}
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"reflect"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+	"gorm.io/gorm"
+)
+
+// ErrOutboxDisabled is returned if the model publishes events but no outbox registered.
+var ErrOutboxDisabled = errors.New("outbox is disabled, import package outbox to enable it")
+
+// eventWriter writes the events into the outbox within the transaction.
+var eventWriter func(tx *gorm.DB, events []types.Event) error
+
+// RegisterEventWriter registers the writer of the events returned by types.EventSource,
+// it's called by package outbox.
+func RegisterEventWriter(fn func(tx *gorm.DB, events []types.Event) error) {
+	eventWriter = fn
+}
+
+// events collects the events of the records implementing types.EventSource, the aggregate
+// defaults to the table name and the record id.
+func events[M types.Model](phase consts.Phase, tableName string, objs []M) []types.Event {
+	var empty M
+	var events []types.Event
+	for i := range objs {
+		if reflect.DeepEqual(empty, objs[i]) {
+			continue
+		}
+		src, ok := any(objs[i]).(types.EventSource)
+		if !ok {
+			continue
+		}
+		for _, e := range src.Events(phase) {
+			if len(e.AggregateType) == 0 {
+				e.AggregateType = tableName
+			}
+			if len(e.AggregateID) == 0 {
+				e.AggregateID = objs[i].GetID()
+			}
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// withEvents calls fn and writes the events within one transaction, fn is called with
+// db.ins directly if there is no event.
+func (db *database[M]) withEvents(events []types.Event, fn func(ins *gorm.DB) error) error {
+	if len(events) == 0 || db.tryRun {
+		return fn(db.ins)
+	}
+	if eventWriter == nil {
+		return ErrOutboxDisabled
+	}
+	return db.ins.Transaction(func(tx *gorm.DB) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return db.writeEvents(tx, events)
+	})
+}
+
+// withRecordEvents calls fn and writes the events of the record changed by fn within one
+// transaction, the record is reloaded after fn. It's used by the changes made by the record
+// id only, eg: UpdateByID.
+func (db *database[M]) withRecordEvents(phase consts.Phase, tableName, id string, fn func(ins *gorm.DB) error) error {
+	if _, ok := any(db.m).(types.EventSource); !ok || db.tryRun {
+		return fn(db.ins)
+	}
+	if eventWriter == nil {
+		return ErrOutboxDisabled
+	}
+	return db.ins.Transaction(func(tx *gorm.DB) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		m := reflect.New(reflect.TypeFor[M]().Elem()).Interface().(M) //nolint:errcheck
+		if err := tx.Session(&gorm.Session{NewDB: true}).Table(tableName).Where("id = ?", id).First(m).Error; err != nil {
+			// Nothing changed.
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return errors.Wrap(err, "failed to reload record")
+		}
+		return db.writeEvents(tx, events(phase, tableName, []M{m}))
+	})
+}
+
+// writeEvents writes the events within the transaction.
+func (db *database[M]) writeEvents(tx *gorm.DB, events []types.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	// The model without custom table name uses the table name derived by gorm.
+	for i := range events {
+		if len(events[i].AggregateType) == 0 {
+			stmt := &gorm.Statement{DB: db.ins}
+			if err := stmt.Parse(*new(M)); err != nil {
+				return errors.Wrap(err, "failed to parse model")
+			}
+			events[i].AggregateType = stmt.Schema.Table
+		}
+	}
+	// The new session drops the conditions of the record change.
+	return eventWriter(tx.Session(&gorm.Session{NewDB: true}), events)
+}
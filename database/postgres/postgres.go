@@ -6,6 +6,7 @@ import (
 
 	"github.com/cockroachdb/errors"
 	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/database"
 	"github.com/forbearing/gst/database/helper"
 	"github.com/forbearing/gst/logger"
 	"go.uber.org/zap"
@@ -40,7 +41,23 @@ func Init() (err error) {
 	db.SetConnMaxIdleTime(config.App.Database.ConnMaxIdleTime)
 
 	zap.S().Infow("successfully connect to postgres", "host", cfg.Host, "port", cfg.Port, "database", cfg.Database, "sslmode", cfg.SSLMode, "timezone", cfg.TimeZone)
-	return helper.InitDatabase(Default, dbmap)
+	if err = helper.InitDatabase(Default, dbmap); err != nil {
+		return err
+	}
+
+	// Register the read replicas after database initialized, the migrations always run on primary.
+	dialectors := make(map[string]gorm.Dialector, len(cfg.Replicas))
+	for _, addr := range cfg.Replicas {
+		replica := cfg
+		if replica.Host, replica.Port, err = helper.ReplicaAddr(addr, cfg.Port); err != nil {
+			return err
+		}
+		dialectors[addr] = postgres.Open(buildDSN(replica))
+	}
+	if err = database.UseReplicas(Default, dialectors); err != nil {
+		return errors.Wrap(err, "failed to connect to postgres replicas")
+	}
+	return nil
 }
 
 // New creates and returns a new PostgreSQL database connection with the given configuration.
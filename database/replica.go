@@ -0,0 +1,150 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/config"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+const replicaPingTimeout = 3 * time.Second
+
+// replicaSets maps the primary connection pool to its read replicas.
+var replicaSets sync.Map
+
+// replicaSet is the read replicas of one primary database, it implements dbresolver.Policy
+// to route the reads to the healthy replicas in round robin.
+type replicaSet struct {
+	primary  gorm.ConnPool
+	replicas []*replica
+	next     atomic.Uint64
+}
+
+type replica struct {
+	name    string
+	pool    *sql.DB
+	healthy atomic.Bool
+}
+
+// UseReplicas routes the reads of db to the read replicas, the key of dialectors is the replica name
+// used in logs, usually the replica address.
+//
+// The routing rules:
+//   - List, Get, First, Last, Take and Count are routed to the healthy replicas in round robin.
+//   - Create, Update, Delete, transactions and the locking reads (WithLock) are routed to the primary.
+//   - WithPrimary forces the reads to the primary, eg: read your own writes.
+//   - The reads fall back to the primary when all the replicas are unhealthy.
+//
+// The replicas are checked periodically by config "database.replica_check_period" and by Health().
+func UseReplicas(db *gorm.DB, dialectors map[string]gorm.Dialector) error {
+	if len(dialectors) == 0 {
+		return nil
+	}
+	primary, err := db.DB()
+	if err != nil {
+		return errors.Wrap(err, "failed to get primary db")
+	}
+
+	names := make([]string, 0, len(dialectors))
+	for name := range dialectors {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	replicas := make([]gorm.Dialector, 0, len(names))
+	for _, name := range names {
+		replicas = append(replicas, dialectors[name])
+	}
+
+	set := &replicaSet{primary: primary}
+	resolver := dbresolver.Register(dbresolver.Config{Replicas: replicas, Policy: set})
+	if err = db.Use(resolver); err != nil {
+		return errors.Wrap(err, "failed to register database replicas")
+	}
+	resolver.
+		SetMaxIdleConns(config.App.Database.MaxIdleConns).
+		SetMaxOpenConns(config.App.Database.MaxOpenConns).
+		SetConnMaxLifetime(config.App.Database.ConnMaxLifetime).
+		SetConnMaxIdleTime(config.App.Database.ConnMaxIdleTime)
+
+	// The replica pools are opened by dbresolver in the order of dialectors, after the primary.
+	_ = resolver.Call(func(pool gorm.ConnPool) error {
+		if sqlDB, ok := pool.(*sql.DB); ok && sqlDB != primary {
+			set.replicas = append(set.replicas, &replica{name: names[len(set.replicas)], pool: sqlDB})
+		}
+		return nil
+	})
+	if err = set.check(); err != nil {
+		zap.S().Warnw("database replica is unhealthy", "error", err)
+	}
+	replicaSets.Store(primary, set)
+
+	if period := config.App.Database.ReplicaCheckPeriod; period > 0 {
+		go func() {
+			ticker := time.NewTicker(period)
+			defer ticker.Stop()
+			for range ticker.C {
+				_ = set.check()
+			}
+		}()
+	}
+	zap.S().Infow("successfully register database replicas", "replicas", names)
+	return nil
+}
+
+// Resolve implements dbresolver.Policy.
+func (s *replicaSet) Resolve(pools []gorm.ConnPool) gorm.ConnPool {
+	n := len(s.replicas)
+	for range n {
+		r := s.replicas[int(s.next.Add(1)%uint64(n))] //nolint:gosec
+		if r.healthy.Load() {
+			return r.pool
+		}
+	}
+	if n == 0 && len(pools) > 0 {
+		return pools[0]
+	}
+	return s.primary
+}
+
+// check pings all the replicas and returns the first error.
+func (s *replicaSet) check() error {
+	var first error
+	for _, r := range s.replicas {
+		if err := r.ping(); err != nil && first == nil {
+			first = errors.Wrapf(err, "database replica %s ping failed", r.name)
+		}
+	}
+	return first
+}
+
+// ping checks the replica connection and marks it healthy or not.
+func (r *replica) ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), replicaPingTimeout)
+	defer cancel()
+	err := r.pool.PingContext(ctx)
+	healthy := err == nil
+	if r.healthy.Swap(healthy) != healthy {
+		if healthy {
+			zap.S().Infow("database replica is healthy", "replica", r.name)
+		} else {
+			zap.S().Warnw("database replica is unhealthy", "replica", r.name, "error", err)
+		}
+	}
+	return err
+}
+
+// checkReplicas pings the read replicas of the primary pool, it's a no-op if no replicas.
+func checkReplicas(primary *sql.DB) error {
+	if val, ok := replicaSets.Load(primary); ok {
+		return val.(*replicaSet).check() //nolint:errcheck
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package database
+
+import (
+	"context"
+	"reflect"
+	"sync"
+
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+	"gorm.io/plugin/dbresolver"
+)
+
+const (
+	tenantCallbackName = "gst:tenant"
+	// withoutTenantKey is the gorm setting key to disable the tenant isolation.
+	withoutTenantKey = "gst:without_tenant"
+)
+
+// tenantScopedTypes caches whether the model type implements types.TenantScoped.
+var tenantScopedTypes sync.Map
+
+type tenantPlugin struct{}
+
+// TenantPlugin returns the gorm plugin isolating the types.TenantScoped models by tenant:
+//   - The queries, updates and deletes are filtered by the tenant id.
+//   - The created records are stamped with the tenant id, the records of other tenants are rejected.
+//   - The upserts, eg: Save, never overwrite the records of other tenants.
+//
+// The tenant id is taken from the statement context, see types.DatabaseContext, the operations
+// without tenant id fail with ErrTenantRequired. Use Database[M]().WithoutTenant() to disable it.
+//
+// It's registered by database/helper.InitDatabase after the tables and records created.
+func TenantPlugin() gorm.Plugin { return tenantPlugin{} }
+
+func (tenantPlugin) Name() string { return tenantCallbackName }
+
+func (tenantPlugin) Initialize(db *gorm.DB) error {
+	if err := db.Callback().Create().Before("gorm:create").Register(tenantCallbackName, tenantCreate); err != nil {
+		return err
+	}
+	if err := db.Callback().Query().Before("gorm:query").Register(tenantCallbackName, tenantFilter); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:update").Register(tenantCallbackName, tenantFilter); err != nil {
+		return err
+	}
+	return db.Callback().Delete().Before("gorm:delete").Register(tenantCallbackName, tenantFilter)
+}
+
+// tenantFilter adds the tenant condition to the queries, updates and deletes.
+// The existing conditions are grouped, so the ORed conditions, eg: WithOr, can't skip it.
+func tenantFilter(db *gorm.DB) {
+	field, tenant, ok := tenantOf(db)
+	if !ok {
+		return
+	}
+	cond := clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: tenant}
+	if c, exists := db.Statement.Clauses["WHERE"]; exists {
+		if where, ok := c.Expression.(clause.Where); ok && len(where.Exprs) > 0 {
+			c.Expression = clause.Where{Exprs: []clause.Expression{clause.AndConditions{Exprs: where.Exprs}, cond}}
+			db.Statement.Clauses["WHERE"] = c
+			return
+		}
+	}
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{cond}})
+}
+
+// tenantCreate stamps the tenant id to the created records and makes sure the upsert
+// doesn't overwrite the records of other tenants.
+func tenantCreate(db *gorm.DB) {
+	field, tenant, ok := tenantOf(db)
+	if !ok {
+		return
+	}
+
+	ids := make([]any, 0)
+	stamp := func(rv reflect.Value) {
+		if rv.Kind() != reflect.Pointer {
+			if !rv.CanAddr() {
+				return
+			}
+			rv = rv.Addr()
+		}
+		if rv.IsNil() {
+			return
+		}
+		m, ok := rv.Interface().(types.TenantScoped)
+		if !ok {
+			return
+		}
+		switch m.GetTenantID() {
+		case "":
+			m.SetTenantID(tenant)
+		case tenant:
+		default:
+			_ = db.AddError(ErrCrossTenant)
+			return
+		}
+		if pk := db.Statement.Schema.PrioritizedPrimaryField; pk != nil {
+			if val, zero := pk.ValueOf(db.Statement.Context, reflect.Indirect(rv)); !zero {
+				ids = append(ids, val)
+			}
+		}
+	}
+	switch rv := db.Statement.ReflectValue; rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := range rv.Len() {
+			stamp(rv.Index(i))
+		}
+	case reflect.Struct, reflect.Pointer:
+		stamp(rv)
+	}
+	if db.Error != nil {
+		return
+	}
+
+	// The upsert updates the existing record with the same primary key, check none of them
+	// belongs to other tenants, including the soft deleted ones.
+	if _, upsert := db.Statement.Clauses["ON CONFLICT"]; !upsert || len(ids) == 0 {
+		return
+	}
+	pk := db.Statement.Schema.PrioritizedPrimaryField
+	var count int64
+	if err := db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).
+		Clauses(dbresolver.Write).
+		Set(withoutTenantKey, true).
+		Table(db.Statement.Table).
+		Unscoped().
+		Where(clause.IN{Column: clause.Column{Name: pk.DBName}, Values: ids}).
+		Where(clause.Neq{Column: clause.Column{Name: field.DBName}, Value: tenant}).
+		Count(&count).Error; err != nil {
+		_ = db.AddError(err)
+		return
+	}
+	if count > 0 {
+		_ = db.AddError(ErrCrossTenant)
+	}
+}
+
+// tenantOf returns the tenant field of the statement model and the tenant id of the context,
+// ok is false if the model is not tenant scoped or the isolation is disabled.
+func tenantOf(db *gorm.DB) (field *schema.Field, tenant string, ok bool) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return nil, "", false
+	}
+	if !isTenantScoped(db.Statement.Schema.ModelType) {
+		return nil, "", false
+	}
+	if val, exists := db.Get(withoutTenantKey); exists && val == true {
+		return nil, "", false
+	}
+	if field = db.Statement.Schema.LookUpField(consts.FIELD_TENANT); field == nil {
+		return nil, "", false
+	}
+	if tenant = tenantFromContext(db.Statement.Context); len(tenant) == 0 {
+		_ = db.AddError(ErrTenantRequired)
+		return nil, "", false
+	}
+	return field, tenant, true
+}
+
+func isTenantScoped(typ reflect.Type) bool {
+	if val, ok := tenantScopedTypes.Load(typ); ok {
+		return val.(bool) //nolint:errcheck
+	}
+	_, scoped := reflect.New(typ).Interface().(types.TenantScoped)
+	tenantScopedTypes.Store(typ, scoped)
+	return scoped
+}
+
+func tenantFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	tenant, _ := ctx.Value(consts.CTX_TENANT_ID).(string)
+	return tenant
+}
+
+// WithoutTenant disables the tenant isolation of types.TenantScoped model, the operations
+// access the records of all tenants and the created records keep their tenant id as is.
+//
+// It's the explicit escape hatch for super admin and system jobs, never use it for the
+// requests of normal users.
+//
+// Example:
+//
+//	WithoutTenant().List(&projects)  // List the projects of all tenants
+func (db *database[M]) WithoutTenant() types.Database[M] {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.ins = db.ins.Set(withoutTenantKey, true)
+	return db
+}
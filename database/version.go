@@ -0,0 +1,89 @@
+package database
+
+import (
+	"reflect"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// versionColumn returns the column name of the version field of the versioned model.
+func (db *database[M]) versionColumn() (string, error) {
+	stmt := &gorm.Statement{DB: db.ins}
+	if err := stmt.Parse(db.m); err != nil {
+		return "", errors.Wrap(err, "failed to parse model schema")
+	}
+	field := stmt.Schema.LookUpField(consts.FIELD_VERSION)
+	if field == nil || len(field.DBName) == 0 {
+		return "", errors.Newf("versioned model %s has no %q field", stmt.Schema.Name, consts.FIELD_VERSION)
+	}
+	return field.DBName, nil
+}
+
+// updateVersioned updates the versioned records within one transaction, any version
+// conflict rolls back all the records and returns ErrVersionConflict.
+//
+// The record with non-zero version is only updated when the stored version equals it:
+//
+//	UPDATE table SET ..., version = version + 1 WHERE id = ? AND version = ?
+//
+// The record with zero version is overwritten unconditionally or created if not exists,
+// its version is still increased so the other writers holding the old version will fail.
+func (db *database[M]) updateVersioned(ins *gorm.DB, tableName string, objs []M) error {
+	column, err := db.versionColumn()
+	if err != nil {
+		return err
+	}
+	col := clause.Column{Table: clause.CurrentTable, Name: column}
+	// The zero value model only provides the table name, the condition is set by Where.
+	m := reflect.New(reflect.TypeFor[M]().Elem()).Interface()
+
+	versions := make([]uint, len(objs))
+	for i := range objs {
+		versions[i] = any(objs[i]).(types.Versioned).GetVersion() //nolint:errcheck
+	}
+	if err = ins.Transaction(func(tx *gorm.DB) error {
+		for i := range objs {
+			v := any(objs[i]).(types.Versioned) //nolint:errcheck
+			if versions[i] > 0 {
+				v.SetVersion(versions[i] + 1)
+				res := tx.Table(tableName).Model(objs[i]).Select("*").Where(clause.Eq{Column: col, Value: versions[i]}).Updates(objs[i])
+				if res.Error != nil {
+					return res.Error
+				}
+				if res.RowsAffected == 0 {
+					return errors.Wrapf(ErrVersionConflict, "id: %s, version: %d", objs[i].GetID(), versions[i])
+				}
+				continue
+			}
+
+			res := tx.Table(tableName).Model(m).Where("id = ?", objs[i].GetID()).UpdateColumn(column, gorm.Expr("? + 1", col))
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				v.SetVersion(1)
+			} else {
+				var current uint
+				if err := tx.Table(tableName).Model(m).Where("id = ?", objs[i].GetID()).Select(column).Scan(&current).Error; err != nil {
+					return err
+				}
+				v.SetVersion(current)
+			}
+			if err := tx.Table(tableName).Save(objs[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		// Restore the versions of caller, the records are not updated.
+		for i := range objs {
+			any(objs[i]).(types.Versioned).SetVersion(versions[i]) //nolint:errcheck
+		}
+		return err
+	}
+	return nil
+}
@@ -191,6 +191,15 @@ func Public(bool) {}
 // Payload specifies the request payload type for the current action.
 // The type parameter T defines the structure of incoming request data.
 // Example: Payload[CreateUserRequest]() or Payload[*User]()
+//
+// The payload is validated by its `validate` struct tags and the optional
+// types.Validator before the service is invoked, and the rules are reflected
+// into the generated OpenAPI schema, eg:
+//
+//	type CreateUserRequest struct {
+//		Name  string `json:"name" validate:"required,max=64"`
+//		Email string `json:"email" validate:"required,email"`
+//	}
 func Payload[T any]() {}
 
 // Result specifies the response result type for the current action.
@@ -0,0 +1,192 @@
+// Package jobrun runs the cronjobs and tasks by the timeout and retry policy, and records
+// the runs: the runtime state, the run history model.JobRun and the prometheus metrics.
+package jobrun
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/logger"
+	"github.com/forbearing/gst/metrics"
+	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/util"
+)
+
+var node, _ = os.Hostname()
+
+// State is the runtime state of a job.
+type State struct {
+	active atomic.Int32
+
+	mu           sync.Mutex
+	lastRun      time.Time
+	lastStatus   string
+	lastDuration time.Duration
+	lastError    string
+	runs         int64
+	failures     int64
+}
+
+// Start marks the job running.
+func (s *State) Start() { s.active.Add(1) }
+
+// Finish records the run started by Start, err is the error returned by the job.
+func (s *State) Finish(kind, name, trigger string, begin time.Time, err error) {
+	s.active.Add(-1)
+	status, msg := model.JobStatusSuccess, ""
+	if err != nil {
+		status, msg = model.JobStatusFailure, err.Error()
+	}
+	cost := time.Since(begin)
+
+	s.mu.Lock()
+	s.lastRun, s.lastStatus, s.lastDuration, s.lastError = begin, status, cost, msg
+	s.runs++
+	if err != nil {
+		s.failures++
+	}
+	s.mu.Unlock()
+
+	if metrics.JobRunDuration != nil {
+		metrics.JobRunDuration.WithLabelValues(kind, name).Observe(cost.Seconds())
+	}
+	record(kind, name, trigger, status, msg, begin, cost)
+}
+
+// Skip records the run skipped by the reason, eg: the previous run is still running.
+func (s *State) Skip(kind, name, trigger, reason string) {
+	record(kind, name, trigger, model.JobStatusSkipped, reason, time.Now(), 0)
+}
+
+// Fill fills the runtime state into the job info.
+func (s *State) Fill(info *types.JobInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info.Running = s.active.Load() > 0
+	if !s.lastRun.IsZero() {
+		info.LastRun = util.ValueOf(s.lastRun)
+	}
+	info.LastStatus = s.lastStatus
+	info.LastDuration = s.lastDuration.Milliseconds()
+	info.LastError = s.lastError
+	info.Runs, info.Failures = s.runs, s.failures
+}
+
+func record(kind, name, trigger, status, msg string, begin time.Time, cost time.Duration) {
+	if metrics.JobRunsTotal != nil {
+		metrics.JobRunsTotal.WithLabelValues(kind, name, status).Inc()
+	}
+	if !config.App.Cronjob.History || database.DB == nil {
+		return
+	}
+	run := &model.JobRun{
+		Kind:      kind,
+		Name:      name,
+		Trigger:   trigger,
+		Status:    status,
+		Error:     msg,
+		Node:      node,
+		StartedAt: util.ValueOf(model.GormTime(begin)),
+		Duration:  cost.Milliseconds(),
+	}
+	if err := database.Database[*model.JobRun](nil).Create(run); err != nil {
+		logger.Cronjob.Errorw("failed to record job run", "kind", kind, "name", name, "error", err)
+	}
+}
+
+// Cleanup removes the run history older than config "cronjob.history_retention".
+func Cleanup() error {
+	retention := config.App.Cronjob.HistoryRetention
+	if retention <= 0 || database.DB == nil {
+		return nil
+	}
+	return database.DB.Unscoped().Where("created_at < ?", time.Now().Add(-retention)).Delete(new(model.JobRun)).Error
+}
+
+// Func is the function of the cronjobs and tasks, the context is canceled on shutdown or timeout.
+type Func interface {
+	func() error | func(context.Context) error
+}
+
+// Wrap converts the job function to the context-aware one.
+func Wrap[F Func](fn F) func(context.Context) error {
+	switch f := any(fn).(type) {
+	case func() error:
+		if f == nil {
+			return nil
+		}
+		return func(context.Context) error { return f() }
+	case func(context.Context) error:
+		return f
+	}
+	return nil
+}
+
+// Policy is the timeout and retry policy of a job.
+type Policy struct {
+	// Timeout cancels the context of each attempt, zero means no timeout.
+	Timeout time.Duration
+	// Retries is the max retries of the failed run.
+	Retries int
+	// Backoff is the delay before the first retry, it doubles every retry up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// Call calls the job function by the policy, it stops retrying once the context is canceled.
+// The panic of the function is returned as error.
+func (p Policy) Call(ctx context.Context, fn func(context.Context) error, onRetry func(attempt int, delay time.Duration, err error)) error {
+	backoff := p.Backoff
+	for attempt := 0; ; attempt++ {
+		err := p.call(ctx, fn)
+		if err == nil || attempt >= p.Retries || ctx.Err() != nil {
+			return err
+		}
+		delay := min(backoff, p.MaxBackoff)
+		if onRetry != nil {
+			onRetry(attempt+1, delay, err)
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		backoff *= 2
+	}
+}
+
+func (p Policy) call(ctx context.Context, fn func(context.Context) error) (err error) {
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("panic: %v", e)
+		}
+	}()
+	return fn(ctx)
+}
+
+// Wait waits for the in-flight runs to finish within the timeout, it reports whether they finished.
+func Wait(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
@@ -0,0 +1,106 @@
+package openapigen
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// filterDescription documents the "_filter" query parameter.
+var filterDescription = strings.Join([]string{
+	"Json encoded filter with nested AND/OR groups, combined with other conditions by AND.",
+	"A condition is `{\"field\":\"age\",\"op\":\"gte\",\"values\":[\"18\"]}`, a group is `{\"and\":[...],\"or\":[...]}`.",
+	"The field of expanded association is prefixed with the association name, eg: `Profile.city`.",
+	fmt.Sprintf("Supported operators: %s.", joinFilterOps(types.FilterOps)),
+}, " ")
+
+// filterParameter returns the "_filter" query parameter.
+func filterParameter() *openapi3.ParameterRef {
+	return &openapi3.ParameterRef{
+		Value: &openapi3.Parameter{
+			Name:        consts.QUERY_FILTER,
+			In:          "query",
+			Required:    false,
+			Schema:      &openapi3.SchemaRef{Value: &openapi3.Schema{Type: &openapi3.Types{openapi3.TypeString}}},
+			Description: filterDescription,
+		},
+	}
+}
+
+// filterOpParameters returns the operator-aware query parameters of the field, eg: "age[gte]".
+// The operators are chosen by the field type, the fields not stored in database are skipped.
+func filterOpParameters(field reflect.StructField, name, description string) []*openapi3.ParameterRef {
+	if field.Tag.Get("gorm") == "-" {
+		return nil
+	}
+	typ := field.Type
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	var ops []types.FilterOp
+	switch typ.Kind() {
+	case reflect.String:
+		ops = []types.FilterOp{types.FilterNe, types.FilterIn, types.FilterNin, types.FilterLike, types.FilterNull}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		ops = []types.FilterOp{types.FilterNe, types.FilterGt, types.FilterGte, types.FilterLt, types.FilterLte, types.FilterIn, types.FilterNin, types.FilterBetween}
+	case reflect.Bool:
+		ops = []types.FilterOp{types.FilterNe, types.FilterNull}
+	case reflect.Struct:
+		if typ != reflect.TypeFor[time.Time]() {
+			return nil
+		}
+		ops = []types.FilterOp{types.FilterGt, types.FilterGte, types.FilterLt, types.FilterLte, types.FilterBetween, types.FilterNull}
+	default:
+		return nil
+	}
+
+	params := make([]*openapi3.ParameterRef, 0, len(ops))
+	for _, op := range ops {
+		desc := fmt.Sprintf("Filter %s by operator %q", name, op)
+		switch op {
+		case types.FilterIn, types.FilterNin:
+			desc += ", multiple values are separated by comma"
+		case types.FilterBetween:
+			desc += ", the lower and upper bounds are separated by comma"
+		case types.FilterNull:
+			desc += ", true matches null values and false matches non-null values"
+		case types.FilterLike:
+			desc += ", matches the records containing the value"
+		}
+		if len(description) > 0 {
+			desc = description + ". " + desc
+		}
+		schemaType := fieldType2openapiType(field)
+		if op == types.FilterNull {
+			schemaType = &openapi3.Types{openapi3.TypeBoolean}
+		} else if op == types.FilterIn || op == types.FilterNin || op == types.FilterBetween || typ.Kind() == reflect.Struct {
+			schemaType = &openapi3.Types{openapi3.TypeString}
+		}
+		params = append(params, &openapi3.ParameterRef{
+			Value: &openapi3.Parameter{
+				Name:        fmt.Sprintf("%s[%s]", name, op),
+				In:          "query",
+				Required:    false,
+				Schema:      &openapi3.SchemaRef{Value: &openapi3.Schema{Type: schemaType}},
+				Description: desc,
+			},
+		})
+	}
+	return params
+}
+
+func joinFilterOps(ops []types.FilterOp) string {
+	items := make([]string, 0, len(ops))
+	for _, op := range ops {
+		items = append(items, string(op))
+	}
+	return strings.Join(items, ", ")
+}
@@ -88,7 +88,7 @@ func setCreate[M types.Model, REQ types.Request, RSP types.Response](path string
 		Tags:        tags(path, consts.Create, typ),
 		Parameters:  parseParametersFromPath(path),
 		RequestBody: newRequestBody[REQ](reqKey),
-		Responses:   newResponses[RSP](201, rspKey),
+		Responses:   withValidationResponse[REQ](newResponses[RSP](201, rspKey)),
 		// RequestBody: &openapi3.RequestBodyRef{Ref: "#/components/requestBodies/" + reqKey},
 		// Responses:   openapi3.NewResponses(openapi3.WithStatus(201, &openapi3.ResponseRef{Ref: "#/components/responses/" + rspKey})),
 
@@ -235,7 +235,7 @@ func setUpdate[M types.Model, REQ types.Request, RSP types.Response](path string
 		Tags:        tags(path, consts.Update, typ),
 		Parameters:  parseParametersFromPath(path),
 		RequestBody: newRequestBody[REQ](reqKey),
-		Responses:   newResponses[RSP](200, rspKey),
+		Responses:   withValidationResponse[REQ](newResponses[RSP](200, rspKey)),
 		// RequestBody: &openapi3.RequestBodyRef{
 		// 	Value: &openapi3.RequestBody{
 		// 		Description: fmt.Sprintf("The %s data to update", name),
@@ -317,7 +317,7 @@ func setPatch[M types.Model, REQ types.Request, RSP types.Response](path string,
 		Tags:        tags(path, consts.Patch, typ),
 		Parameters:  parseParametersFromPath(path),
 		RequestBody: newRequestBody[REQ](reqKey),
-		Responses:   newResponses[RSP](200, rspKey),
+		Responses:   withValidationResponse[REQ](newResponses[RSP](200, rspKey)),
 		// RequestBody: &openapi3.RequestBodyRef{
 		// 	Value: &openapi3.RequestBody{
 		// 		Description: fmt.Sprintf("Partial fields of %s to update", name),
@@ -664,7 +664,7 @@ func setCreateMany[M types.Model, REQ types.Request, RSP types.Response](path st
 		Tags:        tags(path, consts.CreateMany, typ),
 		Parameters:  parseParametersFromPath(path),
 		RequestBody: newRequestBody[REQ](reqKey),
-		Responses:   newResponses[RSP](201, rspKey),
+		Responses:   withValidationResponse[REQ](newResponses[RSP](201, rspKey)),
 		// RequestBody: &openapi3.RequestBodyRef{
 		// 	Value: &openapi3.RequestBody{
 		// 		Description: fmt.Sprintf("Request body for batch creating %s", name),
@@ -905,7 +905,7 @@ func setUpdateMany[M types.Model, REQ types.Request, RSP types.Response](path st
 		Tags:        tags(path, consts.UpdateMany, typ),
 		Parameters:  parseParametersFromPath(path),
 		RequestBody: newRequestBody[REQ](reqKey),
-		Responses:   newResponses[RSP](200, rspKey),
+		Responses:   withValidationResponse[REQ](newResponses[RSP](200, rspKey)),
 		// RequestBody: &openapi3.RequestBodyRef{
 		// 	Value: &openapi3.RequestBody{
 		// 		Description: fmt.Sprintf("Request body for batch updating %s", name),
@@ -1210,6 +1210,7 @@ func registerSchema[M types.Model, REQ types.Request, RSP types.Response](reqKey
 		if _, ok := doc.Components.Schemas[name]; !ok {
 			if schemaRef, err := openapi3gen.NewSchemaRefForValue(*new(M), nil); err == nil {
 				addSchemaTitle[M](schemaRef)
+				addSchemaValidation[M](schemaRef)
 				doc.Components.Schemas[name] = schemaRef
 			}
 		}
@@ -1285,6 +1286,7 @@ func processAllRequestTypes[REQ types.Request](reqSchemaRef *openapi3.SchemaRef)
 	// 如果是普通请求，直接处理
 	if len(reqSchemaRef.Value.Properties) == 0 {
 		addSchemaTitle[REQ](reqSchemaRef)
+		addSchemaValidation[REQ](reqSchemaRef)
 		return
 	}
 
@@ -1293,10 +1295,12 @@ func processAllRequestTypes[REQ types.Request](reqSchemaRef *openapi3.SchemaRef)
 		if itemsProperty.Value != nil && itemsProperty.Value.Items != nil {
 			// 为批量请求的 items 添加注释
 			addSchemaTitle[REQ](itemsProperty.Value.Items)
+			addSchemaValidation[REQ](itemsProperty.Value.Items)
 		}
 	} else {
 		// 普通请求
 		addSchemaTitle[REQ](reqSchemaRef)
+		addSchemaValidation[REQ](reqSchemaRef)
 	}
 }
 
@@ -1963,6 +1967,7 @@ func addQueryParameters[M types.Model, REQ types.Request, RSP types.Response](op
 				Description: description,
 			},
 		})
+		queries = append(queries, filterOpParameters(field, schemaTag, description)...)
 	}
 
 	// Get field descriptions of model.Base (using cache)
@@ -1988,8 +1993,11 @@ func addQueryParameters[M types.Model, REQ types.Request, RSP types.Response](op
 				Description: description,
 			},
 		})
+		queries = append(queries, filterOpParameters(field, schemaTag, description)...)
 	}
 
+	queries = append(queries, filterParameter())
+
 	// queries := []*openapi3.ParameterRef{
 	// 	{
 	// 		Value: &openapi3.Parameter{
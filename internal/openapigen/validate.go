@@ -0,0 +1,232 @@
+package openapigen
+
+import (
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/pkg/validation"
+	"github.com/forbearing/gst/response"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+	"github.com/forbearing/gst/util"
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// formatRules maps the `validate` rules to openapi string formats.
+var formatRules = map[string]string{
+	"email":    "email",
+	"url":      "uri",
+	"http_url": "uri",
+	"uri":      "uri",
+	"uuid":     "uuid",
+	"uuid4":    "uuid",
+	"ipv4":     "ipv4",
+	"ipv6":     "ipv6",
+	"hostname": "hostname",
+}
+
+// addSchemaValidation reflects the `validate` struct tags of T into the schema properties,
+// eg: required, min/max/len, oneof and formats such as email, so the constraints
+// checked by controller are documented.
+func addSchemaValidation[T any](schemaRef *openapi3.SchemaRef) {
+	if schemaRef == nil || schemaRef.Value == nil || schemaRef.Value.Properties == nil {
+		return
+	}
+	typ := reflect.TypeOf(*new(T))
+	if typ == nil {
+		return
+	}
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return
+	}
+	applyValidation(schemaRef.Value, typ)
+}
+
+func applyValidation(schema *openapi3.Schema, typ reflect.Type) {
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		jsonTag := getFieldTag(field, consts.TAG_JSON)
+
+		// Flatten the anonymous embedded structure, eg: model.Base.
+		if field.Anonymous && len(jsonTag) == 0 {
+			ft := field.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				applyValidation(schema, ft)
+			}
+			continue
+		}
+		rules := field.Tag.Get(consts.TAG_VALIDATE)
+		if len(jsonTag) == 0 || len(rules) == 0 || rules == "-" {
+			continue
+		}
+		propRef, ok := schema.Properties[jsonTag]
+		if !ok || propRef == nil || propRef.Value == nil {
+			continue
+		}
+
+		// Create a copy of the schema to avoid shared reference issues.
+		prop := *propRef.Value
+		kind := field.Type.Kind()
+		if kind == reflect.Pointer {
+			kind = field.Type.Elem().Kind()
+		}
+		for rule := range strings.SplitSeq(rules, ",") {
+			name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+			// The rules after "dive" apply to the elements, "|" means any of rules,
+			// both are not expressible by the property schema.
+			if name == "dive" {
+				break
+			}
+			if strings.Contains(name, "|") {
+				continue
+			}
+			if name == "required" {
+				if !slices.Contains(schema.Required, jsonTag) {
+					schema.Required = append(schema.Required, jsonTag)
+				}
+				continue
+			}
+			if format, ok := formatRules[name]; ok {
+				prop.Format = format
+				continue
+			}
+			if name == "oneof" {
+				prop.Enum = make([]any, 0)
+				for v := range strings.FieldsSeq(param) {
+					prop.Enum = append(prop.Enum, enumValue(kind, v))
+				}
+				continue
+			}
+			applyBound(&prop, kind, name, param)
+		}
+		schema.Properties[jsonTag] = &openapi3.SchemaRef{Value: &prop}
+	}
+}
+
+// applyBound applies the len/min/max/gt/gte/lt/lte rules, which constrain the length
+// of string, the number of items of slice and map, or the value of number.
+func applyBound(prop *openapi3.Schema, kind reflect.Kind, name, param string) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	var isMin, isMax, exclusive bool
+	switch name {
+	case "len":
+		isMin, isMax = true, true
+	case "min", "gte":
+		isMin = true
+	case "max", "lte":
+		isMax = true
+	case "gt":
+		isMin, exclusive = true, true
+	case "lt":
+		isMax, exclusive = true, true
+	default:
+		return
+	}
+
+	switch kind {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		if n < 0 {
+			return
+		}
+		size := uint64(n)
+		if exclusive && isMin {
+			size++
+		}
+		if exclusive && isMax {
+			if size == 0 {
+				return
+			}
+			size--
+		}
+		switch kind {
+		case reflect.String:
+			if isMin {
+				prop.MinLength = size
+			}
+			if isMax {
+				prop.MaxLength = util.ValueOf(size)
+			}
+		case reflect.Map:
+			if isMin {
+				prop.MinProps = size
+			}
+			if isMax {
+				prop.MaxProps = util.ValueOf(size)
+			}
+		default:
+			if isMin {
+				prop.MinItems = size
+			}
+			if isMax {
+				prop.MaxItems = util.ValueOf(size)
+			}
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if isMin {
+			prop.Min = util.ValueOf(n)
+			prop.ExclusiveMin = exclusive
+		}
+		if isMax {
+			prop.Max = util.ValueOf(n)
+			prop.ExclusiveMax = exclusive
+		}
+	}
+}
+
+func enumValue(kind reflect.Kind, v string) any {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+	case reflect.Float32, reflect.Float64:
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	}
+	return strings.Trim(v, "'")
+}
+
+// withValidationResponse adds the response of the request validation failed
+// to the operation responses if the operation has request body.
+func withValidationResponse[REQ types.Request](responses *openapi3.Responses) *openapi3.Responses {
+	if model.IsModelEmpty[REQ]() {
+		return responses
+	}
+	schemaRef := newAPIResponseRefWithData(validation.Errors{})
+	schemaRef.Value.Example = map[string]any{
+		"code": response.CodeValidationFailed.Code(),
+		"msg":  "email must be a valid email address",
+		"data": []map[string]any{
+			{"field": "email", "rule": "email", "message": "email must be a valid email address"},
+		},
+		"request_id": "req_123456789",
+	}
+	ref := &openapi3.ResponseRef{
+		Value: &openapi3.Response{
+			Description: util.ValueOf(response.CodeValidationFailed.Msg()),
+			Content:     openapi3.NewContentWithJSONSchemaRef(schemaRef),
+		},
+	}
+	status := response.CodeValidationFailed.Status()
+	if responses == nil {
+		return openapi3.NewResponses(openapi3.WithStatus(status, ref))
+	}
+	responses.Set(strconv.Itoa(status), ref)
+	return responses
+}
@@ -0,0 +1,55 @@
+package openapigen
+
+import (
+	"testing"
+
+	"github.com/forbearing/gst/model"
+	"github.com/getkin/kin-openapi/openapi3gen"
+)
+
+type ValidateTestUser struct {
+	Name   string   `json:"name" validate:"required,min=2,max=32"`
+	Email  string   `json:"email" validate:"omitempty,email"`
+	Age    int      `json:"age" validate:"gt=0,lte=150"`
+	Role   string   `json:"role" validate:"oneof=admin guest"`
+	Level  int      `json:"level" validate:"oneof=1 2 3"`
+	Tags   []string `json:"tags" validate:"max=3,dive,min=1"`
+	Remark string   `json:"remark"`
+
+	model.Base
+}
+
+func TestAddSchemaValidation(t *testing.T) {
+	schemaRef, err := openapi3gen.NewSchemaRefForValue(new(ValidateTestUser), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addSchemaValidation[*ValidateTestUser](schemaRef)
+	props := schemaRef.Value.Properties
+
+	if len(schemaRef.Value.Required) != 1 || schemaRef.Value.Required[0] != "name" {
+		t.Errorf("expected required [name], got %v", schemaRef.Value.Required)
+	}
+	if name := props["name"].Value; name.MinLength != 2 || name.MaxLength == nil || *name.MaxLength != 32 {
+		t.Errorf("unexpected name length: %d, %v", name.MinLength, name.MaxLength)
+	}
+	if email := props["email"].Value; email.Format != "email" {
+		t.Errorf("expected email format, got %q", email.Format)
+	}
+	if age := props["age"].Value; age.Min == nil || *age.Min != 0 || !age.ExclusiveMin || age.Max == nil || *age.Max != 150 || age.ExclusiveMax {
+		t.Errorf("unexpected age bounds: %v, %v", age.Min, age.Max)
+	}
+	if role := props["role"].Value; len(role.Enum) != 2 || role.Enum[0] != "admin" {
+		t.Errorf("unexpected role enum: %v", role.Enum)
+	}
+	if level := props["level"].Value; len(level.Enum) != 3 || level.Enum[0] != int64(1) {
+		t.Errorf("unexpected level enum: %v", level.Enum)
+	}
+	// rules after dive apply to the elements.
+	if tags := props["tags"].Value; tags.MaxItems == nil || *tags.MaxItems != 3 || tags.MinItems != 0 {
+		t.Errorf("unexpected tags items: %d, %v", tags.MinItems, tags.MaxItems)
+	}
+	if remark := props["remark"].Value; remark.Format != "" || remark.MaxLength != nil {
+		t.Errorf("remark should not be changed")
+	}
+}
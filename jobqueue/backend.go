@@ -0,0 +1,197 @@
+package jobqueue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/IBM/sarama"
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/provider/kafka"
+	"github.com/forbearing/gst/provider/nats"
+	"github.com/forbearing/gst/provider/redis"
+	natsgo "github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+// notifier delivers the notifications of the enqueued jobs to the workers of all replicas.
+// The jobs are always stored in database, the notification only wakes up the idle workers
+// of the queue, so a lost one delays the job to the next poll at most.
+type notifier interface {
+	// Notify notifies the workers of the queue.
+	Notify(ctx context.Context, queue string) error
+	// Subscribe calls fn with the queue notified until ctx done.
+	Subscribe(ctx context.Context, fn func(queue string)) error
+	Close()
+}
+
+func newBackend(typ config.JobQueueBackend) (notifier, error) {
+	channel := config.App.JobQueue.Channel
+	switch typ {
+	case "", config.JobQueueBackendDatabase:
+		return nil, nil
+	case config.JobQueueBackendRedis:
+		if redis.Client() == nil {
+			return nil, redis.ErrRedisIsDisabled
+		}
+		return &redisBackend{channel: channel}, nil
+	case config.JobQueueBackendKafka:
+		if kafka.Client() == nil {
+			return nil, errors.New("kafka is disabled")
+		}
+		producer, err := sarama.NewAsyncProducerFromClient(kafka.Client())
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create kafka producer")
+		}
+		go func() {
+			for err := range producer.Errors() {
+				log.Warnz(fmt.Sprintf("failed to notify job queue: %s", err.Err), zap.String("topic", channel))
+			}
+		}()
+		return &kafkaBackend{topic: channel, producer: producer}, nil
+	case config.JobQueueBackendNats:
+		if nats.Conn() == nil {
+			return nil, errors.New("nats is disabled")
+		}
+		return &natsBackend{subject: channel}, nil
+	default:
+		return nil, errors.Newf("unknown jobqueue backend %q", typ)
+	}
+}
+
+type redisBackend struct{ channel string }
+
+func (b *redisBackend) Notify(ctx context.Context, queue string) error {
+	return redis.Client().Publish(ctx, b.channel, queue).Err()
+}
+
+func (b *redisBackend) Subscribe(ctx context.Context, fn func(string)) error {
+	ps := redis.Client().Subscribe(ctx, b.channel)
+	// Wait for the subscription confirmed.
+	if _, err := ps.Receive(ctx); err != nil {
+		_ = ps.Close()
+		return err
+	}
+	go func() {
+		defer ps.Close()
+		ch := ps.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				fn(msg.Payload)
+			}
+		}
+	}()
+	return nil
+}
+
+func (*redisBackend) Close() {}
+
+type kafkaBackend struct {
+	topic string
+
+	mu       sync.Mutex
+	producer sarama.AsyncProducer
+	closed   bool
+}
+
+func (b *kafkaBackend) Notify(ctx context.Context, queue string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return errors.New("kafka producer closed")
+	}
+	select {
+	case b.producer.Input() <- &sarama.ProducerMessage{Topic: b.topic, Value: sarama.StringEncoder(queue)}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Subscribe consumes the newest messages of all partitions, every replica receives
+// every notification.
+func (b *kafkaBackend) Subscribe(ctx context.Context, fn func(string)) error {
+	consumer, err := sarama.NewConsumerFromClient(kafka.Client())
+	if err != nil {
+		return err
+	}
+	partitions, err := consumer.Partitions(b.topic)
+	if err != nil {
+		_ = consumer.Close()
+		return err
+	}
+	pcs := make([]sarama.PartitionConsumer, 0, len(partitions))
+	for _, p := range partitions {
+		pc, err := consumer.ConsumePartition(b.topic, p, sarama.OffsetNewest)
+		if err != nil {
+			for _, pc := range pcs {
+				_ = pc.Close()
+			}
+			_ = consumer.Close()
+			return err
+		}
+		pcs = append(pcs, pc)
+	}
+
+	var wg sync.WaitGroup
+	for _, pc := range pcs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer pc.Close()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case msg, ok := <-pc.Messages():
+					if !ok {
+						return
+					}
+					fn(string(msg.Value))
+				}
+			}
+		}()
+	}
+	go func() {
+		// The consumer must be closed after its partition consumers.
+		wg.Wait()
+		_ = consumer.Close()
+	}()
+	return nil
+}
+
+func (b *kafkaBackend) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.closed {
+		b.closed = true
+		b.producer.AsyncClose()
+	}
+}
+
+type natsBackend struct{ subject string }
+
+func (b *natsBackend) Notify(_ context.Context, queue string) error {
+	return nats.Conn().Publish(b.subject, []byte(queue))
+}
+
+func (b *natsBackend) Subscribe(ctx context.Context, fn func(string)) error {
+	sub, err := nats.Conn().Subscribe(b.subject, func(msg *natsgo.Msg) { fn(string(msg.Data)) })
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		_ = sub.Unsubscribe()
+	}()
+	return nil
+}
+
+func (*natsBackend) Close() {}
@@ -0,0 +1,512 @@
+// Package jobqueue provides the durable background job queue. The jobs are persisted in
+// database (see model.Job) and run by the workers of the queues with the typed handlers,
+// the failed jobs are retried with exponential backoff and dead-lettered after all retries.
+//
+// Example:
+//
+//	type Email struct{ To, Subject string }
+//
+//	jobqueue.Register("send_email", func(ctx context.Context, e Email) error {
+//		return send(ctx, e.To, e.Subject)
+//	}, jobqueue.Config{Queue: "email", Timeout: time.Minute})
+//
+//	// eg: in the service hook.
+//	_, err := jobqueue.Enqueue(ctx, "send_email", Email{To: "user@example.com"}, jobqueue.Options{Delay: time.Minute})
+//
+//	// eg: within the transaction, the job exists only if the transaction committed.
+//	err := database.Database[*model.Order](nil).TransactionFunc(func(tx any) error {
+//		_, err := jobqueue.Enqueue(ctx, "send_email", Email{To: "user@example.com"}, jobqueue.Options{Tx: tx})
+//		return err
+//	})
+//
+// The database is the only storage of the jobs. The backends redis, kafka and nats never
+// carry the jobs, they only wake up the workers of all replicas to claim the jobs at once
+// instead of at the next poll, so the throughput is still bounded by the database.
+package jobqueue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/cronjob"
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/internal/jobrun"
+	pkgzap "github.com/forbearing/gst/logger/zap"
+	"github.com/forbearing/gst/metrics"
+	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/util"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// DefaultQueue is the queue of the jobs without queue specified.
+const DefaultQueue = "default"
+
+// claimBatch is the number of the candidate jobs fetched by each claim.
+const claimBatch = 10
+
+var (
+	log *pkgzap.Logger
+	mu  sync.Mutex
+
+	handlers = make(map[string]*handler) // key is the job type
+	workers  = make(map[string]*worker)  // key is the queue
+
+	// backend notifies the workers of all replicas, nil if only polling the database.
+	backend notifier
+	// owner identifies the worker locking the jobs.
+	owner string
+
+	// ctx is the parent context of the workers and the running jobs, it's canceled by Stop.
+	ctx, cancel = context.WithCancel(context.Background())
+	// inflight tracks the running jobs, no job is claimed once stopped.
+	inflight sync.WaitGroup
+	stopped  bool
+
+	inited bool
+)
+
+// Config is the optional configuration of the job handler.
+type Config struct {
+	// Queue is the queue of the jobs, default to DefaultQueue.
+	Queue string `json:"queue" yaml:"queue" toml:"queue"`
+	// Timeout cancels the context of each run, zero means no timeout.
+	Timeout time.Duration `json:"timeout" yaml:"timeout" toml:"timeout"`
+	// Retries is the max retries of the failed job, zero means config "jobqueue.max_retries"
+	// and negative means no retry.
+	Retries int `json:"retries" yaml:"retries" toml:"retries"`
+	// Backoff and MaxBackoff override config "jobqueue.backoff" and "jobqueue.max_backoff".
+	Backoff    time.Duration `json:"backoff" yaml:"backoff" toml:"backoff"`
+	MaxBackoff time.Duration `json:"max_backoff" yaml:"max_backoff" toml:"max_backoff"`
+}
+
+// Options is the optional options of the enqueued job.
+type Options struct {
+	// Queue overrides the queue of the job handler registered in this process.
+	Queue string
+	// Priority is the priority of the job in the queue, the higher runs first.
+	Priority int
+	// Delay delays the job, it's ignored if RunAt is set.
+	Delay time.Duration
+	// RunAt schedules the job at the time.
+	RunAt time.Time
+	// Tx is the optional transaction (*gorm.DB) the job is created within, eg: the tx of
+	// Database.TransactionFunc. The workers are not notified, the job is claimed by the
+	// polling after the transaction committed.
+	Tx any
+}
+
+type handler struct {
+	typ     string
+	payload reflect.Type
+	fn      func(context.Context, string) error
+	config  Config
+}
+
+// Init starts the workers of the queues with handlers registered and subscribes the
+// notifications of the backend. It's called by bootstrap.
+func Init() (err error) {
+	if log == nil {
+		log = pkgzap.New("jobqueue.log")
+	}
+	cfg := config.App.JobQueue
+	if cfg.Lease < time.Second {
+		return errors.Newf("jobqueue lease %s less than 1 second", cfg.Lease)
+	}
+	if cfg.PollInterval <= 0 {
+		return errors.Newf("invalid jobqueue poll interval %s", cfg.PollInterval)
+	}
+	if len(owner) == 0 {
+		hostname, _ := os.Hostname()
+		owner = hostname + ":" + strconv.Itoa(os.Getpid()) + ":" + util.UUID()
+	}
+	if backend == nil {
+		if backend, err = newBackend(cfg.Backend); err != nil {
+			return err
+		}
+		if backend != nil && cfg.Worker {
+			if err = backend.Subscribe(ctx, wakeup); err != nil {
+				return errors.Wrapf(err, "failed to subscribe jobqueue backend %q", cfg.Backend)
+			}
+		}
+	}
+	if cfg.Retention > 0 && !inited {
+		cronjob.Register(Cleanup, "0 30 * * * *", "cleanup job queue")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, h := range handlers {
+		startWorker(h.config.Queue)
+	}
+
+	inited = true
+	return nil
+}
+
+// Register registers the handler of the jobs of the type, the payload is decoded from json.
+// It can be called at any point before or after Init, the worker of the queue is started
+// once the first handler of the queue registered.
+func Register[T any](typ string, fn func(context.Context, T) error, config ...Config) {
+	var cfg Config
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if len(cfg.Queue) == 0 {
+		cfg.Queue = DefaultQueue
+	}
+	h := &handler{
+		typ:     typ,
+		payload: reflect.TypeFor[T](),
+		config:  cfg,
+		fn: func(ctx context.Context, payload string) error {
+			var v T
+			if err := json.Unmarshal([]byte(payload), &v); err != nil {
+				return Permanent(errors.Wrap(err, "invalid payload"))
+			}
+			return fn(ctx, v)
+		},
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	handlers[typ] = h
+	if inited {
+		startWorker(cfg.Queue)
+	}
+}
+
+// Enqueue persists the job of the type, the payload is encoded to json. The job is run
+// by the worker of any replica which registered the handler of the type.
+func Enqueue[T any](ctx context.Context, typ string, payload T, options ...Options) (*model.Job, error) {
+	var opt Options
+	if len(options) > 0 {
+		opt = options[0]
+	}
+	if database.DB == nil {
+		return nil, errors.New("database is not initialized")
+	}
+
+	queue := opt.Queue
+	mu.Lock()
+	h := handlers[typ]
+	mu.Unlock()
+	if h != nil {
+		if t := reflect.TypeFor[T](); t != h.payload {
+			return nil, errors.Newf("payload type %s of job %q mismatches the handler %s", t, h.typ, h.payload)
+		}
+		if len(queue) == 0 {
+			queue = h.config.Queue
+		}
+	}
+	if len(queue) == 0 {
+		queue = DefaultQueue
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode job payload")
+	}
+	now := time.Now()
+	runAt := opt.RunAt
+	if runAt.IsZero() {
+		runAt = now.Add(opt.Delay)
+	}
+	job := &model.Job{
+		Queue:    queue,
+		Type:     typ,
+		Payload:  string(data),
+		Priority: opt.Priority,
+		State:    model.JobStatePending,
+		RunAt:    runAt.UnixMilli(),
+	}
+	job.SetID()
+	// Without transaction, the insert is bound to the caller context.
+	tx := opt.Tx
+	if tx == nil {
+		tx = database.DB.WithContext(ctx)
+	}
+	if err = database.Database[*model.Job](nil).WithTx(tx).Create(job); err != nil {
+		return nil, errors.Wrap(err, "failed to enqueue job")
+	}
+	if opt.Tx == nil && !runAt.After(now) {
+		notify(ctx, queue)
+	}
+	return job, nil
+}
+
+// Permanent wraps the error returned by the handler to dead-letter the job without retry.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err}
+}
+
+type permanentError struct{ error }
+
+func (e *permanentError) Unwrap() error { return e.error }
+
+// Stop stops claiming the jobs and cancels the context of the running ones, then waits
+// for them to finish within config "jobqueue.drain_timeout". The interrupted jobs are
+// run again without counting the attempts. It's registered to bootstrap.Cleanup.
+func Stop() {
+	mu.Lock()
+	stopped = true
+	mu.Unlock()
+	cancel()
+	if !jobrun.Wait(&inflight, config.App.JobQueue.DrainTimeout) && log != nil {
+		log.Warnz("jobs not finished within the drain timeout", zap.Duration("timeout", config.App.JobQueue.DrainTimeout))
+	}
+	if backend != nil {
+		backend.Close()
+	}
+}
+
+// Cleanup removes the finished jobs older than config "jobqueue.retention".
+func Cleanup() error {
+	retention := config.App.JobQueue.Retention
+	if retention <= 0 || database.DB == nil {
+		return nil
+	}
+	return database.DB.Unscoped().Where("state = ? AND updated_at < ?", model.JobStateDone, time.Now().Add(-retention)).Delete(new(model.Job)).Error
+}
+
+// notify wakes up the workers of the queue, both the local ones and the ones of the other replicas.
+func notify(ctx context.Context, queue string) {
+	wakeup(queue)
+	if backend != nil {
+		if err := backend.Notify(ctx, queue); err != nil && log != nil {
+			log.Warnz(fmt.Sprintf("failed to notify job queue: %s", err), zap.String("queue", queue))
+		}
+	}
+}
+
+func wakeup(queue string) {
+	mu.Lock()
+	w := workers[queue]
+	mu.Unlock()
+	if w != nil {
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// track tracks the job starting, it returns false if stopped.
+func track() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	if stopped {
+		return false
+	}
+	inflight.Add(1)
+	return true
+}
+
+// worker claims and runs the jobs of the queue, at most config "jobqueue.concurrency"
+// jobs at the same time.
+type worker struct {
+	queue string
+	wake  chan struct{}
+}
+
+// startWorker starts the worker of the queue if not started, the caller must hold mu.
+func startWorker(queue string) {
+	if !config.App.JobQueue.Worker || stopped {
+		return
+	}
+	if _, ok := workers[queue]; ok {
+		return
+	}
+	concurrency := config.App.JobQueue.Concurrency
+	if n := config.App.JobQueue.Queues[queue]; n > 0 {
+		concurrency = n
+	}
+	concurrency = max(concurrency, 1)
+
+	w := &worker{queue: queue, wake: make(chan struct{}, 1)}
+	workers[queue] = w
+	go w.loop(concurrency)
+	log.Infoz("job queue worker started", zap.String("queue", queue), zap.Int("concurrency", concurrency))
+}
+
+func (w *worker) loop(concurrency int) {
+	sem := make(chan struct{}, concurrency)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sem <- struct{}{}:
+		}
+		if !track() {
+			return
+		}
+		job, h, err := w.claim()
+		if err != nil && ctx.Err() == nil {
+			log.Errorz(fmt.Sprintf("failed to claim job: %s", err), zap.String("queue", w.queue))
+		}
+		if job == nil {
+			inflight.Done()
+			<-sem
+			select {
+			case <-ctx.Done():
+				return
+			case <-w.wake:
+			case <-time.After(config.App.JobQueue.PollInterval):
+			}
+			continue
+		}
+		go func() {
+			defer func() {
+				<-sem
+				inflight.Done()
+			}()
+			run(job, h)
+		}()
+	}
+}
+
+// claim locks the due job of the highest priority, including the running job whose lock expired.
+func (w *worker) claim() (*model.Job, *handler, error) {
+	mu.Lock()
+	hs := make(map[string]*handler)
+	types := make([]string, 0)
+	for typ, h := range handlers {
+		if h.config.Queue == w.queue {
+			hs[typ] = h
+			types = append(types, typ)
+		}
+	}
+	mu.Unlock()
+	if len(types) == 0 {
+		return nil, nil, nil
+	}
+
+	now := time.Now()
+	db := database.DB.WithContext(ctx)
+	jobs := make([]*model.Job, 0)
+	if err := db.Where("queue = ? AND type IN ? AND ((state = ? AND run_at <= ?) OR (state = ? AND locked_until < ?))",
+		w.queue, types, model.JobStatePending, now.UnixMilli(), model.JobStateRunning, now.UnixMilli()).
+		Order("priority DESC").Order("run_at").Limit(claimBatch).Find(&jobs).Error; err != nil {
+		return nil, nil, err
+	}
+	lockedUntil := now.Add(config.App.JobQueue.Lease).UnixMilli()
+	for _, job := range jobs {
+		// The job is claimed by only one worker, the others see the state or lock changed.
+		res := db.Model(new(model.Job)).
+			Where("id = ? AND state = ? AND locked_until = ?", job.ID, job.State, job.LockedUntil).
+			Updates(map[string]any{
+				"state":        model.JobStateRunning,
+				"locked_by":    owner,
+				"locked_until": lockedUntil,
+				"attempts":     gorm.Expr("attempts + 1"),
+			})
+		if res.Error != nil {
+			return nil, nil, res.Error
+		}
+		if res.RowsAffected == 0 {
+			continue
+		}
+		job.State, job.LockedBy, job.LockedUntil = model.JobStateRunning, owner, lockedUntil
+		job.Attempts++
+		return job, hs[job.Type], nil
+	}
+	return nil, nil, nil
+}
+
+func run(job *model.Job, h *handler) {
+	cfg := config.App.JobQueue
+	begin := time.Now()
+	log.Infoz("starting job", zap.String("id", job.ID), zap.String("queue", job.Queue), zap.String("type", job.Type), zap.Int("attempt", job.Attempts))
+
+	hbCtx, stopHeartbeat := context.WithCancel(ctx)
+	go heartbeat(hbCtx, job.ID)
+	err := jobrun.Policy{Timeout: h.config.Timeout}.Call(ctx, func(ctx context.Context) error { return h.fn(ctx, job.Payload) }, nil)
+	stopHeartbeat()
+	cost := time.Since(begin)
+
+	retries := h.config.Retries
+	if retries == 0 {
+		retries = cfg.MaxRetries
+	}
+	now := time.Now()
+	updates := map[string]any{"locked_by": "", "last_error": ""}
+	switch {
+	case err == nil:
+		updates["state"], updates["finished_at"] = model.JobStateDone, model.GormTime(now)
+		log.Infoz("finished job", zap.String("id", job.ID), zap.String("type", job.Type), zap.String("cost", util.FormatDurationSmart(cost)))
+	case ctx.Err() != nil:
+		// Interrupted by the shutdown, run it again later without counting the attempt.
+		updates["state"], updates["run_at"], updates["attempts"], updates["last_error"] = model.JobStatePending, now.UnixMilli(), job.Attempts-1, err.Error()
+		log.Warnz(fmt.Sprintf("job interrupted: %s", err), zap.String("id", job.ID), zap.String("type", job.Type))
+	case errors.HasType(err, (*permanentError)(nil)) || job.Attempts > retries:
+		updates["state"], updates["finished_at"], updates["last_error"] = model.JobStateDead, model.GormTime(now), err.Error()
+		log.Errorz(fmt.Sprintf("job dead-lettered: %s", err), zap.String("id", job.ID), zap.String("type", job.Type), zap.Int("attempts", job.Attempts))
+	default:
+		delay := backoff(h.config, job.Attempts)
+		updates["state"], updates["run_at"], updates["last_error"] = model.JobStatePending, now.Add(delay).UnixMilli(), err.Error()
+		log.Warnz(fmt.Sprintf("job failed, retry: %s", err), zap.String("id", job.ID), zap.String("type", job.Type), zap.Int("attempt", job.Attempts), zap.String("delay", util.FormatDurationSmart(delay)))
+	}
+
+	status := model.JobStatusSuccess
+	if err != nil {
+		status = model.JobStatusFailure
+	}
+	if metrics.QueueJobsTotal != nil {
+		metrics.QueueJobsTotal.WithLabelValues(job.Queue, job.Type, status).Inc()
+		metrics.QueueJobDuration.WithLabelValues(job.Queue, job.Type).Observe(cost.Seconds())
+	}
+
+	// The context may be canceled, the result is still recorded.
+	res := database.DB.Model(new(model.Job)).Where("id = ? AND locked_by = ?", job.ID, owner).Updates(updates)
+	if res.Error != nil {
+		log.Errorz(fmt.Sprintf("failed to update job: %s", res.Error), zap.String("id", job.ID))
+	} else if res.RowsAffected == 0 {
+		log.Warnz("job lock lost, it's claimed by the other worker", zap.String("id", job.ID))
+	}
+}
+
+// heartbeat renews the lock of the running job until ctx done.
+func heartbeat(ctx context.Context, id string) {
+	lease := config.App.JobQueue.Lease
+	ticker := time.NewTicker(lease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := database.DB.WithContext(ctx).Model(new(model.Job)).
+				Where("id = ? AND locked_by = ?", id, owner).
+				Update("locked_until", time.Now().Add(lease).UnixMilli()).Error
+			if err != nil && ctx.Err() == nil {
+				log.Warnz(fmt.Sprintf("failed to renew job lock: %s", err), zap.String("id", id))
+			}
+		}
+	}
+}
+
+// backoff returns the delay before the retry after the attempts, it doubles every retry.
+func backoff(cfg Config, attempts int) time.Duration {
+	delay, maxDelay := cfg.Backoff, cfg.MaxBackoff
+	if delay <= 0 {
+		delay = config.App.JobQueue.Backoff
+	}
+	if maxDelay <= 0 {
+		maxDelay = config.App.JobQueue.MaxBackoff
+	}
+	for i := 1; i < attempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxDelay)
+}
@@ -0,0 +1,175 @@
+package jobqueue_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/forbearing/gst/bootstrap"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/jobqueue"
+	"github.com/forbearing/gst/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	os.Setenv(config.LOGGER_DIR, "/tmp/test_jobqueue")
+	os.Setenv(config.DATABASE_TYPE, string(config.DBSqlite))
+	os.Setenv(config.SQLITE_IS_MEMORY, "false")
+	os.Setenv(config.SQLITE_PATH, "/tmp/test_jobqueue.db")
+	os.Setenv(config.JOBQUEUE_POLL_INTERVAL, "50ms")
+	os.Setenv(config.JOBQUEUE_BACKOFF, "10ms")
+	_ = os.Remove("/tmp/test_jobqueue.db")
+
+	if err := bootstrap.Bootstrap(); err != nil {
+		panic(err)
+	}
+}
+
+type email struct {
+	To string `json:"to"`
+}
+
+func waitState(t *testing.T, id, state string) *model.Job {
+	t.Helper()
+	var job *model.Job
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = jobqueue.Get(context.Background(), id)
+		return err == nil && job.State == state
+	}, 5*time.Second, 20*time.Millisecond)
+	return job
+}
+
+func TestEnqueue(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("typed handler", func(t *testing.T) {
+		got := make(chan email, 1)
+		jobqueue.Register("email", func(_ context.Context, e email) error { got <- e; return nil })
+
+		_, err := jobqueue.Enqueue(ctx, "email", "not an email")
+		assert.Error(t, err)
+
+		job, err := jobqueue.Enqueue(ctx, "email", email{To: "user@example.com"})
+		require.NoError(t, err)
+		assert.Equal(t, jobqueue.DefaultQueue, job.Queue)
+		assert.Equal(t, email{To: "user@example.com"}, <-got)
+		job = waitState(t, job.ID, model.JobStateDone)
+		assert.Equal(t, 1, job.Attempts)
+	})
+
+	t.Run("delay", func(t *testing.T) {
+		ran := make(chan time.Time, 1)
+		jobqueue.Register("delay", func(context.Context, struct{}) error { ran <- time.Now(); return nil })
+
+		begin := time.Now()
+		job, err := jobqueue.Enqueue(ctx, "delay", struct{}{}, jobqueue.Options{Delay: 300 * time.Millisecond})
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, (<-ran).Sub(begin), 300*time.Millisecond)
+		waitState(t, job.ID, model.JobStateDone)
+	})
+
+	t.Run("transaction", func(t *testing.T) {
+		jobqueue.Register("tx", func(context.Context, struct{}) error { return nil })
+
+		// The job enqueued within the transaction rolled back never exists.
+		var rolledBack *model.Job
+		err := database.Database[*model.Job](nil).TransactionFunc(func(tx any) error {
+			var err error
+			if rolledBack, err = jobqueue.Enqueue(ctx, "tx", struct{}{}, jobqueue.Options{Tx: tx}); err != nil {
+				return err
+			}
+			return errors.New("rollback")
+		})
+		require.Error(t, err)
+		_, err = jobqueue.Get(ctx, rolledBack.ID)
+		require.Error(t, err)
+
+		var committed *model.Job
+		require.NoError(t, database.Database[*model.Job](nil).TransactionFunc(func(tx any) error {
+			var err error
+			committed, err = jobqueue.Enqueue(ctx, "tx", struct{}{}, jobqueue.Options{Tx: tx})
+			return err
+		}))
+		waitState(t, committed.ID, model.JobStateDone)
+	})
+
+	t.Run("priority", func(t *testing.T) {
+		// Enqueue before the worker of the queue started.
+		config.App.JobQueue.Queues = map[string]int{"ordered": 1}
+		ids := make([]string, 0)
+		for _, p := range []int{1, 3, 2} {
+			job, err := jobqueue.Enqueue(ctx, "priority", p, jobqueue.Options{Queue: "ordered", Priority: p})
+			require.NoError(t, err)
+			ids = append(ids, job.ID)
+		}
+
+		var mu sync.Mutex
+		order := make([]int, 0)
+		jobqueue.Register("priority", func(_ context.Context, p int) error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, p)
+			return nil
+		}, jobqueue.Config{Queue: "ordered"})
+		for _, id := range ids {
+			waitState(t, id, model.JobStateDone)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, []int{3, 2, 1}, order)
+	})
+}
+
+func TestDeadLetter(t *testing.T) {
+	ctx := context.Background()
+	jobqueue.Register("flaky", func(_ context.Context, fail bool) error {
+		if fail {
+			return errors.New("boom")
+		}
+		return nil
+	}, jobqueue.Config{Queue: "flaky", Retries: 2})
+	jobqueue.Register("poison", func(context.Context, string) error {
+		return jobqueue.Permanent(errors.New("poison"))
+	}, jobqueue.Config{Queue: "flaky"})
+
+	job, err := jobqueue.Enqueue(ctx, "flaky", true)
+	require.NoError(t, err)
+	job = waitState(t, job.ID, model.JobStateDead)
+	assert.Equal(t, 3, job.Attempts)
+	assert.Equal(t, "boom", job.LastError)
+
+	poison, err := jobqueue.Enqueue(ctx, "poison", "")
+	require.NoError(t, err)
+	poison = waitState(t, poison.ID, model.JobStateDead)
+	assert.Equal(t, 1, poison.Attempts)
+
+	dead, total, err := jobqueue.List(ctx, jobqueue.Filter{Queue: "flaky", State: model.JobStateDead})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Len(t, dead, 2)
+
+	stats, err := jobqueue.Stats(ctx)
+	require.NoError(t, err)
+	for _, s := range stats {
+		if s.Queue == "flaky" {
+			assert.Equal(t, int64(2), s.Dead)
+		}
+	}
+
+	// The retried dead job runs again from the first attempt.
+	assert.Error(t, jobqueue.Retry(ctx, "unknown"))
+	require.NoError(t, jobqueue.Retry(ctx, poison.ID))
+	poison = waitState(t, poison.ID, model.JobStateDead)
+	assert.Equal(t, 1, poison.Attempts)
+
+	require.NoError(t, jobqueue.Delete(ctx, job.ID))
+	_, err = jobqueue.Get(ctx, job.ID)
+	assert.ErrorIs(t, err, jobqueue.ErrNotFound)
+}
@@ -0,0 +1,145 @@
+package jobqueue
+
+import (
+	"context"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/model"
+	"gorm.io/gorm"
+)
+
+// ErrNotFound is returned if no job matched.
+var ErrNotFound = errors.New("job not found")
+
+// Filter filters the jobs listed, the empty field matches all.
+type Filter struct {
+	Queue string
+	Type  string
+	State string
+	Page  int
+	Size  int
+}
+
+// QueueStats is the number of the jobs of a queue by state.
+type QueueStats struct {
+	Queue   string `json:"queue"`
+	Pending int64  `json:"pending"`
+	Running int64  `json:"running"`
+	Done    int64  `json:"done"`
+	Dead    int64  `json:"dead"`
+}
+
+// Get returns the job by id.
+func Get(ctx context.Context, id string) (*model.Job, error) {
+	job := new(model.Job)
+	if err := database.DB.WithContext(ctx).Where("id = ?", id).First(job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.Wrapf(ErrNotFound, "job %q", id)
+		}
+		return nil, err
+	}
+	return job, nil
+}
+
+// List lists the jobs by the filter, the newest first. The dead letters are the jobs
+// in state model.JobStateDead.
+func List(ctx context.Context, filter Filter) ([]*model.Job, int64, error) {
+	db := database.DB.WithContext(ctx).Model(new(model.Job))
+	if len(filter.Queue) > 0 {
+		db = db.Where("queue = ?", filter.Queue)
+	}
+	if len(filter.Type) > 0 {
+		db = db.Where("type = ?", filter.Type)
+	}
+	if len(filter.State) > 0 {
+		db = db.Where("state = ?", filter.State)
+	}
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if filter.Size > 0 {
+		db = db.Limit(filter.Size).Offset(max(filter.Page-1, 0) * filter.Size)
+	}
+	jobs := make([]*model.Job, 0)
+	if err := db.Order("created_at DESC").Find(&jobs).Error; err != nil {
+		return nil, 0, err
+	}
+	return jobs, total, nil
+}
+
+// Stats returns the number of the jobs of each queue by state.
+func Stats(ctx context.Context) ([]QueueStats, error) {
+	var rows []struct {
+		Queue string
+		State string
+		Count int64
+	}
+	if err := database.DB.WithContext(ctx).Model(new(model.Job)).
+		Select("queue, state, COUNT(*) AS count").Group("queue, state").Order("queue").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	stats := make([]QueueStats, 0)
+	for _, row := range rows {
+		if len(stats) == 0 || stats[len(stats)-1].Queue != row.Queue {
+			stats = append(stats, QueueStats{Queue: row.Queue})
+		}
+		s := &stats[len(stats)-1]
+		switch row.State {
+		case model.JobStatePending:
+			s.Pending = row.Count
+		case model.JobStateRunning:
+			s.Running = row.Count
+		case model.JobStateDone:
+			s.Done = row.Count
+		case model.JobStateDead:
+			s.Dead = row.Count
+		}
+	}
+	return stats, nil
+}
+
+// Retry requeues the dead job to run now, its attempts are reset.
+func Retry(ctx context.Context, id string) error {
+	job, err := Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if job.State != model.JobStateDead {
+		return errors.Newf("job %q is %s, only the dead job can be retried", id, job.State)
+	}
+	res := database.DB.WithContext(ctx).Model(new(model.Job)).
+		Where("id = ? AND state = ?", id, model.JobStateDead).
+		Updates(map[string]any{
+			"state":       model.JobStatePending,
+			"attempts":    0,
+			"run_at":      time.Now().UnixMilli(),
+			"finished_at": nil,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errors.Wrapf(ErrNotFound, "dead job %q", id)
+	}
+	notify(ctx, job.Queue)
+	return nil
+}
+
+// Delete deletes the job which is not running, eg: cancels the pending job or
+// discards the dead letter.
+func Delete(ctx context.Context, id string) error {
+	res := database.DB.WithContext(ctx).Unscoped().
+		Where("id = ? AND state <> ?", id, model.JobStateRunning).
+		Delete(new(model.Job))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errors.Wrapf(ErrNotFound, "job %q not found or running", id)
+	}
+	return nil
+}
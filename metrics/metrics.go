@@ -30,6 +30,10 @@ var (
 	CacheHit              *prometheus.CounterVec
 	CacheMiss             *prometheus.CounterVec
 	QueueSize             prometheus.Gauge
+	JobRunsTotal          *prometheus.CounterVec
+	JobRunDuration        *prometheus.HistogramVec
+	QueueJobsTotal        *prometheus.CounterVec
+	QueueJobDuration      *prometheus.HistogramVec
 )
 
 func Init() error {
@@ -146,6 +150,33 @@ func Init() error {
 		Help:      "Current size of the task queue",
 	})
 
+	JobRunsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Subsystem: SUBSYSTEM,
+		Name:      "job_runs_total",
+		Help:      "Total number of cronjob and task runs",
+	}, []string{"kind", "name", "status"})
+	JobRunDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Subsystem: SUBSYSTEM,
+		Name:      "job_run_duration_seconds",
+		Help:      "Cronjob and task run latencies in seconds",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"kind", "name"})
+	QueueJobsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Subsystem: SUBSYSTEM,
+		Name:      "queue_jobs_total",
+		Help:      "Total number of job queue runs",
+	}, []string{"queue", "type", "status"})
+	QueueJobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Subsystem: SUBSYSTEM,
+		Name:      "queue_job_duration_seconds",
+		Help:      "Job queue run latencies in seconds",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"queue", "type"})
+
 	errs := make([]error, 0)
 	errs = append(errs, prometheus.Register(State))
 	errs = append(errs, prometheus.Register(Uptime))
@@ -163,6 +194,10 @@ func Init() error {
 	errs = append(errs, prometheus.Register(CacheHit))
 	errs = append(errs, prometheus.Register(CacheMiss))
 	errs = append(errs, prometheus.Register(QueueSize))
+	errs = append(errs, prometheus.Register(JobRunsTotal))
+	errs = append(errs, prometheus.Register(JobRunDuration))
+	errs = append(errs, prometheus.Register(QueueJobsTotal))
+	errs = append(errs, prometheus.Register(QueueJobDuration))
 
 	errs = append(errs, prometheus.Register(collectors.NewBuildInfoCollector()))
 	errs = append(errs, prometheus.Register(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{Namespace: NAMESPACE})))
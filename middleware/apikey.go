@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/forbearing/gst/authn/apikey"
+	. "github.com/forbearing/gst/response"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// APIKeyAuth authenticates the request by the API key in header "X-API-Key" or
+// "Authorization: Bearer <key>", and sets the same context as JwtAuth, so Authz
+// enforces the permissions of the key owner. The scopes of the key are checked too.
+//
+// The request without API key is handled by fallback, eg: JwtAuth, or rejected if no fallback.
+//
+// Example:
+//
+//	middleware.RegisterAuth(middleware.APIKeyAuth(middleware.JwtAuth()))
+func APIKeyAuth(fallback ...gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("X-API-Key")
+		if len(key) == 0 {
+			if token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok && apikey.IsKey(token) {
+				key = token
+			}
+		}
+		if len(key) == 0 {
+			if len(fallback) > 0 && fallback[0] != nil {
+				fallback[0](c)
+				return
+			}
+			ResponseJSON(c, NewCode(CodeUnauthorized, http.StatusUnauthorized, "api key required"))
+			c.Abort()
+			return
+		}
+
+		k, user, err := apikey.Authenticate(types.NewDatabaseContext(c), key, c.ClientIP())
+		if err != nil {
+			zap.S().Warnw("api key authentication failed", "key_id", keyID(key), "error", err)
+			ResponseJSON(c, NewCode(CodeUnauthorized, http.StatusUnauthorized, apikey.ErrInvalidKey.Error()))
+			c.Abort()
+			return
+		}
+		if !apikey.Allow(k, c.Request.Method, c.Request.URL.Path) {
+			ResponseJSON(c, CodeForbidden.WithErr(apikey.ErrInvalidScope))
+			c.Abort()
+			return
+		}
+
+		c.Set(consts.CTX_USER_ID, user.ID)
+		c.Set(consts.CTX_USERNAME, user.Name)
+		c.Set(consts.CTX_TENANT_ID, user.TenantID)
+		c.Next()
+	}
+}
+
+// keyID returns the non-secret id part of the key for logging.
+func keyID(key string) string {
+	id, _, _ := strings.Cut(strings.TrimPrefix(key, apikey.Prefix), "_")
+	return id
+}
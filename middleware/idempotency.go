@@ -0,0 +1,263 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/cache"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/provider/redis"
+	. "github.com/forbearing/gst/response"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	idempotencyPrefix    = "idempotency:"
+	idempotencyMaxKeyLen = 255
+	// ctxIdempotencyKey marks the request has been handled by idempotency middleware,
+	// so the controller won't handle it again.
+	ctxIdempotencyKey = "idempotency_key"
+)
+
+// idempotentResponse is the stored response replayed for the same idempotency key.
+type idempotentResponse struct {
+	Fingerprint string      `json:"fingerprint"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header"`
+	Body        []byte      `json:"body"`
+}
+
+// idempotencyStore stores the responses and the in-flight locks of idempotency keys.
+type idempotencyStore interface {
+	Get(key string) (*idempotentResponse, error)
+	Set(key string, rsp *idempotentResponse, ttl time.Duration) error
+	Lock(key string, ttl time.Duration) (bool, error)
+	Unlock(key string) error
+}
+
+var (
+	idemStore     idempotencyStore
+	idemStoreOnce sync.Once
+)
+
+func getIdempotencyStore() idempotencyStore {
+	idemStoreOnce.Do(func() {
+		if config.App.Server.Idempotency.Backend == config.IdempotencyRedis {
+			if config.App.Redis.Enable {
+				idemStore = new(redisIdempotencyStore)
+				return
+			}
+			zap.S().Warn("idempotency backend is redis but redis is disabled, fallback to memory")
+		}
+		idemStore = &memoryIdempotencyStore{locks: make(map[string]time.Time)}
+	})
+	return idemStore
+}
+
+// Idempotency deduplicates the non-idempotent requests carrying the "Idempotency-Key" header, eg: POST.
+//
+// The first request with a key is processed and its response is stored for the configured window,
+// the retries with the same key replay the stored response with header "Idempotency-Replayed: true"
+// instead of being processed again. Behaviors:
+//   - The concurrent request with the same key is rejected with 409 Conflict until the first one finished.
+//   - The same key with a different request body is rejected with 422 Unprocessable Entity.
+//   - The 5xx responses are not stored so that the request can be retried.
+//   - The keys are scoped by the current user, http method and request path.
+//
+// CreateFactory and CreateManyFactory apply it automatically, register it by Register
+// to make all POST/PATCH requests idempotent.
+func Idempotency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idempotent(c, c.Next)
+	}
+}
+
+// IdempotentHandler wraps the handler to deduplicate the requests carrying the
+// "Idempotency-Key" header, see Idempotency.
+func IdempotentHandler(handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idempotent(c, func() { handler(c) })
+	}
+}
+
+func idempotent(c *gin.Context, next func()) {
+	cfg := config.App.Server.Idempotency
+	key := strings.TrimSpace(c.GetHeader(consts.HEADER_IDEMPOTENCY_KEY))
+	if !cfg.Enable || len(key) == 0 || c.GetBool(ctxIdempotencyKey) {
+		next()
+		return
+	}
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		next()
+		return
+	}
+	if len(key) > idempotencyMaxKeyLen {
+		ResponseJSON(c, CodeInvalidParam.WithErr(errors.Newf("%s exceeds max length %d", consts.HEADER_IDEMPOTENCY_KEY, idempotencyMaxKeyLen)))
+		c.Abort()
+		return
+	}
+	c.Set(ctxIdempotencyKey, true)
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		ResponseJSON(c, CodeBadRequest.WithErr(err))
+		c.Abort()
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	sum := sha256.Sum256(body)
+	fingerprint := hex.EncodeToString(sum[:])
+
+	user := c.GetString(consts.CTX_USER_ID)
+	if len(user) == 0 {
+		user = c.ClientIP()
+	}
+	storeKey := idempotencyPrefix + user + ":" + c.Request.Method + ":" + c.Request.URL.Path + ":" + key
+	store := getIdempotencyStore()
+
+	if replayIdempotentResponse(c, store, storeKey, fingerprint) {
+		return
+	}
+	locked, err := store.Lock(storeKey, cfg.LockTTL)
+	if err != nil {
+		// The idempotency is best effort, the request is still processed if store unavailable.
+		zap.S().Errorw("failed to lock idempotency key", "key", key, "error", err)
+		next()
+		return
+	}
+	if !locked {
+		// The previous request may just finished between Get and Lock.
+		if replayIdempotentResponse(c, store, storeKey, fingerprint) {
+			return
+		}
+		ResponseJSON(c, CodeIdempotencyInProgress)
+		c.Abort()
+		return
+	}
+	defer func() {
+		if err := store.Unlock(storeKey); err != nil {
+			zap.S().Errorw("failed to unlock idempotency key", "key", key, "error", err)
+		}
+	}()
+
+	w := &idempotencyWriter{ResponseWriter: c.Writer}
+	c.Writer = w
+	next()
+	c.Writer = w.ResponseWriter
+
+	if status := w.Status(); status < http.StatusInternalServerError {
+		if err := store.Set(storeKey, &idempotentResponse{
+			Fingerprint: fingerprint,
+			Status:      status,
+			Header:      w.Header().Clone(),
+			Body:        w.body.Bytes(),
+		}, cfg.TTL); err != nil {
+			zap.S().Errorw("failed to store idempotent response", "key", key, "error", err)
+		}
+	}
+}
+
+// replayIdempotentResponse writes the stored response and returns true if the key was completed.
+func replayIdempotentResponse(c *gin.Context, store idempotencyStore, key, fingerprint string) bool {
+	rsp, err := store.Get(key)
+	if err != nil || rsp == nil {
+		if err != nil && !errors.Is(err, types.ErrEntryNotFound) {
+			zap.S().Errorw("failed to get idempotent response", "key", key, "error", err)
+		}
+		return false
+	}
+	if rsp.Fingerprint != fingerprint {
+		ResponseJSON(c, CodeIdempotencyMismatch)
+		c.Abort()
+		return true
+	}
+	for k, v := range rsp.Header {
+		for _, item := range v {
+			c.Writer.Header().Add(k, item)
+		}
+	}
+	c.Header(consts.HEADER_IDEMPOTENCY_REPLAYED, "true")
+	c.Data(rsp.Status, rsp.Header.Get("Content-Type"), rsp.Body)
+	c.Abort()
+	return true
+}
+
+// idempotencyWriter captures the response body.
+type idempotencyWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *idempotencyWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *idempotencyWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// memoryIdempotencyStore stores the responses in local cache,
+// it only deduplicates the requests within the current process.
+type memoryIdempotencyStore struct {
+	mu    sync.Mutex
+	locks map[string]time.Time
+}
+
+func (s *memoryIdempotencyStore) Get(key string) (*idempotentResponse, error) {
+	return cache.ExpirableCache[*idempotentResponse]().Get(key)
+}
+
+func (s *memoryIdempotencyStore) Set(key string, rsp *idempotentResponse, ttl time.Duration) error {
+	return cache.ExpirableCache[*idempotentResponse]().Set(key, rsp, ttl)
+}
+
+func (s *memoryIdempotencyStore) Lock(key string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if expire, ok := s.locks[key]; ok && (ttl <= 0 || now.Before(expire)) {
+		return false, nil
+	}
+	s.locks[key] = now.Add(ttl)
+	return true, nil
+}
+
+func (s *memoryIdempotencyStore) Unlock(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.locks, key)
+	return nil
+}
+
+// redisIdempotencyStore stores the responses in redis,
+// it deduplicates the requests across all the instances.
+type redisIdempotencyStore struct{}
+
+func (redisIdempotencyStore) Get(key string) (*idempotentResponse, error) {
+	return redis.Cache[*idempotentResponse]().Get(key)
+}
+
+func (redisIdempotencyStore) Set(key string, rsp *idempotentResponse, ttl time.Duration) error {
+	return redis.Cache[*idempotentResponse]().Set(key, rsp, ttl)
+}
+
+func (redisIdempotencyStore) Lock(key string, ttl time.Duration) (bool, error) {
+	return redis.SetNX(key+":lock", 1, ttl)
+}
+
+func (redisIdempotencyStore) Unlock(key string) error {
+	return redis.Remove(key + ":lock")
+}
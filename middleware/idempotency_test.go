@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/types/consts"
+	"github.com/forbearing/gst/util"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotency(t *testing.T) {
+	config.App.Cache.Capacity = 1000
+	config.App.Server.Idempotency = config.Idempotency{Enable: true, TTL: time.Minute, LockTTL: time.Minute}
+	gin.SetMode(gin.TestMode)
+
+	// The stored responses are shared by the whole process.
+	prefix := util.UUID()
+	var calls atomic.Int32
+	entered, release := make(chan struct{}), make(chan struct{})
+	r := gin.New()
+	r.POST("/users", IdempotentHandler(func(c *gin.Context) {
+		if c.GetHeader("X-Block") == "true" {
+			close(entered)
+			<-release
+		}
+		c.JSON(http.StatusCreated, gin.H{"call": calls.Add(1)})
+	}))
+	do := func(key, body string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+		if len(key) > 0 {
+			req.Header.Set(consts.HEADER_IDEMPOTENCY_KEY, prefix+key)
+		}
+		if len(header) == 2 {
+			req.Header.Set(header[0], header[1])
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// The retry with the same key replays the stored response.
+	first := do("key-1", `{"name":"a"}`)
+	assert.Equal(t, http.StatusCreated, first.Code)
+	replayed := do("key-1", `{"name":"a"}`)
+	assert.Equal(t, http.StatusCreated, replayed.Code)
+	assert.Equal(t, first.Body.String(), replayed.Body.String())
+	assert.Equal(t, "true", replayed.Header().Get(consts.HEADER_IDEMPOTENCY_REPLAYED))
+	assert.Equal(t, int32(1), calls.Load())
+
+	// The same key with different body is rejected.
+	assert.Equal(t, http.StatusUnprocessableEntity, do("key-1", `{"name":"b"}`).Code)
+
+	// The requests without key or with other key are always processed.
+	assert.Equal(t, http.StatusCreated, do("", `{"name":"a"}`).Code)
+	assert.Equal(t, http.StatusCreated, do("key-2", `{"name":"a"}`).Code)
+	assert.Equal(t, int32(3), calls.Load())
+
+	// The concurrent duplicate is rejected while the first one is in flight.
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- do("key-3", `{}`, "X-Block", "true") }()
+	<-entered
+	assert.Equal(t, http.StatusConflict, do("key-3", `{}`).Code)
+	close(release)
+	assert.Equal(t, http.StatusCreated, (<-done).Code)
+	assert.Equal(t, http.StatusCreated, do("key-3", `{}`).Code)
+	assert.Equal(t, int32(4), calls.Load())
+}
@@ -11,7 +11,8 @@ import (
 
 // JwtAuth 效果如下:
 // 1.重复登录之后，会刷新 accessToken, refreshToken, 之后老的 accessToken 是失效
-// 2.换浏览器、换操作系统都需要重新登录，重新登录之后会挤掉其他设备、浏览器的登录
+// 2.每个设备、浏览器有独立的 session, 换浏览器、换操作系统都需要重新登录
+// 3.被吊销的 session 和 token (jwt.RevokeSession, jwt.RevokeTokens) 在所有节点上都会被拒绝
 func JwtAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		accessToken, claims, err := jwt.ParseTokenFromHeader(c.Request.Header)
@@ -31,6 +32,7 @@ func JwtAuth() gin.HandlerFunc {
 		// TODO: 将 user id 和 username 定义成变量/常量
 		c.Set(consts.CTX_USER_ID, claims.UserID)
 		c.Set(consts.CTX_USERNAME, claims.Username)
+		c.Set(consts.CTX_TENANT_ID, claims.TenantID)
 		c.Set(consts.CTX_SESSION_ID, c.GetHeader("X-Session-Id"))
 		c.Next()
 	}
@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"net"
+	"strings"
+
+	"github.com/forbearing/gst/authz/rbac"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/logger"
+	. "github.com/forbearing/gst/response"
+	"github.com/forbearing/gst/types/consts"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Tenant resolves the tenant of the request and sets it to the context, so the database
+// isolates the types.TenantScoped models and TenantAuthz enforces the permissions by it.
+// It must be registered after JwtAuth or APIKeyAuth.
+//
+// The tenant is resolved from:
+//  1. The tenant claim of the login user, the user bound to a tenant can't access other tenants.
+//  2. The request header of config "auth.tenant_header", default "X-Tenant-ID".
+//  3. The subdomain of config "auth.tenant_domain", eg: "acme.example.com" -> "acme".
+//
+// The user not bound to a tenant can only choose the tenants the user has roles in,
+// the super admin can switch to any tenant by the header or subdomain.
+//
+// Example:
+//
+//	middleware.RegisterAuth(middleware.JwtAuth(), middleware.Tenant(), middleware.TenantAuthz())
+func Tenant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bound := c.GetString(consts.CTX_TENANT_ID)
+		requested := requestTenant(c)
+		tenant := bound
+		if len(requested) > 0 && requested != bound {
+			// The bound user can't switch tenants, the unbound user must have roles in the requested tenant.
+			sub := authzSubject(c)
+			if !rbac.IsSuperAdmin(sub) && (len(bound) > 0 || len(rbac.TenantRoles(requested, sub)) == 0) {
+				logger.Authz.Warnz("cross tenant request rejected",
+					zap.String("user_id", c.GetString(consts.CTX_USER_ID)),
+					zap.String("tenant", bound),
+					zap.String("requested", requested),
+				)
+				ResponseJSON(c, CodeForbidden)
+				c.Abort()
+				return
+			}
+			tenant = requested
+		}
+		c.Set(consts.CTX_TENANT_ID, tenant)
+		c.Next()
+	}
+}
+
+// TenantAuthz enforces the permissions of the login user in the tenant of the request,
+// see rbac.TenantRBAC. It must be registered after Tenant.
+func TenantAuthz() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenant := c.GetString(consts.CTX_TENANT_ID)
+		sub := authzSubject(c)
+		obj := c.Request.URL.Path
+		act := c.Request.Method
+
+		allow, err := rbac.EnforceTenant(tenant, sub, obj, act)
+		if err != nil {
+			zap.S().Error(err)
+			ResponseJSON(c, CodeFailure)
+			c.Abort()
+			return
+		}
+		logger.Authz.Infoz("",
+			zap.String("tenant", tenant),
+			zap.String("sub", sub),
+			zap.String("obj", obj),
+			zap.String("act", act),
+			zap.Bool("res", allow),
+		)
+		if !allow {
+			ResponseJSON(c, CodeForbidden)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// authzSubject returns the casbin subject of the login user.
+func authzSubject(c *gin.Context) string {
+	return rbac.Subject(c.GetString(consts.CTX_USER_ID), c.GetString(consts.CTX_USERNAME))
+}
+
+// requestTenant returns the tenant requested by the header or subdomain.
+func requestTenant(c *gin.Context) string {
+	if header := config.App.Auth.TenantHeader; len(header) > 0 {
+		if tenant := strings.TrimSpace(c.GetHeader(header)); len(tenant) > 0 {
+			return tenant
+		}
+	}
+	domain := strings.Trim(config.App.Auth.TenantDomain, ".")
+	if len(domain) == 0 {
+		return ""
+	}
+	host := c.Request.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	sub, ok := strings.CutSuffix(strings.ToLower(host), "."+strings.ToLower(domain))
+	if !ok || len(sub) == 0 || strings.Contains(sub, ".") {
+		return ""
+	}
+	return sub
+}
@@ -0,0 +1,29 @@
+package model
+
+func init() {
+	Register[*APIKey]()
+}
+
+// APIKey is the personal access token of the user for the machine clients, the key is
+// "gst_<id>_<secret>" and only the hash of the secret is stored.
+// The key is shown to the user once at the creation.
+type APIKey struct {
+	UserID   string `json:"user_id,omitempty" gorm:"size:191;index"`
+	Username string `json:"username,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Hash     string `json:"-"`
+	// Scopes limits the requests of the key, the scope is "<methods>:<path>", eg:
+	// "GET:/api/user/*", "GET|POST:/api/order", "*:*". The empty scopes allow all the requests.
+	// The key never exceeds the permissions of the user.
+	Scopes GormStrings `json:"scopes,omitempty"`
+
+	ExpiresAt  *GormTime `json:"expires_at,omitempty"`
+	LastUsedAt *GormTime `json:"last_used_at,omitempty"`
+	LastUsedIP string    `json:"last_used_ip,omitempty"`
+	RevokedAt  *GormTime `json:"revoked_at,omitempty"`
+
+	// Key is the plaintext key only returned at the creation.
+	Key string `json:"key,omitempty" gorm:"-"`
+
+	Base
+}
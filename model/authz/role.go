@@ -14,10 +14,14 @@ import (
 
 func init() {
 	model.Register[*Role]()
+	rbac.RegisterRoleStore(roleStore{})
 }
 
 type Role struct {
 	Name string `json:"name,omitempty" schema:"name"`
+	// Parents are the names of the roles inherited, the role is granted the permissions of
+	// the parents unless denied, see rbac.RBAC.
+	Parents model.GormStrings `json:"parents,omitempty"`
 
 	model.Base
 }
@@ -32,9 +36,23 @@ func (r *Role) CreateBefore(*types.ModelContext) error {
 
 	return nil
 }
-func (r *Role) UpdateBefore(ctx *types.ModelContext) error { return r.CreateAfter(ctx) }
 
-func (r *Role) CreateAfter(*types.ModelContext) error { return rbac.RBAC().AddRole(r.Name) }
+// UpdateBefore syncs the parents to the rbac. The update without parents, eg: only the remark
+// updated, keeps the stored parents, set the empty parents to remove the inheritance.
+func (r *Role) UpdateBefore(ctx *types.ModelContext) error {
+	if r.Parents == nil {
+		stored := new(Role)
+		if err := database.Database[*Role](ctx.DatabaseContext()).Get(stored, r.ID); err != nil {
+			return err
+		}
+		r.Parents = stored.Parents
+	}
+	return r.CreateAfter(ctx)
+}
+
+func (r *Role) CreateAfter(*types.ModelContext) error {
+	return rbac.RBAC().AddRole(r.Name, r.Parents...)
+}
 func (r *Role) DeleteBefore(ctx *types.ModelContext) error {
 	// The delete request always don't have role id, so we should get the role from database.
 	if err := database.Database[*Role](ctx.DatabaseContext()).Get(r, r.ID); err != nil {
@@ -55,6 +73,35 @@ func (r *Role) MarshalLogObject(enc zapcore.ObjectEncoder) error {
 		return nil
 	}
 	enc.AddString("name", r.Name)
+	enc.AddString("parents", strings.Join(r.Parents, ","))
 	_ = enc.AddObject("base", &r.Base)
 	return nil
 }
+
+// roleStore persists the roles created by rbac.RBAC().AddRole, the hooks are skipped
+// because they call back to the rbac.
+type roleStore struct{}
+
+func (roleStore) Save(name string, parents []string) error {
+	role := &Role{Name: name, Parents: parents}
+	role.SetID(util.HashID(name))
+	return database.Database[*Role](nil).WithoutHook().Update(role)
+}
+
+func (roleStore) Delete(name string) error {
+	role := new(Role)
+	role.SetID(util.HashID(name))
+	return database.Database[*Role](nil).WithoutHook().WithPurge().Delete(role)
+}
+
+func (roleStore) List() ([]string, error) {
+	roles := make([]*Role, 0)
+	if err := database.Database[*Role](nil).WithLimit(-1).List(&roles); err != nil {
+		return nil, err
+	}
+	names := make([]string, len(roles))
+	for i := range roles {
+		names[i] = roles[i].Name
+	}
+	return names, nil
+}
@@ -57,7 +57,10 @@ func (r *RolePermission) CreateBefore(*types.ModelContext) error {
 }
 
 func (r *RolePermission) CreateAfter(*types.ModelContext) error {
-	// grant the permission: (role, resource, action)
+	// grant or deny the permission: (role, resource, action)
+	if r.Effect == EffectDeny {
+		return rbac.RBAC().DenyPermission(r.Role, r.Resource, r.Action)
+	}
 	return rbac.RBAC().GrantPermission(r.Role, r.Resource, r.Action)
 }
 
@@ -0,0 +1,97 @@
+package modelauthz
+
+import (
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/authz/rbac"
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/util"
+	"go.uber.org/zap/zapcore"
+)
+
+func init() {
+	model.Register[*TenantRole]()
+	rbac.RegisterTenantRoleStore(tenantRoleStore{})
+}
+
+// TenantRole is a role of the tenant, the roles of different tenants are isolated
+// even if they have the same name, see rbac.TenantRBAC.
+type TenantRole struct {
+	Name string `json:"name,omitempty" schema:"name"`
+
+	model.Base
+	model.TenantScoped
+}
+
+func (r *TenantRole) CreateBefore(ctx *types.ModelContext) error {
+	if len(strings.TrimSpace(r.Name)) == 0 {
+		return errors.New("name is required")
+	}
+	if err := setTenant(ctx, &r.TenantScoped); err != nil {
+		return err
+	}
+	// Ensure the role with the same name in the tenant share the same ID.
+	r.SetID(util.HashID(r.TenantID, r.Name))
+	return nil
+}
+func (r *TenantRole) UpdateBefore(ctx *types.ModelContext) error { return r.CreateBefore(ctx) }
+
+func (r *TenantRole) CreateAfter(*types.ModelContext) error {
+	return rbac.TenantRBAC().AddRole(r.TenantID, r.Name)
+}
+
+func (r *TenantRole) DeleteBefore(ctx *types.ModelContext) error {
+	// The delete request always don't have role name, so we should get the role from database.
+	if err := database.Database[*TenantRole](ctx.DatabaseContext()).Get(r, r.ID); err != nil {
+		return err
+	}
+	if len(r.Name) == 0 {
+		return nil
+	}
+	return rbac.TenantRBAC().RemoveRole(r.TenantID, r.Name)
+}
+
+func (r *TenantRole) DeleteAfter(ctx *types.ModelContext) error {
+	return database.Database[*TenantRole](ctx.DatabaseContext()).Cleanup()
+}
+
+func (r *TenantRole) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	if r == nil {
+		return nil
+	}
+	enc.AddString("tenant_id", r.TenantID)
+	enc.AddString("name", r.Name)
+	_ = enc.AddObject("base", &r.Base)
+	return nil
+}
+
+// setTenant sets the tenant of the record to the tenant of the request if not set,
+// the record of other tenants is rejected by the database tenant isolation.
+func setTenant(ctx *types.ModelContext, t *model.TenantScoped) error {
+	if len(t.TenantID) == 0 {
+		t.TenantID = ctx.DatabaseContext().TenantID
+	}
+	if len(t.TenantID) == 0 {
+		return database.ErrTenantRequired
+	}
+	return nil
+}
+
+// tenantRoleStore persists the roles created by rbac.TenantRBAC().AddRole, the hooks are
+// skipped because they call back to the rbac.
+type tenantRoleStore struct{}
+
+func (tenantRoleStore) Save(tenant, name string) error {
+	role := &TenantRole{Name: name, TenantScoped: model.TenantScoped{TenantID: tenant}}
+	role.SetID(util.HashID(tenant, name))
+	return database.Database[*TenantRole](&types.DatabaseContext{TenantID: tenant}).WithoutHook().Update(role)
+}
+
+func (tenantRoleStore) Delete(tenant, name string) error {
+	role := new(TenantRole)
+	role.SetID(util.HashID(tenant, name))
+	return database.Database[*TenantRole](&types.DatabaseContext{TenantID: tenant}).WithoutHook().WithPurge().Delete(role)
+}
@@ -0,0 +1,73 @@
+package modelauthz
+
+import (
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/authz/rbac"
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/util"
+	"go.uber.org/zap/zapcore"
+)
+
+func init() {
+	model.Register[*TenantRolePermission]()
+}
+
+// TenantRolePermission is a permission granted to the role of the tenant.
+type TenantRolePermission struct {
+	Role     string `json:"role" schema:"role"`
+	Resource string `json:"resource" schema:"resource"`
+	Action   string `json:"action" schema:"action"`
+
+	model.Base
+	model.TenantScoped
+}
+
+func (r *TenantRolePermission) CreateBefore(ctx *types.ModelContext) error {
+	if len(r.Role) == 0 {
+		return errors.New("role is required")
+	}
+	if len(r.Resource) == 0 {
+		return errors.New("resource is required")
+	}
+	if len(r.Action) == 0 {
+		return errors.New("action is required")
+	}
+	if err := setTenant(ctx, &r.TenantScoped); err != nil {
+		return err
+	}
+	// If the role already has the permission(Resource+Action), set same id to just update it.
+	r.SetID(util.HashID(r.TenantID, r.Role, r.Resource, r.Action))
+	return nil
+}
+
+func (r *TenantRolePermission) CreateAfter(*types.ModelContext) error {
+	return rbac.TenantRBAC().GrantPermission(r.TenantID, r.Role, r.Resource, r.Action)
+}
+
+func (r *TenantRolePermission) DeleteBefore(ctx *types.ModelContext) error {
+	// The request always only contains id, so we should get the TenantRolePermission from database.
+	if err := database.Database[*TenantRolePermission](ctx.DatabaseContext()).Get(r, r.ID); err != nil {
+		return err
+	}
+	if len(r.Role) == 0 {
+		return nil
+	}
+	return rbac.TenantRBAC().RevokePermission(r.TenantID, r.Role, r.Resource, r.Action)
+}
+
+func (r *TenantRolePermission) DeleteAfter(ctx *types.ModelContext) error {
+	return database.Database[*TenantRolePermission](ctx.DatabaseContext()).Cleanup()
+}
+
+func (r *TenantRolePermission) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	if r == nil {
+		return nil
+	}
+	enc.AddString("tenant_id", r.TenantID)
+	enc.AddString("role", r.Role)
+	enc.AddString("resource", r.Resource)
+	enc.AddString("action", r.Action)
+	return nil
+}
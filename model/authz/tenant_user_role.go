@@ -0,0 +1,95 @@
+package modelauthz
+
+import (
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/authz/rbac"
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/util"
+	"go.uber.org/zap/zapcore"
+)
+
+func init() {
+	model.Register[*TenantUserRole]()
+}
+
+// TenantUserRole assigns the role of the tenant to the user, the role must be
+// a TenantRole of the same tenant or the built-in rbac.TenantAdmin.
+type TenantUserRole struct {
+	UserID string `json:"user_id,omitempty" schema:"user_id"`
+	Role   string `json:"role,omitempty" schema:"role"`
+
+	User string `json:"user,omitempty" schema:"user"` // 用户名,只是为了方便查询
+
+	model.Base
+	model.TenantScoped
+}
+
+func (r *TenantUserRole) CreateBefore(ctx *types.ModelContext) error {
+	if len(r.UserID) == 0 {
+		return errors.New("user_id is required")
+	}
+	if len(r.Role) == 0 {
+		return errors.New("role is required")
+	}
+	if err := setTenant(ctx, &r.TenantScoped); err != nil {
+		return err
+	}
+	user := new(model.User)
+	if err := database.Database[*model.User](ctx.DatabaseContext()).Get(user, r.UserID); err != nil {
+		return err
+	}
+	if len(user.ID) == 0 {
+		return errors.Newf("user %s not found", r.UserID)
+	}
+	// The user bound to a tenant never accesses other tenants.
+	if len(user.TenantID) > 0 && user.TenantID != r.TenantID {
+		return errors.Newf("user %s belongs to other tenant", r.UserID)
+	}
+	r.User = user.Name
+	if r.Role != rbac.TenantAdmin {
+		role := new(TenantRole)
+		if err := database.Database[*TenantRole](ctx.DatabaseContext()).Get(role, util.HashID(r.TenantID, r.Role)); err != nil {
+			return err
+		}
+		if len(role.ID) == 0 {
+			return errors.Newf("role %q not found in tenant %s", r.Role, r.TenantID)
+		}
+	}
+
+	// If the user already has the role, set same id to just update it.
+	r.SetID(util.HashID(r.TenantID, r.UserID, r.Role))
+	return nil
+}
+
+func (r *TenantUserRole) CreateAfter(*types.ModelContext) error {
+	return rbac.TenantRBAC().AssignRole(r.TenantID, r.UserID, r.Role)
+}
+
+func (r *TenantUserRole) DeleteBefore(ctx *types.ModelContext) error {
+	// The delete request always don't have user_id and role, so we should get the record from database.
+	if err := database.Database[*TenantUserRole](ctx.DatabaseContext()).Get(r, r.ID); err != nil {
+		return err
+	}
+	if len(r.UserID) == 0 {
+		return nil
+	}
+	return rbac.TenantRBAC().UnassignRole(r.TenantID, r.UserID, r.Role)
+}
+
+func (r *TenantUserRole) DeleteAfter(ctx *types.ModelContext) error {
+	return database.Database[*TenantUserRole](ctx.DatabaseContext()).Cleanup()
+}
+
+func (r *TenantUserRole) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	if r == nil {
+		return nil
+	}
+	enc.AddString("tenant_id", r.TenantID)
+	enc.AddString("user_id", r.UserID)
+	enc.AddString("user", r.User)
+	enc.AddString("role", r.Role)
+	_ = enc.AddObject("base", &r.Base)
+	return nil
+}
@@ -14,7 +14,20 @@ import (
 type GormTime time.Time
 
 func (gt *GormTime) Scan(value any) error {
-	localTime, err := time.Parse(consts.DATE_TIME_LAYOUT, string(value.([]byte))) //nolint:errcheck
+	var s string
+	switch v := value.(type) {
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case time.Time:
+		// Some drivers, eg: sqlite, parse the datetime column themselves.
+		*gt = GormTime(v)
+		return nil
+	default:
+		return errors.Newf("GormTime: unsupported type %T", value)
+	}
+	localTime, err := time.Parse(consts.DATE_TIME_LAYOUT, s)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,37 @@
+package model
+
+func init() {
+	Register[*Job]()
+}
+
+const (
+	JobStatePending = "pending"
+	JobStateRunning = "running"
+	JobStateDone    = "done"
+	JobStateDead    = "dead"
+)
+
+// Job is the job of the background job queue, see package jobqueue.
+// The job failed after all retries is kept in state "dead" as the dead letter,
+// the finished jobs are cleaned up by config "jobqueue.retention".
+type Job struct {
+	Queue string `json:"queue,omitempty" gorm:"size:191;index:idx_job_fetch,priority:1"`
+	// Type is the type of the job handler.
+	Type    string `json:"type,omitempty" gorm:"size:191;index"`
+	Payload string `json:"payload,omitempty"` // json
+	// Priority is the priority of the job in the queue, the higher runs first.
+	Priority int    `json:"priority"`
+	State    string `json:"state,omitempty" gorm:"size:32;index:idx_job_fetch,priority:2"`
+	// Attempts is the number of the runs, including the running one.
+	Attempts int `json:"attempts"`
+	// RunAt is the time the job is run at, the delayed or retried job is not run before it.
+	RunAt int64 `json:"run_at" gorm:"index:idx_job_fetch,priority:3"` // unix milliseconds
+	// LockedBy is the worker running the job, the lock expires at LockedUntil.
+	LockedBy    string `json:"locked_by,omitempty" gorm:"size:255"`
+	LockedUntil int64  `json:"locked_until,omitempty"` // unix milliseconds
+	LastError   string `json:"last_error,omitempty"`
+
+	FinishedAt *GormTime `json:"finished_at,omitempty"`
+
+	Base
+}
@@ -0,0 +1,36 @@
+package model
+
+func init() {
+	Register[*JobRun]()
+}
+
+const (
+	JobKindCronjob = "cronjob"
+	JobKindTask    = "task"
+
+	JobTriggerSchedule  = "schedule"
+	JobTriggerImmediate = "immediate"
+	JobTriggerManual    = "manual"
+
+	JobStatusSuccess = "success"
+	JobStatusFailure = "failure"
+	JobStatusSkipped = "skipped"
+)
+
+// JobRun is the run history of the cronjobs and tasks, it's cleaned up by
+// config "cronjob.history_retention".
+type JobRun struct {
+	Kind    string `json:"kind,omitempty" gorm:"size:32;index"`
+	Name    string `json:"name,omitempty" gorm:"size:191;index"`
+	Trigger string `json:"trigger,omitempty"`
+	Status  string `json:"status,omitempty"`
+	// Error is the error returned by the job or the reason of the skipped run.
+	Error string `json:"error,omitempty"`
+	// Node is the replica running the job.
+	Node string `json:"node,omitempty"`
+
+	StartedAt *GormTime `json:"started_at,omitempty"`
+	Duration  int64     `json:"duration,omitempty"` // milliseconds
+
+	Base
+}
@@ -4,11 +4,16 @@ import (
 	"github.com/forbearing/gst/model"
 )
 
+func init() {
+	model.Register[*LoginLog]()
+}
+
 type LoginStatus string
 
 const (
-	LoginStatusSuccess = "success"
-	LoginStatusFailure = "failure"
+	LoginStatusSuccess LoginStatus = "success"
+	LoginStatusFailure LoginStatus = "failure"
+	LoginStatusLocked  LoginStatus = "locked"
 )
 
 type LoginLog struct {
@@ -17,6 +22,7 @@ type LoginLog struct {
 	ClientIP string      `json:"client_ip" schema:"client_ip"`
 	Token    string      `json:"token" schema:"token"`
 	Status   LoginStatus `json:"status" schema:"status"`
+	Reason   string      `json:"reason,omitempty" schema:"reason"`
 
 	model.UserAgent
 	model.Base
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"github.com/forbearing/gst/types"
+)
+
+func init() {
+	Register[*MFA]()
+	Register[*MFARecoveryCode]()
+}
+
+// MFA is the multi-factor authentication settings of the user, the id is the user id.
+type MFA struct {
+	UserID string `json:"user_id,omitempty"`
+	// Secret is the base32 encoded TOTP secret.
+	Secret string `json:"-"`
+	// Enabled reports whether the TOTP is enrolled and activated.
+	Enabled bool `json:"enabled,omitempty"`
+	// Required requires the user to log in with MFA regardless of the roles.
+	Required bool `json:"required,omitempty"`
+	// LastCounter is the time step of the last accepted TOTP code, the code is accepted only once.
+	LastCounter int64 `json:"-"`
+
+	Base
+	// Versioned makes the code accepted only once by the concurrent verifications.
+	Versioned
+}
+
+func (m *MFA) CreateBefore(*types.ModelContext) error {
+	m.ID = m.UserID
+	return nil
+}
+
+// MFARecoveryCode is the one-time recovery code of the user, only the hash is stored.
+type MFARecoveryCode struct {
+	UserID string    `json:"user_id,omitempty" gorm:"size:191;index"`
+	Hash   string    `json:"-"`
+	UsedAt *GormTime `json:"used_at,omitempty"`
+
+	Base
+	// Versioned makes the recovery code used only once by the concurrent verifications.
+	Versioned
+}
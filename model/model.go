@@ -118,8 +118,10 @@ func RegisterRoutes[M types.Model](path string, verbs ...consts.HTTPVerb) {
 }
 
 var (
-	_ types.Model = (*Base)(nil)
-	_ types.Model = (*Empty)(nil)
+	_ types.Model        = (*Base)(nil)
+	_ types.Model        = (*Empty)(nil)
+	_ types.Versioned    = (*Versioned)(nil)
+	_ types.TenantScoped = (*TenantScoped)(nil)
 )
 
 // Base implement types.Model interface.
@@ -200,6 +202,42 @@ func (*Base) ListAfter(*types.ModelContext) error    { return nil }
 func (*Base) GetBefore(*types.ModelContext) error    { return nil }
 func (*Base) GetAfter(*types.ModelContext) error     { return nil }
 
+// Versioned enables optimistic concurrency control for the model embedding it,
+// the version starts from 1 and is increased by every update, see types.Versioned.
+//
+// Usage example:
+//
+//	type Article struct {
+//	    Title string `json:"title"`
+//
+//	    model.Base
+//	    model.Versioned
+//	}
+type Versioned struct {
+	Version uint `json:"version" gorm:"not null;default:1" schema:"-" url:"-"` // Version of the record, used to detect concurrent modification
+}
+
+func (v *Versioned) GetVersion() uint    { return v.Version }
+func (v *Versioned) SetVersion(ver uint) { v.Version = ver }
+
+// TenantScoped isolates the records of the model embedding it by tenant,
+// the tenant id is taken from the login user, see types.TenantScoped.
+//
+// Usage example:
+//
+//	type Project struct {
+//	    Name string `json:"name"`
+//
+//	    model.Base
+//	    model.TenantScoped
+//	}
+type TenantScoped struct {
+	TenantID string `json:"tenant_id,omitempty" gorm:"size:191;index" schema:"tenant_id"` // Tenant of the record, set by database automatically
+}
+
+func (t *TenantScoped) GetTenantID() string   { return t.TenantID }
+func (t *TenantScoped) SetTenantID(id string) { t.TenantID = id }
+
 func setID(m types.Model, id ...string) {
 	val := reflect.ValueOf(m).Elem()
 	idField := val.FieldByName(consts.FIELD_ID)
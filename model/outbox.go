@@ -0,0 +1,57 @@
+package model
+
+func init() {
+	Register[*OutboxEvent]()
+	Register[*OutboxSeq]()
+}
+
+const (
+	OutboxStatePending   = "pending"
+	OutboxStateDelivered = "delivered"
+	// OutboxStateDead is the event given up after config "outbox.max_attempts" failures,
+	// it no longer holds back the later events of its aggregate.
+	OutboxStateDead = "dead"
+)
+
+// OutboxEvent is the domain event written by the types.EventSource model in the same
+// transaction as its change, the relay of package outbox delivers it to the broker.
+// The delivered events are cleaned up by config "outbox.retention", the dead ones are kept.
+type OutboxEvent struct {
+	Topic         string `json:"topic,omitempty" gorm:"size:255"`
+	AggregateType string `json:"aggregate_type,omitempty" gorm:"size:191;uniqueIndex:idx_outbox_aggregate_seq,priority:1"`
+	AggregateID   string `json:"aggregate_id,omitempty" gorm:"size:191;uniqueIndex:idx_outbox_aggregate_seq,priority:2"`
+	// Seq is the order of the event in the aggregate, it's allocated by OutboxSeq.
+	Seq     int64  `json:"seq" gorm:"uniqueIndex:idx_outbox_aggregate_seq,priority:3"`
+	Type    string `json:"type,omitempty" gorm:"size:191"`
+	Payload string `json:"payload,omitempty"` // json
+	Headers string `json:"headers,omitempty"` // json
+
+	State string `json:"state,omitempty" gorm:"size:32;index"`
+	// Attempts is the number of the delivery attempts.
+	Attempts int `json:"attempts"`
+	// NextAttemptAt is the time of the next delivery attempt, it's delayed after failures.
+	NextAttemptAt int64 `json:"next_attempt_at" gorm:"index"` // unix milliseconds
+	// LockedBy is the relay delivering the event, the lock expires at LockedUntil.
+	LockedBy    string    `json:"locked_by,omitempty" gorm:"size:255"`
+	LockedUntil int64     `json:"locked_until,omitempty"` // unix milliseconds
+	LastError   string    `json:"last_error,omitempty"`
+	DeliveredAt *GormTime `json:"delivered_at,omitempty"`
+
+	Base
+}
+
+// Purge deletes the outbox event permanently, the seq of the aggregate is unique.
+func (*OutboxEvent) Purge() bool { return true }
+
+// OutboxSeq is the last seq allocated to the aggregate, it outlives the cleaned up
+// events so the seq of the aggregate never restarts.
+type OutboxSeq struct {
+	AggregateType string `json:"aggregate_type,omitempty" gorm:"size:191;uniqueIndex:idx_outbox_seq_aggregate,priority:1"`
+	AggregateID   string `json:"aggregate_id,omitempty" gorm:"size:191;uniqueIndex:idx_outbox_seq_aggregate,priority:2"`
+	Seq           int64  `json:"seq"`
+
+	Base
+}
+
+// Purge deletes the outbox seq permanently, the aggregate is unique.
+func (*OutboxSeq) Purge() bool { return true }
@@ -0,0 +1,13 @@
+package model
+
+func init() {
+	Register[*PasswordHistory]()
+}
+
+// PasswordHistory is the previous password of the user, only the bcrypt hash is stored.
+type PasswordHistory struct {
+	UserID string `json:"user_id,omitempty" gorm:"size:191;index"`
+	Hash   string `json:"-"`
+
+	Base
+}
@@ -17,6 +17,7 @@ type Session struct {
 	RefreshToken string `json:"refresh_token"`
 	UserID       string `json:"user_id"`
 	Username     string `json:"username"`
+	TenantID     string `json:"tenant_id"`
 	SessionID    string `json:"session_id"`
 
 	// TODO: 统一起来，使用 model.UserAgent
@@ -31,18 +32,20 @@ type Session struct {
 }
 
 func (s *Session) initDefault() error {
-	s.ID = s.id()
+	s.ID = s.DeviceID()
 	return nil
 }
 
 func (s *Session) CreateBefore(*types.ModelContext) error { return s.initDefault() }
 func (s *Session) UpdateBefore(*types.ModelContext) error { return s.initDefault() }
 func (s *Session) DeleteBefore(*types.ModelContext) error {
-	s.ID = s.id()
+	s.ID = s.DeviceID()
 	return nil
 }
 
-func (s *Session) id() string {
+// DeviceID returns the session id derived from the user and device, so the user has
+// one session per device. The tokens carry it as the "sid" claim.
+func (s *Session) DeviceID() string {
 	parts := []string{
 		s.UserID,
 		s.Platform,
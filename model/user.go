@@ -24,6 +24,7 @@ type User struct {
 
 	RoleID       string `json:"role_id,omitempty"`
 	DepartmentID string `json:"department_id,omitempty"`
+	TenantID     string `json:"tenant_id,omitempty" gorm:"size:191;index"`
 
 	LastLoginIP string `json:"last_login_ip,omitempty"`
 	LockExpire  int64  `json:"lock_expire,omitempty"`
@@ -34,7 +35,8 @@ type User struct {
 
 	LastLoginAt          *GormTime `json:"last_login,omitempty"`
 	TokenExpiration      *GormTime `json:"token_expiration,omitempty"`
-	LastPasswordChangeAt *GormTime `json:"last_password_change_at,omitempty" gorm:"-"`
+	LastPasswordChangeAt *GormTime `json:"last_password_change_at,omitempty"`
+	PasswordExpired      bool      `json:"password_expired,omitempty" gorm:"-"`
 
 	Token        string `json:"token,omitempty" gorm:"-"`
 	AccessToken  string `json:"access_token,omitempty" gorm:"-"`
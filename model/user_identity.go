@@ -0,0 +1,34 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/forbearing/gst/types"
+)
+
+func init() {
+	Register[*UserIdentity]()
+}
+
+// UserIdentity links the User to the account of an external identity provider, eg: OIDC.
+// The id is derived from the provider and subject, so one external account links to only one user.
+type UserIdentity struct {
+	UserID   string `json:"user_id,omitempty" gorm:"size:191;index"`
+	Provider string `json:"provider,omitempty"`
+	Subject  string `json:"subject,omitempty"`
+	Email    string `json:"email,omitempty"`
+
+	Base
+}
+
+func (i *UserIdentity) CreateBefore(*types.ModelContext) error {
+	i.ID = UserIdentityID(i.Provider, i.Subject)
+	return nil
+}
+
+// UserIdentityID returns the id of the UserIdentity by the identity provider and subject.
+func UserIdentityID(provider, subject string) string {
+	hash := sha256.Sum256([]byte(provider + ":" + subject))
+	return hex.EncodeToString(hash[:16])
+}
@@ -0,0 +1,135 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/apache/rocketmq-client-go/v2"
+	"github.com/apache/rocketmq-client-go/v2/primitive"
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/provider/kafka"
+	"github.com/forbearing/gst/provider/mqtt"
+	"github.com/forbearing/gst/provider/nats"
+	pkgrocketmq "github.com/forbearing/gst/provider/rocketmq"
+	natsgo "github.com/nats-io/nats.go"
+)
+
+// The headers set to every message delivered.
+const (
+	HeaderEventID       = "event_id"
+	HeaderEventType     = "event_type"
+	HeaderAggregateType = "aggregate_type"
+	HeaderAggregateID   = "aggregate_id"
+	HeaderSeq           = "seq"
+)
+
+// publishTimeout is the timeout of the brokers waiting for the acks.
+const publishTimeout = 10 * time.Second
+
+// Message is the outbox event delivered to the broker.
+type Message struct {
+	Topic string
+	// Key is the aggregate id, the brokers partitioning by key keep the order of the aggregate.
+	Key     string
+	Payload []byte
+	Headers map[string]string
+}
+
+// Broker delivers the messages, Publish returns nil only if the broker accepted the message.
+// The broker implementing "Close() error" is closed by Stop.
+type Broker interface {
+	Publish(ctx context.Context, msg *Message) error
+}
+
+// RegisterBroker registers the custom broker, it overrides config "outbox.broker".
+// It should be called before Init.
+func RegisterBroker(b Broker) {
+	broker = b
+}
+
+func newBroker(typ config.OutboxBroker) (Broker, error) {
+	switch typ {
+	case config.OutboxBrokerKafka:
+		if kafka.Client() == nil {
+			return nil, errors.New("kafka is disabled")
+		}
+		producer, err := kafka.NewReliableSyncProducer(config.App.Kafka)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create kafka producer")
+		}
+		return &kafkaBroker{producer: producer}, nil
+	case config.OutboxBrokerNats:
+		if nats.Conn() == nil {
+			return nil, errors.New("nats is disabled")
+		}
+		return new(natsBroker), nil
+	case config.OutboxBrokerMqtt:
+		if _, err := mqtt.Client(); err != nil {
+			return nil, err
+		}
+		return new(mqttBroker), nil
+	case config.OutboxBrokerRocketMQ:
+		producer, err := pkgrocketmq.Producer()
+		if err != nil {
+			return nil, err
+		}
+		return &rocketmqBroker{producer: producer}, nil
+	case "":
+		return nil, errors.New("outbox broker is not configured")
+	default:
+		return nil, errors.Newf("unknown outbox broker %q", typ)
+	}
+}
+
+type kafkaBroker struct{ producer sarama.SyncProducer }
+
+func (b *kafkaBroker) Publish(_ context.Context, msg *Message) error {
+	headers := make([]sarama.RecordHeader, 0, len(msg.Headers))
+	for k, v := range msg.Headers {
+		headers = append(headers, sarama.RecordHeader{Key: []byte(k), Value: []byte(v)})
+	}
+	_, _, err := b.producer.SendMessage(&sarama.ProducerMessage{
+		Topic:   msg.Topic,
+		Key:     sarama.StringEncoder(msg.Key),
+		Value:   sarama.ByteEncoder(msg.Payload),
+		Headers: headers,
+	})
+	return err
+}
+
+func (b *kafkaBroker) Close() error { return b.producer.Close() }
+
+// natsBroker publishes the messages and flushes them to the server, stream the subjects
+// by JetStream to persist them.
+type natsBroker struct{}
+
+func (natsBroker) Publish(_ context.Context, msg *Message) error {
+	m := natsgo.NewMsg(msg.Topic)
+	m.Data = msg.Payload
+	for k, v := range msg.Headers {
+		m.Header.Set(k, v)
+	}
+	conn := nats.Conn()
+	if err := conn.PublishMsg(m); err != nil {
+		return err
+	}
+	return conn.FlushTimeout(publishTimeout)
+}
+
+// mqttBroker publishes the payload with QoS 1, MQTT 3 doesn't support headers.
+type mqttBroker struct{}
+
+func (mqttBroker) Publish(_ context.Context, msg *Message) error {
+	return mqtt.Publish(msg.Topic, msg.Payload, mqtt.PublishOption{QoS: 1, Timeout: publishTimeout})
+}
+
+type rocketmqBroker struct{ producer rocketmq.Producer }
+
+func (b *rocketmqBroker) Publish(ctx context.Context, msg *Message) error {
+	m := primitive.NewMessage(msg.Topic, msg.Payload).WithShardingKey(msg.Key).WithKeys([]string{msg.Headers[HeaderEventID]})
+	m.WithProperties(msg.Headers)
+	_, err := b.producer.SendSync(ctx, m)
+	return err
+}
@@ -0,0 +1,332 @@
+// Package outbox implements the transactional outbox. The models implementing
+// types.EventSource write their domain events into the outbox (see model.OutboxEvent)
+// in the same transaction as the record change, and the relay delivers the events to
+// the broker at least once, the events of the same aggregate are delivered in order.
+//
+// The consumers should deduplicate the events by the header "event_id".
+//
+// Example:
+//
+//	func (o *Order) Events(phase consts.Phase) []types.Event {
+//		if phase != consts.PHASE_CREATE {
+//			return nil
+//		}
+//		return []types.Event{{Topic: "orders", Type: "order.created", Payload: o}}
+//	}
+package outbox
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/database"
+	pkgzap "github.com/forbearing/gst/logger/zap"
+	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/task"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/util"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	log *pkgzap.Logger
+
+	// broker is the broker the events delivered to, nil if not configured.
+	broker Broker
+	// owner identifies the relay locking the events.
+	owner string
+
+	inited bool
+)
+
+func init() {
+	hostname, _ := os.Hostname()
+	owner = hostname + ":" + strconv.Itoa(os.Getpid()) + ":" + util.UUID()
+	database.RegisterEventWriter(write)
+}
+
+// Init creates the broker and registers the relay task if config "outbox.enable" is true.
+// It's called by bootstrap.
+func Init() (err error) {
+	if log == nil {
+		log = pkgzap.New("outbox.log")
+	}
+	cfg := config.App.Outbox
+	if !cfg.Enable || inited {
+		return nil
+	}
+	if cfg.RelayInterval < time.Second {
+		return errors.Newf("outbox relay interval %s less than 1 second", cfg.RelayInterval)
+	}
+	if cfg.Lease < time.Second {
+		return errors.Newf("outbox lease %s less than 1 second", cfg.Lease)
+	}
+	if broker == nil {
+		if broker, err = newBroker(cfg.Broker); err != nil {
+			return err
+		}
+	}
+
+	task.Register(Relay, cfg.RelayInterval, "outbox relay") //nolint:staticcheck
+	if cfg.Retention > 0 {
+		task.Register(Cleanup, time.Hour, "cleanup outbox") //nolint:staticcheck
+	}
+
+	inited = true
+	return nil
+}
+
+// Add writes the events into the outbox within the transaction, it's used by the changes
+// not made through types.EventSource, eg: within Database.TransactionFunc.
+// The aggregate of the event is required.
+func Add(tx any, events ...types.Event) error {
+	db, ok := tx.(*gorm.DB)
+	if !ok || db == nil {
+		return errors.New("invalid transaction, expect *gorm.DB")
+	}
+	for _, e := range events {
+		if len(e.AggregateType) == 0 || len(e.AggregateID) == 0 {
+			return errors.Newf("aggregate of event %q is required", e.Type)
+		}
+	}
+	return write(db.Session(&gorm.Session{NewDB: true}), events)
+}
+
+// write writes the events, the seqs of each aggregate are allocated by its model.OutboxSeq.
+// The concurrent transactions writing the same aggregate are serialized by the row of
+// model.OutboxSeq, or conflict on its unique aggregate and only one of them commits.
+func write(tx *gorm.DB, events []types.Event) error {
+	type aggregate struct{ typ, id string }
+	counts := make(map[aggregate]int64)
+	keys := make([]aggregate, 0)
+	for _, e := range events {
+		key := aggregate{e.AggregateType, e.AggregateID}
+		if counts[key] == 0 {
+			keys = append(keys, key)
+		}
+		counts[key]++
+	}
+	// The counters are locked in the same order by the concurrent transactions.
+	slices.SortFunc(keys, func(a, b aggregate) int { return cmp.Or(cmp.Compare(a.typ, b.typ), cmp.Compare(a.id, b.id)) })
+
+	// seqs is the seq before the events of each aggregate.
+	seqs := make(map[aggregate]int64, len(keys))
+	for _, key := range keys {
+		n := counts[key]
+		last, err := allocate(tx, key.typ, key.id, n)
+		if err != nil {
+			return err
+		}
+		seqs[key] = last - n
+	}
+
+	now := time.Now()
+	rows := make([]*model.OutboxEvent, 0, len(events))
+	for _, e := range events {
+		key := aggregate{e.AggregateType, e.AggregateID}
+		seqs[key]++
+		seq := seqs[key]
+
+		payload, err := json.Marshal(e.Payload)
+		if err != nil {
+			return errors.Wrapf(err, "failed to encode payload of event %q", e.Type)
+		}
+		var headers []byte
+		if len(e.Headers) > 0 {
+			if headers, err = json.Marshal(e.Headers); err != nil {
+				return errors.Wrapf(err, "failed to encode headers of event %q", e.Type)
+			}
+		}
+		row := &model.OutboxEvent{
+			Topic:         e.Topic,
+			AggregateType: e.AggregateType,
+			AggregateID:   e.AggregateID,
+			Seq:           seq,
+			Type:          e.Type,
+			Payload:       string(payload),
+			Headers:       string(headers),
+			State:         model.OutboxStatePending,
+			NextAttemptAt: now.UnixMilli(),
+		}
+		row.SetID()
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return errors.Wrap(tx.Create(&rows).Error, "failed to write outbox events")
+}
+
+// allocate allocates n seqs of the aggregate and returns the last one.
+// The counter of the aggregate written before model.OutboxSeq starts from its last event.
+func allocate(tx *gorm.DB, aggregateType, aggregateID string, n int64) (int64, error) {
+	where := "aggregate_type = ? AND aggregate_id = ?"
+	res := tx.Model(new(model.OutboxSeq)).Where(where, aggregateType, aggregateID).UpdateColumn("seq", gorm.Expr("seq + ?", n))
+	if res.Error != nil {
+		return 0, errors.Wrap(res.Error, "failed to allocate outbox seq")
+	}
+	var seq int64
+	if res.RowsAffected > 0 {
+		if err := tx.Model(new(model.OutboxSeq)).Where(where, aggregateType, aggregateID).Select("seq").Scan(&seq).Error; err != nil {
+			return 0, errors.Wrap(err, "failed to query outbox seq")
+		}
+		return seq, nil
+	}
+
+	if err := tx.Model(new(model.OutboxEvent)).Unscoped().Where(where, aggregateType, aggregateID).
+		Select("COALESCE(MAX(seq), 0)").Scan(&seq).Error; err != nil {
+		return 0, errors.Wrap(err, "failed to query outbox seq")
+	}
+	row := &model.OutboxSeq{AggregateType: aggregateType, AggregateID: aggregateID, Seq: seq + n}
+	row.SetID()
+	if err := tx.Create(row).Error; err != nil {
+		return 0, errors.Wrap(err, "failed to create outbox seq")
+	}
+	return row.Seq, nil
+}
+
+// Relay delivers the pending events until none left, it's run by the task "outbox relay"
+// every config "outbox.relay_interval". Only the earliest pending event of each aggregate
+// is claimed, so the events of the same aggregate are delivered one by one in order.
+// The event failed config "outbox.max_attempts" times is given up as dead.
+func Relay(ctx context.Context) error {
+	if broker == nil {
+		return errors.New("outbox broker is not configured")
+	}
+	batch := max(config.App.Outbox.BatchSize, 1)
+	for ctx.Err() == nil {
+		events, err := claim(ctx, batch)
+		if err != nil {
+			return err
+		}
+		settled := 0
+		for _, e := range events {
+			if deliver(ctx, e) {
+				settled++
+			}
+		}
+		// The delivered and dead events unblock the next events of their aggregates,
+		// the failed ones are retried by the next run.
+		if settled == 0 {
+			return nil
+		}
+	}
+	return nil
+}
+
+// claim locks the earliest pending events of the aggregates.
+func claim(ctx context.Context, batch int) ([]*model.OutboxEvent, error) {
+	now := time.Now()
+	db := database.DB.WithContext(ctx)
+	events := make([]*model.OutboxEvent, 0)
+	if err := db.Where("state = ? AND next_attempt_at <= ? AND locked_until < ?", model.OutboxStatePending, now.UnixMilli(), now.UnixMilli()).
+		Where(`NOT EXISTS (SELECT 1 FROM outbox_events prev WHERE prev.aggregate_type = outbox_events.aggregate_type
+			AND prev.aggregate_id = outbox_events.aggregate_id AND prev.state = ? AND prev.seq < outbox_events.seq)`, model.OutboxStatePending).
+		Order("created_at").Limit(batch).Find(&events).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to query outbox events")
+	}
+
+	lockedUntil := now.Add(config.App.Outbox.Lease).UnixMilli()
+	claimed := events[:0]
+	for _, e := range events {
+		// The event is claimed by only one relay, the others see the lock changed.
+		res := db.Model(new(model.OutboxEvent)).
+			Where("id = ? AND state = ? AND locked_until = ?", e.ID, model.OutboxStatePending, e.LockedUntil).
+			Updates(map[string]any{"locked_by": owner, "locked_until": lockedUntil})
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		if res.RowsAffected == 0 {
+			continue
+		}
+		e.LockedBy, e.LockedUntil = owner, lockedUntil
+		claimed = append(claimed, e)
+	}
+	return claimed, nil
+}
+
+// deliver publishes the event and records the result, it reports whether the event delivered
+// or given up, either unblocks the next event of the aggregate.
+func deliver(ctx context.Context, e *model.OutboxEvent) bool {
+	msg := &Message{
+		Topic:   cmp.Or(e.Topic, config.App.Outbox.Topic),
+		Key:     e.AggregateID,
+		Payload: []byte(e.Payload),
+		Headers: map[string]string{},
+	}
+	if len(e.Headers) > 0 {
+		if err := json.Unmarshal([]byte(e.Headers), &msg.Headers); err != nil {
+			log.Warnz(fmt.Sprintf("invalid outbox event headers: %s", err), zap.String("id", e.ID))
+		}
+	}
+	msg.Headers[HeaderEventID] = e.ID
+	msg.Headers[HeaderEventType] = e.Type
+	msg.Headers[HeaderAggregateType] = e.AggregateType
+	msg.Headers[HeaderAggregateID] = e.AggregateID
+	msg.Headers[HeaderSeq] = strconv.FormatInt(e.Seq, 10)
+
+	now := time.Now()
+	attempts := e.Attempts + 1
+	updates := map[string]any{"locked_by": "", "locked_until": 0, "attempts": attempts}
+	err := broker.Publish(ctx, msg)
+	if maxAttempts := config.App.Outbox.MaxAttempts; err != nil && maxAttempts > 0 && attempts >= maxAttempts {
+		// Give up the event, the later events of the aggregate are delivered without it.
+		updates["state"], updates["last_error"] = model.OutboxStateDead, err.Error()
+		log.Errorz(fmt.Sprintf("failed to deliver outbox event, give up after %d attempts: %s", attempts, err), zap.String("id", e.ID),
+			zap.String("type", e.Type), zap.String("aggregate_id", e.AggregateID), zap.Int64("seq", e.Seq))
+	} else if err != nil {
+		delay := backoff(attempts)
+		updates["next_attempt_at"], updates["last_error"] = now.Add(delay).UnixMilli(), err.Error()
+		log.Warnz(fmt.Sprintf("failed to deliver outbox event, retry: %s", err), zap.String("id", e.ID), zap.String("type", e.Type),
+			zap.String("aggregate_id", e.AggregateID), zap.Int64("seq", e.Seq), zap.String("delay", util.FormatDurationSmart(delay)))
+	} else {
+		updates["state"], updates["delivered_at"], updates["last_error"] = model.OutboxStateDelivered, model.GormTime(now), ""
+		log.Infoz("outbox event delivered", zap.String("id", e.ID), zap.String("type", e.Type), zap.String("topic", msg.Topic))
+	}
+
+	// The context may be canceled, the result is still recorded. The event delivered but
+	// not recorded is delivered again once the lock expires.
+	res := database.DB.Model(new(model.OutboxEvent)).Where("id = ? AND locked_by = ?", e.ID, owner).Updates(updates)
+	if res.Error != nil {
+		log.Errorz(fmt.Sprintf("failed to update outbox event: %s", res.Error), zap.String("id", e.ID))
+	} else if res.RowsAffected == 0 {
+		log.Warnz("outbox event lock lost, it's claimed by the other relay", zap.String("id", e.ID))
+	}
+	return updates["state"] != nil && res.Error == nil && res.RowsAffected > 0
+}
+
+// backoff returns the delay of the redelivery after the failures, it doubles every failure.
+func backoff(failures int) time.Duration {
+	delay, maxDelay := config.App.Outbox.Backoff, config.App.Outbox.MaxBackoff
+	for i := 1; i < failures && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxDelay)
+}
+
+// Cleanup removes the delivered events older than config "outbox.retention".
+func Cleanup() error {
+	retention := config.App.Outbox.Retention
+	if retention <= 0 || database.DB == nil {
+		return nil
+	}
+	return database.DB.Unscoped().Where("state = ? AND updated_at < ?", model.OutboxStateDelivered, time.Now().Add(-retention)).Delete(new(model.OutboxEvent)).Error
+}
+
+// Stop closes the broker, it's registered to bootstrap.Cleanup.
+func Stop() {
+	if c, ok := broker.(interface{ Close() error }); ok {
+		if err := c.Close(); err != nil && log != nil {
+			log.Warnz(fmt.Sprintf("failed to close outbox broker: %s", err))
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/forbearing/gst/bootstrap"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/outbox"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Order struct {
+	Amount int `json:"amount"`
+
+	model.Base
+}
+
+func (o *Order) Events(phase consts.Phase) []types.Event {
+	if o.Amount < 0 {
+		// The payload can't be encoded, the change is rolled back.
+		return []types.Event{{Type: "order.invalid", Payload: make(chan int)}}
+	}
+	return []types.Event{{Topic: "orders", Type: "order." + string(phase), Payload: o}}
+}
+
+type broker struct {
+	mu   sync.Mutex
+	msgs []*outbox.Message
+	fail map[string]bool // key is the aggregate id
+}
+
+func (b *broker) Publish(_ context.Context, msg *outbox.Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.fail[msg.Key] {
+		return errors.New("broker unavailable")
+	}
+	b.msgs = append(b.msgs, msg)
+	return nil
+}
+
+func init() {
+	os.Setenv(config.LOGGER_DIR, "/tmp/test_outbox")
+	os.Setenv(config.DATABASE_TYPE, string(config.DBSqlite))
+	os.Setenv(config.SQLITE_IS_MEMORY, "false")
+	os.Setenv(config.SQLITE_PATH, "/tmp/test_outbox.db")
+	os.Setenv(config.OUTBOX_BACKOFF, "100ms")
+	os.Setenv(config.OUTBOX_MAX_BACKOFF, "100ms")
+	_ = os.Remove("/tmp/test_outbox.db")
+
+	model.Register[*Order]()
+	if err := bootstrap.Bootstrap(); err != nil {
+		panic(err)
+	}
+}
+
+func pending(t *testing.T, aggregateID string) []*model.OutboxEvent {
+	t.Helper()
+	events := make([]*model.OutboxEvent, 0)
+	require.NoError(t, database.DB.Where("aggregate_id = ? AND state = ?", aggregateID, model.OutboxStatePending).Order("seq").Find(&events).Error)
+	return events
+}
+
+func TestOutbox(t *testing.T) {
+	ctx := context.Background()
+	b := &broker{fail: make(map[string]bool)}
+	outbox.RegisterBroker(b)
+
+	o1, o2 := &Order{Amount: 1}, &Order{Amount: 2}
+	require.NoError(t, database.Database[*Order](nil).Create(o1, o2))
+	o1.Amount = 10
+	require.NoError(t, database.Database[*Order](nil).Update(o1))
+
+	events := pending(t, o1.ID)
+	require.Len(t, events, 2)
+	assert.Equal(t, "orders", events[0].AggregateType)
+	assert.Equal(t, []int64{1, 2}, []int64{events[0].Seq, events[1].Seq})
+	assert.Equal(t, []string{"order.create", "order.update"}, []string{events[0].Type, events[1].Type})
+
+	t.Run("rollback", func(t *testing.T) {
+		invalid := &Order{Amount: -1}
+		assert.Error(t, database.Database[*Order](nil).Create(invalid))
+		var count int64
+		require.NoError(t, database.DB.Model(new(Order)).Where("id = ?", invalid.ID).Count(&count).Error)
+		assert.Zero(t, count)
+
+		// The events added within the transaction rolled back are discarded too.
+		_ = database.Database[*Order](nil).TransactionFunc(func(tx any) error {
+			require.NoError(t, outbox.Add(tx, types.Event{AggregateType: "orders", AggregateID: o2.ID, Type: "order.paid"}))
+			return errors.New("rollback")
+		})
+		assert.Len(t, pending(t, o2.ID), 1)
+	})
+
+	t.Run("ordered per aggregate", func(t *testing.T) {
+		// The first event of o1 fails, the second one is held back.
+		b.fail[o1.ID] = true
+		require.NoError(t, outbox.Relay(ctx))
+		require.Len(t, b.msgs, 1)
+		assert.Equal(t, o2.ID, b.msgs[0].Key)
+		events := pending(t, o1.ID)
+		require.Len(t, events, 2)
+		assert.Equal(t, 1, events[0].Attempts)
+		assert.Equal(t, "broker unavailable", events[0].LastError)
+		assert.Zero(t, events[1].Attempts)
+
+		// Redelivered after the backoff.
+		b.fail[o1.ID] = false
+		require.NoError(t, outbox.Relay(ctx))
+		require.Len(t, b.msgs, 1)
+		time.Sleep(150 * time.Millisecond)
+		require.NoError(t, outbox.Relay(ctx))
+		require.Len(t, b.msgs, 3)
+		for i, typ := range []string{"order.create", "order.update"} {
+			msg := b.msgs[i+1]
+			assert.Equal(t, o1.ID, msg.Key)
+			assert.Equal(t, typ, msg.Headers[outbox.HeaderEventType])
+			assert.NotEmpty(t, msg.Headers[outbox.HeaderEventID])
+		}
+		assert.Empty(t, pending(t, o1.ID))
+	})
+
+	t.Run("update by id", func(t *testing.T) {
+		require.NoError(t, database.Database[*Order](nil).UpdateByID(o2.ID, "amount", 20))
+		events := pending(t, o2.ID)
+		require.Len(t, events, 1)
+		assert.Equal(t, int64(2), events[0].Seq)
+		assert.Equal(t, "order.update", events[0].Type)
+		assert.Contains(t, events[0].Payload, `"amount":20`)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		config.App.Outbox.Retention = time.Nanosecond
+		defer func() { config.App.Outbox.Retention = 7 * 24 * time.Hour }()
+		time.Sleep(10 * time.Millisecond)
+		require.NoError(t, outbox.Cleanup())
+		var count int64
+		require.NoError(t, database.DB.Model(new(model.OutboxEvent)).Where("state = ?", model.OutboxStateDelivered).Count(&count).Error)
+		assert.Zero(t, count)
+
+		// The seq of the aggregate goes on after its events cleaned up.
+		o1.Amount = 100
+		require.NoError(t, database.Database[*Order](nil).Update(o1))
+		events := pending(t, o1.ID)
+		require.Len(t, events, 1)
+		assert.Equal(t, int64(3), events[0].Seq)
+	})
+
+	t.Run("dead", func(t *testing.T) {
+		config.App.Outbox.MaxAttempts = 2
+		defer func() { config.App.Outbox.MaxAttempts = 20 }()
+		require.NoError(t, outbox.Relay(ctx))
+		b.msgs = nil
+
+		o3 := &Order{Amount: 3}
+		require.NoError(t, database.Database[*Order](nil).Create(o3))
+		o3.Amount = 30
+		require.NoError(t, database.Database[*Order](nil).Update(o3))
+
+		// The first event of o3 is given up after 2 attempts, the second one is no longer held back.
+		b.fail[o3.ID] = true
+		require.NoError(t, outbox.Relay(ctx))
+		time.Sleep(150 * time.Millisecond)
+		require.NoError(t, outbox.Relay(ctx))
+		events := pending(t, o3.ID)
+		require.Len(t, events, 1)
+		assert.Equal(t, "order.update", events[0].Type)
+
+		b.fail[o3.ID] = false
+		time.Sleep(150 * time.Millisecond)
+		require.NoError(t, outbox.Relay(ctx))
+		require.Len(t, b.msgs, 1)
+		assert.Equal(t, "order.update", b.msgs[0].Headers[outbox.HeaderEventType])
+		assert.Empty(t, pending(t, o3.ID))
+
+		dead := make([]*model.OutboxEvent, 0)
+		require.NoError(t, database.DB.Where("aggregate_id = ? AND state = ?", o3.ID, model.OutboxStateDead).Find(&dead).Error)
+		require.Len(t, dead, 1)
+		assert.Equal(t, "order.create", dead[0].Type)
+		assert.Equal(t, 2, dead[0].Attempts)
+		assert.Equal(t, "broker unavailable", dead[0].LastError)
+	})
+}
@@ -0,0 +1,112 @@
+// Package codec provides the built-in tabular codecs used by the generic
+// Import/Export controllers.
+//
+// Rows are mapped to columns by reflecting over the model's `json` tags,
+// embedded structs (such as model.Base) are flattened and fields tagged
+// `json:"-"` are ignored. Supported formats are CSV, XLSX and NDJSON.
+package codec
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/forbearing/gst/types/consts"
+)
+
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatXLSX   Format = "xlsx"
+	FormatNDJSON Format = "ndjson"
+)
+
+// DefaultFormat is used when neither the `_format` query parameter nor the
+// "Accept" header selects a supported format.
+const DefaultFormat = FormatXLSX
+
+const (
+	mimeCSV    = "text/csv"
+	mimeXLSX   = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	mimeNDJSON = "application/x-ndjson"
+)
+
+// ContentType returns the MIME type written to the "Content-Type" header.
+func (f Format) ContentType() string {
+	switch f {
+	case FormatCSV:
+		return mimeCSV + "; charset=utf-8"
+	case FormatNDJSON:
+		return mimeNDJSON + "; charset=utf-8"
+	default:
+		return mimeXLSX
+	}
+}
+
+// Ext returns the file extension without leading dot.
+func (f Format) Ext() string {
+	switch f {
+	case FormatCSV:
+		return "csv"
+	case FormatNDJSON:
+		return "ndjson"
+	default:
+		return "xlsx"
+	}
+}
+
+// ParseFormat parses the format name or file extension, case-insensitive.
+// Aliases: "excel" for xlsx, "jsonl" and "json-lines" for ndjson.
+func ParseFormat(s string) (Format, bool) {
+	switch strings.ToLower(strings.TrimPrefix(strings.TrimSpace(s), ".")) {
+	case "csv":
+		return FormatCSV, true
+	case "xlsx", "excel":
+		return FormatXLSX, true
+	case "ndjson", "jsonl", "json-lines":
+		return FormatNDJSON, true
+	}
+	return "", false
+}
+
+// Negotiate selects the format from the `_format` query parameter first,
+// then from the "Accept" header, falling back to DefaultFormat.
+func Negotiate(query map[string][]string, header http.Header) Format {
+	if vals := query[consts.QUERY_FORMAT]; len(vals) > 0 {
+		if f, ok := ParseFormat(vals[0]); ok {
+			return f
+		}
+	}
+	for _, item := range strings.Split(header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(item))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case mimeCSV:
+			return FormatCSV
+		case mimeXLSX:
+			return FormatXLSX
+		case mimeNDJSON, "application/jsonl", "application/json-lines":
+			return FormatNDJSON
+		}
+	}
+	return DefaultFormat
+}
+
+// Detect guesses the format of the content: xlsx files are zip archives,
+// NDJSON starts with a json object and anything else is treated as CSV.
+func Detect(data []byte) Format {
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return FormatXLSX
+	}
+	data = bytes.TrimPrefix(data, utf8BOM)
+	if trimmed := bytes.TrimLeft(data, " \t\r\n"); len(trimmed) > 0 && trimmed[0] == '{' {
+		return FormatNDJSON
+	}
+	return FormatCSV
+}
+
+var utf8BOM = []byte("\xef\xbb\xbf")
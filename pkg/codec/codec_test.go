@@ -0,0 +1,114 @@
+package codec
+
+import (
+	"bytes"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type base struct {
+	ID        string `json:"id"`
+	CreatedBy string `json:"created_by,omitempty"`
+	Page      uint   `json:"-"`
+}
+
+type user struct {
+	base
+
+	Name    string            `json:"name"`
+	Age     uint              `json:"age"`
+	Email   *string           `json:"email,omitempty"`
+	Enabled bool              `json:"enabled"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	secret  string            //nolint:unused
+}
+
+func TestColumns(t *testing.T) {
+	cols := Columns(reflect.TypeFor[*user]())
+	names := make([]string, 0, len(cols))
+	for _, col := range cols {
+		names = append(names, col.Name)
+	}
+	assert.Equal(t, []string{"id", "created_by", "name", "age", "email", "enabled", "labels"}, names)
+
+	cols = Columns(reflect.TypeFor[*user](), "name", "CreatedBy", "unknown", " id ")
+	names = names[:0]
+	for _, col := range cols {
+		names = append(names, col.Name)
+	}
+	assert.Equal(t, []string{"name", "created_by", "id"}, names)
+}
+
+func TestRoundTrip(t *testing.T) {
+	email := "root@example.com"
+	users := []*user{
+		{base: base{ID: "1"}, Name: "root", Age: 30, Email: &email, Enabled: true, Labels: map[string]string{"a": "b"}},
+		{base: base{ID: "2"}, Name: "guest, \"quoted\"", Age: 0},
+	}
+
+	for _, format := range []Format{FormatCSV, FormatXLSX, FormatNDJSON} {
+		t.Run(string(format), func(t *testing.T) {
+			data, err := Marshal(format, nil, users...)
+			require.NoError(t, err)
+			assert.Equal(t, format, Detect(data))
+
+			decoded, err := Unmarshal[*user]("", bytes.NewReader(data))
+			require.NoError(t, err)
+			assert.Equal(t, users, decoded)
+		})
+	}
+}
+
+func TestMarshalSelect(t *testing.T) {
+	data, err := Marshal(FormatCSV, []string{"name", "age"}, &user{base: base{ID: "1"}, Name: "root", Age: 30})
+	require.NoError(t, err)
+	assert.Equal(t, "name,age\nroot,30\n", string(data))
+
+	data, err = Marshal(FormatNDJSON, []string{"name", "age"}, &user{base: base{ID: "1"}, Name: "root", Age: 30})
+	require.NoError(t, err)
+	assert.Equal(t, `{"name":"root","age":30}`+"\n", string(data))
+}
+
+func TestUnmarshalRowErrors(t *testing.T) {
+	input := strings.Join([]string{
+		"ID,Name,Age,unknown",
+		"1,root,30,x",
+		"2,guest,abc,x",
+		"",
+		"3,admin,-1,x",
+	}, "\n")
+	users, err := Unmarshal[*user](FormatCSV, strings.NewReader(input))
+	var rowErrs RowErrors
+	require.ErrorAs(t, err, &rowErrs)
+	require.Len(t, users, 1)
+	assert.Equal(t, "root", users[0].Name)
+	require.Len(t, rowErrs, 2)
+	assert.Equal(t, 3, rowErrs[0].Row)
+	assert.Equal(t, "age", rowErrs[0].Column)
+	assert.Equal(t, 5, rowErrs[1].Row)
+	rows, err := Rows(FormatCSV, []byte(input))
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 3, 5}, rows)
+
+	users, err = Unmarshal[*user](FormatNDJSON, strings.NewReader("{\"name\":\"root\"}\n{bad json}\n"))
+	require.ErrorAs(t, err, &rowErrs)
+	require.Len(t, users, 1)
+	assert.Equal(t, 2, rowErrs[0].Row)
+	rows, err = Rows("", []byte("{\"name\":\"root\"}\n\n{bad json}\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 3}, rows)
+}
+
+func TestNegotiate(t *testing.T) {
+	header := http.Header{}
+	assert.Equal(t, DefaultFormat, Negotiate(nil, header))
+	header.Set("Accept", "application/json, text/csv;q=0.9")
+	assert.Equal(t, FormatCSV, Negotiate(nil, header))
+	assert.Equal(t, FormatNDJSON, Negotiate(map[string][]string{"_format": {"jsonl"}}, header))
+	assert.Equal(t, FormatCSV, Negotiate(map[string][]string{"_format": {"unknown"}}, header))
+}
@@ -0,0 +1,264 @@
+package codec
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/types/consts"
+	"github.com/stoewer/go-strcase"
+)
+
+var (
+	timeType            = reflect.TypeFor[time.Time]()
+	textMarshalerType   = reflect.TypeFor[encoding.TextMarshaler]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// Column describes one exported/imported column.
+type Column struct {
+	// Name is the column header, which is the json tag name of the field,
+	// or the field name if json tag not set.
+	Name string
+
+	index []int
+}
+
+// Columns returns the columns of the structure type, typ can be a structure
+// or a pointer to structure.
+//
+// If selects is not empty, only the selected columns are returned in the given order,
+// a select item matches either the column name or its snake case, case-insensitive,
+// so the value of `_select` query parameter can be reused.
+func Columns(typ reflect.Type, selects ...string) []Column {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+	all := dedupColumns(collectColumns(typ, nil, nil))
+
+	wanted := make([]string, 0, len(selects))
+	for _, s := range selects {
+		if s = strings.TrimSpace(s); len(s) > 0 {
+			wanted = append(wanted, s)
+		}
+	}
+	if len(wanted) == 0 {
+		return all
+	}
+	cols := make([]Column, 0, len(wanted))
+	for _, s := range wanted {
+		if col, ok := lookupColumn(all, s); ok {
+			cols = append(cols, col)
+		}
+	}
+	return cols
+}
+
+func collectColumns(typ reflect.Type, parent []int, cols []Column) []Column {
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		name, _, _ := strings.Cut(strings.TrimSpace(field.Tag.Get(consts.TAG_JSON)), ",")
+		if name == "-" {
+			continue
+		}
+		index := append(append(make([]int, 0, len(parent)+1), parent...), i)
+
+		// Flatten embedded structure, eg: model.Base.
+		if field.Anonymous && len(name) == 0 {
+			if ft := indirectType(field.Type); ft.Kind() == reflect.Struct {
+				cols = collectColumns(ft, index, cols)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		switch indirectType(field.Type).Kind() {
+		case reflect.Chan, reflect.Func, reflect.UnsafePointer, reflect.Interface:
+			continue
+		}
+		if len(name) == 0 {
+			name = field.Name
+		}
+		cols = append(cols, Column{Name: name, index: index})
+	}
+	return cols
+}
+
+// dedupColumns removes the columns with duplicated name, the shallower field wins,
+// same as the rule of encoding/json.
+func dedupColumns(cols []Column) []Column {
+	depth := make(map[string]int, len(cols))
+	for _, col := range cols {
+		if d, ok := depth[col.Name]; !ok || len(col.index) < d {
+			depth[col.Name] = len(col.index)
+		}
+	}
+	result := make([]Column, 0, len(cols))
+	for _, col := range cols {
+		if d, ok := depth[col.Name]; ok && d == len(col.index) {
+			result = append(result, col)
+			delete(depth, col.Name)
+		}
+	}
+	return result
+}
+
+func lookupColumn(cols []Column, name string) (Column, bool) {
+	name = strings.TrimSpace(name)
+	snake := strcase.SnakeCase(name)
+	for _, col := range cols {
+		if strings.EqualFold(col.Name, name) || strcase.SnakeCase(col.Name) == snake {
+			return col, true
+		}
+	}
+	return Column{}, false
+}
+
+// field returns the field value of the column, the returned value is invalid
+// if the column is inside a nil embedded pointer.
+func (col Column) field(val reflect.Value, alloc bool) reflect.Value {
+	for i, x := range col.index {
+		if i > 0 && val.Kind() == reflect.Pointer {
+			if val.IsNil() {
+				if !alloc {
+					return reflect.Value{}
+				}
+				val.Set(reflect.New(val.Type().Elem()))
+			}
+			val = val.Elem()
+		}
+		val = val.Field(x)
+	}
+	return val
+}
+
+// format formats the field value to cell string.
+func (col Column) format(val reflect.Value) (string, error) {
+	v := col.field(val, false)
+	if !v.IsValid() {
+		return "", nil
+	}
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	if v.Type() == timeType {
+		t := v.Interface().(time.Time) //nolint:errcheck
+		if t.IsZero() {
+			return "", nil
+		}
+		return t.Format(consts.DATE_TIME_LAYOUT), nil
+	}
+	if v.Type().Implements(textMarshalerType) {
+		b, err := v.Interface().(encoding.TextMarshaler).MarshalText() //nolint:errcheck
+		return string(b), err
+	}
+	if reflect.PointerTo(v.Type()).Implements(textMarshalerType) && v.CanAddr() {
+		b, err := v.Addr().Interface().(encoding.TextMarshaler).MarshalText() //nolint:errcheck
+		return string(b), err
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
+	case reflect.Slice, reflect.Map:
+		if v.IsNil() {
+			return "", nil
+		}
+	}
+	// structure, slice, map, array are encoded as json.
+	b, err := json.Marshal(v.Interface())
+	return string(b), err
+}
+
+// parse parses the cell string and set it to field value.
+// Empty cell keeps the field zero value.
+func (col Column) parse(val reflect.Value, s string) error {
+	if len(s) == 0 {
+		return nil
+	}
+	v := col.field(val, true)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	if v.Type() == timeType {
+		t, err := parseTime(s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if reflect.PointerTo(v.Type()).Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)) //nolint:errcheck
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return json.Unmarshal([]byte(s), v.Addr().Interface())
+	}
+	return nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range []string{consts.DATE_TIME_LAYOUT, time.RFC3339Nano, time.DateOnly} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.Newf("invalid time %q", s)
+}
+
+func indirectType(typ reflect.Type) reflect.Type {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	return typ
+}
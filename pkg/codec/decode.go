@@ -0,0 +1,230 @@
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/xuri/excelize/v2"
+)
+
+// RowError records why a row failed to be imported.
+type RowError struct {
+	// Row is the 1-based line number in the source file, the header line
+	// of csv/xlsx is counted as row 1.
+	Row    int    `json:"row"`
+	Column string `json:"column,omitempty"`
+	Error  string `json:"error"`
+}
+
+// RowErrors is returned by Unmarshal together with the rows successfully decoded
+// when some rows of the source file are invalid.
+type RowErrors []RowError
+
+func (re RowErrors) Error() string {
+	if len(re) == 0 {
+		return ""
+	}
+	first := re[0]
+	msg := fmt.Sprintf("row %d: %s", first.Row, first.Error)
+	if len(first.Column) > 0 {
+		msg = fmt.Sprintf("row %d, column %q: %s", first.Row, first.Column, first.Error)
+	}
+	if len(re) > 1 {
+		msg += fmt.Sprintf(" (and %d more errors)", len(re)-1)
+	}
+	return msg
+}
+
+// Unmarshal decodes the content of r into models, M must be a pointer to structure.
+// The format is detected from the content if format is empty.
+//
+// The header row of csv/xlsx is mapped back to the model fields by column name,
+// unknown headers are ignored.
+//
+// If some rows are invalid, the successfully decoded rows are returned together
+// with a RowErrors error, other errors means the whole content cannot be decoded.
+func Unmarshal[M any](format Format, r io.Reader) ([]M, error) {
+	typ := reflect.TypeFor[M]()
+	if typ.Kind() != reflect.Pointer || typ.Elem().Kind() != reflect.Struct {
+		return nil, errors.Newf("model must be a pointer to structure, got %s", typ)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(format) == 0 {
+		format = Detect(data)
+	}
+
+	if format == FormatNDJSON {
+		return decodeNDJSON[M](data)
+	}
+	records, lines, err := readRecords(format, data)
+	if err != nil {
+		return nil, err
+	}
+	return decodeRecords[M](records, lines)
+}
+
+// Rows returns the line numbers of the data rows of the content, in the same order as
+// the models decoded by Unmarshal, the header and blank rows are not included.
+// It's used to locate the decoded models in the source file, eg: the failures after
+// decoding are reported by RowError.
+func Rows(format Format, data []byte) ([]int, error) {
+	if len(format) == 0 {
+		format = Detect(data)
+	}
+	rows := make([]int, 0)
+	if format == FormatNDJSON {
+		scanner := bufio.NewScanner(bytes.NewReader(bytes.TrimPrefix(data, utf8BOM)))
+		scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+		for rowNum := 1; scanner.Scan(); rowNum++ {
+			if len(bytes.TrimSpace(scanner.Bytes())) > 0 {
+				rows = append(rows, rowNum)
+			}
+		}
+		return rows, scanner.Err()
+	}
+	records, lines, err := readRecords(format, data)
+	if err != nil {
+		return nil, err
+	}
+	for i := 1; i < len(records); i++ {
+		if !isBlank(records[i]) {
+			rows = append(rows, lines[i])
+		}
+	}
+	return rows, nil
+}
+
+// readRecords reads the records of csv/xlsx and the line number of each record.
+func readRecords(format Format, data []byte) ([][]string, []int, error) {
+	switch format {
+	case FormatCSV:
+		cr := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, utf8BOM)))
+		cr.FieldsPerRecord = -1
+		records := make([][]string, 0)
+		lines := make([]int, 0)
+		for {
+			record, err := cr.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			line, _ := cr.FieldPos(0)
+			records = append(records, record)
+			lines = append(lines, line)
+		}
+		return records, lines, nil
+	case FormatXLSX:
+		f, err := excelize.OpenReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, nil, err
+		}
+		defer f.Close()
+		sheets := f.GetSheetList()
+		if len(sheets) == 0 {
+			return nil, nil, nil
+		}
+		records, err := f.GetRows(sheets[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		lines := make([]int, len(records))
+		for i := range records {
+			lines[i] = i + 1
+		}
+		return records, lines, nil
+	default:
+		return nil, nil, errors.Newf("unsupported format %q", format)
+	}
+}
+
+// decodeRecords decodes the records, the first record is the header,
+// lines are the line numbers of each record in the source file.
+func decodeRecords[M any](records [][]string, lines []int) ([]M, error) {
+	result := make([]M, 0, len(records))
+	if len(records) == 0 {
+		return result, nil
+	}
+	typ := reflect.TypeFor[M]().Elem()
+	all := Columns(typ)
+	columns := make([]*Column, len(records[0]))
+	for i, name := range records[0] {
+		if col, ok := lookupColumn(all, name); ok {
+			columns[i] = &col
+		}
+	}
+
+	var rowErrs RowErrors
+	for i, record := range records[1:] {
+		if isBlank(record) {
+			continue
+		}
+		rowNum := lines[i+1]
+		val := reflect.New(typ)
+		var failed bool
+		for j, cell := range record {
+			if j >= len(columns) || columns[j] == nil {
+				continue
+			}
+			if err := columns[j].parse(val.Elem(), strings.TrimSpace(cell)); err != nil {
+				rowErrs = append(rowErrs, RowError{Row: rowNum, Column: columns[j].Name, Error: err.Error()})
+				failed = true
+			}
+		}
+		if !failed {
+			result = append(result, val.Interface().(M)) //nolint:errcheck
+		}
+	}
+	if len(rowErrs) > 0 {
+		return result, rowErrs
+	}
+	return result, nil
+}
+
+func decodeNDJSON[M any](data []byte) ([]M, error) {
+	result := make([]M, 0)
+	typ := reflect.TypeFor[M]().Elem()
+
+	var rowErrs RowErrors
+	scanner := bufio.NewScanner(bytes.NewReader(bytes.TrimPrefix(data, utf8BOM)))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for rowNum := 1; scanner.Scan(); rowNum++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		val := reflect.New(typ)
+		if err := json.Unmarshal(line, val.Interface()); err != nil {
+			rowErrs = append(rowErrs, RowError{Row: rowNum, Error: err.Error()})
+			continue
+		}
+		result = append(result, val.Interface().(M)) //nolint:errcheck
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(rowErrs) > 0 {
+		return result, rowErrs
+	}
+	return result, nil
+}
+
+func isBlank(record []string) bool {
+	for _, cell := range record {
+		if len(strings.TrimSpace(cell)) > 0 {
+			return false
+		}
+	}
+	return true
+}
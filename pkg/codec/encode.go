@@ -0,0 +1,224 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"reflect"
+
+	"github.com/cockroachdb/errors"
+	"github.com/xuri/excelize/v2"
+)
+
+// SheetName is the worksheet name of the xlsx exported.
+const SheetName = "Sheet1"
+
+// Encoder writes rows to the underlying writer in a specific format.
+//
+// Encode can be called any times, and Close must be called once after all rows
+// written to flush the buffered data, Close doesn't close the underlying writer.
+type Encoder interface {
+	Encode(rows ...any) error
+	Close() error
+}
+
+// NewEncoder creates an Encoder writes the columns of each row to w.
+// The header row is written immediately for csv and xlsx.
+func NewEncoder(w io.Writer, format Format, columns []Column) (Encoder, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVEncoder(w, columns)
+	case FormatNDJSON:
+		return &ndjsonEncoder{w: w, columns: columns}, nil
+	case FormatXLSX:
+		return newXLSXEncoder(w, columns)
+	default:
+		return nil, errors.Newf("unsupported format %q", format)
+	}
+}
+
+// Marshal encodes data to the given format, selects is used to choose and order
+// the columns, all columns are exported if selects is empty.
+func Marshal[M any](format Format, selects []string, data ...M) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	enc, err := NewEncoder(buf, format, Columns(reflect.TypeFor[M](), selects...))
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range data {
+		if err = enc.Encode(m); err != nil {
+			return nil, err
+		}
+	}
+	if err = enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func formatRow(columns []Column, row any) ([]string, error) {
+	val := reflect.Indirect(reflect.ValueOf(row))
+	cells := make([]string, len(columns))
+	if !val.IsValid() {
+		return cells, nil
+	}
+	if val.Kind() != reflect.Struct {
+		return nil, errors.Newf("row must be structure or pointer to structure, got %s", val.Type())
+	}
+	var err error
+	for i, col := range columns {
+		if cells[i], err = col.format(val); err != nil {
+			return nil, errors.Wrapf(err, "column %q", col.Name)
+		}
+	}
+	return cells, nil
+}
+
+func headerRow(columns []Column) []string {
+	header := make([]string, len(columns))
+	for i := range columns {
+		header[i] = columns[i].Name
+	}
+	return header
+}
+
+type csvEncoder struct {
+	w       *csv.Writer
+	columns []Column
+}
+
+func newCSVEncoder(w io.Writer, columns []Column) (*csvEncoder, error) {
+	enc := &csvEncoder{w: csv.NewWriter(w), columns: columns}
+	if err := enc.w.Write(headerRow(columns)); err != nil {
+		return nil, err
+	}
+	return enc, nil
+}
+
+func (e *csvEncoder) Encode(rows ...any) error {
+	for _, row := range rows {
+		cells, err := formatRow(e.columns, row)
+		if err != nil {
+			return err
+		}
+		if err = e.w.Write(cells); err != nil {
+			return err
+		}
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvEncoder) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type ndjsonEncoder struct {
+	w       io.Writer
+	columns []Column
+	buf     bytes.Buffer
+}
+
+// Encode writes each row as a json object contains only the selected columns,
+// the values are marshaled by encoding/json to keep their json types.
+func (e *ndjsonEncoder) Encode(rows ...any) error {
+	for _, row := range rows {
+		val := reflect.Indirect(reflect.ValueOf(row))
+		if val.IsValid() && val.Kind() != reflect.Struct {
+			return errors.Newf("row must be structure or pointer to structure, got %s", val.Type())
+		}
+		e.buf.Reset()
+		e.buf.WriteByte('{')
+		for i, col := range e.columns {
+			if i > 0 {
+				e.buf.WriteByte(',')
+			}
+			key, _ := json.Marshal(col.Name)
+			e.buf.Write(key)
+			e.buf.WriteByte(':')
+
+			var fv reflect.Value
+			if val.IsValid() {
+				fv = col.field(val, false)
+			}
+			if !fv.IsValid() {
+				e.buf.WriteString("null")
+				continue
+			}
+			b, err := json.Marshal(fv.Interface())
+			if err != nil {
+				return errors.Wrapf(err, "column %q", col.Name)
+			}
+			e.buf.Write(b)
+		}
+		e.buf.WriteString("}\n")
+		if _, err := e.w.Write(e.buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *ndjsonEncoder) Close() error { return nil }
+
+// xlsxEncoder uses the excelize stream writer, rows are buffered by excelize
+// (spilled to temporary file if too large) and the workbook is written on Close.
+type xlsxEncoder struct {
+	w       io.Writer
+	f       *excelize.File
+	sw      *excelize.StreamWriter
+	columns []Column
+	row     int
+}
+
+func newXLSXEncoder(w io.Writer, columns []Column) (*xlsxEncoder, error) {
+	f := excelize.NewFile()
+	sw, err := f.NewStreamWriter(SheetName)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	enc := &xlsxEncoder{w: w, f: f, sw: sw, columns: columns}
+	if err = enc.writeRow(headerRow(columns)); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return enc, nil
+}
+
+func (e *xlsxEncoder) Encode(rows ...any) error {
+	for _, row := range rows {
+		cells, err := formatRow(e.columns, row)
+		if err != nil {
+			return err
+		}
+		if err = e.writeRow(cells); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *xlsxEncoder) writeRow(cells []string) error {
+	e.row++
+	cell, err := excelize.CoordinatesToCellName(1, e.row)
+	if err != nil {
+		return err
+	}
+	values := make([]any, len(cells))
+	for i := range cells {
+		values[i] = cells[i]
+	}
+	return e.sw.SetRow(cell, values)
+}
+
+func (e *xlsxEncoder) Close() error {
+	defer e.f.Close()
+	if err := e.sw.Flush(); err != nil {
+		return err
+	}
+	_, err := e.f.WriteTo(e.w)
+	return err
+}
@@ -0,0 +1,172 @@
+// Package filter parses the operator-aware filter conditions from url query.
+//
+// Two forms are supported and combined with AND:
+//
+//  1. Operator suffix in the query key: "field[op]=value", eg:
+//
+//     age[gte]=18&name[ne]=root&status[in]=a,b&deleted_at[null]=true&Profile.city[like]=york
+//
+//  2. Json encoded types.Filter in "_filter" query parameter, which supports nested AND/OR groups, eg:
+//
+//     _filter={"or":[{"field":"age","op":"lt","values":["18"]},{"and":[{"field":"status","op":"in","values":["a","b"]}]}]}
+//
+// The supported operators are defined by types.FilterOps.
+package filter
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+)
+
+const (
+	// MaxDepth is the max nesting depth of AND/OR groups.
+	MaxDepth = 5
+	// MaxConditions is the max number of conditions in one filter.
+	MaxConditions = 50
+)
+
+var (
+	keyRegexp   = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_.]*)\[([a-z]+)\]$`)
+	fieldRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+)
+
+// Parse parses the filter from url query, it returns nil filter if the query
+// contains no filter condition.
+func Parse(query url.Values) (*types.Filter, error) {
+	root := new(types.Filter)
+
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		matches := keyRegexp.FindStringSubmatch(key)
+		if matches == nil {
+			continue
+		}
+		op := types.FilterOp(matches[2])
+		values := query[key]
+		switch op {
+		case types.FilterIn, types.FilterNin, types.FilterBetween:
+			values = splitValues(values)
+		default:
+			if len(values) > 1 {
+				values = values[:1]
+			}
+		}
+		root.And = append(root.And, &types.Filter{Field: matches[1], Op: op, Values: values})
+	}
+
+	if raw := strings.TrimSpace(query.Get(consts.QUERY_FILTER)); len(raw) > 0 {
+		f := new(types.Filter)
+		if err := json.Unmarshal([]byte(raw), f); err != nil {
+			return nil, errors.Wrapf(err, "invalid %s", consts.QUERY_FILTER)
+		}
+		root.And = append(root.And, f)
+	}
+
+	if root.IsEmpty() {
+		return nil, nil
+	}
+	if err := Validate(root); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// Validate checks the filter is well-formed: each node is either a condition or a group,
+// the operators and field names are valid, the number of values matches the operator,
+// and the filter doesn't exceed MaxDepth and MaxConditions.
+func Validate(f *types.Filter) error {
+	count := 0
+	return validate(f, 1, &count)
+}
+
+func validate(f *types.Filter, depth int, count *int) error {
+	if f == nil {
+		return nil
+	}
+	if depth > MaxDepth {
+		return errors.Newf("filter nesting exceeds max depth %d", MaxDepth)
+	}
+	isGroup := len(f.And) > 0 || len(f.Or) > 0
+	if len(f.Field) == 0 {
+		if !isGroup && (len(f.Op) > 0 || len(f.Values) > 0) {
+			return errors.New("filter field is required")
+		}
+		for _, sub := range f.And {
+			if err := validate(sub, depth+1, count); err != nil {
+				return err
+			}
+		}
+		for _, sub := range f.Or {
+			if err := validate(sub, depth+1, count); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if isGroup {
+		return errors.Newf("filter %q cannot be both condition and group", f.Field)
+	}
+
+	*count++
+	if *count > MaxConditions {
+		return errors.Newf("filter conditions exceed max count %d", MaxConditions)
+	}
+	if !fieldRegexp.MatchString(f.Field) {
+		return errors.Newf("invalid filter field %q", f.Field)
+	}
+	if len(f.Op) == 0 {
+		f.Op = types.FilterEq
+	}
+	if !slices.Contains(types.FilterOps, f.Op) {
+		return errors.Newf("unknown filter operator %q of field %q", f.Op, f.Field)
+	}
+
+	switch f.Op {
+	case types.FilterIn, types.FilterNin:
+		if len(f.Values) == 0 {
+			return errors.Newf("filter %s[%s] requires at least one value", f.Field, f.Op)
+		}
+	case types.FilterBetween:
+		if len(f.Values) != 2 {
+			return errors.Newf("filter %s[%s] requires exactly two values", f.Field, f.Op)
+		}
+	case types.FilterNull:
+		if len(f.Values) > 1 {
+			return errors.Newf("filter %s[%s] requires at most one value", f.Field, f.Op)
+		}
+		if len(f.Values) == 1 && len(f.Values[0]) > 0 {
+			if _, err := strconv.ParseBool(f.Values[0]); err != nil {
+				return errors.Newf("filter %s[%s] requires boolean value, got %q", f.Field, f.Op, f.Values[0])
+			}
+		}
+	default:
+		if len(f.Values) != 1 {
+			return errors.Newf("filter %s[%s] requires exactly one value", f.Field, f.Op)
+		}
+	}
+	return nil
+}
+
+func splitValues(values []string) []string {
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		for item := range strings.SplitSeq(v, ",") {
+			if item = strings.TrimSpace(item); len(item) > 0 {
+				result = append(result, item)
+			}
+		}
+	}
+	return result
+}
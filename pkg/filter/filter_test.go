@@ -0,0 +1,65 @@
+package filter
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/forbearing/gst/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	f, err := Parse(url.Values{"name": {"root"}, "_page": {"1"}})
+	require.NoError(t, err)
+	assert.Nil(t, f)
+
+	f, err = Parse(url.Values{
+		"age[gte]":           {"18"},
+		"status[in]":         {"a, b", "c"},
+		"deleted_at[null]":   {"true"},
+		"Profile.city[like]": {"york"},
+		"_filter":            {`{"or":[{"field":"age","values":["1"]},{"field":"name","op":"ne","values":["x"]}]}`},
+	})
+	require.NoError(t, err)
+	require.Len(t, f.And, 5)
+	assert.Equal(t, &types.Filter{Field: "Profile.city", Op: types.FilterLike, Values: []string{"york"}}, f.And[0])
+	assert.Equal(t, &types.Filter{Field: "age", Op: types.FilterGte, Values: []string{"18"}}, f.And[1])
+	assert.Equal(t, []string{"a", "b", "c"}, f.And[3].Values)
+	// the missing operator defaults to eq.
+	assert.Equal(t, types.FilterEq, f.And[4].Or[0].Op)
+
+	for query, msg := range map[string]string{
+		"age[foo]=1":          "unknown filter operator",
+		"age[between]=1":      "exactly two values",
+		"age[gt]=1&age[gt]=2": "",
+		"age[null]=yes":       "boolean value",
+		"_filter={":           "invalid _filter",
+		"_filter=" + url.QueryEscape(`{"field":"a","and":[{"field":"b","values":["1"]}]}`): "both condition and group",
+		"_filter=" + url.QueryEscape(`{"field":"a;drop","values":["1"]}`):                  "invalid filter field",
+	} {
+		values, err := url.ParseQuery(query)
+		require.NoError(t, err)
+		_, err = Parse(values)
+		if len(msg) == 0 {
+			assert.NoError(t, err, query)
+			continue
+		}
+		require.Error(t, err, query)
+		assert.Contains(t, err.Error(), msg, query)
+	}
+}
+
+func TestValidateLimits(t *testing.T) {
+	deep := &types.Filter{Field: "a", Values: []string{"1"}}
+	for range MaxDepth {
+		deep = &types.Filter{And: []*types.Filter{deep}}
+	}
+	require.ErrorContains(t, Validate(deep), "max depth")
+
+	many := new(types.Filter)
+	for range MaxConditions + 1 {
+		many.Or = append(many.Or, &types.Filter{Field: "a", Values: []string{"1"}})
+	}
+	require.ErrorContains(t, Validate(many), "max count")
+}
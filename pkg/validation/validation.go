@@ -0,0 +1,246 @@
+// Package validation validates the request payloads by the `validate` struct tags
+// and the optional types.Validator interface.
+//
+// The `validate` tag uses the rules of github.com/go-playground/validator/v10,
+// eg: `validate:"required,email,max=64"`, and the field errors are reported
+// with their json names so they can be returned to the client as is.
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+	"github.com/go-playground/validator/v10"
+)
+
+var validate = newValidate()
+
+func newValidate() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.SetTagName(consts.TAG_VALIDATE)
+	return v
+}
+
+// FieldError describes why a field failed the validation.
+type FieldError struct {
+	// Field is the json path of the field, eg: "email", "items[0].email".
+	// It is empty if the error is not bound to any field.
+	Field string `json:"field,omitempty"`
+	// Rule is the validation rule the field failed, eg: "required", "max".
+	Rule string `json:"rule,omitempty"`
+	// Param is the parameter of the rule, eg: "64" of "max=64".
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// Errors is the list of field errors returned by Struct and Validate.
+type Errors []FieldError
+
+func (errs Errors) Error() string {
+	if len(errs) == 0 {
+		return ""
+	}
+	msg := errs[0].Message
+	if len(errs) > 1 {
+		msg += fmt.Sprintf(" (and %d more errors)", len(errs)-1)
+	}
+	return msg
+}
+
+// WithPrefix returns a copy of errs with prefix prepended to the field of each error,
+// it is used to locate the errors of the items in batch request, eg: "items[0]".
+func (errs Errors) WithPrefix(prefix string) Errors {
+	result := make(Errors, len(errs))
+	for i, fe := range errs {
+		switch {
+		case len(fe.Field) == 0:
+			fe.Field = prefix
+		case strings.HasPrefix(fe.Field, "["):
+			fe.Field = prefix + fe.Field
+		default:
+			fe.Field = prefix + "." + fe.Field
+		}
+		result[i] = fe
+	}
+	return result
+}
+
+// NewError creates Errors contains only one field error,
+// it is intended to be returned by the types.Validator implementations.
+func NewError(field, message string) Errors {
+	return Errors{{Field: field, Message: message}}
+}
+
+// RegisterRule registers a custom rule that can be used in the `validate` tags.
+// It must be called before any validation happens, eg: in init function.
+func RegisterRule(rule string, fn func(value reflect.Value, param string) bool) error {
+	return validate.RegisterValidation(rule, func(fl validator.FieldLevel) bool {
+		return fn(fl.Field(), fl.Param())
+	})
+}
+
+// Struct validates v by the `validate` struct tags, v should be a structure
+// or pointer to structure, otherwise it's always valid.
+// The returned error is of type Errors if validation failed.
+func Struct(v any) error {
+	typ := reflect.TypeOf(v)
+	if typ == nil {
+		return nil
+	}
+	val := reflect.ValueOf(v)
+	for typ.Kind() == reflect.Pointer {
+		if val.IsNil() {
+			return nil
+		}
+		typ, val = typ.Elem(), val.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+
+	err := validate.Struct(val.Interface())
+	if err == nil {
+		return nil
+	}
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return err
+	}
+	errs := make(Errors, 0, len(verrs))
+	for _, fe := range verrs {
+		field := fieldPath(typ, fe.StructNamespace())
+		errs = append(errs, FieldError{
+			Field:   field,
+			Rule:    fe.Tag(),
+			Param:   fe.Param(),
+			Message: message(field, fe),
+		})
+	}
+	return errs
+}
+
+// Validate validates v by the `validate` struct tags, then calls the Validate method
+// if v implements types.Validator.
+//
+// Errors returned by types.Validator that are not of type Errors are converted to
+// Errors contains one error without field.
+func Validate(ctx *types.ServiceContext, v any) error {
+	if err := Struct(v); err != nil {
+		return err
+	}
+	vd, ok := v.(types.Validator)
+	if !ok {
+		return nil
+	}
+	if err := vd.Validate(ctx); err != nil {
+		var errs Errors
+		if errors.As(err, &errs) {
+			return errs
+		}
+		return Errors{{Message: err.Error()}}
+	}
+	return nil
+}
+
+// fieldPath converts the structure namespace reported by validator,
+// eg: "User.Base.Profile.Emails[0]", to json path, eg: "profile.emails[0]".
+// The anonymous embedded structures without json name are flattened like encoding/json.
+func fieldPath(typ reflect.Type, ns string) string {
+	segs := strings.Split(ns, ".")
+	if len(segs) > 0 {
+		segs = segs[1:] // the first segment is the structure name.
+	}
+	parts := make([]string, 0, len(segs))
+	for _, seg := range segs {
+		name, index, _ := strings.Cut(seg, "[")
+		for typ != nil && (typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array || typ.Kind() == reflect.Map) {
+			typ = typ.Elem()
+		}
+		if typ == nil || typ.Kind() != reflect.Struct {
+			typ = nil
+			parts = append(parts, seg)
+			continue
+		}
+		field, ok := typ.FieldByName(name)
+		if !ok {
+			typ = nil
+			parts = append(parts, seg)
+			continue
+		}
+		typ = field.Type
+		jsonName, _, _ := strings.Cut(field.Tag.Get(consts.TAG_JSON), ",")
+		if field.Anonymous && len(jsonName) == 0 && len(index) == 0 {
+			continue
+		}
+		if len(jsonName) == 0 || jsonName == "-" {
+			jsonName = field.Name
+		}
+		if len(index) > 0 {
+			jsonName += "[" + index
+		}
+		parts = append(parts, jsonName)
+	}
+	return strings.Join(parts, ".")
+}
+
+// message returns the human readable message of the common rules.
+func message(field string, fe validator.FieldError) string {
+	if len(field) == 0 {
+		field = "value"
+	}
+	param := fe.Param()
+	kind := fe.Kind()
+	isLength := kind == reflect.String || kind == reflect.Slice || kind == reflect.Array || kind == reflect.Map
+	unit := "items"
+	if kind == reflect.String {
+		unit = "characters"
+	}
+
+	switch fe.Tag() {
+	case "required", "required_if", "required_unless", "required_with", "required_without":
+		return fmt.Sprintf("%s is required", field)
+	case "email":
+		return fmt.Sprintf("%s must be a valid email address", field)
+	case "url", "http_url":
+		return fmt.Sprintf("%s must be a valid url", field)
+	case "uuid", "uuid4":
+		return fmt.Sprintf("%s must be a valid uuid", field)
+	case "ip", "ipv4", "ipv6":
+		return fmt.Sprintf("%s must be a valid %s address", field, fe.Tag())
+	case "oneof":
+		return fmt.Sprintf("%s must be one of [%s]", field, strings.Join(strings.Fields(param), ", "))
+	case "len":
+		if isLength {
+			return fmt.Sprintf("%s must be exactly %s %s", field, param, unit)
+		}
+		return fmt.Sprintf("%s must be equal to %s", field, param)
+	case "min", "gte":
+		if isLength {
+			return fmt.Sprintf("%s must be at least %s %s", field, param, unit)
+		}
+		return fmt.Sprintf("%s must be greater than or equal to %s", field, param)
+	case "max", "lte":
+		if isLength {
+			return fmt.Sprintf("%s must be at most %s %s", field, param, unit)
+		}
+		return fmt.Sprintf("%s must be less than or equal to %s", field, param)
+	case "gt":
+		if isLength {
+			return fmt.Sprintf("%s must be more than %s %s", field, param, unit)
+		}
+		return fmt.Sprintf("%s must be greater than %s", field, param)
+	case "lt":
+		if isLength {
+			return fmt.Sprintf("%s must be less than %s %s", field, param, unit)
+		}
+		return fmt.Sprintf("%s must be less than %s", field, param)
+	}
+	if len(param) > 0 {
+		return fmt.Sprintf("%s failed on the %q rule with %q", field, fe.Tag(), param)
+	}
+	return fmt.Sprintf("%s failed on the %q rule", field, fe.Tag())
+}
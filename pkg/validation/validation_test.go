@@ -0,0 +1,94 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/forbearing/gst/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type base struct {
+	ID string `json:"id" validate:"omitempty,len=3"`
+}
+
+type address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type user struct {
+	base
+
+	Name      string    `json:"name" validate:"required,max=8"`
+	Email     string    `json:"email,omitempty" validate:"omitempty,email"`
+	Age       int       `json:"age" validate:"gte=0,lte=150"`
+	Role      string    `json:"role" validate:"omitempty,oneof=admin guest"`
+	Addresses []address `json:"addresses" validate:"dive"`
+	Password  string    `json:"-" validate:"omitempty,min=6"`
+}
+
+func (u *user) Validate(*types.ServiceContext) error {
+	if u.Name == "root" && u.Role != "admin" {
+		return NewError("role", "root must be admin")
+	}
+	return nil
+}
+
+type plain struct {
+	Name string `json:"name"`
+}
+
+func (*plain) Validate(*types.ServiceContext) error { return errors.New("always invalid") }
+
+func TestStruct(t *testing.T) {
+	require.NoError(t, Struct(&user{Name: "alice", Age: 20}))
+	require.NoError(t, Struct(nil))
+	require.NoError(t, Struct((*user)(nil)))
+	require.NoError(t, Struct("not a struct"))
+
+	err := Struct(&user{
+		base:      base{ID: "1"},
+		Email:     "invalid",
+		Age:       200,
+		Role:      "root",
+		Addresses: []address{{City: "x"}, {}},
+		Password:  "123",
+	})
+	var errs Errors
+	require.ErrorAs(t, err, &errs)
+
+	fields := make(map[string]FieldError, len(errs))
+	for _, fe := range errs {
+		fields[fe.Field] = fe
+	}
+	assert.Len(t, fields, 7)
+	assert.Equal(t, "len", fields["id"].Rule)
+	assert.Equal(t, "name is required", fields["name"].Message)
+	assert.Equal(t, "email must be a valid email address", fields["email"].Message)
+	assert.Equal(t, "lte", fields["age"].Rule)
+	assert.Equal(t, "150", fields["age"].Param)
+	assert.Equal(t, "role must be one of [admin, guest]", fields["role"].Message)
+	assert.Equal(t, "required", fields["addresses[1].city"].Rule)
+	assert.Equal(t, "Password must be at least 6 characters", fields["Password"].Message)
+}
+
+func TestValidate(t *testing.T) {
+	require.NoError(t, Validate(nil, &user{Name: "root", Role: "admin"}))
+
+	var errs Errors
+	require.ErrorAs(t, Validate(nil, &user{Name: "root"}), &errs)
+	assert.Equal(t, Errors{{Field: "role", Message: "root must be admin"}}, errs)
+
+	// struct tags are checked before the Validate method.
+	require.ErrorAs(t, Validate(nil, &user{}), &errs)
+	assert.Equal(t, "name", errs[0].Field)
+
+	require.ErrorAs(t, Validate(nil, &plain{}), &errs)
+	assert.Equal(t, Errors{{Message: "always invalid"}}, errs)
+
+	errs = Errors{{Field: "role"}, {Field: "[0]"}, {}}.WithPrefix("items[1]")
+	assert.Equal(t, "items[1].role", errs[0].Field)
+	assert.Equal(t, "items[1][0]", errs[1].Field)
+	assert.Equal(t, "items[1]", errs[2].Field)
+}
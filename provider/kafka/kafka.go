@@ -175,11 +175,29 @@ func NewSyncProducer(client sarama.Client) (sarama.SyncProducer, error) {
 	return sarama.NewSyncProducerFromClient(client)
 }
 
+// NewReliableSyncProducer creates a new SyncProducer waiting for the acks of every message,
+// it owns its client created with the given configuration, caller should close it.
+func NewReliableSyncProducer(cfg config.Kafka) (sarama.SyncProducer, error) {
+	saramaConfig := sarama.NewConfig()
+	if err := configureKafka(saramaConfig, cfg); err != nil {
+		return nil, err
+	}
+	saramaConfig.Producer.Return.Successes = true
+	return sarama.NewSyncProducer(cfg.Brokers, saramaConfig)
+}
+
 // NewConsumerGroup creates a new ConsumerGroup with the given client
 func NewConsumerGroup(client sarama.Client, groupID string) (sarama.ConsumerGroup, error) {
 	return sarama.NewConsumerGroupFromClient(groupID, client)
 }
 
+// Client returns the global kafka client, it returns nil if kafka is not enabled.
+func Client() sarama.Client {
+	mu.RLock()
+	defer mu.RUnlock()
+	return client
+}
+
 func Close() {
 	mu.Lock()
 	defer mu.Unlock()
@@ -14,6 +14,9 @@ import (
 	"go.uber.org/zap"
 )
 
+// ErrUserNotFound is returned when the user doesn't exist in the LDAP directory.
+var ErrUserNotFound = errors.New("ldap user not found")
+
 var (
 	initialized bool
 	gconn       *ldap.Conn
@@ -297,6 +300,9 @@ func Authenticate(username, password string) (bool, error) {
 		return false, errors.Wrap(err, "failed to find user")
 	}
 
+	if len(entries) == 0 {
+		return false, errors.Wrapf(ErrUserNotFound, "user %s", username)
+	}
 	if len(entries) != 1 {
 		return false, errors.Errorf("found %d entries for user %s, expected 1", len(entries), username)
 	}
@@ -346,6 +352,9 @@ func GetUser(username string, attributes []string) (*ldap.Entry, error) {
 		return nil, errors.Wrap(err, "failed to find user")
 	}
 
+	if len(entries) == 0 {
+		return nil, errors.Wrapf(ErrUserNotFound, "user %s", username)
+	}
 	if len(entries) != 1 {
 		return nil, errors.Errorf("found %d entries for user %s, expected 1", len(entries), username)
 	}
@@ -407,6 +416,9 @@ func GetUserGroups(username string) ([]string, error) {
 		return nil, errors.Wrap(err, "failed to find user")
 	}
 
+	if len(entries) == 0 {
+		return nil, errors.Wrapf(ErrUserNotFound, "user %s", username)
+	}
 	if len(entries) != 1 {
 		return nil, errors.Errorf("found %d entries for user %s, expected 1", len(entries), username)
 	}
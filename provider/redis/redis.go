@@ -171,6 +171,14 @@ func NewCluster(cfg config.Redis) (*redis.ClusterClient, error) {
 	return redis.NewClusterClient(opts), nil
 }
 
+// Client returns the global redis client, either the standalone or the cluster client.
+// It returns nil if redis is not enabled.
+func Client() redis.UniversalClient {
+	mu.Lock()
+	defer mu.Unlock()
+	return cli
+}
+
 func Close() {
 	if client != nil {
 		if err := client.Close(); err != nil {
@@ -210,6 +218,23 @@ func Set(key string, data any, expiration ...time.Duration) error {
 	return client.Set(ctx, key, data, _expiration).Err()
 }
 
+// SetNX set data into redis with specific key only if the key does not exist,
+// it reports whether the key was set.
+func SetNX(key string, data any, expiration ...time.Duration) (bool, error) {
+	if !config.App.Redis.Enable {
+		zap.S().Warn(ErrRedisIsDisabled.Error())
+		return false, ErrRedisIsDisabled
+	}
+	_expiration := config.App.Redis.Expiration
+	if len(expiration) > 0 {
+		_expiration = expiration[0]
+	}
+	if config.App.Redis.ClusterMode {
+		return cluster.SetNX(ctx, key, data, _expiration).Result()
+	}
+	return client.SetNX(ctx, key, data, _expiration).Result()
+}
+
 // SetM set types.Model into redis with specific key.
 func SetM[M types.Model](key string, m M, expiration ...time.Duration) error {
 	if !config.App.Redis.Enable {
@@ -31,6 +31,11 @@ const (
 	CodeNotFound
 	CodeForbidden
 	CodeAlreadyExist
+	CodeValidationFailed
+	CodeVersionConflict
+	CodePreconditionFailed
+	CodeIdempotencyInProgress
+	CodeIdempotencyMismatch
 )
 
 // 业务状态码
@@ -49,6 +54,10 @@ const (
 	CodeAlreadyExistsRole
 
 	CodeTooLargeFile
+
+	CodeAccountLocked
+	CodePasswordReused
+	CodePasswordExpired
 )
 
 type codeValue struct {
@@ -63,17 +72,22 @@ var defaultCodeValueMap = map[Code]codeValue{
 	CodeFailure: {http.StatusBadRequest, "failure"},
 
 	// 通用状态码值
-	CodeInvalidParam:    {http.StatusBadRequest, "Invalid parameters provided in the request."},
-	CodeBadRequest:      {http.StatusBadRequest, "Malformed or illegal request."},
-	CodeInvalidToken:    {http.StatusUnauthorized, "Invalid or expired authentication token."},
-	CodeNeedLogin:       {http.StatusUnauthorized, "Authentication required to access the requested resource."},
-	CodeUnauthorized:    {http.StatusUnauthorized, "Unauthorized access to the requested resource."},
-	CodeNetworkTimeout:  {http.StatusGatewayTimeout, "Network operation timed out."},
-	CodeContextTimeout:  {http.StatusGatewayTimeout, "Request context timed out."},
-	CodeTooManyRequests: {http.StatusTooManyRequests, "too many requests, please try again later."},
-	CodeNotFound:        {http.StatusNotFound, "Requested resource not found."},
-	CodeForbidden:       {http.StatusForbidden, "Forbidden: Inadequate privileges for the requested operation."},
-	CodeAlreadyExist:    {http.StatusConflict, "Resource already exists."},
+	CodeInvalidParam:          {http.StatusBadRequest, "Invalid parameters provided in the request."},
+	CodeBadRequest:            {http.StatusBadRequest, "Malformed or illegal request."},
+	CodeInvalidToken:          {http.StatusUnauthorized, "Invalid or expired authentication token."},
+	CodeNeedLogin:             {http.StatusUnauthorized, "Authentication required to access the requested resource."},
+	CodeUnauthorized:          {http.StatusUnauthorized, "Unauthorized access to the requested resource."},
+	CodeNetworkTimeout:        {http.StatusGatewayTimeout, "Network operation timed out."},
+	CodeContextTimeout:        {http.StatusGatewayTimeout, "Request context timed out."},
+	CodeTooManyRequests:       {http.StatusTooManyRequests, "too many requests, please try again later."},
+	CodeNotFound:              {http.StatusNotFound, "Requested resource not found."},
+	CodeForbidden:             {http.StatusForbidden, "Forbidden: Inadequate privileges for the requested operation."},
+	CodeAlreadyExist:          {http.StatusConflict, "Resource already exists."},
+	CodeValidationFailed:      {http.StatusBadRequest, "Request validation failed."},
+	CodeVersionConflict:       {http.StatusConflict, "Resource has been modified by another request."},
+	CodePreconditionFailed:    {http.StatusPreconditionFailed, "Resource version does not match the precondition."},
+	CodeIdempotencyInProgress: {http.StatusConflict, "A request with the same idempotency key is in progress."},
+	CodeIdempotencyMismatch:   {http.StatusUnprocessableEntity, "Idempotency key is reused with a different request."},
 
 	// 业务状态码值
 	CodeInvalidLogin:        {http.StatusBadRequest, "invalid username or password"},
@@ -87,6 +101,9 @@ var defaultCodeValueMap = map[Code]codeValue{
 	CodeAlreadyExistsUser:   {http.StatusConflict, "user already exists"},
 	CodeAlreadyExistsRole:   {http.StatusConflict, "role already exists"},
 	CodeTooLargeFile:        {http.StatusBadRequest, "too large file"},
+	CodeAccountLocked:       {http.StatusForbidden, "account is locked"},
+	CodePasswordReused:      {http.StatusBadRequest, "password was used recently"},
+	CodePasswordExpired:     {http.StatusForbidden, "password is expired, change the password to login"},
 }
 
 // 用于存储自定义的错误码映射
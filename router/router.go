@@ -5,11 +5,11 @@ import (
 	"net"
 	"net/http"
 	gopath "path"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/forbearing/gst/authz/rbac"
 	"github.com/forbearing/gst/config"
 	"github.com/forbearing/gst/controller"
 	"github.com/forbearing/gst/database"
@@ -53,6 +53,7 @@ func Init() error {
 	root.GET("/-/healthz", controller.Probe.Healthz)
 	root.GET("/-/readyz", controller.Probe.Readyz)
 	root.GET("/-/pageid", controller.PageID)
+	root.GET("/.well-known/jwks.json", controller.JWKS)
 	root.GET("/openapi.json", middleware.BaseAuth(), gin.WrapH(openapigen.DocumentHandler()))
 	root.GET("/docs/*any", middleware.BaseAuth(), ginSwagger.WrapHandler(swaggerFiles.Handler, ginSwagger.URL("/openapi.json")))
 	root.GET("/redoc", middleware.BaseAuth(), controller.Redoc)
@@ -93,7 +94,7 @@ func Run() error {
 	for endpoint, methods := range model.Routes {
 		for _, method := range methods {
 			permissions = append(permissions, &modelauthz.Permission{
-				Resource: convertGinPathToCasbinKeyMatch3(endpoint),
+				Resource: rbac.Resource(endpoint),
 				Action:   method,
 			})
 		}
@@ -321,9 +322,3 @@ func buildVerbMap(verbs ...consts.HTTPVerb) map[consts.HTTPVerb]bool {
 	}
 	return verbMap
 }
-
-func convertGinPathToCasbinKeyMatch3(ginPath string) string {
-	// Match :param style and replace with {param}
-	re := regexp.MustCompile(`:([a-zA-Z0-9_]+)`)
-	return re.ReplaceAllString(ginPath, `{$1}`)
-}
@@ -0,0 +1,50 @@
+package serviceauthz
+
+import (
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/logger"
+	modelauthz "github.com/forbearing/gst/model/authz"
+	"github.com/forbearing/gst/service"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+	"go.uber.org/zap"
+)
+
+// tenantRole manages the roles of the current tenant, the roles of other tenants are
+// invisible because modelauthz.TenantRole is isolated by tenant.
+//
+// Example:
+//
+//	router.Register[*modelauthz.TenantRole, *modelauthz.TenantRole, *modelauthz.TenantRole](router.Auth(), "tenant/roles", nil, consts.Create, consts.Delete, consts.List)
+//	router.Register[*modelauthz.TenantUserRole, *modelauthz.TenantUserRole, *modelauthz.TenantUserRole](router.Auth(), "tenant/user-roles", nil, consts.Create, consts.Delete, consts.List)
+type tenantRole struct {
+	service.Base[*modelauthz.TenantRole, *modelauthz.TenantRole, *modelauthz.TenantRole]
+}
+
+func init() {
+	service.Register[*tenantRole](consts.PHASE_DELETE)
+}
+
+// DeleteAfter support filter and delete multiple roles of the tenant by query parameter `name`.
+func (r *tenantRole) DeleteAfter(ctx *types.ServiceContext, role *modelauthz.TenantRole) error {
+	log := logger.Service.WithServiceContext(ctx, consts.PHASE_DELETE_AFTER)
+	name := ctx.URL.Query().Get("name")
+	if len(name) == 0 {
+		return nil
+	}
+
+	roles := make([]*modelauthz.TenantRole, 0)
+	if err := database.Database[*modelauthz.TenantRole](ctx.DatabaseContext()).WithLimit(-1).WithQuery(&modelauthz.TenantRole{Name: name}).List(&roles); err != nil {
+		log.Error(err)
+		return err
+	}
+	for _, role := range roles {
+		log.Infoz("will delete tenant role", zap.Object("role", role))
+	}
+	if err := database.Database[*modelauthz.TenantRole](ctx.DatabaseContext()).WithLimit(-1).WithPurge().Delete(roles...); err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return nil
+}
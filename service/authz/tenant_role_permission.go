@@ -0,0 +1,46 @@
+package serviceauthz
+
+import (
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/logger"
+	modelauthz "github.com/forbearing/gst/model/authz"
+	"github.com/forbearing/gst/service"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+	"go.uber.org/zap"
+)
+
+type tenantRolePermission struct {
+	service.Base[*modelauthz.TenantRolePermission, *modelauthz.TenantRolePermission, *modelauthz.TenantRolePermission]
+}
+
+func init() {
+	service.Register[*tenantRolePermission](consts.PHASE_DELETE)
+}
+
+// DeleteAfter support delete multiple tenant_role_permissions of the tenant by query parameters `role`, `resource`, `action`
+func (*tenantRolePermission) DeleteAfter(ctx *types.ServiceContext, _ *modelauthz.TenantRolePermission) error {
+	log := logger.Service.WithServiceContext(ctx, consts.PHASE_DELETE_AFTER)
+	role := ctx.URL.Query().Get("role")
+	resource := ctx.URL.Query().Get("resource")
+	action := ctx.URL.Query().Get("action")
+
+	rolePermissions := make([]*modelauthz.TenantRolePermission, 0)
+	if err := database.Database[*modelauthz.TenantRolePermission](ctx.DatabaseContext()).WithLimit(-1).WithQuery(&modelauthz.TenantRolePermission{
+		Role:     role,
+		Resource: resource,
+		Action:   action,
+	}).List(&rolePermissions); err != nil {
+		log.Error(err)
+		return err
+	}
+	for _, rp := range rolePermissions {
+		log.Infoz("will delete tenant role permission", zap.Object("role_permission", rp))
+	}
+	if err := database.Database[*modelauthz.TenantRolePermission](ctx.DatabaseContext()).WithLimit(-1).WithPurge().Delete(rolePermissions...); err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return nil
+}
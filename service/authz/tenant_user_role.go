@@ -0,0 +1,41 @@
+package serviceauthz
+
+import (
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/logger"
+	modelauthz "github.com/forbearing/gst/model/authz"
+	"github.com/forbearing/gst/service"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+	"go.uber.org/zap"
+)
+
+type tenantUserRole struct {
+	service.Base[*modelauthz.TenantUserRole, *modelauthz.TenantUserRole, *modelauthz.TenantUserRole]
+}
+
+func init() {
+	service.Register[*tenantUserRole](consts.PHASE_DELETE)
+}
+
+// DeleteAfter support filter and delete multiple tenant_user_roles of the tenant by query parameter `user_id` and `role`.
+func (*tenantUserRole) DeleteAfter(ctx *types.ServiceContext, _ *modelauthz.TenantUserRole) error {
+	log := logger.Service.WithServiceContext(ctx, consts.PHASE_DELETE_AFTER)
+	userID := ctx.URL.Query().Get("user_id")
+	role := ctx.URL.Query().Get("role")
+
+	userRoles := make([]*modelauthz.TenantUserRole, 0)
+	if err := database.Database[*modelauthz.TenantUserRole](ctx.DatabaseContext()).WithQuery(&modelauthz.TenantUserRole{UserID: userID, Role: role}).WithLimit(-1).List(&userRoles); err != nil {
+		log.Error(err)
+		return err
+	}
+	for _, ur := range userRoles {
+		log.Infoz("will delete tenant user role", zap.Object("user_role", ur))
+	}
+	if err := database.Database[*modelauthz.TenantUserRole](ctx.DatabaseContext()).WithLimit(-1).WithPurge().Delete(userRoles...); err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return nil
+}
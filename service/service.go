@@ -9,6 +9,7 @@ import (
 
 	"github.com/cockroachdb/errors"
 	"github.com/forbearing/gst/logger"
+	"github.com/forbearing/gst/pkg/codec"
 	"github.com/forbearing/gst/types"
 	"github.com/forbearing/gst/types/consts"
 	"go.uber.org/zap"
@@ -134,12 +135,32 @@ func (Base[M, REQ, RSP]) UpdateManyAfter(*types.ServiceContext, ...M) error  { r
 func (Base[M, REQ, RSP]) PatchManyBefore(*types.ServiceContext, ...M) error  { return nil }
 func (Base[M, REQ, RSP]) PatchManyAfter(*types.ServiceContext, ...M) error   { return nil }
 
-func (Base[M, REQ, RSP]) Import(*types.ServiceContext, io.Reader) ([]M, error) {
-	return make([]M, 0), nil
+// Import decodes the uploaded csv/xlsx/ndjson file into models by the built-in codec.
+// The format is specified by query parameter `_format`, or detected from the file content.
+//
+// If some rows are invalid, the valid rows are returned together with a codec.RowErrors.
+func (Base[M, REQ, RSP]) Import(ctx *types.ServiceContext, r io.Reader) ([]M, error) {
+	var format codec.Format
+	if ctx != nil {
+		if vals := ctx.Query[consts.QUERY_FORMAT]; len(vals) > 0 {
+			format, _ = codec.ParseFormat(vals[0])
+		}
+	}
+	return codec.Unmarshal[M](format, r)
 }
 
-func (Base[M, REQ, RSP]) Export(*types.ServiceContext, ...M) ([]byte, error) {
-	return make([]byte, 0), nil
+// Export encodes models to csv/xlsx/ndjson by the built-in codec.
+// The format is negotiated by query parameter `_format` or "Accept" header,
+// and the columns are chosen by query parameter `_select`.
+func (Base[M, REQ, RSP]) Export(ctx *types.ServiceContext, data ...M) ([]byte, error) {
+	if ctx == nil {
+		return codec.Marshal(codec.DefaultFormat, nil, data...)
+	}
+	var selects []string
+	if vals := ctx.Query[consts.QUERY_SELECT]; len(vals) > 0 {
+		selects = strings.Split(vals[0], ",")
+	}
+	return codec.Marshal(codec.Negotiate(ctx.Query, ctx.Header), selects, data...)
 }
 
 func (Base[M, REQ, RSP]) Filter(_ *types.ServiceContext, m M) M    { return m }
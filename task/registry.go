@@ -0,0 +1,131 @@
+package task
+
+import (
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/logger"
+	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/util"
+)
+
+// ErrNotFound is returned if no task registered with the name.
+var ErrNotFound = errors.New("task not found")
+
+// List returns the runtime state of the registered tasks.
+func List() []types.JobInfo {
+	mu.Lock()
+	defer mu.Unlock()
+	infos := make([]types.JobInfo, 0, len(tasks))
+	for _, t := range tasks {
+		t.mu.Lock()
+		info := types.JobInfo{
+			Kind:     model.JobKindTask,
+			Name:     t.name,
+			Interval: t.interval.String(),
+			Paused:   t.paused,
+		}
+		if t.cancel != nil {
+			info.Next = util.ValueOf(t.next)
+		}
+		t.mu.Unlock()
+		t.state.Fill(&info)
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// Trigger runs the task now in background.
+func Trigger(name string) error {
+	t, err := find(name)
+	if err != nil {
+		return err
+	}
+	go t.run(model.JobTriggerManual)
+	return nil
+}
+
+// Pause stops running the task every interval, the running one is not interrupted.
+func Pause(name string) error {
+	t, err := find(name)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stop()
+	t.paused = true
+	logger.Task.Infow("task paused", "name", t.name)
+	return nil
+}
+
+// Resume resumes the task paused, the next run is one interval later.
+func Resume(name string) error {
+	t, err := find(name)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.paused {
+		return nil
+	}
+	t.paused = false
+	if inited && t.interval >= time.Second {
+		t.start(false)
+	}
+	logger.Task.Infow("task resumed", "name", t.name)
+	return nil
+}
+
+// Reschedule changes the interval of the task without restart, the next run is one interval later.
+func Reschedule(name string, interval time.Duration) error {
+	if interval < time.Second {
+		return errors.Newf("task interval %s less than 1 second", interval)
+	}
+	t, err := find(name)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stop()
+	old := t.interval
+	t.interval = interval
+	if inited && !t.paused {
+		t.start(false)
+	}
+	logger.Task.Infow("task rescheduled", "name", t.name, "old", old.String(), "interval", interval.String())
+	return nil
+}
+
+// Unregister removes the task, the running one is not interrupted.
+func Unregister(name string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	for i, t := range tasks {
+		if t.name != name {
+			continue
+		}
+		t.mu.Lock()
+		t.stop()
+		t.paused = true
+		t.mu.Unlock()
+		tasks = append(tasks[:i], tasks[i+1:]...)
+		logger.Task.Infow("task unregistered", "name", name)
+		return nil
+	}
+	return errors.Wrapf(ErrNotFound, "task %q", name)
+}
+
+func find(name string) (*task, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, t := range tasks {
+		if t.name == name {
+			return t, nil
+		}
+	}
+	return nil, errors.Wrapf(ErrNotFound, "task %q", name)
+}
@@ -11,7 +11,10 @@ import (
 	"sync"
 	"time"
 
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/internal/jobrun"
 	"github.com/forbearing/gst/logger"
+	"github.com/forbearing/gst/model"
 	"github.com/forbearing/gst/util"
 )
 
@@ -19,15 +22,50 @@ var (
 	tasks []*task
 	mu    sync.Mutex
 
+	// ctx is the parent context of the task loops and runs, it's canceled by Stop.
+	ctx, cancel = context.WithCancel(context.Background())
+	// inflight tracks the running tasks, no run starts once stopped.
+	inflight sync.WaitGroup
+	stopped  bool
+
 	inited bool
 )
 
+const (
+	defaultBackoff    = time.Second
+	defaultMaxBackoff = time.Minute
+)
+
+// Func is the function of the task, either func() error or func(context.Context) error.
+// The context is canceled on shutdown or Config.Timeout.
+type Func = jobrun.Func
+
+// Config defines the optional configuration of the task.
+type Config struct {
+	// Timeout cancels the context of each attempt, zero means no timeout.
+	Timeout time.Duration `json:"timeout" yaml:"timeout" toml:"timeout"`
+	// Retries is the max retries of the failed run, zero means no retry.
+	Retries int `json:"retries" yaml:"retries" toml:"retries"`
+	// Backoff is the delay before the first retry, default to 1 second.
+	// It doubles every retry up to MaxBackoff, default to 1 minute.
+	Backoff    time.Duration `json:"backoff" yaml:"backoff" toml:"backoff"`
+	MaxBackoff time.Duration `json:"max_backoff" yaml:"max_backoff" toml:"max_backoff"`
+}
+
 type task struct {
-	name     string
+	name   string
+	fn     func(context.Context) error
+	policy jobrun.Policy
+
+	// mu guards the interval and the loop which can be changed at runtime, see Reschedule.
+	mu       sync.Mutex
 	interval time.Duration
-	fn       func() error
 	ctx      context.Context
 	cancel   context.CancelFunc
+	next     time.Time
+	paused   bool
+
+	state jobrun.State
 }
 
 // Init initializes the task scheduler and starts all registered tasks.
@@ -36,6 +74,8 @@ type task struct {
 func Init() error {
 	Register(runtimestats, 60*time.Second, "runtime stats")
 
+	mu.Lock()
+	defer mu.Unlock()
 	for _, t := range tasks {
 		register(t)
 	}
@@ -52,21 +92,41 @@ func Init() error {
 //
 //	// Old: task.Register(fn, 5*time.Minute, "my-task")
 //	// New: cronjob.Register(fn, "0 */5 * * * *", "my-task")
-func Register(fn func() error, interval time.Duration, name string) {
+func Register[F Func](fn F, interval time.Duration, name string, config ...Config) {
+	var cfg Config
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = defaultBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = defaultMaxBackoff
+	}
+
 	mu.Lock()
 	defer mu.Unlock()
 
-	ctx, cancel := context.WithCancel(context.Background())
+	t := &task{
+		name:     name,
+		fn:       jobrun.Wrap(fn),
+		interval: interval,
+		policy: jobrun.Policy{
+			Timeout:    cfg.Timeout,
+			Retries:    cfg.Retries,
+			Backoff:    cfg.Backoff,
+			MaxBackoff: cfg.MaxBackoff,
+		},
+	}
+	tasks = append(tasks, t)
 	if inited {
-		register(&task{name: name, fn: fn, interval: interval, ctx: ctx, cancel: cancel})
-	} else {
-		tasks = append(tasks, &task{name: name, fn: fn, interval: interval, ctx: ctx, cancel: cancel})
+		register(t)
 	}
 }
 
 func register(t *task) {
 	if t == nil {
-		logger.Task.Warnw("task is nil, skip", "name", t.name, "interval", t.interval.String())
+		logger.Task.Warnw("task is nil, skip")
 		return
 	}
 	if t.interval < time.Second {
@@ -77,41 +137,95 @@ func register(t *task) {
 		logger.Task.Warnw("task function is nil, skip", "name", t.name, "interval", t.interval.String())
 		return
 	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.paused {
+		t.start(true)
+	}
+}
+
+// start starts the loop running the task every interval, the caller must hold t.mu.
+func (t *task) start(runNow bool) {
+	t.ctx, t.cancel = context.WithCancel(ctx)
+	t.next = time.Now().Add(t.interval)
+	loopCtx, interval := t.ctx, t.interval
 	go func() {
-		defer func() {
-			if err := recover(); err != nil {
-				logger.Task.Errorw(fmt.Sprintf("task panic: %s", err), "name", t.name, "interval", t.interval.String())
-			}
-		}()
-		begin := time.Now()
-		logger.Task.Infow("starting task", "name", t.name, "interval", t.interval.String())
-		if err := t.fn(); err != nil {
-			logger.Task.Errorw(fmt.Sprintf("finished task with error: %s", err), "name", t.name, "interval", t.interval.String(), "cost", util.FormatDurationSmart(time.Since(begin)))
-		} else {
-			logger.Task.Infow("finished task", "name", t.name, "interval", t.interval.String(), "cost", util.FormatDurationSmart(time.Since(begin)))
+		if runNow {
+			t.run(model.JobTriggerImmediate)
 		}
 
-		ticker := time.NewTicker(t.interval)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
 
 		for {
 			select {
-			case <-t.ctx.Done():
+			case <-loopCtx.Done():
 				return
 			case <-ticker.C:
-				begin = time.Now()
-				logger.Task.Infow("starting task", "name", t.name, "interval", t.interval.String())
-				if err := t.fn(); err != nil {
-					logger.Task.Errorw(fmt.Sprintf("finished task with error: %s", err), "name", t.name, "interval", t.interval.String(), "cost", util.FormatDurationSmart(time.Since(begin)))
-					// return
-				} else {
-					logger.Task.Infow("finished task", "name", t.name, "interval", t.interval.String(), "cost", util.FormatDurationSmart(time.Since(begin)))
-					// return
-				}
+				t.mu.Lock()
+				t.next = time.Now().Add(interval)
+				t.mu.Unlock()
+				t.run(model.JobTriggerSchedule)
 			}
 		}
 	}()
 }
 
+// stop stops the loop, the running task is not interrupted. The caller must hold t.mu.
+func (t *task) stop() {
+	if t.cancel != nil {
+		t.cancel()
+		t.cancel = nil
+	}
+}
+
+func (t *task) run(trigger string) {
+	if !track() {
+		return
+	}
+	defer inflight.Done()
+	t.mu.Lock()
+	interval := t.interval
+	t.mu.Unlock()
+
+	begin := time.Now()
+	logger.Task.Infow("starting task", "name", t.name, "interval", interval.String(), "trigger", trigger)
+	t.state.Start()
+	err := t.policy.Call(ctx, t.fn, func(attempt int, delay time.Duration, err error) {
+		logger.Task.Warnw(fmt.Sprintf("task failed, retry: %s", err), "name", t.name, "attempt", attempt, "delay", util.FormatDurationSmart(delay))
+	})
+	t.state.Finish(model.JobKindTask, t.name, trigger, begin, err)
+	if err != nil {
+		logger.Task.Errorw(fmt.Sprintf("finished task with error: %s", err), "name", t.name, "interval", interval.String(), "cost", util.FormatDurationSmart(time.Since(begin)))
+	} else {
+		logger.Task.Infow("finished task", "name", t.name, "interval", interval.String(), "cost", util.FormatDurationSmart(time.Since(begin)))
+	}
+}
+
+// track tracks the run starting, it returns false if stopped.
+func track() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	if stopped {
+		return false
+	}
+	inflight.Add(1)
+	return true
+}
+
+// Stop stops the task loops and cancels the context of the running tasks,
+// then waits for them to finish within config "cronjob.drain_timeout".
+// It's registered to bootstrap.Cleanup.
+func Stop() {
+	mu.Lock()
+	stopped = true
+	mu.Unlock()
+	cancel()
+	if !jobrun.Wait(&inflight, config.App.Cronjob.DrainTimeout) {
+		logger.Task.Warnw("tasks not finished within the drain timeout", "timeout", config.App.Cronjob.DrainTimeout.String())
+	}
+}
+
 func runtimestats() error {
 	rtm := new(runtime.MemStats)
 	runtime.ReadMemStats(rtm)
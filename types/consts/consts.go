@@ -26,6 +26,7 @@ const (
 	CTX_USERNAME      = "username"
 	CTX_USER_ID       = "user_id"
 	CTX_SESSION_ID    = "session_id"
+	CTX_TENANT_ID     = "tenant_id"
 	CTX_REQUIRES_AUTH = "requires_auth"
 
 	DATE_TIME_LAYOUT = "2006-01-02 15:04:05"
@@ -56,6 +57,9 @@ const (
 	QUERY_CURSOR_VALUE  = "_cursor_value"
 	QUERY_CURSOR_FIELDS = "_cursor_fields"
 	QUERY_CURSOR_NEXT   = "_cursor_next"
+	QUERY_FORMAT        = "_format"
+	QUERY_STREAM        = "_stream"
+	QUERY_FILTER        = "_filter"
 
 	PARAM_ID   = "id"
 	PARAM_FILE = "file"
@@ -78,7 +82,16 @@ const (
 	HEADER_SPAN_ID    = "X-Span-ID"
 	HEADER_PSPAN_ID   = "X-Pspan-ID"
 
-	FIELD_ID = "ID"
+	HEADER_ETAG          = "ETag"
+	HEADER_IF_MATCH      = "If-Match"
+	HEADER_IF_NONE_MATCH = "If-None-Match"
+
+	HEADER_IDEMPOTENCY_KEY      = "Idempotency-Key"
+	HEADER_IDEMPOTENCY_REPLAYED = "Idempotency-Replayed"
+
+	FIELD_ID      = "ID"
+	FIELD_VERSION = "Version"
+	FIELD_TENANT  = "TenantID"
 
 	PHASE  = "phase"
 	PARAMS = "params"
@@ -87,9 +100,10 @@ const (
 	USER_SYSTEM = "system"
 	USER_ROOT   = "root"
 
-	TAG_JSON   = "json"
-	TAG_SCHEMA = "schema"
-	TAG_QUERY  = "query"
+	TAG_JSON     = "json"
+	TAG_SCHEMA   = "schema"
+	TAG_QUERY    = "query"
+	TAG_VALIDATE = "validate"
 )
 
 const (
@@ -12,6 +12,7 @@ import (
 type ControllerContext struct {
 	Username string // currrent login user.
 	UserID   string // currrent login user id
+	TenantID string // currrent login user tenant id
 	Route    string
 	Params   map[string]string
 	Query    map[string][]string
@@ -38,6 +39,7 @@ func NewControllerContext(c *gin.Context) *ControllerContext {
 		Route:     c.GetString(consts.CTX_ROUTE),
 		Username:  c.GetString(consts.CTX_USERNAME),
 		UserID:    c.GetString(consts.CTX_USER_ID),
+		TenantID:  c.GetString(consts.CTX_TENANT_ID),
 		RequestID: c.GetString(consts.REQUEST_ID),
 		TraceID:   c.GetString(consts.TRACE_ID),
 		Params:    params,
@@ -48,6 +50,7 @@ func NewControllerContext(c *gin.Context) *ControllerContext {
 type DatabaseContext struct {
 	Username string // currrent login user.
 	UserID   string // currrent login user id
+	TenantID string // currrent login user tenant id
 	Route    string
 	Params   map[string]string
 	Query    map[string][]string
@@ -84,6 +87,7 @@ func NewDatabaseContext(c *gin.Context, ctxs ...context.Context) *DatabaseContex
 		Route:     c.GetString(consts.CTX_ROUTE),
 		Username:  c.GetString(consts.CTX_USERNAME),
 		UserID:    c.GetString(consts.CTX_USER_ID),
+		TenantID:  c.GetString(consts.CTX_TENANT_ID),
 		RequestID: c.GetString(consts.REQUEST_ID),
 		TraceID:   c.GetString(consts.TRACE_ID),
 		Params:    params,
@@ -94,17 +98,23 @@ func NewDatabaseContext(c *gin.Context, ctxs ...context.Context) *DatabaseContex
 // Context converts *DatabaseContext to context.Context.
 // It starts from the underlying ctx.context and conditionally injects extra metadata.
 func (dc *DatabaseContext) Context() context.Context {
-	if dc == nil || dc.context == nil {
+	if dc == nil {
 		return context.Background()
 	}
 
 	c := dc.context
+	if c == nil {
+		c = context.Background()
+	}
 	if len(dc.Username) != 0 {
 		c = context.WithValue(c, consts.CTX_USERNAME, dc.Username) //nolint:staticcheck
 	}
 	if len(dc.UserID) != 0 {
 		c = context.WithValue(c, consts.CTX_USER_ID, dc.UserID) //nolint:staticcheck
 	}
+	if len(dc.TenantID) != 0 {
+		c = context.WithValue(c, consts.CTX_TENANT_ID, dc.TenantID) //nolint:staticcheck
+	}
 	if len(dc.RequestID) != 0 {
 		c = context.WithValue(c, consts.REQUEST_ID, dc.RequestID) //nolint:staticcheck
 	}
@@ -141,6 +151,7 @@ type ServiceContext struct {
 	SessionID string // session id
 	Username  string // currrent login user.
 	UserID    string // currrent login user id
+	TenantID  string // currrent login user tenant id
 	Route     string
 
 	RequestID string
@@ -189,6 +200,7 @@ func NewServiceContext(c *gin.Context, ctxs ...context.Context) *ServiceContext
 		Route:     c.GetString(consts.CTX_ROUTE),
 		Username:  c.GetString(consts.CTX_USERNAME),
 		UserID:    c.GetString(consts.CTX_USER_ID),
+		TenantID:  c.GetString(consts.CTX_TENANT_ID),
 		SessionID: c.GetString(consts.CTX_SESSION_ID),
 
 		RequestID: c.GetString(consts.REQUEST_ID),
@@ -276,6 +288,7 @@ func NewModelContext(dbctx *DatabaseContext, ctx context.Context) *ModelContext
 			context:  ctx,
 			Username: dbctx.Username,
 			UserID:   dbctx.UserID,
+			TenantID: dbctx.TenantID,
 			Route:    dbctx.Route,
 			Params:   dbctx.Params,
 			Query:    dbctx.Query,
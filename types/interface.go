@@ -148,6 +148,10 @@ type Database[M Model] interface {
 	UpdateByID(id string, key string, value any) error
 	// List all records and write to dest.
 	List(dest *[]M, cache ...*[]byte) error
+	// Iterate retrieves all records batch by batch and calls fn with each batch,
+	// records are fetched by keyset pagination on the cursor field, the batch size
+	// is set by WithBatchSize. It's used to process large tables in bounded memory.
+	Iterate(fn func(batch []M) error) error
 	// Get one record with specific id and write to dest.
 	Get(dest M, id string, cache ...*[]byte) error
 	// First finds the first record ordered by primary key.
@@ -372,6 +376,10 @@ type DatabaseOption[M Model] interface {
 	WithTryRun(...bool) Database[M]
 	// WithoutHook tells the database manipulator not invoke model hooks.
 	WithoutHook() Database[M]
+	// WithPrimary forces the reads to the primary database instead of the read replicas.
+	WithPrimary() Database[M]
+	// WithoutTenant disables the tenant isolation of TenantScoped model, eg: super admin access across tenants.
+	WithoutTenant() Database[M]
 }
 
 // Model interface defines the contract for all data models in the framework.
@@ -437,6 +445,52 @@ type (
 	Response any
 )
 
+// Validator is an optional interface implemented by models or request payloads
+// to perform the validation that cannot be expressed by `validate` struct tags,
+// eg: cross-field checks or checks depending on the current user.
+//
+// The controller calls Validate after the struct tags validation passed and
+// before the service hooks such as CreateBefore, UpdateBefore and PatchBefore.
+type Validator interface {
+	Validate(*ServiceContext) error
+}
+
+// Versioned is an optional interface implemented by models to enable optimistic
+// concurrency control, usually by embedding model.Versioned.
+//
+// The version is stored in the "Version" field and increased by every update.
+// Database.Update only updates the record whose stored version equals GetVersion,
+// otherwise it returns database.ErrVersionConflict. The version zero means the caller
+// doesn't know the version, the record is overwritten unconditionally.
+type Versioned interface {
+	GetVersion() uint
+	SetVersion(uint)
+}
+
+// TenantScoped is implemented by the models isolated by tenant, eg: embedding model.TenantScoped.
+//
+// Database filters every query and stamps every created record with the tenant id of
+// DatabaseContext, the operations without tenant id fail, and the records of other tenants
+// are never read or overwritten. Database.WithoutTenant disables it for cross-tenant access.
+type TenantScoped interface {
+	GetTenantID() string
+	SetTenantID(string)
+}
+
+// EventSource is an optional interface implemented by models to publish domain events
+// through the outbox.
+//
+// Database.Create, Update, UpdateByID and Delete write the events returned by Events in the
+// same transaction as the record change, so the events are neither lost nor published for the
+// change rolled back. UpdateByID calls Events on the record reloaded after the update.
+// The phase is consts.PHASE_CREATE, consts.PHASE_UPDATE or consts.PHASE_DELETE.
+//
+// Database.Cleanup purging the soft deleted records and the changes made by raw statements,
+// eg: within Database.TransactionFunc, write no events, add them by outbox.Add explicitly.
+type EventSource interface {
+	Events(phase consts.Phase) []Event
+}
+
 // Service interface provides comprehensive business logic operations for model types.
 // This interface defines the service layer that sits between controllers and database operations,
 // implementing business rules, validation, complex operations, and lifecycle management.
@@ -663,14 +717,57 @@ type DistributedCache[T any] interface {
 //   - Resource-level access control
 //   - Multi-tenant permission management
 type RBAC interface {
-	AddRole(name string) error
+	// AddRole adds the role inheriting the permissions of the parents, the parents of
+	// the existing role are replaced.
+	AddRole(name string, parents ...string) error
+	// RemoveRole removes the role with its grants, assignments and inheritances.
 	RemoveRole(name string) error
 
 	GrantPermission(role string, resource string, action string) error
+	// DenyPermission denies the permission to the role, the deny overrides the permissions
+	// granted to the role and its parents.
+	DenyPermission(role string, resource string, action string) error
+	// RevokePermission revokes the permission granted or denied.
 	RevokePermission(role string, resource string, action string) error
 
 	AssignRole(subject string, role string) error
 	UnassignRole(subject string, role string) error
+
+	// ListRoles returns all the roles.
+	ListRoles() ([]string, error)
+	// ListMembers returns the subjects and the roles directly inheriting the role.
+	ListMembers(role string) ([]string, error)
+	// ListGrants returns the permissions granted or denied to the role directly.
+	ListGrants(role string) ([]RoleGrant, error)
+}
+
+// TenantRBAC is the RBAC whose roles, grants and assignments are scoped by tenant,
+// the subject is only granted the permissions of its roles in the same tenant.
+type TenantRBAC interface {
+	AddRole(tenant string, name string) error
+	RemoveRole(tenant string, name string) error
+
+	GrantPermission(tenant string, role string, resource string, action string) error
+	RevokePermission(tenant string, role string, resource string, action string) error
+
+	AssignRole(tenant string, subject string, role string) error
+	UnassignRole(tenant string, subject string, role string) error
+}
+
+// RBACIntrospector answers what the RBAC would decide without enforcing the request,
+// eg: the frontend shows the buttons by the permissions of the login user.
+//
+// The resources are the casbin keyMatch3 paths, eg: "/api/user/{id}", the same as the
+// resources of the permissions derived from the routes.
+type RBACIntrospector interface {
+	// Roles returns the roles of the subject, including the inherited ones.
+	Roles(subject string) ([]string, error)
+	// Permissions returns the permissions granted to the subject and its roles.
+	Permissions(subject string) ([]PermissionCheck, error)
+	// Can checks the permissions, the Allowed of the returned checks is set by the decisions.
+	Can(subject string, checks ...PermissionCheck) ([]PermissionCheck, error)
+	// Explain returns the decision of the request and the policy deciding it.
+	Explain(subject string, resource string, action string) (*PermissionExplanation, error)
 }
 
 // ESDocumenter represents a document that can be indexed into Elasticsearch.
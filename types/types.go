@@ -1,5 +1,7 @@
 package types
 
+import "time"
+
 type ControllerConfig[M Model] struct {
 	DB        any // only support *gorm.DB
 	TableName string
@@ -13,6 +15,7 @@ type ControllerConfig[M Model] struct {
 //   - AllowEmpty: Allow empty query conditions to match all records. Default: false (blocked for safety)
 //   - RawQuery: Raw SQL query string for custom WHERE conditions. When provided, model fields are ignored
 //   - RawQueryArgs: Arguments for the raw SQL query, used with RawQuery for parameterized queries
+//   - Filter: Operator-aware conditions (gte, ne, in, null...) with nested AND/OR groups, see Filter
 //
 // CRITICAL SAFETY FEATURE:
 // Empty query conditions (all fields are zero values) are blocked by default to prevent
@@ -43,11 +46,151 @@ type ControllerConfig[M Model] struct {
 //
 //	// Raw SQL with complex conditions
 //	WithQuery(&User{}, QueryConfig{RawQuery: "created_at BETWEEN ? AND ? OR priority IN (?)", RawQueryArgs: []any{startDate, endDate, priorities}})
+//
+//	// Operator-aware filter: age >= 18 AND (status IN ('a', 'b') OR deleted_at IS NULL)
+//	WithQuery(&User{}, QueryConfig{AllowEmpty: true, Filter: &Filter{And: []*Filter{
+//		{Field: "age", Op: FilterGte, Values: []string{"18"}},
+//		{Or: []*Filter{
+//			{Field: "status", Op: FilterIn, Values: []string{"a", "b"}},
+//			{Field: "deleted_at", Op: FilterNull, Values: []string{"true"}},
+//		}},
+//	}}})
 type QueryConfig struct {
-	FuzzyMatch   bool   // Enable fuzzy matching (LIKE/REGEXP). Default: false
-	AllowEmpty   bool   // Allow empty query conditions. Default: false
-	RawQuery     string // Raw SQL query string for custom WHERE conditions
-	RawQueryArgs []any  // Arguments for the raw SQL query parameters
+	FuzzyMatch   bool    // Enable fuzzy matching (LIKE/REGEXP). Default: false
+	AllowEmpty   bool    // Allow empty query conditions. Default: false
+	RawQuery     string  // Raw SQL query string for custom WHERE conditions
+	RawQueryArgs []any   // Arguments for the raw SQL query parameters
+	Filter       *Filter // Operator-aware filter conditions, always combined with AND
+}
+
+// FilterOp is the comparison operator of Filter.
+type FilterOp string
+
+const (
+	FilterEq      FilterOp = "eq"      // field = value
+	FilterNe      FilterOp = "ne"      // field <> value
+	FilterGt      FilterOp = "gt"      // field > value
+	FilterGte     FilterOp = "gte"     // field >= value
+	FilterLt      FilterOp = "lt"      // field < value
+	FilterLte     FilterOp = "lte"     // field <= value
+	FilterIn      FilterOp = "in"      // field IN (values...)
+	FilterNin     FilterOp = "nin"     // field NOT IN (values...)
+	FilterLike    FilterOp = "like"    // field LIKE %value%
+	FilterNull    FilterOp = "null"    // field IS NULL if value is true, otherwise IS NOT NULL
+	FilterBetween FilterOp = "between" // field BETWEEN values[0] AND values[1]
+)
+
+// FilterOps is all the supported filter operators.
+var FilterOps = []FilterOp{FilterEq, FilterNe, FilterGt, FilterGte, FilterLt, FilterLte, FilterIn, FilterNin, FilterLike, FilterNull, FilterBetween}
+
+// Filter is a tree of query conditions.
+//
+// A leaf node compares Field with Values by Op, a group node combines its children
+// by And or Or, a node should be either leaf or group.
+//
+// Field is the column name, json name or structure field name of the model,
+// it can be prefixed by association names separated by dot to filter on the
+// associated records, eg: "Profile.city", the first association must be one of
+// model Expands().
+//
+// Values are always strings and converted to the column type when translated
+// into SQL, so the filter parsed from url query can be used directly.
+type Filter struct {
+	Field  string   `json:"field,omitempty"`
+	Op     FilterOp `json:"op,omitempty"`
+	Values []string `json:"values,omitempty"`
+
+	And []*Filter `json:"and,omitempty"`
+	Or  []*Filter `json:"or,omitempty"`
+}
+
+// IsEmpty reports whether the filter has no condition.
+func (f *Filter) IsEmpty() bool {
+	if f == nil {
+		return true
+	}
+	if len(f.Field) > 0 {
+		return false
+	}
+	for _, sub := range f.And {
+		if !sub.IsEmpty() {
+			return false
+		}
+	}
+	for _, sub := range f.Or {
+		if !sub.IsEmpty() {
+			return false
+		}
+	}
+	return true
+}
+
+// JobInfo is the runtime state of a cronjob or task, see cronjob.List and task.List.
+type JobInfo struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// Spec is the cron expression of the cronjob.
+	Spec string `json:"spec,omitempty"`
+	// Interval is the interval of the task, eg: "1m0s".
+	Interval    string `json:"interval,omitempty"`
+	Paused      bool   `json:"paused"`
+	Running     bool   `json:"running"`
+	Distributed bool   `json:"distributed,omitempty"`
+
+	Next         *time.Time `json:"next,omitempty"`
+	LastRun      *time.Time `json:"last_run,omitempty"`
+	LastStatus   string     `json:"last_status,omitempty"`
+	LastDuration int64      `json:"last_duration,omitempty"` // milliseconds
+	LastError    string     `json:"last_error,omitempty"`
+
+	Runs     int64 `json:"runs"`
+	Failures int64 `json:"failures"`
+}
+
+// Event is the domain event written to the outbox, see EventSource.
+type Event struct {
+	// Topic is the topic or subject of the broker, default to config "outbox.topic".
+	Topic string
+	// AggregateType and AggregateID identify the aggregate, the events of the same aggregate
+	// are delivered in order. They default to the table name and the id of the model.
+	AggregateType string
+	AggregateID   string
+	// Type is the type of the event, eg: "order.created".
+	Type string
+	// Payload is encoded to json.
+	Payload any
+	Headers map[string]string
+}
+
+// PermissionCheck is the permission checked by RBACIntrospector.
+type PermissionCheck struct {
+	Resource string `json:"resource"`
+	Action   string `json:"action"`
+	Allowed  bool   `json:"allowed"`
+}
+
+// RoleGrant is the permission granted or denied to the role, see RBAC.ListGrants.
+type RoleGrant struct {
+	Role     string `json:"role"`
+	Resource string `json:"resource"`
+	Action   string `json:"action"`
+	// Effect is "allow" or "deny".
+	Effect string `json:"effect"`
+}
+
+// PermissionExplanation explains the decision of RBACIntrospector.
+type PermissionExplanation struct {
+	Subject  string `json:"subject"`
+	Resource string `json:"resource"`
+	Action   string `json:"action"`
+	Allowed  bool   `json:"allowed"`
+	// Reason describes how the decision is made.
+	Reason string `json:"reason"`
+	// Policy is the policy line deciding the request, eg: ["p", "editor", "/api/doc/{id}", "GET", "allow"].
+	// It's empty if the request is allowed by the admin role or denied by default.
+	Policy []string `json:"policy,omitempty"`
+	// Roles are the roles of the subject, including the inherited ones.
+	Roles []string `json:"roles,omitempty"`
 }
 
 // ServiceError represents an error with a custom HTTP status code